@@ -4,8 +4,11 @@
 package builder
 
 import (
+	"crypto/sha512"
 	"debug/elf"
 	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"go/types"
@@ -38,6 +41,28 @@ type BuildResult struct {
 	MainDir string
 }
 
+// packageAction is the struct that is serialized to JSON and hashed, to work as
+// a cache key of compiled packages. It should contain all the information that
+// goes into a compiled package to avoid using stale data.
+//
+// Right now it's necessary to include a hash of every Go file that is part of
+// the package (and the C files and headers it uses with CGo), and the action
+// IDs of all imported packages. The version number alone doesn't change when
+// the compiler itself is modified during development, so a hash of the
+// compiler executable is included as well.
+type packageAction struct {
+	ImportPath    string
+	TinyGoVersion string
+	CompilerHash  string
+	LLVMVersion   string
+	Config        *compiler.Config
+	BuildTags     []string
+	CFlags        []string
+	Opt           string
+	FileHashes    map[string]string // hash of every file that's part of the package
+	Imports       map[string]string // map from imported package to action ID hash
+}
+
 // Build performs a single package to executable Go build. It takes in a package
 // name, an output path, and set of compile options and from that it manages the
 // whole compilation process.
@@ -59,6 +84,7 @@ func Build(pkgName, outpath string, config *compileopts.Config, action func(Buil
 		AutomaticStackSize: config.AutomaticStackSize(),
 		DefaultStackSize:   config.Target.DefaultStackSize,
 		NeedsStackObjects:  config.NeedsStackObjects(),
+		PanicStrategy:      config.PanicStrategy(),
 		Debug:              config.Debug(),
 	}
 
@@ -82,28 +108,207 @@ func Build(pkgName, outpath string, config *compileopts.Config, action func(Buil
 		return err
 	}
 
+	// Check for a main function. This is a common mistake and gives a more
+	// helpful error than an undefined symbol while linking.
+	switch lprogram.MainPkg().Pkg.Scope().Lookup("main").(type) {
+	case *types.Func:
+		// ok
+	case nil:
+		return errors.New("function main is undeclared in the main package")
+	default:
+		return errors.New("cannot declare main - must be func")
+	}
+
+	// Create the *ssa.Program. This does not yet build the entire SSA of the
+	// program so it's pretty fast and doesn't need to be parallelized.
+	program := lprogram.LoadSSA()
+
+	// Determine where to store the bitcode of each package.
+	cacheDir := goenv.Get("GOCACHE")
+	err = os.MkdirAll(cacheDir, 0777)
+	if err != nil {
+		return err
+	}
+	compilerHash, err := getCompilerHash()
+	if err != nil {
+		return err
+	}
+
 	// The slice of jobs that orchestrates most of the build.
 	// This is somewhat like an in-memory Makefile with each job being a
 	// Makefile target.
 	var jobs []*compileJob
 
-	// Add job to compile and optimize all Go files at once.
-	// TODO: parallelize this.
+	// Add jobs to compile each package.
+	// Packages that have a cache hit will not be compiled again.
+	var packageJobs []*compileJob
+	packageBitcodePaths := make(map[string]string)
+	packageActionIDs := make(map[string]string)
+	for _, pkg := range lprogram.Sorted() {
+		pkg := pkg // necessary to avoid a race condition
+
+		// Create a cache key: a hash from the action ID below that contains all
+		// the parameters for the build.
+		actionID := packageAction{
+			ImportPath:    pkg.ImportPath,
+			TinyGoVersion: goenv.Version,
+			CompilerHash:  compilerHash,
+			LLVMVersion:   llvm.Version,
+			Config:        compilerConfig,
+			BuildTags:     config.BuildTags(),
+			CFlags:        config.CFlags(),
+			Opt:           config.Options.Opt,
+			FileHashes:    make(map[string]string, len(pkg.FileHashes)),
+			Imports:       make(map[string]string, len(pkg.Pkg.Imports())),
+		}
+		for filePath, hash := range pkg.FileHashes {
+			actionID.FileHashes[filePath] = hex.EncodeToString(hash)
+		}
+		for _, imported := range pkg.Pkg.Imports() {
+			hash, ok := packageActionIDs[imported.Path()]
+			if !ok {
+				return fmt.Errorf("package %s imports %s but couldn't find dependency", pkg.ImportPath, imported.Path())
+			}
+			actionID.Imports[imported.Path()] = hash
+		}
+		buf, err := json.Marshal(actionID)
+		if err != nil {
+			panic(err) // shouldn't happen
+		}
+		hash := sha512.Sum512_224(buf)
+		packageActionIDs[pkg.Pkg.Path()] = hex.EncodeToString(hash[:])
+
+		// Determine the path of the bitcode file (which is a serialized version
+		// of a LLVM module).
+		bitcodePath := filepath.Join(cacheDir, "pkg-"+hex.EncodeToString(hash[:])+".bc")
+		packageBitcodePaths[pkg.ImportPath] = bitcodePath
+
+		// The package is already compiled and stored in the cache, so there
+		// is no need to compile it again.
+		if _, err := os.Stat(bitcodePath); err == nil {
+			continue
+		}
+
+		// The package has not yet been compiled, so create a job to do so.
+		job := &compileJob{
+			description: "compile package " + pkg.ImportPath,
+			run: func() error {
+				// Compile AST to IR. The compiler.CompilePackage function will
+				// build the SSA as needed.
+				mod, errs := compiler.CompilePackage(pkg.ImportPath, pkg, program.Package(pkg.Pkg), machine, compilerConfig, config.DumpSSA())
+				defer mod.Context().Dispose()
+				defer mod.Dispose()
+				if errs != nil {
+					return newMultiError(errs)
+				}
+				if err := llvm.VerifyModule(mod, llvm.PrintMessageAction); err != nil {
+					return errors.New("verification error after compiling package " + pkg.ImportPath)
+				}
+				return writeBitcode(mod, bitcodePath)
+			},
+		}
+		jobs = append(jobs, job)
+		packageJobs = append(packageJobs, job)
+	}
+
+	// Add job that links and optimizes all packages together.
 	var mod llvm.Module
 	var stackSizeLoads []string
 	programJob := &compileJob{
-		description: "compile Go files",
-		run: func() (err error) {
-			mod, err = compileWholeProgram(pkgName, config, compilerConfig, lprogram, machine)
+		description:  "link+optimize packages (LTO)",
+		dependencies: packageJobs,
+		run: func() error {
+			// Load and link all the bitcode files. This does not yet optimize
+			// anything, it only links the bitcode files together.
+			ctx := llvm.NewContext()
+			mod = ctx.NewModule("")
+			for _, pkg := range lprogram.Sorted() {
+				buf, err := llvm.NewMemoryBufferFromFile(packageBitcodePaths[pkg.ImportPath])
+				if err != nil {
+					return fmt.Errorf("failed to load bitcode file for package %s: %s", pkg.ImportPath, err)
+				}
+				pkgMod, err := ctx.ParseIR(buf) // takes ownership of buf
+				if err != nil {
+					return fmt.Errorf("failed to parse bitcode file for package %s: %s", pkg.ImportPath, err)
+				}
+				err = llvm.LinkModules(mod, pkgMod)
+				if err != nil {
+					return fmt.Errorf("failed to link package %s: %s", pkg.ImportPath, err)
+				}
+			}
+
+			// Create runtime.initAll function that calls the runtime
+			// initializer of each package.
+			createInitAll(mod, lprogram)
+
+			// Pass the flags of `tinygo test` to the testing package. They are
+			// stored in the binary for targets that don't support command line
+			// arguments, such as microcontrollers in an emulator.
+			if config.TestConfig.CompileTestBinary && !config.CommandLineArgs() {
+				setGlobalString(mod, "testing.testFlags", strings.Join(config.TestConfig.Flags(), "\x00"))
+			}
+
+			// Store the directory of the -embed-dir flag in the binary, so
+			// that the os package can mount it as a read-only filesystem.
+			if config.Options.EmbedDir != "" {
+				dir, prefix, err := config.Options.SplitEmbedDir()
+				if err != nil {
+					return err
+				}
+				files, err := readEmbedDir(dir)
+				if err != nil {
+					return fmt.Errorf("could not read -embed-dir directory: %w", err)
+				}
+				setGlobalString(mod, "os.embeddedPrefix", prefix)
+				setGlobalString(mod, "os.embeddedFiles", files)
+			}
+
+			// After linking, functions should (as far as possible) be set to
+			// internal linkage. The compiler package marks non-exported
+			// functions and globals by setting the visibility to hidden or (for
+			// synthetic functions) to linkonce_odr linkage. Change the linkage
+			// here to internal to benefit much more from interprocedural
+			// optimizations.
+			for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+				if fn.IsDeclaration() {
+					continue
+				}
+				if fn.Visibility() == llvm.HiddenVisibility || fn.Linkage() == llvm.LinkOnceODRLinkage {
+					fn.SetVisibility(llvm.DefaultVisibility)
+					fn.SetLinkage(llvm.InternalLinkage)
+				}
+			}
+			for global := mod.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+				if global.IsDeclaration() {
+					continue
+				}
+				if global.Visibility() == llvm.HiddenVisibility || global.Linkage() == llvm.LinkOnceODRLinkage {
+					global.SetVisibility(llvm.DefaultVisibility)
+					global.SetLinkage(llvm.InternalLinkage)
+				}
+			}
+
+			// The profiler in runtime/pprof walks the stack using frame
+			// pointers, so make sure they are not omitted when the program
+			// may be profiled.
+			if _, ok := packageBitcodePaths["runtime/pprof"]; ok && config.FramePointers() {
+				transform.AddFramePointers(mod) // -fno-omit-frame-pointer
+			}
+
+			err := optimizeProgram(mod, config)
 			if err != nil {
-				return
+				return err
 			}
+			// Look up goroutine names for goroutine dumps. This is done after
+			// optimization so only goroutines that can still be started are
+			// included.
+			transform.CreateGoroutineNames(mod)
 			// Make sure stack sizes are loaded from a separate section so they can be
 			// modified after linking.
 			if config.AutomaticStackSize() {
 				stackSizeLoads = transform.CreateStackSizeLoads(mod, config)
 			}
-			return
+			return nil
 		},
 	}
 	jobs = append(jobs, programJob)
@@ -237,13 +442,34 @@ func Build(pkgName, outpath string, config *compileopts.Config, action func(Buil
 	// TODO: do this as part of building the package to be able to link the
 	// bitcode files together.
 	for i, pkg := range lprogram.Sorted() {
+		if len(pkg.CFiles) == 0 {
+			continue
+		}
+		// Write the _cgo_export.h header with the functions exported from Go,
+		// so that the C files can include it.
+		var includeFlags []string
+		if pkg.CGoHeader != "" {
+			headerDir := filepath.Join(dir, "pkg"+strconv.Itoa(i)+"-include")
+			err := os.Mkdir(headerDir, 0777)
+			if err != nil {
+				return err
+			}
+			err = ioutil.WriteFile(filepath.Join(headerDir, "_cgo_export.h"), []byte(pkg.CGoHeader), 0666)
+			if err != nil {
+				return err
+			}
+			// The header contains the preambles, which may include headers
+			// relative to the package directory.
+			includeFlags = append(includeFlags, "-I"+headerDir, "-I"+pkg.Dir)
+		}
 		for j, filename := range pkg.CFiles {
 			file := filepath.Join(pkg.Dir, filename)
 			outpath := filepath.Join(dir, "pkg"+strconv.Itoa(i)+"."+strconv.Itoa(j)+"-"+filepath.Base(file)+".o")
 			job := &compileJob{
 				description: "compile CGo file " + file,
 				run: func() error {
-					err := runCCompiler(config.Target.Compiler, append(config.CFlags(), "-c", "-o", outpath, file)...)
+					cflags := append(config.CFlags(), includeFlags...)
+					err := runCCompiler(config.Target.Compiler, append(cflags, "-c", "-o", outpath, file)...)
 					if err != nil {
 						return &commandError{"failed to build", file, err}
 					}
@@ -293,22 +519,14 @@ func Build(pkgName, outpath string, config *compileopts.Config, action func(Buil
 				}
 			}
 
-			if config.Options.PrintSizes == "short" || config.Options.PrintSizes == "full" {
+			if config.Options.PrintSizes != "" && config.Options.PrintSizes != "none" {
 				sizes, err := loadProgramSize(executable)
 				if err != nil {
 					return err
 				}
-				if config.Options.PrintSizes == "short" {
-					fmt.Printf("   code    data     bss |   flash     ram\n")
-					fmt.Printf("%7d %7d %7d | %7d %7d\n", sizes.Code, sizes.Data, sizes.BSS, sizes.Code+sizes.Data, sizes.Data+sizes.BSS)
-				} else {
-					fmt.Printf("   code  rodata    data     bss |   flash     ram | package\n")
-					for _, name := range sizes.sortedPackageNames() {
-						pkgSize := sizes.Packages[name]
-						fmt.Printf("%7d %7d %7d %7d | %7d %7d | %s\n", pkgSize.Code, pkgSize.ROData, pkgSize.Data, pkgSize.BSS, pkgSize.Flash(), pkgSize.RAM(), name)
-					}
-					fmt.Printf("%7d %7d %7d %7d | %7d %7d | (sum)\n", sizes.Sum.Code, sizes.Sum.ROData, sizes.Sum.Data, sizes.Sum.BSS, sizes.Sum.Flash(), sizes.Sum.RAM())
-					fmt.Printf("%7d       - %7d %7d | %7d %7d | (all)\n", sizes.Code, sizes.Data, sizes.BSS, sizes.Code+sizes.Data, sizes.Data+sizes.BSS)
+				err = printProgramSize(os.Stdout, sizes, config.Options.PrintSizes, config.Options.PrintSizesFormat)
+				if err != nil {
+					return err
 				}
 			}
 
@@ -366,29 +584,97 @@ func Build(pkgName, outpath string, config *compileopts.Config, action func(Buil
 	})
 }
 
-// compileWholeProgram compiles the entire *loader.Program to a LLVM module and
-// applies most necessary optimizations and transformations.
-func compileWholeProgram(pkgName string, config *compileopts.Config, compilerConfig *compiler.Config, lprogram *loader.Program, machine llvm.TargetMachine) (llvm.Module, error) {
-	// Compile AST to IR.
-	mod, errs := compiler.CompileProgram(lprogram, machine, compilerConfig, config.DumpSSA())
-	if errs != nil {
-		return mod, newMultiError(errs)
+// writeBitcode writes the given module as bitcode to the given path. It first
+// writes to a temporary file that is then renamed, to avoid race conditions
+// with other TinyGo invocations that might be compiling the same package at
+// the same time.
+func writeBitcode(mod llvm.Module, path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	buf := llvm.WriteBitcodeToMemoryBuffer(mod)
+	defer buf.Dispose()
+	_, err = f.Write(buf.Bytes())
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// createInitAll defines the runtime.initAll function (declared in the runtime
+// package) that calls the initializer of each package in the program, in
+// dependency order.
+func createInitAll(mod llvm.Module, lprogram *loader.Program) {
+	ctx := mod.Context()
+	i8ptrType := llvm.PointerType(ctx.Int8Type(), 0)
+	llvmInitFn := mod.NamedFunction("runtime.initAll")
+	llvmInitFn.SetLinkage(llvm.InternalLinkage)
+	llvmInitFn.SetUnnamedAddr(true)
+	llvmInitFn.Param(0).SetName("context")
+	llvmInitFn.Param(1).SetName("parentHandle")
+	block := ctx.AddBasicBlock(llvmInitFn, "entry")
+	irbuilder := ctx.NewBuilder()
+	defer irbuilder.Dispose()
+	irbuilder.SetInsertPointAtEnd(block)
+	for _, pkg := range lprogram.Sorted() {
+		pkgInit := mod.NamedFunction(pkg.Pkg.Path() + ".init")
+		if pkgInit.IsNil() {
+			panic("init not found for " + pkg.Pkg.Path())
+		}
+		irbuilder.CreateCall(pkgInit, []llvm.Value{llvm.Undef(i8ptrType), llvm.Undef(i8ptrType)}, "")
 	}
+	irbuilder.CreateRetVoid()
+}
+
+// setGlobalString sets the value of a Go string global to the given string.
+// It does nothing if the global doesn't exist (for example, because the package
+// isn't part of the program).
+func setGlobalString(mod llvm.Module, name, value string) {
+	global := mod.NamedGlobal(name)
+	if global.IsNil() || global.IsDeclaration() || value == "" {
+		return
+	}
+	ctx := mod.Context()
+	bufInitializer := ctx.ConstString(value, false)
+	buf := llvm.AddGlobal(mod, bufInitializer.Type(), name+"$string")
+	buf.SetInitializer(bufInitializer)
+	buf.SetLinkage(llvm.InternalLinkage)
+	buf.SetGlobalConstant(true)
+	buf.SetUnnamedAddr(true)
+	stringType := global.Type().ElementType()
+	lengthType := stringType.StructElementTypes()[1]
+	zero := llvm.ConstInt(ctx.Int32Type(), 0, false)
+	bufPtr := llvm.ConstInBoundsGEP(buf, []llvm.Value{zero, zero})
+	length := llvm.ConstInt(lengthType, uint64(len(value)), false)
+	global.SetInitializer(llvm.ConstNamedStruct(stringType, []llvm.Value{bufPtr, length}))
+}
 
+// optimizeProgram runs a series of optimizations and transformations that are
+// needed to convert a program to its final form. Some transformations are not
+// optional and must be run as the compiler expects them to run.
+func optimizeProgram(mod llvm.Module, config *compileopts.Config) error {
 	if config.Options.PrintIR {
 		fmt.Println("; Generated LLVM IR:")
 		fmt.Println(mod.String())
 	}
 	if err := llvm.VerifyModule(mod, llvm.PrintMessageAction); err != nil {
-		return mod, errors.New("verification error after IR construction")
+		return errors.New("verification error after IR construction")
 	}
 
 	err := interp.Run(mod, config.DumpSSA())
 	if err != nil {
-		return mod, err
+		return err
 	}
 	if err := llvm.VerifyModule(mod, llvm.PrintMessageAction); err != nil {
-		return mod, errors.New("verification error after interpreting runtime.initAll")
+		return errors.New("verification error after interpreting runtime.initAll")
 	}
 
 	if config.GOOS() != "darwin" {
@@ -403,13 +689,13 @@ func compileWholeProgram(pkgName string, config *compileopts.Config, compilerCon
 	if config.WasmAbi() == "js" {
 		err := transform.ExternalInt64AsPtr(mod)
 		if err != nil {
-			return mod, err
+			return err
 		}
 	}
 
 	// Optimization levels here are roughly the same as Clang, but probably not
 	// exactly.
-	errs = nil
+	var errs []error
 	switch config.Options.Opt {
 	/*
 		Currently, turning optimizations off causes compile failures.
@@ -432,10 +718,10 @@ func compileWholeProgram(pkgName string, config *compileopts.Config, compilerCon
 		errs = []error{errors.New("unknown optimization level: -opt=" + config.Options.Opt)}
 	}
 	if len(errs) > 0 {
-		return mod, newMultiError(errs)
+		return newMultiError(errs)
 	}
 	if err := llvm.VerifyModule(mod, llvm.PrintMessageAction); err != nil {
-		return mod, errors.New("verification failure after LLVM optimization passes")
+		return errors.New("verification failure after LLVM optimization passes")
 	}
 
 	// LLVM 11 by default tries to emit tail calls (even with the target feature
@@ -449,7 +735,14 @@ func compileWholeProgram(pkgName string, config *compileopts.Config, compilerCon
 		transform.DisableTailCalls(mod)
 	}
 
-	return mod, nil
+	if config.Options.PrintWhy != "" {
+		err := printWhy(os.Stdout, mod, config.Options.PrintWhy)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // functionStackSizes keeps stack size information about a single function
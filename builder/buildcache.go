@@ -1,14 +1,50 @@
 package builder
 
 import (
+	"crypto/sha512"
+	"encoding/hex"
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/tinygo-org/tinygo/goenv"
 )
 
+var (
+	compilerHashOnce  sync.Once
+	compilerHashValue string
+	compilerHashErr   error
+)
+
+// getCompilerHash returns a hash of the running compiler executable. It is part
+// of the cache key of compiled packages, so that a rebuilt compiler (with
+// changes to the compiler, interp or transform packages for example) doesn't
+// reuse bitcode compiled by an older version. The hash is only calculated once.
+func getCompilerHash() (string, error) {
+	compilerHashOnce.Do(func() {
+		path, err := os.Executable()
+		if err != nil {
+			compilerHashErr = err
+			return
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			compilerHashErr = err
+			return
+		}
+		defer f.Close()
+		hash := sha512.New512_224()
+		if _, err := io.Copy(hash, f); err != nil {
+			compilerHashErr = err
+			return
+		}
+		compilerHashValue = hex.EncodeToString(hash.Sum(nil))
+	})
+	return compilerHashValue, compilerHashErr
+}
+
 // Return the newest timestamp of all the file paths passed in. Used to check
 // for stale caches.
 func cacheTimestamp(paths []string) (time.Time, error) {
@@ -55,14 +91,17 @@ func cacheLoad(name string, sourceFiles []string) (string, error) {
 
 // Store the file located at tmppath in the cache with the given name. The
 // tmppath may or may not be gone afterwards.
+//
+// The name must encode all configuration that affects the output (such as the
+// target triple and CPU), as only the timestamps of the source files are
+// checked when loading the file from the cache. Go packages use a different
+// cache, keyed by a hash of all their inputs (see Build).
 func cacheStore(tmppath, name string, sourceFiles []string) (string, error) {
 	// get the last modified time
 	if len(sourceFiles) == 0 {
 		panic("cache: no source files")
 	}
 
-	// TODO: check the config key
-
 	dir := goenv.Get("GOCACHE")
 	err := os.MkdirAll(dir, 0777)
 	if err != nil {
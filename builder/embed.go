@@ -0,0 +1,57 @@
+package builder
+
+// This file implements the -embed-dir flag, which stores a directory in the
+// program so that it can be read through the os package at runtime.
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readEmbedDir reads all regular files in the given directory (recursively)
+// and returns them in the format expected by the read-only filesystem in the
+// os package. For each file, in lexical order, it contains:
+//
+//   - the length of the path as a 32-bit little endian integer
+//   - the path relative to the directory, with a leading slash
+//   - the length of the file contents as a 32-bit little endian integer
+//   - the file contents
+func readEmbedDir(dir string) (string, error) {
+	var buf strings.Builder
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			// Directories are implied by the paths of the files in them.
+			// Other files (like symlinks and devices) are not embedded.
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		writeEmbedString(&buf, "/"+filepath.ToSlash(rel))
+		writeEmbedString(&buf, string(data))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// writeEmbedString writes a string with its length in front of it.
+func writeEmbedString(buf *strings.Builder, s string) {
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(s)))
+	buf.Write(length[:])
+	buf.WriteString(s)
+}
@@ -2,17 +2,22 @@ package builder
 
 import (
 	"debug/elf"
+	"encoding/json"
+	"fmt"
+	"io"
 	"sort"
 	"strings"
 )
 
 // programSize contains size statistics per package of a compiled program.
 type programSize struct {
-	Packages map[string]*packageSize
-	Sum      *packageSize
-	Code     uint64
-	Data     uint64
-	BSS      uint64
+	Packages map[string]*packageSize `json:"packages"`
+	Sum      *packageSize            `json:"sum"`
+	Code     uint64                  `json:"code"`
+	Data     uint64                  `json:"data"`
+	BSS      uint64                  `json:"bss"`
+	Sections []sectionSize           `json:"sections"`
+	Symbols  []symbolSize            `json:"symbols"`
 }
 
 // sortedPackageNames returns the list of package names (ProgramSize.Packages)
@@ -29,10 +34,10 @@ func (ps *programSize) sortedPackageNames() []string {
 // packageSize contains the size of a package, calculated from the linked object
 // file.
 type packageSize struct {
-	Code   uint64
-	ROData uint64
-	Data   uint64
-	BSS    uint64
+	Code   uint64 `json:"code"`
+	ROData uint64 `json:"rodata"`
+	Data   uint64 `json:"data"`
+	BSS    uint64 `json:"bss"`
 }
 
 // Flash usage in regular microcontrollers.
@@ -45,6 +50,24 @@ func (ps *packageSize) RAM() uint64 {
 	return ps.Data + ps.BSS
 }
 
+// sectionSize is the size of a single allocated section in the ELF file.
+type sectionSize struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"` // code, rodata, data, or bss
+	Address uint64 `json:"address"`
+	Size    uint64 `json:"size"`
+}
+
+// symbolSize is the size of a single function or global in the ELF file.
+type symbolSize struct {
+	Name    string `json:"name"`
+	Package string `json:"package"`
+	Kind    string `json:"kind"` // code, rodata, data, or bss
+	Section string `json:"section"`
+	Address uint64 `json:"address"`
+	Size    uint64 `json:"size"`
+}
+
 type symbolList []elf.Symbol
 
 func (l symbolList) Len() int {
@@ -77,6 +100,7 @@ func loadProgramSize(path string) (*programSize, error) {
 	var sumCode uint64
 	var sumData uint64
 	var sumBSS uint64
+	var sections []sectionSize
 	for _, section := range file.Sections {
 		if section.Flags&elf.SHF_ALLOC == 0 {
 			continue
@@ -84,6 +108,7 @@ func loadProgramSize(path string) (*programSize, error) {
 		if section.Type != elf.SHT_PROGBITS && section.Type != elf.SHT_NOBITS {
 			continue
 		}
+		kind := sectionKind(section)
 		if section.Name == ".stack" {
 			// HACK: this works around a bug in ld.lld from LLVM 10. The linker
 			// marks sections with no input symbols (such as is the case for the
@@ -95,6 +120,7 @@ func loadProgramSize(path string) (*programSize, error) {
 			// It has been merged in master, but it has not (yet) been
 			// backported to the LLVM 10 release branch.
 			sumBSS += section.Size
+			kind = "bss"
 		} else if section.Type == elf.SHT_NOBITS {
 			sumBSS += section.Size
 		} else if section.Flags&elf.SHF_EXECINSTR != 0 {
@@ -102,6 +128,12 @@ func loadProgramSize(path string) (*programSize, error) {
 		} else if section.Flags&elf.SHF_WRITE != 0 {
 			sumData += section.Size
 		}
+		sections = append(sections, sectionSize{
+			Name:    section.Name,
+			Kind:    kind,
+			Address: section.Addr,
+			Size:    section.Size,
+		})
 	}
 
 	allSymbols, err := file.Symbols()
@@ -129,38 +161,56 @@ func loadProgramSize(path string) (*programSize, error) {
 	sort.Sort(symbolList(symbols))
 
 	sizes := map[string]*packageSize{}
+	var symbolSizes []symbolSize
 	var lastSymbolValue uint64
 	for _, symbol := range symbols {
 		symType := elf.ST_TYPE(symbol.Info)
 		//bind := elf.ST_BIND(symbol.Info)
 		section := file.Sections[symbol.Section]
-		pkgName := "(bootstrap)"
-		symName := strings.TrimLeft(symbol.Name, "(*")
-		dot := strings.IndexByte(symName, '.')
-		if dot > 0 {
-			pkgName = symName[:dot]
-		}
+		pkgName := symbolPackage(symbol.Name)
 		pkgSize := sizes[pkgName]
 		if pkgSize == nil {
 			pkgSize = &packageSize{}
 			sizes[pkgName] = pkgSize
 		}
 		if lastSymbolValue != symbol.Value || lastSymbolValue == 0 {
+			kind := "rodata"
 			if symType == elf.STT_FUNC {
-				pkgSize.Code += symbol.Size
+				kind = "code"
 			} else if section.Flags&elf.SHF_WRITE != 0 {
 				if section.Type == elf.SHT_NOBITS {
-					pkgSize.BSS += symbol.Size
+					kind = "bss"
 				} else {
-					pkgSize.Data += symbol.Size
+					kind = "data"
 				}
-			} else {
+			}
+			switch kind {
+			case "code":
+				pkgSize.Code += symbol.Size
+			case "bss":
+				pkgSize.BSS += symbol.Size
+			case "data":
+				pkgSize.Data += symbol.Size
+			default:
 				pkgSize.ROData += symbol.Size
 			}
+			symbolSizes = append(symbolSizes, symbolSize{
+				Name:    symbol.Name,
+				Package: pkgName,
+				Kind:    kind,
+				Section: section.Name,
+				Address: symbol.Value,
+				Size:    symbol.Size,
+			})
 		}
 		lastSymbolValue = symbol.Value
 	}
 
+	// Show the biggest symbols first.
+	sort.SliceStable(symbolSizes, func(i, j int) bool {
+		return symbolSizes[i].Size > symbolSizes[j].Size
+	})
+
 	sum := &packageSize{}
 	for _, pkg := range sizes {
 		sum.Code += pkg.Code
@@ -169,5 +219,247 @@ func loadProgramSize(path string) (*programSize, error) {
 		sum.BSS += pkg.BSS
 	}
 
-	return &programSize{Packages: sizes, Code: sumCode, Data: sumData, BSS: sumBSS, Sum: sum}, nil
+	return &programSize{Packages: sizes, Code: sumCode, Data: sumData, BSS: sumBSS, Sum: sum, Sections: sections, Symbols: symbolSizes}, nil
+}
+
+// sectionKind returns the kind of data stored in the section: code, rodata,
+// data, or bss.
+func sectionKind(section *elf.Section) string {
+	switch {
+	case section.Flags&elf.SHF_EXECINSTR != 0:
+		return "code"
+	case section.Flags&elf.SHF_WRITE == 0:
+		return "rodata"
+	case section.Type == elf.SHT_NOBITS:
+		return "bss"
+	default:
+		return "data"
+	}
+}
+
+// symbolPackage returns the package a symbol belongs to, based on its name.
+// Symbols generated by the compiler, such as interface method thunks and type
+// codes, are attributed to the package that caused them to be generated.
+// Anonymous interfaces and unnamed types don't belong to a package, so their
+// type codes and thunks are attributed to "reflect/types". Symbols that don't
+// come from Go code (such as those from libc or compiler-rt) are attributed to
+// "(bootstrap)".
+func symbolPackage(name string) string {
+	switch {
+	case strings.HasPrefix(name, "interface:"), strings.HasPrefix(name, "reflect/types.interface:"):
+		// Method set or type assert thunk of an anonymous interface.
+		return "reflect/types"
+	case strings.HasPrefix(name, "typeInInterface:"):
+		// Global holding a type code that is stored in an interface.
+		return symbolPackage(name[len("typeInInterface:"):])
+	case strings.HasPrefix(name, "reflect/types.type:"):
+		// Type code. Attribute named types to the package they're defined in.
+		if index := strings.LastIndex(name, "named:"); index >= 0 {
+			return symbolPackage(name[index+len("named:"):])
+		}
+		return "reflect/types"
+	case strings.HasPrefix(name, "runtime/gc.layout:"):
+		// Object layout for the precise garbage collector.
+		return "runtime"
+	}
+
+	// Regular Go symbols look like "pkg/path.Func" or "(*pkg/path.T).Method",
+	// possibly with a suffix like "$invoke" or "$gowrapper". The package path
+	// ends at the first dot after the last slash.
+	name = strings.TrimLeft(name, "(*")
+	if end := strings.IndexAny(name, "(){}[]$: "); end >= 0 {
+		name = name[:end]
+	}
+	lastSlash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[lastSlash+1:], '.')
+	if dot <= 0 {
+		return "(bootstrap)"
+	}
+	return name[:lastSlash+1+dot]
+}
+
+// PrintSizes loads the ELF file at the given path and prints a size report
+// to w. The level is one of "short", "full", or "symbols", and the format
+// is either "text" or "json".
+func PrintSizes(w io.Writer, path, level, format string) error {
+	sizes, err := loadProgramSize(path)
+	if err != nil {
+		return err
+	}
+	return printProgramSize(w, sizes, level, format)
+}
+
+// printProgramSize prints a size report of a program at the given level
+// ("short", "full", or "symbols") and in the given format ("text" or "json").
+func printProgramSize(w io.Writer, sizes *programSize, level, format string) error {
+	if format == "json" {
+		// Only include the information that was asked for.
+		report := *sizes
+		if level == "short" {
+			report.Packages = nil
+			report.Sum = nil
+		}
+		if level != "symbols" {
+			report.Sections = nil
+			report.Symbols = nil
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(&report)
+	}
+
+	if level == "short" {
+		fmt.Fprintf(w, "   code    data     bss |   flash     ram\n")
+		fmt.Fprintf(w, "%7d %7d %7d | %7d %7d\n", sizes.Code, sizes.Data, sizes.BSS, sizes.Code+sizes.Data, sizes.Data+sizes.BSS)
+		return nil
+	}
+
+	fmt.Fprintf(w, "   code  rodata    data     bss |   flash     ram | package\n")
+	for _, name := range sizes.sortedPackageNames() {
+		pkgSize := sizes.Packages[name]
+		fmt.Fprintf(w, "%7d %7d %7d %7d | %7d %7d | %s\n", pkgSize.Code, pkgSize.ROData, pkgSize.Data, pkgSize.BSS, pkgSize.Flash(), pkgSize.RAM(), name)
+	}
+	fmt.Fprintf(w, "%7d %7d %7d %7d | %7d %7d | (sum)\n", sizes.Sum.Code, sizes.Sum.ROData, sizes.Sum.Data, sizes.Sum.BSS, sizes.Sum.Flash(), sizes.Sum.RAM())
+	fmt.Fprintf(w, "%7d       - %7d %7d | %7d %7d | (all)\n", sizes.Code, sizes.Data, sizes.BSS, sizes.Code+sizes.Data, sizes.Data+sizes.BSS)
+	if level != "symbols" {
+		return nil
+	}
+
+	fmt.Fprintf(w, "\n   address    size | kind   | section\n")
+	for _, section := range sizes.Sections {
+		fmt.Fprintf(w, "%#10x %7d | %-6s | %s\n", section.Address, section.Size, section.Kind, section.Name)
+	}
+
+	fmt.Fprintf(w, "\n   size | kind   | package | symbol\n")
+	for _, symbol := range sizes.Symbols {
+		fmt.Fprintf(w, "%7d | %-6s | %s | %s\n", symbol.Size, symbol.Kind, symbol.Package, symbol.Name)
+	}
+	return nil
+}
+
+// sizeDiff is a single changed entry (a symbol or a package) between two
+// programs.
+type sizeDiff struct {
+	Name    string `json:"name"`
+	Package string `json:"package,omitempty"`
+	Old     uint64 `json:"old"`
+	New     uint64 `json:"new"`
+}
+
+// Delta returns the change in size, which is negative if the entry shrunk.
+func (d sizeDiff) Delta() int64 {
+	return int64(d.New) - int64(d.Old)
+}
+
+// programSizeDiff contains all the differences between two programs.
+type programSizeDiff struct {
+	Flash    sizeDiff   `json:"flash"`
+	RAM      sizeDiff   `json:"ram"`
+	Packages []sizeDiff `json:"packages"`
+	Symbols  []sizeDiff `json:"symbols"`
+}
+
+// PrintSizeDiff compares the two given ELF files and prints all packages and
+// symbols that changed in size to w, the biggest changes first. The format is
+// either "text" or "json".
+func PrintSizeDiff(w io.Writer, oldPath, newPath, format string) error {
+	oldSizes, err := loadProgramSize(oldPath)
+	if err != nil {
+		return err
+	}
+	newSizes, err := loadProgramSize(newPath)
+	if err != nil {
+		return err
+	}
+	return printSizeDiff(w, diffProgramSizes(oldSizes, newSizes), format)
+}
+
+// printSizeDiff prints the differences between two programs in the given
+// format ("text" or "json").
+func printSizeDiff(w io.Writer, diff *programSizeDiff, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(diff)
+	}
+
+	fmt.Fprintf(w, "    old     new   delta | package\n")
+	for _, d := range diff.Packages {
+		fmt.Fprintf(w, "%7d %7d %+7d | %s\n", d.Old, d.New, d.Delta(), d.Name)
+	}
+	fmt.Fprintf(w, "\n    old     new   delta | symbol\n")
+	for _, d := range diff.Symbols {
+		fmt.Fprintf(w, "%7d %7d %+7d | %s\n", d.Old, d.New, d.Delta(), d.Name)
+	}
+	fmt.Fprintf(w, "\n    old     new   delta |\n")
+	fmt.Fprintf(w, "%7d %7d %+7d | flash\n", diff.Flash.Old, diff.Flash.New, diff.Flash.Delta())
+	fmt.Fprintf(w, "%7d %7d %+7d | ram\n", diff.RAM.Old, diff.RAM.New, diff.RAM.Delta())
+	return nil
+}
+
+// diffProgramSizes returns the packages and symbols that differ in size
+// between the two programs. Symbols are matched by name. If there are
+// multiple symbols with the same name (for example, static functions in C),
+// their sizes are added together.
+func diffProgramSizes(oldSizes, newSizes *programSize) *programSizeDiff {
+	diff := &programSizeDiff{
+		Flash: sizeDiff{Name: "flash", Old: oldSizes.Code + oldSizes.Data, New: newSizes.Code + newSizes.Data},
+		RAM:   sizeDiff{Name: "ram", Old: oldSizes.Data + oldSizes.BSS, New: newSizes.Data + newSizes.BSS},
+	}
+
+	// Compare packages.
+	packages := map[string]*sizeDiff{}
+	for name, pkg := range oldSizes.Packages {
+		packages[name] = &sizeDiff{Name: name, Old: pkg.Flash()}
+	}
+	for name, pkg := range newSizes.Packages {
+		if packages[name] == nil {
+			packages[name] = &sizeDiff{Name: name}
+		}
+		packages[name].New = pkg.Flash()
+	}
+	diff.Packages = sortedSizeDiffs(packages)
+
+	// Compare symbols.
+	symbols := map[string]*sizeDiff{}
+	for _, symbol := range oldSizes.Symbols {
+		if symbols[symbol.Name] == nil {
+			symbols[symbol.Name] = &sizeDiff{Name: symbol.Name, Package: symbol.Package}
+		}
+		symbols[symbol.Name].Old += symbol.Size
+	}
+	for _, symbol := range newSizes.Symbols {
+		if symbols[symbol.Name] == nil {
+			symbols[symbol.Name] = &sizeDiff{Name: symbol.Name, Package: symbol.Package}
+		}
+		symbols[symbol.Name].New += symbol.Size
+	}
+	diff.Symbols = sortedSizeDiffs(symbols)
+
+	return diff
+}
+
+// sortedSizeDiffs returns the entries that changed in size, sorted by the
+// size of the change (biggest first) and then by name.
+func sortedSizeDiffs(entries map[string]*sizeDiff) []sizeDiff {
+	diffs := []sizeDiff{}
+	for _, d := range entries {
+		if d.Delta() != 0 {
+			diffs = append(diffs, *d)
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		di, dj := diffs[i].Delta(), diffs[j].Delta()
+		if di < 0 {
+			di = -di
+		}
+		if dj < 0 {
+			dj = -dj
+		}
+		if di != dj {
+			return di > dj
+		}
+		return diffs[i].Name < diffs[j].Name
+	})
+	return diffs
 }
@@ -0,0 +1,165 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestSymbolPackage(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		pkg  string
+	}{
+		// Regular Go functions and methods.
+		{"main.main", "main"},
+		{"runtime.alloc", "runtime"},
+		{"(*bytes.Buffer).Write", "bytes"},
+		{"(image/color.RGBA).RGBA", "image/color"},
+		{"(*github.com/tinygo-org/tinygo/src/foo.T).Method", "github.com/tinygo-org/tinygo/src/foo"},
+
+		// Functions generated by the compiler for Go code.
+		{"main.main$1", "main"},
+		{"main.worker$gowrapper", "main"},
+		{"(*bytes.Buffer).Write$invoke", "bytes"},
+		{"fmt.Stringer$typeassert", "fmt"},
+		{"internal/task.start$1$gowrapper", "internal/task"},
+
+		// Type codes and other type information.
+		{"reflect/types.type:named:main.T", "main"},
+		{"reflect/types.type:pointer:named:image/color.RGBA", "image/color"},
+		{"reflect/types.type:basic:int", "reflect/types"},
+		{"reflect/types.type:slice:basic:uint8", "reflect/types"},
+		{"typeInInterface:reflect/types.type:named:main.T", "main"},
+		{"typeInInterface:reflect/types.type:basic:string", "reflect/types"},
+		{"runtime/gc.layout:62-2000000000000001", "runtime"},
+
+		// Anonymous interfaces don't belong to a package.
+		{"interface:{String:func:{}{basic:string}}$typeassert", "reflect/types"},
+		{"reflect/types.interface:interface{String() string}$interface", "reflect/types"},
+
+		// Symbols that are not from Go code.
+		{"memcpy", "(bootstrap)"},
+		{"__aeabi_memclr", "(bootstrap)"},
+		{"_start", "(bootstrap)"},
+		{".Lstr.12", "(bootstrap)"},
+	} {
+		if pkg := symbolPackage(tc.name); pkg != tc.pkg {
+			t.Errorf("symbolPackage(%q) = %q, expected %q", tc.name, pkg, tc.pkg)
+		}
+	}
+}
+
+// testProgramSize returns a small program size breakdown, as it could have
+// been loaded from an ELF file by loadProgramSize.
+func testProgramSize(mainCode, mainBSS uint64) *programSize {
+	packages := map[string]*packageSize{
+		"main":    {Code: mainCode, BSS: mainBSS},
+		"runtime": {Code: 400, ROData: 20, Data: 8, BSS: 16},
+	}
+	sum := &packageSize{}
+	for _, pkg := range packages {
+		sum.Code += pkg.Code
+		sum.ROData += pkg.ROData
+		sum.Data += pkg.Data
+		sum.BSS += pkg.BSS
+	}
+	return &programSize{
+		Packages: packages,
+		Sum:      sum,
+		Code:     sum.Code + sum.ROData,
+		Data:     sum.Data,
+		BSS:      sum.BSS,
+		Sections: []sectionSize{
+			{Name: ".text", Kind: "code", Address: 0x1000, Size: sum.Code + sum.ROData},
+			{Name: ".bss", Kind: "bss", Address: 0x20000000, Size: sum.BSS},
+		},
+		Symbols: []symbolSize{
+			{Name: "runtime.alloc", Package: "runtime", Kind: "code", Section: ".text", Address: 0x1000, Size: 400},
+			{Name: "main.main", Package: "main", Kind: "code", Section: ".text", Address: 0x1190, Size: mainCode},
+			{Name: "main.buf", Package: "main", Kind: "bss", Section: ".bss", Address: 0x20000000, Size: mainBSS},
+		},
+	}
+}
+
+func TestPrintSizesJSON(t *testing.T) {
+	for _, tc := range []struct {
+		level    string
+		packages bool
+		symbols  bool
+	}{
+		{"short", false, false},
+		{"full", true, false},
+		{"symbols", true, true},
+	} {
+		buf := &bytes.Buffer{}
+		err := printProgramSize(buf, testProgramSize(100, 64), tc.level, "json")
+		if err != nil {
+			t.Errorf("level %s: could not print sizes: %v", tc.level, err)
+			continue
+		}
+		var report programSize
+		err = json.Unmarshal(buf.Bytes(), &report)
+		if err != nil {
+			t.Errorf("level %s: invalid JSON: %v", tc.level, err)
+			continue
+		}
+		if report.Code != 520 || report.Data != 8 || report.BSS != 80 {
+			t.Errorf("level %s: unexpected totals: code=%d data=%d bss=%d", tc.level, report.Code, report.Data, report.BSS)
+		}
+		if (report.Packages != nil) != tc.packages || (report.Sum != nil) != tc.packages {
+			t.Errorf("level %s: expected packages to be included: %v", tc.level, tc.packages)
+		}
+		if tc.packages && report.Packages["main"].Code != 100 {
+			t.Errorf("level %s: unexpected size of package main: %+v", tc.level, report.Packages["main"])
+		}
+		if (report.Symbols != nil) != tc.symbols || (report.Sections != nil) != tc.symbols {
+			t.Errorf("level %s: expected symbols to be included: %v", tc.level, tc.symbols)
+		}
+		if tc.symbols && (len(report.Symbols) != 3 || report.Symbols[1].Package != "main") {
+			t.Errorf("level %s: unexpected symbols: %+v", tc.level, report.Symbols)
+		}
+	}
+}
+
+func TestPrintSizeDiff(t *testing.T) {
+	// main.main grows by 20 bytes and main.buf shrinks by 32 bytes.
+	diff := diffProgramSizes(testProgramSize(100, 64), testProgramSize(120, 32))
+
+	buf := &bytes.Buffer{}
+	err := printSizeDiff(buf, diff, "text")
+	if err != nil {
+		t.Fatal("could not print size diff:", err)
+	}
+	expected := `    old     new   delta | package
+    100     120     +20 | main
+
+    old     new   delta | symbol
+     64      32     -32 | main.buf
+    100     120     +20 | main.main
+
+    old     new   delta |
+    528     548     +20 | flash
+     88      56     -32 | ram
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected size diff output:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	err = printSizeDiff(buf, diff, "json")
+	if err != nil {
+		t.Fatal("could not print size diff:", err)
+	}
+	var report programSizeDiff
+	err = json.Unmarshal(buf.Bytes(), &report)
+	if err != nil {
+		t.Fatal("invalid JSON:", err)
+	}
+	if report.Flash.Old != 528 || report.Flash.New != 548 || report.RAM.Old != 88 || report.RAM.New != 56 {
+		t.Errorf("unexpected flash or RAM diff: %+v %+v", report.Flash, report.RAM)
+	}
+	if len(report.Symbols) != 2 || report.Symbols[0].Name != "main.buf" || report.Symbols[0].Package != "main" {
+		t.Errorf("unexpected symbol diff: %+v", report.Symbols)
+	}
+}
@@ -0,0 +1,52 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+%fmt.pp = type { i8* }
+%main.T = type { i32 }
+
+@main.handlers = internal global [1 x void ()*] [void ()* @main.handler]
+@main.unusedGlobal = internal global i32 0
+
+define void @main() {
+entry:
+  call void @main.main()
+  ret void
+}
+
+define internal void @main.main() {
+entry:
+  %handler = load void ()*, void ()** getelementptr inbounds ([1 x void ()*], [1 x void ()*]* @main.handlers, i32 0, i32 0)
+  call void %handler()
+  ret void
+}
+
+define internal void @main.handler() {
+entry:
+  call void @"(*fmt.pp).printValue"(%fmt.pp* null)
+  ret void
+}
+
+define internal void @"(*fmt.pp).printValue"(%fmt.pp* %p) {
+entry:
+  ret void
+}
+
+define internal void @"(main.T).String"(%main.T %t) {
+entry:
+  ret void
+}
+
+define internal void @"(*github.com/user/lib.Buffer).Write"(i8* %b) {
+entry:
+  ret void
+}
+
+; Not referenced from any root, so this function and everything only it
+; references would be removed by the linker.
+define internal void @main.unused() {
+entry:
+  store i32 1, i32* @main.unusedGlobal
+  call void @"(main.T).String"(%main.T zeroinitializer)
+  call void @"(*github.com/user/lib.Buffer).Write"(i8* null)
+  ret void
+}
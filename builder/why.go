@@ -0,0 +1,202 @@
+package builder
+
+// This file implements the -why flag, which explains why a given function or
+// global is included in the program by printing a chain of references from a
+// root of the program (such as the entry point or an interrupt handler) to the
+// symbol.
+//
+// The references are read from the fully optimized LLVM module instead of
+// from the linked ELF file. This works for every architecture and also shows
+// references through globals (such as method sets and interface tables), not
+// just direct calls. Everything that is still referenced at this point will
+// also be present in the linked binary.
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// printWhy prints the shortest chain of references from a root of the program
+// to the named function or global.
+func printWhy(w io.Writer, mod llvm.Module, name string) error {
+	target := findWhySymbol(mod, name)
+	if target.IsNil() {
+		return fmt.Errorf("-why: %s is not included in the program%s", name, whySuggestions(mod, name))
+	}
+
+	// Collect all functions and globals, with the roots first. A root is any
+	// definition that is visible outside of the module, such as the program
+	// entry point, interrupt handlers, and exported functions. The main entry
+	// point is put at the front so that chains starting there are preferred.
+	var roots []llvm.Value
+	var symbols []llvm.Value
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		symbols = append(symbols, fn)
+	}
+	for global := mod.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+		symbols = append(symbols, global)
+	}
+	for _, symbol := range symbols {
+		if symbol.IsDeclaration() {
+			continue
+		}
+		switch symbol.Linkage() {
+		case llvm.InternalLinkage, llvm.PrivateLinkage:
+			continue
+		}
+		roots = append(roots, symbol)
+	}
+	sort.SliceStable(roots, func(i, j int) bool {
+		return roots[i].Name() == "main" && roots[j].Name() != "main"
+	})
+
+	// Do a breadth-first search starting at all roots, so that the shortest
+	// chain is found.
+	parents := map[llvm.Value]llvm.Value{}
+	queue := []llvm.Value{}
+	for _, root := range roots {
+		if _, ok := parents[root]; !ok {
+			parents[root] = llvm.Value{}
+			queue = append(queue, root)
+		}
+	}
+	for len(queue) != 0 && parents[target].IsNil() {
+		symbol := queue[0]
+		queue = queue[1:]
+		if symbol == target {
+			break
+		}
+		for _, ref := range symbolReferences(symbol) {
+			if _, ok := parents[ref]; ok {
+				continue
+			}
+			parents[ref] = symbol
+			queue = append(queue, ref)
+		}
+	}
+
+	if _, ok := parents[target]; !ok {
+		fmt.Fprintf(w, "%s is not referenced from any root of the program, it will be removed by the linker\n", target.Name())
+		return nil
+	}
+
+	// Print the chain, starting at the root.
+	var chain []llvm.Value
+	for symbol := target; !symbol.IsNil(); symbol = parents[symbol] {
+		chain = append(chain, symbol)
+	}
+	fmt.Fprintf(w, "%s is included because of this chain of references:\n", target.Name())
+	for i := len(chain) - 1; i >= 0; i-- {
+		symbol := chain[i]
+		kind := "global"
+		if !symbol.IsAFunction().IsNil() {
+			kind = "func"
+		}
+		indent := strings.Repeat("  ", len(chain)-1-i)
+		fmt.Fprintf(w, "  %s%s (%s)\n", indent, symbol.Name(), kind)
+	}
+	return nil
+}
+
+// symbolReferences returns all functions and globals directly referenced by
+// the given function (in its instructions) or global (in its initializer),
+// in a stable order.
+func symbolReferences(symbol llvm.Value) []llvm.Value {
+	var refs []llvm.Value
+	seen := map[llvm.Value]struct{}{}
+	var addConstant func(value llvm.Value)
+	addConstant = func(value llvm.Value) {
+		if value.IsNil() {
+			return
+		}
+		if !value.IsAGlobalValue().IsNil() {
+			if _, ok := seen[value]; !ok {
+				seen[value] = struct{}{}
+				refs = append(refs, value)
+			}
+			return
+		}
+		if value.IsAConstant().IsNil() || value.IsAUser().IsNil() {
+			// Not a constant expression or aggregate, so it cannot contain a
+			// reference to a global.
+			return
+		}
+		if _, ok := seen[value]; ok {
+			return
+		}
+		seen[value] = struct{}{}
+		for i := 0; i < value.OperandsCount(); i++ {
+			addConstant(value.Operand(i))
+		}
+	}
+
+	if !symbol.IsAFunction().IsNil() {
+		for bb := symbol.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+				for i := 0; i < inst.OperandsCount(); i++ {
+					addConstant(inst.Operand(i))
+				}
+			}
+		}
+	} else if !symbol.IsAGlobalVariable().IsNil() {
+		addConstant(symbol.Initializer())
+	}
+	return refs
+}
+
+// Matches a method name as printed by the Go toolchain, like
+// fmt.(*pp).printValue, to convert it to the form used in TinyGo:
+// (*fmt.pp).printValue.
+var goMethodName = regexp.MustCompile(`^(.*)\.\((\*?)([^()]+)\)\.(.+)$`)
+
+// findWhySymbol looks up the function or global with the given name. Method
+// names can be specified in the form printed by the Go toolchain.
+func findWhySymbol(mod llvm.Module, name string) llvm.Value {
+	names := []string{name}
+	if m := goMethodName.FindStringSubmatch(name); m != nil {
+		names = append(names, "("+m[2]+m[1]+"."+m[3]+")."+m[4])
+	}
+	for _, name := range names {
+		if fn := mod.NamedFunction(name); !fn.IsNil() {
+			return fn
+		}
+		if global := mod.NamedGlobal(name); !global.IsNil() {
+			return global
+		}
+	}
+	return llvm.Value{}
+}
+
+// whySuggestions returns a list of similarly named symbols, to help when a
+// name was not found.
+func whySuggestions(mod llvm.Module, name string) string {
+	// Only look at the last part of the name, as package paths and receiver
+	// types can be written in different ways.
+	if index := strings.LastIndexAny(name, ".)"); index >= 0 {
+		name = name[index+1:]
+	}
+	var matches []string
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if strings.Contains(fn.Name(), name) {
+			matches = append(matches, fn.Name())
+		}
+	}
+	for global := mod.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+		if strings.Contains(global.Name(), name) {
+			matches = append(matches, global.Name())
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+	sort.Strings(matches)
+	if len(matches) > 10 {
+		matches = matches[:10]
+	}
+	return "\ndid you mean one of these?\n\t" + strings.Join(matches, "\n\t")
+}
@@ -0,0 +1,118 @@
+package builder
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// loadWhyTestModule loads the module used by the -why tests.
+func loadWhyTestModule(t *testing.T) llvm.Module {
+	ctx := llvm.NewContext()
+	buf, err := llvm.NewMemoryBufferFromFile("testdata/why.ll")
+	if err != nil {
+		t.Fatal("could not read file testdata/why.ll:", err)
+	}
+	mod, err := ctx.ParseIR(buf)
+	if err != nil {
+		t.Fatalf("could not load module:\n%v", err)
+	}
+	return mod
+}
+
+func TestFindWhySymbol(t *testing.T) {
+	t.Parallel()
+	mod := loadWhyTestModule(t)
+
+	for _, tc := range []struct {
+		name   string
+		symbol string // expected symbol, or "" if not found
+	}{
+		// Names as used by TinyGo.
+		{"main.main", "main.main"},
+		{"main.handlers", "main.handlers"},
+		{"(*fmt.pp).printValue", "(*fmt.pp).printValue"},
+
+		// Method names as printed by the Go toolchain.
+		{"fmt.(*pp).printValue", "(*fmt.pp).printValue"},
+		{"main.(T).String", "(main.T).String"},
+		{"github.com/user/lib.(*Buffer).Write", "(*github.com/user/lib.Buffer).Write"},
+
+		// Symbols that don't exist.
+		{"fmt.(*pp).printArg", ""},
+		{"main.(*T).String", ""},
+		{"main.missing", ""},
+	} {
+		symbol := findWhySymbol(mod, tc.name)
+		if symbol.IsNil() {
+			if tc.symbol != "" {
+				t.Errorf("findWhySymbol(%q): expected %s, found nothing", tc.name, tc.symbol)
+			}
+			continue
+		}
+		if symbol.Name() != tc.symbol {
+			t.Errorf("findWhySymbol(%q) = %s, expected %q", tc.name, symbol.Name(), tc.symbol)
+		}
+	}
+}
+
+func TestPrintWhy(t *testing.T) {
+	t.Parallel()
+	mod := loadWhyTestModule(t)
+
+	for _, tc := range []struct {
+		name   string
+		output string
+		err    string
+	}{
+		{
+			// The shortest chain goes through a global (the handler table),
+			// not just through calls.
+			name: "fmt.(*pp).printValue",
+			output: `(*fmt.pp).printValue is included because of this chain of references:
+  main (func)
+    main.main (func)
+      main.handlers (global)
+        main.handler (func)
+          (*fmt.pp).printValue (func)
+`,
+		},
+		{
+			name: "main.main",
+			output: `main.main is included because of this chain of references:
+  main (func)
+    main.main (func)
+`,
+		},
+		{
+			name:   "main.unusedGlobal",
+			output: "main.unusedGlobal is not referenced from any root of the program, it will be removed by the linker\n",
+		},
+		{
+			name: "fmt.printValue",
+			err:  "-why: fmt.printValue is not included in the program\ndid you mean one of these?\n\t(*fmt.pp).printValue",
+		},
+		{
+			name: "main.doesNotExist",
+			err:  "-why: main.doesNotExist is not included in the program",
+		},
+	} {
+		buf := &bytes.Buffer{}
+		err := printWhy(buf, mod, tc.name)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("printWhy(%q): expected error %q, got %v", tc.name, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("printWhy(%q): unexpected error: %v", tc.name, err)
+			continue
+		}
+		if buf.String() != tc.output {
+			t.Errorf("printWhy(%q): unexpected output:\n%s\nexpected:\n%s", tc.name, buf.String(), strings.TrimSuffix(tc.output, "\n"))
+		}
+	}
+}
@@ -42,6 +42,7 @@ type cgoPackage struct {
 	enums           map[string]enumInfo
 	anonStructNum   int
 	ldflags         []string
+	includes        map[string]struct{} // header files included by the preambles
 }
 
 // constantInfo stores some information about a CGo constant found by libclang
@@ -135,6 +136,23 @@ var builtinAliases = map[string]struct{}{
 	"ulonglong": struct{}{},
 }
 
+// builtinFuncs lists the helper functions that are provided by CGo, such as
+// C.CString, with their parameter and result types. They are implemented in
+// the runtime and declared in the generated AST when they are used. See
+// addBuiltinFuncDecls.
+//
+// See: https://golang.org/cmd/cgo/#hdr-Go_references_to_C
+var builtinFuncs = map[string]struct {
+	params  []string
+	results []string
+}{
+	"CString":   {[]string{"string"}, []string{"*C.char"}},
+	"CBytes":    {[]string{"[]byte"}, []string{"unsafe.Pointer"}},
+	"GoString":  {[]string{"*C.char"}, []string{"string"}},
+	"GoStringN": {[]string{"*C.char", "C.int"}, []string{"string"}},
+	"GoBytes":   {[]string{"unsafe.Pointer", "C.int"}, []string{"[]byte"}},
+}
+
 // cgoTypes lists some C types with ambiguous sizes that must be retrieved
 // somehow from C. This is done by adding some typedefs to get the size of each
 // type.
@@ -156,9 +174,11 @@ typedef unsigned long long  _Cgo_ulonglong;
 // Process extracts `import "C"` statements from the AST, parses the comment
 // with libclang, and modifies the AST to use this information. It returns a
 // newly created *ast.File that should be added to the list of to-be-parsed
-// files. If there is one or more error, it returns these in the []error slice
-// but still modifies the AST.
-func Process(files []*ast.File, dir string, fset *token.FileSet, cflags []string) (*ast.File, []string, []error) {
+// files, the linker flags from #cgo lines, the contents of the _cgo_export.h
+// header for the C files of this package and the list of header files that
+// were included by the preambles (for build caching). If there is one or more
+// error, it returns these in the []error slice but still modifies the AST.
+func Process(files []*ast.File, dir string, fset *token.FileSet, cflags []string) (*ast.File, []string, string, []string, []error) {
 	p := &cgoPackage{
 		dir:             dir,
 		fset:            fset,
@@ -170,6 +190,7 @@ func Process(files []*ast.File, dir string, fset *token.FileSet, cflags []string
 		typedefs:        map[string]*typedefInfo{},
 		elaboratedTypes: map[string]*elaboratedTypeInfo{},
 		enums:           map[string]enumInfo{},
+		includes:        map[string]struct{}{},
 	}
 
 	// Disable _FORTIFY_SOURCE as it causes problems on macOS.
@@ -185,7 +206,7 @@ func Process(files []*ast.File, dir string, fset *token.FileSet, cflags []string
 	// Find the absolute path for this package.
 	packagePath, err := filepath.Abs(fset.File(files[0].Pos()).Name())
 	if err != nil {
-		return nil, nil, []error{
+		return nil, nil, "", nil, []error{
 			scanner.Error{
 				Pos: fset.Position(files[0].Pos()),
 				Msg: "cgo: cannot find absolute path: " + err.Error(), // TODO: wrap this error
@@ -258,7 +279,9 @@ func Process(files []*ast.File, dir string, fset *token.FileSet, cflags []string
 
 	// Find `import "C"` statements in the file.
 	var statements []*ast.GenDecl
+	statementExports := map[*ast.GenDecl]string{}
 	for _, f := range files {
+		foundPreamble := false
 		for i := 0; i < len(f.Decls); i++ {
 			decl := f.Decls[i]
 			genDecl, ok := decl.(*ast.GenDecl)
@@ -285,6 +308,13 @@ func Process(files []*ast.File, dir string, fset *token.FileSet, cflags []string
 			// Found a CGo statement.
 			statements = append(statements, genDecl)
 
+			// Declare the functions exported from this file when parsing the
+			// (first) preamble, so that they can be used as C.name in Go.
+			if !foundPreamble && genDecl.Doc != nil {
+				statementExports[genDecl] = exportFuncDecls([]*ast.File{f})
+				foundPreamble = true
+			}
+
 			// Remove this import declaration.
 			f.Decls = append(f.Decls[:i], f.Decls[i+1:]...)
 			i--
@@ -392,12 +422,29 @@ func Process(files []*ast.File, dir string, fset *token.FileSet, cflags []string
 			pos = genDecl.Doc.Pos()
 		}
 		position := fset.PositionFor(pos, true)
-		p.parseFragment(cgoComment+cgoTypes, cflags, position.Filename, position.Line)
+		fragment := cgoComment + cgoTypes
+		if exports := statementExports[genDecl]; exports != "" {
+			fragment += "# 1 \"_cgo_export.h\"\n" + exportTypes + exports
+		}
+		p.parseFragment(fragment, cflags, position.Filename, position.Line)
 	}
 
+	// Create the _cgo_export.h header for the C files in this package. Like in
+	// the standard Go toolchain, it contains the preambles followed by the
+	// declarations of all exported functions.
+	var header strings.Builder
+	header.WriteString("/* Code generated by TinyGo. DO NOT EDIT. */\n\n#pragma once\n")
+	for _, genDecl := range statements {
+		header.WriteString("\n" + genDecl.Doc.Text())
+	}
+	header.WriteString(exportTypes + "\n" + exportFuncDecls(files))
+
 	// Declare functions found by libclang.
 	p.addFuncDecls()
 
+	// Declare the CGo helper functions (like C.CString) that are used.
+	p.addBuiltinFuncDecls()
+
 	// Declare stub function pointer values found by libclang.
 	p.addFuncPtrDecls()
 
@@ -427,7 +474,13 @@ func Process(files []*ast.File, dir string, fset *token.FileSet, cflags []string
 	// Print the newly generated in-memory AST, for debugging.
 	//ast.Print(fset, p.generated)
 
-	return p.generated, p.ldflags, p.errors
+	includes := make([]string, 0, len(p.includes))
+	for path := range p.includes {
+		includes = append(includes, path)
+	}
+	sort.Strings(includes)
+
+	return p.generated, p.ldflags, header.String(), includes, p.errors
 }
 
 // makePathsAbsolute converts some common path compiler flags (-I, -L) from
@@ -516,6 +569,83 @@ func (p *cgoPackage) addFuncDecls() {
 	}
 }
 
+// addBuiltinFuncDecls declares the CGo helper functions listed in builtinFuncs
+// that are used in this package, unless a C function with the same name exists.
+// The declarations are linked to their implementation in the runtime:
+//
+//     //export tinygo_cgo_CString
+//     func C.CString(string) *C.char
+func (p *cgoPackage) addBuiltinFuncDecls() {
+	names := make([]string, 0, len(builtinFuncs))
+	for name := range builtinFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := p.missingSymbols[name]; !ok {
+			continue // not used
+		}
+		if _, ok := p.functions[name]; ok {
+			continue // declared in C
+		}
+		fn := builtinFuncs[name]
+		params := &ast.FieldList{}
+		for _, typ := range fn.params {
+			params.List = append(params.List, &ast.Field{Type: builtinFuncType(typ)})
+		}
+		results := &ast.FieldList{}
+		for _, typ := range fn.results {
+			results.List = append(results.List, &ast.Field{Type: builtinFuncType(typ)})
+		}
+		obj := &ast.Object{
+			Kind: ast.Fun,
+			Name: "C." + name,
+		}
+		decl := &ast.FuncDecl{
+			Doc: &ast.CommentGroup{
+				List: []*ast.Comment{
+					&ast.Comment{
+						Slash: p.generatedPos,
+						Text:  "//export tinygo_cgo_" + name,
+					},
+				},
+			},
+			Name: &ast.Ident{
+				NamePos: p.generatedPos,
+				Name:    "C." + name,
+				Obj:     obj,
+			},
+			Type: &ast.FuncType{
+				Func:    p.generatedPos,
+				Params:  params,
+				Results: results,
+			},
+		}
+		obj.Decl = decl
+		p.generated.Decls = append(p.generated.Decls, decl)
+	}
+}
+
+// builtinFuncType returns the AST for a type in builtinFuncs. Only the types
+// used there are supported.
+func builtinFuncType(typ string) ast.Expr {
+	switch {
+	case strings.HasPrefix(typ, "*"):
+		return &ast.StarExpr{X: builtinFuncType(typ[1:])}
+	case strings.HasPrefix(typ, "[]"):
+		return &ast.ArrayType{Elt: builtinFuncType(typ[2:])}
+	case typ == "unsafe.Pointer":
+		return &ast.SelectorExpr{
+			X:   &ast.Ident{Name: "unsafe"},
+			Sel: &ast.Ident{Name: "Pointer"},
+		}
+	default:
+		// Regular Go types like string, and C types like C.char (which are
+		// regular identifiers in the generated AST).
+		return &ast.Ident{Name: typ}
+	}
+}
+
 // addFuncPtrDecls creates stub declarations of function pointer values. These
 // values will later be replaced with the real values in the compiler.
 // It adds code like the following to the AST:
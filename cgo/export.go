@@ -0,0 +1,209 @@
+package cgo
+
+// This file generates C declarations for Go functions exported with //export,
+// so that they can be called from C. These declarations are added to the
+// preamble when parsing it, which makes it possible to refer to them from Go
+// (for example, to pass C.callback as a function pointer to C). They are also
+// written to the _cgo_export.h header that can be included from the C files
+// of the package.
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// exportTypes contains C typedefs for Go types that are used in the
+// declarations of exported functions. They are defined using macros predefined
+// by the compiler so that no (libc) headers need to be included.
+const exportTypes = `
+typedef __INT8_TYPE__    GoInt8;
+typedef __INT16_TYPE__   GoInt16;
+typedef __INT32_TYPE__   GoInt32;
+typedef __INT64_TYPE__   GoInt64;
+typedef __UINT8_TYPE__   GoUint8;
+typedef __UINT16_TYPE__  GoUint16;
+typedef __UINT32_TYPE__  GoUint32;
+typedef __UINT64_TYPE__  GoUint64;
+typedef __UINTPTR_TYPE__ GoUintptr;
+#if __SIZEOF_POINTER__ <= 4
+typedef GoInt32          GoInt;
+typedef GoUint32         GoUint;
+#else
+typedef GoInt64          GoInt;
+typedef GoUint64         GoUint;
+#endif
+typedef float            GoFloat32;
+typedef double           GoFloat64;
+typedef _Bool            GoBool;
+`
+
+// exportGoTypes maps Go basic types to the C types defined in exportTypes.
+var exportGoTypes = map[string]string{
+	"int8":    "GoInt8",
+	"int16":   "GoInt16",
+	"int32":   "GoInt32",
+	"rune":    "GoInt32",
+	"int64":   "GoInt64",
+	"uint8":   "GoUint8",
+	"byte":    "GoUint8",
+	"uint16":  "GoUint16",
+	"uint32":  "GoUint32",
+	"uint64":  "GoUint64",
+	"uintptr": "GoUintptr",
+	"int":     "GoInt",
+	"uint":    "GoUint",
+	"float32": "GoFloat32",
+	"float64": "GoFloat64",
+	"bool":    "GoBool",
+}
+
+// exportCTypes maps the C types in builtinAliases to their name in C.
+var exportCTypes = map[string]string{
+	"char":      "char",
+	"schar":     "signed char",
+	"uchar":     "unsigned char",
+	"short":     "short",
+	"ushort":    "unsigned short",
+	"int":       "int",
+	"uint":      "unsigned int",
+	"long":      "long",
+	"ulong":     "unsigned long",
+	"longlong":  "long long",
+	"ulonglong": "unsigned long long",
+}
+
+// exportFuncDecls returns C declarations for all functions in the given files
+// that are exported with //export. Functions with a parameter or result type
+// that has no C equivalent (such as strings, slices and Go structs) are left
+// out, as they can't be called from C. The declarations use the types defined
+// in exportTypes.
+func exportFuncDecls(files []*ast.File) string {
+	var decls strings.Builder
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if !ok || decl.Recv != nil || decl.Body == nil || decl.Doc == nil {
+				continue
+			}
+			name := exportName(decl.Doc)
+			if name == "" {
+				continue
+			}
+			if declaration, ok := exportFuncDecl(name, decl.Type); ok {
+				decls.WriteString(declaration)
+			}
+		}
+	}
+	return decls.String()
+}
+
+// exportName returns the C name of a function with the given doc comment, or
+// the empty string if it is not exported.
+func exportName(doc *ast.CommentGroup) string {
+	for _, comment := range doc.List {
+		parts := strings.Fields(comment.Text)
+		if len(parts) == 2 && (parts[0] == "//export" || parts[0] == "//go:export") {
+			return parts[1]
+		}
+	}
+	return ""
+}
+
+// exportFuncDecl returns the C declaration of an exported function, like:
+//
+//     GoInt32 mul(GoInt32 a, GoInt32 b);
+//
+// It returns false if the signature can't be expressed in C.
+func exportFuncDecl(name string, fn *ast.FuncType) (string, bool) {
+	result := "void"
+	if fn.Results != nil && len(fn.Results.List) != 0 {
+		if len(fn.Results.List) != 1 || len(fn.Results.List[0].Names) > 1 {
+			// Multiple return values.
+			return "", false
+		}
+		var ok bool
+		result, ok = exportCType(fn.Results.List[0].Type)
+		if !ok {
+			return "", false
+		}
+	}
+	var params []string
+	for _, field := range fn.Params.List {
+		typ, ok := exportCType(field.Type)
+		if !ok {
+			return "", false
+		}
+		if len(field.Names) == 0 {
+			params = append(params, typ)
+		}
+		for _, paramName := range field.Names {
+			params = append(params, exportCDecl(typ, paramName.Name))
+		}
+	}
+	if len(params) == 0 {
+		params = append(params, "void")
+	}
+	return exportCDecl(result, name) + "(" + strings.Join(params, ", ") + ");\n", true
+}
+
+// exportCDecl declares name with the given C type.
+func exportCDecl(typ, name string) string {
+	if name == "_" {
+		return typ
+	}
+	if strings.HasSuffix(typ, "*") {
+		return typ + name
+	}
+	return typ + " " + name
+}
+
+// exportCType returns the C equivalent of the given Go type expression, or
+// false if there is none.
+func exportCType(expr ast.Expr) (string, bool) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		typ, ok := exportGoTypes[expr.Name]
+		return typ, ok
+	case *ast.SelectorExpr:
+		x, ok := expr.X.(*ast.Ident)
+		if !ok {
+			return "", false
+		}
+		name := expr.Sel.Name
+		switch {
+		case x.Name == "unsafe" && name == "Pointer":
+			return "void *", true
+		case x.Name != "C":
+			return "", false
+		case exportCTypes[name] != "":
+			return exportCTypes[name], true
+		case cgoAliases["C."+name] != "":
+			// Types like C.uint32_t, which may be used without including
+			// <stdint.h>.
+			return exportGoTypes[cgoAliases["C."+name]], true
+		case strings.HasPrefix(name, "struct_"):
+			return "struct " + name[len("struct_"):], true
+		case strings.HasPrefix(name, "union_"):
+			return "union " + name[len("union_"):], true
+		case strings.HasPrefix(name, "enum_"):
+			return "enum " + name[len("enum_"):], true
+		default:
+			// A typedef, like C.uint32_t or a typedef in the preamble.
+			return name, true
+		}
+	case *ast.StarExpr:
+		typ, ok := exportCType(expr.X)
+		if !ok {
+			// Pointers to Go types are opaque to C.
+			typ = "void"
+		}
+		if !strings.HasSuffix(typ, "*") {
+			typ += " "
+		}
+		return typ + "*", true
+	case *ast.ParenExpr:
+		return exportCType(expr.X)
+	default:
+		return "", false
+	}
+}
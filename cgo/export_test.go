@@ -0,0 +1,43 @@
+package cgo
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestExportFuncDecls(t *testing.T) {
+	// Test the C declarations of functions exported from Go.
+	for _, tc := range []struct {
+		Go string
+		C  string
+	}{
+		{`func add(a, b int32) int32`, "GoInt32 add(GoInt32 a, GoInt32 b);\n"},
+		{`func mul(a, b C.int) C.int`, "int mul(int a, int b);\n"},
+		{`func noargs()`, "void noargs(void);\n"},
+		{`func unnamed(int, uintptr) bool`, "GoBool unnamed(GoInt, GoUintptr);\n"},
+		{`func ignored(_ C.ulonglong) C.uint32_t`, "GoUint32 ignored(unsigned long long);\n"},
+		{`func ptr(s *C.char, p unsafe.Pointer) **C.struct_foo`, "struct foo **ptr(char *s, void *p);\n"},
+		{`func gotype(p *goStruct, t C.mytype_t) C.enum_color`, "enum color gotype(void *p, mytype_t t);\n"},
+		{`func str(s string)`, ""},
+		{`func slice() []byte`, ""},
+		{`func multi() (int, error)`, ""},
+		{`func named() (a, b int)`, ""},
+	} {
+		// Export the function with its Go name.
+		name := tc.Go[len("func "):strings.IndexByte(tc.Go, '(')]
+		src := "package main\n\n//export " + name + "\n" + tc.Go + " {\n}\n"
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+		if err != nil {
+			t.Errorf("could not parse %#v: %v", tc.Go, err)
+			continue
+		}
+		decls := exportFuncDecls([]*ast.File{f})
+		if decls != tc.C {
+			t.Errorf("expected declaration %#v for %#v but got %#v", tc.C, tc.Go, decls)
+		}
+	}
+}
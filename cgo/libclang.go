@@ -56,6 +56,7 @@ unsigned tinygo_clang_Cursor_isBitField(GoCXCursor c);
 int tinygo_clang_globals_visitor(GoCXCursor c, GoCXCursor parent, CXClientData client_data);
 int tinygo_clang_struct_visitor(GoCXCursor c, GoCXCursor parent, CXClientData client_data);
 int tinygo_clang_enum_visitor(GoCXCursor c, GoCXCursor parent, CXClientData client_data);
+void tinygo_clang_inclusion_visitor(CXFile included_file, CXSourceLocation *inclusion_stack, unsigned include_len, CXClientData client_data);
 */
 import "C"
 
@@ -138,6 +139,21 @@ func (p *cgoPackage) parseFragment(fragment string, cflags []string, posFilename
 	defer storedRefs.Remove(ref)
 	cursor := C.tinygo_clang_getTranslationUnitCursor(unit)
 	C.tinygo_clang_visitChildren(cursor, C.CXCursorVisitor(C.tinygo_clang_globals_visitor), C.CXClientData(ref))
+
+	// Remember which header files were included, so that the package is
+	// rebuilt when one of them changes.
+	C.clang_getInclusions(unit, C.CXInclusionVisitor(C.tinygo_clang_inclusion_visitor), C.CXClientData(ref))
+}
+
+//export tinygo_clang_inclusion_visitor
+func tinygo_clang_inclusion_visitor(includedFile C.CXFile, inclusionStack *C.CXSourceLocation, includeLen C.uint, client_data C.CXClientData) {
+	if includeLen == 0 {
+		// This is the fragment itself, which is not a real file.
+		return
+	}
+	p := storedRefs.Get(unsafe.Pointer(client_data)).(*cgoPackage)
+	path := getString(C.clang_getFileName(includedFile))
+	p.includes[path] = struct{}{}
 }
 
 //export tinygo_clang_globals_visitor
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/tinygo-org/tinygo/goenv"
@@ -65,6 +66,21 @@ func (c *Config) BuildTags() []string {
 	return tags
 }
 
+// CommandLineArgs returns whether programs built for this target receive the
+// command line arguments they're started with. This is not the case for
+// bare-metal systems and for the JavaScript emulator of WebAssembly.
+func (c *Config) CommandLineArgs() bool {
+	if c.GOOS() == "js" {
+		return false
+	}
+	for _, tag := range c.Target.BuildTags {
+		if tag == "baremetal" || tag == "nintendoswitch" {
+			return false
+		}
+	}
+	return true
+}
+
 // CgoEnabled returns true if (and only if) CGo is enabled. It is true by
 // default and false if CGO_ENABLED is set to "0".
 func (c *Config) CgoEnabled() bool {
@@ -72,7 +88,7 @@ func (c *Config) CgoEnabled() bool {
 }
 
 // GC returns the garbage collection strategy in use on this platform. Valid
-// values are "none", "leaking", "extalloc", and "conservative".
+// values are "none", "leaking", "extalloc", "conservative", and "precise".
 func (c *Config) GC() string {
 	if c.Options.GC != "" {
 		return c.Options.GC
@@ -88,11 +104,27 @@ func (c *Config) GC() string {
 	return "extalloc"
 }
 
+// FramePointers returns whether all functions should keep a frame pointer when
+// the program is profiled. This is needed by the profiler in runtime/pprof to
+// walk the stack, which is only supported on hosted linux/amd64 and
+// linux/arm64 systems.
+func (c *Config) FramePointers() bool {
+	if c.GOOS() != "linux" || (c.GOARCH() != "amd64" && c.GOARCH() != "arm64") {
+		return false
+	}
+	for _, tag := range c.BuildTags() {
+		if tag == "baremetal" || tag == "nintendoswitch" {
+			return false
+		}
+	}
+	return true
+}
+
 // NeedsStackObjects returns true if the compiler should insert stack objects
 // that can be traced by the garbage collector.
 func (c *Config) NeedsStackObjects() bool {
 	switch c.GC() {
-	case "conservative", "extalloc":
+	case "conservative", "precise", "extalloc":
 		for _, tag := range c.BuildTags() {
 			if tag == "wasm" {
 				return true
@@ -213,6 +245,17 @@ func (c *Config) VerifyIR() bool {
 	return c.Options.VerifyIR
 }
 
+// PrintAllocs returns the regular expression of the -print-allocs flag, which
+// selects the packages for which heap allocations are printed. It returns nil
+// if the flag is not set.
+func (c *Config) PrintAllocs() *regexp.Regexp {
+	if c.Options.PrintAllocs == "" {
+		return nil
+	}
+	// The regular expression has already been checked in Options.Verify.
+	return regexp.MustCompile(c.Options.PrintAllocs)
+}
+
 // Debug returns whether to add debug symbols to the IR, for debugging with GDB
 // and similar.
 func (c *Config) Debug() bool {
@@ -323,5 +366,35 @@ func (c *Config) WasmAbi() string {
 
 type TestConfig struct {
 	CompileTestBinary bool
-	// TODO: Filter the test functions to run, include verbose flag, etc
+	Verbose           bool
+	Short             bool
+	RunRegexp         string
+	Count             int
+	BenchRegexp       string
+	BenchTime         string
+}
+
+// Flags returns the flags to pass to the test binary, in the form understood by
+// the testing package (-test.v etc).
+func (c TestConfig) Flags() []string {
+	var flags []string
+	if c.Verbose {
+		flags = append(flags, "-test.v")
+	}
+	if c.Short {
+		flags = append(flags, "-test.short")
+	}
+	if c.RunRegexp != "" {
+		flags = append(flags, "-test.run="+c.RunRegexp)
+	}
+	if c.Count != 0 {
+		flags = append(flags, "-test.count="+strconv.Itoa(c.Count))
+	}
+	if c.BenchRegexp != "" {
+		flags = append(flags, "-test.bench="+c.BenchRegexp)
+	}
+	if c.BenchTime != "" {
+		flags = append(flags, "-test.benchtime="+c.BenchTime)
+	}
+	return flags
 }
@@ -0,0 +1,49 @@
+package compileopts
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTestConfigFlags(t *testing.T) {
+	tests := []struct {
+		config TestConfig
+		flags  []string
+	}{
+		{TestConfig{}, nil},
+		{TestConfig{Verbose: true}, []string{"-test.v"}},
+		{
+			TestConfig{Short: true, RunRegexp: "TestFoo/bar", Count: 3},
+			[]string{"-test.short", "-test.run=TestFoo/bar", "-test.count=3"},
+		},
+		{
+			TestConfig{BenchRegexp: ".", BenchTime: "100x"},
+			[]string{"-test.bench=.", "-test.benchtime=100x"},
+		},
+	}
+	for _, tc := range tests {
+		flags := tc.config.Flags()
+		if !reflect.DeepEqual(flags, tc.flags) {
+			t.Errorf("%+v: expected flags %q, got %q", tc.config, tc.flags, flags)
+		}
+	}
+}
+
+func TestCommandLineArgs(t *testing.T) {
+	tests := []struct {
+		target TargetSpec
+		args   bool
+	}{
+		{TargetSpec{GOOS: "linux", BuildTags: []string{"linux", "amd64"}}, true},
+		{TargetSpec{GOOS: "linux", BuildTags: []string{"wasm", "wasi"}}, true},
+		{TargetSpec{GOOS: "js", BuildTags: []string{"wasm"}}, false},
+		{TargetSpec{GOOS: "linux", BuildTags: []string{"cortexm", "baremetal"}}, false},
+		{TargetSpec{GOOS: "linux", BuildTags: []string{"nintendoswitch", "arm64"}}, false},
+	}
+	for _, tc := range tests {
+		config := &Config{Target: &tc.target}
+		if args := config.CommandLineArgs(); args != tc.args {
+			t.Errorf("%v: expected CommandLineArgs() to be %v, got %v", tc.target.BuildTags, tc.args, args)
+		}
+	}
+}
@@ -2,37 +2,43 @@ package compileopts
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 )
 
 var (
-	validGCOptions            = []string{"none", "leaking", "extalloc", "conservative"}
+	validGCOptions            = []string{"none", "leaking", "extalloc", "conservative", "precise"}
 	validSchedulerOptions     = []string{"none", "tasks", "coroutines"}
-	validPrintSizeOptions     = []string{"none", "short", "full"}
+	validPrintSizeOptions     = []string{"none", "short", "full", "symbols"}
+	validPrintSizeFormats     = []string{"text", "json"}
 	validPanicStrategyOptions = []string{"print", "trap"}
 )
 
 // Options contains extra options to give to the compiler. These options are
 // usually passed from the command line.
 type Options struct {
-	Target        string
-	Opt           string
-	GC            string
-	PanicStrategy string
-	Scheduler     string
-	PrintIR       bool
-	DumpSSA       bool
-	VerifyIR      bool
-	PrintCommands bool
-	Debug         bool
-	PrintSizes    string
-	PrintStacks   bool
-	CFlags        []string
-	LDFlags       []string
-	Tags          string
-	WasmAbi       string
-	TestConfig    TestConfig
-	Programmer    string
+	Target           string
+	Opt              string
+	GC               string
+	PanicStrategy    string
+	Scheduler        string
+	PrintIR          bool
+	DumpSSA          bool
+	VerifyIR         bool
+	PrintCommands    bool
+	Debug            bool
+	PrintSizes       string
+	PrintSizesFormat string
+	PrintStacks      bool
+	PrintWhy         string
+	PrintAllocs      string
+	EmbedDir         string
+	CFlags           []string
+	LDFlags          []string
+	Tags             string
+	WasmAbi          string
+	TestConfig       TestConfig
+	Programmer       string
 }
 
 // Verify performs a validation on the given options, raising an error if options are not valid.
@@ -64,6 +70,15 @@ func (o *Options) Verify() error {
 		}
 	}
 
+	if o.PrintSizesFormat != "" {
+		valid := isInArray(validPrintSizeFormats, o.PrintSizesFormat)
+		if !valid {
+			return fmt.Errorf(`invalid size format '%s': valid values are %s`,
+				o.PrintSizesFormat,
+				strings.Join(validPrintSizeFormats, ", "))
+		}
+	}
+
 	if o.PanicStrategy != "" {
 		valid := isInArray(validPanicStrategyOptions, o.PanicStrategy)
 		if !valid {
@@ -73,9 +88,36 @@ func (o *Options) Verify() error {
 		}
 	}
 
+	if o.PrintAllocs != "" {
+		if _, err := regexp.Compile(o.PrintAllocs); err != nil {
+			return fmt.Errorf(`invalid print-allocs option '%s': %v`, o.PrintAllocs, err)
+		}
+	}
+
+	if o.EmbedDir != "" {
+		if _, _, err := o.SplitEmbedDir(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// SplitEmbedDir splits the -embed-dir option, in the form dir:/prefix/, into
+// the directory to embed and the path where it is mounted in the os package.
+func (o *Options) SplitEmbedDir() (dir, prefix string, err error) {
+	// Split at the last colon, so that Windows paths like C:\assets work.
+	index := strings.LastIndexByte(o.EmbedDir, ':')
+	if index < 0 {
+		return "", "", fmt.Errorf(`invalid embed-dir option '%s': expected dir:/prefix/`, o.EmbedDir)
+	}
+	dir, prefix = o.EmbedDir[:index], o.EmbedDir[index+1:]
+	if dir == "" || len(prefix) < 1 || prefix[0] != '/' || prefix[len(prefix)-1] != '/' {
+		return "", "", fmt.Errorf(`invalid embed-dir option '%s': expected dir:/prefix/`, o.EmbedDir)
+	}
+	return dir, prefix, nil
+}
+
 func isInArray(arr []string, item string) bool {
 	for _, i := range arr {
 		if i == item {
@@ -9,10 +9,14 @@ import (
 
 func TestVerifyOptions(t *testing.T) {
 
-	expectedGCError := errors.New(`invalid gc option 'incorrect': valid values are none, leaking, extalloc, conservative`)
+	expectedGCError := errors.New(`invalid gc option 'incorrect': valid values are none, leaking, extalloc, conservative, precise`)
 	expectedSchedulerError := errors.New(`invalid scheduler option 'incorrect': valid values are none, tasks, coroutines`)
-	expectedPrintSizeError := errors.New(`invalid size option 'incorrect': valid values are none, short, full`)
+	expectedPrintSizeError := errors.New(`invalid size option 'incorrect': valid values are none, short, full, symbols`)
+	expectedPrintSizeFormatError := errors.New(`invalid size format 'incorrect': valid values are text, json`)
 	expectedPanicStrategyError := errors.New(`invalid panic option 'incorrect': valid values are print, trap`)
+	expectedEmbedDirError := errors.New(`invalid embed-dir option 'assets': expected dir:/prefix/`)
+	expectedEmbedDirPrefixError := errors.New(`invalid embed-dir option 'assets:static': expected dir:/prefix/`)
+	expectedPrintAllocsError := errors.New("invalid print-allocs option 'main(': error parsing regexp: missing closing ): `main(`")
 
 	testCases := []struct {
 		name          string
@@ -104,6 +108,26 @@ func TestVerifyOptions(t *testing.T) {
 				PrintSizes: "full",
 			},
 		},
+		{
+			name: "PrintSizeOptionSymbols",
+			opts: compileopts.Options{
+				PrintSizes: "symbols",
+			},
+		},
+		{
+			name: "InvalidPrintSizeFormat",
+			opts: compileopts.Options{
+				PrintSizesFormat: "incorrect",
+			},
+			expectedError: expectedPrintSizeFormatError,
+		},
+		{
+			name: "PrintSizeFormatJSON",
+			opts: compileopts.Options{
+				PrintSizes:       "symbols",
+				PrintSizesFormat: "json",
+			},
+		},
 		{
 			name: "InvalidPanicOption",
 			opts: compileopts.Options{
@@ -123,6 +147,39 @@ func TestVerifyOptions(t *testing.T) {
 				PanicStrategy: "trap",
 			},
 		},
+		{
+			name: "InvalidEmbedDir",
+			opts: compileopts.Options{
+				EmbedDir: "assets",
+			},
+			expectedError: expectedEmbedDirError,
+		},
+		{
+			name: "InvalidEmbedDirPrefix",
+			opts: compileopts.Options{
+				EmbedDir: "assets:static",
+			},
+			expectedError: expectedEmbedDirPrefixError,
+		},
+		{
+			name: "EmbedDir",
+			opts: compileopts.Options{
+				EmbedDir: "assets:/static/",
+			},
+		},
+		{
+			name: "InvalidPrintAllocs",
+			opts: compileopts.Options{
+				PrintAllocs: "main(",
+			},
+			expectedError: expectedPrintAllocsError,
+		},
+		{
+			name: "PrintAllocs",
+			opts: compileopts.Options{
+				PrintAllocs: "^main$",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -214,15 +214,18 @@ func (b *builder) createRuntimeAssert(assert llvm.Value, blockPrefix, assertFunc
 
 	faultBlock := b.ctx.AddBasicBlock(b.llvmFn, blockPrefix+".throw")
 	nextBlock := b.ctx.AddBasicBlock(b.llvmFn, blockPrefix+".next")
-	b.blockExits[b.currentBlock] = nextBlock // adjust outgoing block for phi nodes
 
 	// Now branch to the out-of-bounds or the regular block.
 	b.CreateCondBr(assert, faultBlock, nextBlock)
 
 	// Fail: the assert triggered so panic.
 	b.SetInsertPointAtEnd(faultBlock)
+	if b.hasDeferFrame() {
+		b.createInvokeCheckpoint()
+	}
 	b.createRuntimeCall(assertFunc, nil, "")
 	b.CreateUnreachable()
+	b.blockExits[b.currentBlock] = nextBlock // adjust outgoing block for phi nodes
 
 	// Ok: assert didn't trigger so continue normally.
 	b.SetInsertPointAtEnd(nextBlock)
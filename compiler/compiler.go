@@ -53,6 +53,7 @@ type Config struct {
 	AutomaticStackSize bool
 	DefaultStackSize   uint64
 	NeedsStackObjects  bool
+	PanicStrategy      string
 	Debug              bool // Whether to emit debug information in the LLVM module.
 }
 
@@ -134,6 +135,8 @@ type builder struct {
 	phis              []phiNode
 	taskHandle        llvm.Value
 	deferPtr          llvm.Value
+	deferFrame        llvm.Value
+	landingpad        llvm.BasicBlock
 	difunc            llvm.Metadata
 	dilocals          map[*types.Var]llvm.Metadata
 	allDeferFuncs     []interface{}
@@ -241,21 +244,21 @@ func Sizes(machine llvm.TargetMachine) types.Sizes {
 	}
 }
 
-// CompileProgram compiles the given package path or .go file path. Return an
-// error when this fails (in any stage). If successful it returns the LLVM
-// module. If not, one or more errors will be returned.
-func CompileProgram(lprogram *loader.Program, machine llvm.TargetMachine, config *Config, dumpSSA bool) (llvm.Module, []error) {
-	c := newCompilerContext("", machine, config, dumpSSA)
-
-	c.program = lprogram.LoadSSA()
-	c.program.Build()
+// CompilePackage compiles a single package to a LLVM module. The SSA of the
+// package is built as needed. It is safe to call CompilePackage from multiple
+// goroutines at the same time for different packages of the same program.
+//
+// Functions and globals that are only referenced from within the program are
+// marked with hidden visibility (or linkonce_odr linkage for synthetic
+// functions) so that they can be internalized after all packages have been
+// linked together.
+func CompilePackage(moduleName string, pkg *loader.Package, ssaPkg *ssa.Package, machine llvm.TargetMachine, config *Config, dumpSSA bool) (llvm.Module, []error) {
+	c := newCompilerContext(moduleName, machine, config, dumpSSA)
+	c.program = ssaPkg.Prog
 	c.runtimePkg = c.program.ImportedPackage("runtime").Pkg
 
-	// Run a simple dead code elimination pass.
-	functions, err := c.simpleDCE(lprogram)
-	if err != nil {
-		return llvm.Module{}, []error{err}
-	}
+	// Build SSA from AST.
+	ssaPkg.Build()
 
 	// Initialize debug information.
 	if c.Debug {
@@ -268,62 +271,31 @@ func CompileProgram(lprogram *loader.Program, machine llvm.TargetMachine, config
 		})
 	}
 
-	c.loadASTComments(lprogram)
+	// Load comments such as //go:extern on globals.
+	c.loadASTComments(pkg)
 
 	// Predeclare the runtime.alloc function, which is used by the wordpack
 	// functionality.
 	c.getFunction(c.program.ImportedPackage("runtime").Members["alloc"].(*ssa.Function))
 
-	// Add definitions to declarations.
-	var initFuncs []llvm.Value
+	// Compile all functions, methods, and global variables in this package.
 	irbuilder := c.ctx.NewBuilder()
 	defer irbuilder.Dispose()
-	for _, f := range functions {
-		if f.Synthetic == "package initializer" {
-			initFuncs = append(initFuncs, c.getFunction(f))
-		}
-		if f.Blocks == nil {
-			continue // external function
-		}
-
-		// Create the function definition.
-		b := newBuilder(c, irbuilder, f)
-		b.createFunction()
-	}
-
-	// After all packages are imported, add a synthetic initializer function
-	// that calls the initializer of each package.
-	initFn := c.program.ImportedPackage("runtime").Members["initAll"].(*ssa.Function)
-	llvmInitFn := c.getFunction(initFn)
-	llvmInitFn.SetLinkage(llvm.InternalLinkage)
-	llvmInitFn.SetUnnamedAddr(true)
-	if c.Debug {
-		difunc := c.attachDebugInfo(initFn)
-		pos := c.program.Fset.Position(initFn.Pos())
-		irbuilder.SetCurrentDebugLocation(uint(pos.Line), uint(pos.Column), difunc, llvm.Metadata{})
-	}
-	llvmInitFn.Param(0).SetName("context")
-	llvmInitFn.Param(1).SetName("parentHandle")
-	block := c.ctx.AddBasicBlock(llvmInitFn, "entry")
-	irbuilder.SetInsertPointAtEnd(block)
-	for _, fn := range initFuncs {
-		irbuilder.CreateCall(fn, []llvm.Value{llvm.Undef(c.i8ptrType), llvm.Undef(c.i8ptrType)}, "")
-	}
-	irbuilder.CreateRetVoid()
+	c.createPackage(irbuilder, ssaPkg)
 
 	// see: https://reviews.llvm.org/D18355
 	if c.Debug {
 		c.mod.AddNamedMetadataOperand("llvm.module.flags",
 			c.ctx.MDNode([]llvm.Metadata{
 				llvm.ConstInt(c.ctx.Int32Type(), 1, false).ConstantAsMetadata(), // Error on mismatch
-				llvm.GlobalContext().MDString("Debug Info Version"),
+				c.ctx.MDString("Debug Info Version"),
 				llvm.ConstInt(c.ctx.Int32Type(), 3, false).ConstantAsMetadata(), // DWARF version
 			}),
 		)
 		c.mod.AddNamedMetadataOperand("llvm.module.flags",
 			c.ctx.MDNode([]llvm.Metadata{
 				llvm.ConstInt(c.ctx.Int32Type(), 1, false).ConstantAsMetadata(),
-				llvm.GlobalContext().MDString("Dwarf Version"),
+				c.ctx.MDString("Dwarf Version"),
 				llvm.ConstInt(c.ctx.Int32Type(), 4, false).ConstantAsMetadata(),
 			}),
 		)
@@ -333,25 +305,21 @@ func CompileProgram(lprogram *loader.Program, machine llvm.TargetMachine, config
 	return c.mod, c.diagnostics
 }
 
-// CompilePackage compiles a single package to a LLVM module.
-func CompilePackage(moduleName string, pkg *loader.Package, machine llvm.TargetMachine, config *Config, dumpSSA bool) (llvm.Module, []error) {
-	c := newCompilerContext(moduleName, machine, config, dumpSSA)
-
-	// Build SSA from AST.
-	ssaPkg := pkg.LoadSSA()
-	ssaPkg.Build()
-
+// createPackage builds the IR of all functions, methods and global variables
+// defined in the given package. Synthetic functions (wrappers, thunks, etc.)
+// are not created here but by getFunction when they are first referenced.
+func (c *compilerContext) createPackage(irbuilder llvm.Builder, pkg *ssa.Package) {
 	// Sort by position, so that the order of the functions in the IR matches
 	// the order of functions in the source file. This is useful for testing,
 	// for example.
 	var members []string
-	for name := range ssaPkg.Members {
+	for name := range pkg.Members {
 		members = append(members, name)
 	}
 	sort.Slice(members, func(i, j int) bool {
-		iPos := ssaPkg.Members[members[i]].Pos()
-		jPos := ssaPkg.Members[members[j]].Pos()
-		if i == j {
+		iPos := pkg.Members[members[i]].Pos()
+		jPos := pkg.Members[members[j]].Pos()
+		if iPos == jPos {
 			// Cannot sort by pos, so do it by name.
 			return members[i] < members[j]
 		}
@@ -359,10 +327,8 @@ func CompilePackage(moduleName string, pkg *loader.Package, machine llvm.TargetM
 	})
 
 	// Define all functions.
-	irbuilder := c.ctx.NewBuilder()
-	defer irbuilder.Dispose()
 	for _, name := range members {
-		member := ssaPkg.Members[name]
+		member := pkg.Members[name]
 		switch member := member.(type) {
 		case *ssa.Function:
 			if member.Blocks == nil {
@@ -371,10 +337,49 @@ func CompilePackage(moduleName string, pkg *loader.Package, machine llvm.TargetM
 			// Create the function definition.
 			b := newBuilder(c, irbuilder, member)
 			b.createFunction()
+		case *ssa.Type:
+			if types.IsInterface(member.Type()) {
+				// Interfaces don't have concrete methods.
+				continue
+			}
+
+			// Named type. We should make sure all methods are created.
+			// This includes both functions with pointer receivers and those
+			// without.
+			methods := getAllMethods(pkg.Prog, member.Type())
+			methods = append(methods, getAllMethods(pkg.Prog, types.NewPointer(member.Type()))...)
+			for _, method := range methods {
+				fn := pkg.Prog.MethodValue(method)
+				if fn == nil || fn.Synthetic != "" || fn.Pkg != pkg {
+					// This is a wrapper function (created by the ssa package,
+					// not appearing in the source code) or a method of a type
+					// alias for a type in another package. Wrappers are
+					// created by getFunction as needed.
+					continue
+				}
+				if fn.Blocks == nil {
+					continue // external function
+				}
+				if !c.getFunction(fn).IsDeclaration() {
+					// Value receiver methods are listed both in the method set
+					// of T and of *T.
+					continue
+				}
+				// Create the function definition.
+				b := newBuilder(c, irbuilder, fn)
+				b.createFunction()
+			}
+		case *ssa.Global:
+			// Global variable.
+			info := c.getGlobalInfo(member)
+			global := c.getGlobal(member)
+			if !info.extern {
+				global.SetInitializer(llvm.ConstNull(global.Type().ElementType()))
+				global.SetVisibility(llvm.HiddenVisibility)
+				c.createGlobalDebugInfo(member, global, info)
+			}
 		}
 	}
-
-	return c.mod, nil
 }
 
 // getLLVMRuntimeType obtains a named type from the runtime package and returns
@@ -776,7 +781,10 @@ func (b *builder) createFunction() {
 		return
 	}
 	if !b.info.exported {
-		b.llvmFn.SetLinkage(llvm.InternalLinkage)
+		// Do not make this function internal yet: it may be referenced from
+		// other packages. It will be internalized after all packages have
+		// been linked together.
+		b.llvmFn.SetVisibility(llvm.HiddenVisibility)
 		b.llvmFn.SetUnnamedAddr(true)
 	}
 
@@ -937,6 +945,12 @@ func (b *builder) createFunction() {
 		}
 	}
 
+	if b.hasDeferFrame() {
+		// Create the landing pad, where control is transferred to when a
+		// panic unwinds the stack.
+		b.createLandingPad()
+	}
+
 	if b.NeedsStackObjects {
 		// Track phi nodes.
 		for _, phi := range b.phis {
@@ -948,6 +962,14 @@ func (b *builder) createFunction() {
 			b.trackValue(phi.llvm)
 		}
 	}
+
+	// Create anonymous functions (closures etc.). They can only be referenced
+	// from within this function, so they can be internal right away.
+	for _, sub := range b.fn.AnonFuncs {
+		b := newBuilder(b.compilerContext, b.Builder, sub)
+		b.createFunction()
+		b.llvmFn.SetLinkage(llvm.InternalLinkage)
+	}
 }
 
 // createInstruction builds the LLVM IR equivalent instructions for the
@@ -1043,9 +1065,17 @@ func (b *builder) createInstruction(instr ssa.Instruction) {
 		b.createMapUpdate(mapType.Key(), m, key, value, instr.Pos())
 	case *ssa.Panic:
 		value := b.getValue(instr.X)
+		if b.hasDeferFrame() {
+			b.createInvokeCheckpoint()
+		}
 		b.createRuntimeCall("_panic", []llvm.Value{value}, "")
 		b.CreateUnreachable()
 	case *ssa.Return:
+		if b.hasDeferFrame() {
+			// Pop the defer frame. This continues the panic if this function
+			// was panicking and none of the deferred calls recovered.
+			b.createRuntimeCall("destroyDeferFrame", []llvm.Value{b.deferFrame}, "")
+		}
 		if len(instr.Results) == 0 {
 			b.CreateRetVoid()
 		} else if len(instr.Results) == 1 {
@@ -1093,7 +1123,8 @@ func (b *builder) createBuiltin(argTypes []types.Type, argValues []llvm.Value, c
 		elemsLen := b.CreateExtractValue(elems, 1, "append.elemsLen")
 		elemType := srcBuf.Type().ElementType()
 		elemSize := llvm.ConstInt(b.uintptrType, b.targetData.TypeAllocSize(elemType), false)
-		result := b.createRuntimeCall("sliceAppend", []llvm.Value{srcPtr, elemsPtr, srcLen, srcCap, elemsLen, elemSize}, "append.new")
+		elemLayout := llvmutil.CreateObjectLayout(b.mod, elemType)
+		result := b.createRuntimeCall("sliceAppend", []llvm.Value{srcPtr, elemsPtr, srcLen, srcCap, elemsLen, elemSize, elemLayout}, "append.new")
 		newPtr := b.CreateExtractValue(result, 0, "append.newPtr")
 		newBuf := b.CreateBitCast(newPtr, srcBuf.Type(), "append.newBuf")
 		newLen := b.CreateExtractValue(result, 1, "append.newLen")
@@ -1234,7 +1265,15 @@ func (b *builder) createBuiltin(argTypes []types.Type, argValues []llvm.Value, c
 		cplx := argValues[0]
 		return b.CreateExtractValue(cplx, 0, "real"), nil
 	case "recover":
-		return b.createRuntimeCall("_recover", nil, ""), nil
+		// When this function has a defer frame itself, recover() has to look
+		// at the frame of the function that is running the deferred calls.
+		useParentFrame := uint64(0)
+		if b.hasDeferFrame() {
+			useParentFrame = 1
+		}
+		return b.createRuntimeCall("_recover", []llvm.Value{
+			llvm.ConstInt(b.ctx.Int1Type(), useParentFrame, false),
+		}, ""), nil
 	case "ssa:wrapnilchk":
 		// TODO: do an actual nil check?
 		return argValues[0], nil
@@ -1251,6 +1290,9 @@ func (b *builder) createBuiltin(argTypes []types.Type, argValues []llvm.Value, c
 func (b *builder) createFunctionCall(instr *ssa.CallCommon) (llvm.Value, error) {
 	if instr.IsInvoke() {
 		fnCast, args := b.getInvokeCall(instr)
+		if b.hasDeferFrame() {
+			b.createInvokeCheckpoint()
+		}
 		return b.createCall(fnCast, args, ""), nil
 	}
 
@@ -1292,6 +1334,12 @@ func (b *builder) createFunctionCall(instr *ssa.CallCommon) (llvm.Value, error)
 			// probably something else. Continue as usual.
 		case name == "runtime/interrupt.New":
 			return b.createInterruptGlobal(instr)
+		case name == "runtime.supportsRecover":
+			supportsRecover := uint64(0)
+			if b.supportsRecover() {
+				supportsRecover = 1
+			}
+			return llvm.ConstInt(b.ctx.Int1Type(), supportsRecover, false), nil
 		}
 
 		callee = b.getFunction(fn)
@@ -1344,6 +1392,9 @@ func (b *builder) createFunctionCall(instr *ssa.CallCommon) (llvm.Value, error)
 		params = append(params, llvm.Undef(b.i8ptrType))
 	}
 
+	if b.hasDeferFrame() {
+		b.createInvokeCheckpoint()
+	}
 	return b.createCall(callee, params, ""), nil
 }
 
@@ -1397,7 +1448,8 @@ func (b *builder) createExpr(expr ssa.Value) (llvm.Value, error) {
 				return llvm.Value{}, b.makeError(expr.Pos(), fmt.Sprintf("value is too big (%v bytes)", size))
 			}
 			sizeValue := llvm.ConstInt(b.uintptrType, size, false)
-			buf := b.createRuntimeCall("alloc", []llvm.Value{sizeValue}, expr.Comment)
+			layoutValue := llvmutil.CreateObjectLayout(b.mod, typ)
+			buf := b.createRuntimeCall("alloc", []llvm.Value{sizeValue, layoutValue}, expr.Comment)
 			buf = b.CreateBitCast(buf, llvm.PointerType(typ, 0), "")
 			return buf, nil
 		} else {
@@ -1610,7 +1662,8 @@ func (b *builder) createExpr(expr ssa.Value) (llvm.Value, error) {
 			return llvm.Value{}, err
 		}
 		sliceSize := b.CreateBinOp(llvm.Mul, elemSizeValue, sliceCapCast, "makeslice.cap")
-		slicePtr := b.createRuntimeCall("alloc", []llvm.Value{sliceSize}, "makeslice.buf")
+		layoutValue := llvmutil.CreateObjectLayout(b.mod, llvmElemType)
+		slicePtr := b.createRuntimeCall("alloc", []llvm.Value{sliceSize, layoutValue}, "makeslice.buf")
 		slicePtr = b.CreateBitCast(slicePtr, llvm.PointerType(llvmElemType, 0), "makeslice.array")
 
 		// Extend or truncate if necessary. This is safe as we've already done
@@ -12,9 +12,28 @@ package compiler
 //   * On return, runtime.rundefers is called which calls all deferred functions
 //     from the head of the linked list until it has gone through all defer
 //     frames.
+//
+// On targets that support it, a panic unwinds the stack and runs the deferred
+// functions. This works as follows:
+//   * At the start of a function with defer statements, a runtime.deferFrame
+//     struct is allocated on the stack and pushed on the linked list of defer
+//     frames of the current goroutine (runtime.setupDeferFrame).
+//   * Before each call that may panic, a checkpoint (similar to setjmp) stores
+//     the program counter in the defer frame. All registers are clobbered by
+//     the checkpoint, so that only the stack pointer needs to be restored.
+//   * A panic jumps to the most recent checkpoint of the innermost defer frame
+//     (tinygo_longjmp), which continues at the landing pad. The landing pad
+//     runs all deferred calls and then returns from the function through the
+//     ssa.Function.Recover block.
+//   * Just before returning, the defer frame is popped again
+//     (runtime.destroyDeferFrame). If the function is still panicking at that
+//     point, because no deferred call recovered, the panic continues in the
+//     parent function.
 
 import (
 	"go/types"
+	"strconv"
+	"strings"
 
 	"github.com/tinygo-org/tinygo/compiler/llvmutil"
 	"golang.org/x/tools/go/ssa"
@@ -36,6 +55,202 @@ func (b *builder) deferInitFunc() {
 	deferType := llvm.PointerType(b.getLLVMRuntimeType("_defer"), 0)
 	b.deferPtr = b.CreateAlloca(deferType, "deferPtr")
 	b.CreateStore(llvm.ConstPointerNull(deferType), b.deferPtr)
+
+	if b.hasDeferFrame() {
+		// Set up the defer frame with the current stack pointer. This assumes
+		// that the stack pointer doesn't change after the function prologue.
+		b.deferFrame = b.CreateAlloca(b.getLLVMRuntimeType("deferFrame"), "deferframe.buf")
+		stackPointer := b.readStackPointer()
+		b.createRuntimeCall("setupDeferFrame", []llvm.Value{b.deferFrame, stackPointer}, "")
+
+		// Create the landing pad block, which is where control transfers
+		// after a panic. Also create an initial checkpoint, so that the defer
+		// frame is valid right away.
+		b.landingpad = b.ctx.AddBasicBlock(b.llvmFn, "lpad")
+		b.createInvokeCheckpoint()
+		b.blockEntries[b.fn.Blocks[0]] = b.GetInsertBlock()
+		b.blockExits[b.fn.Blocks[0]] = b.GetInsertBlock()
+	}
+}
+
+// supportsRecover returns whether the stack can be unwound on a panic for the
+// current target, which is what makes recover() work. This requires an
+// architecture for which a checkpoint can be created (see createCheckpoint).
+// With the coroutines scheduler, defer frames are removed again from functions
+// that turn out to be async.
+func (b *builder) supportsRecover() bool {
+	if b.PanicStrategy == "trap" {
+		// Panics are replaced with traps, there is nothing to recover from.
+		return false
+	}
+	switch b.archFamily() {
+	case "i386", "x86_64", "arm", "aarch64", "riscv32", "riscv64":
+		return true
+	default:
+		// Notably not supported: WebAssembly (no way to unwind the stack),
+		// AVR and Xtensa.
+		return false
+	}
+}
+
+// hasDeferFrame returns whether the current function needs to catch panics and
+// run deferred calls when unwinding the stack.
+func (b *builder) hasDeferFrame() bool {
+	return b.fn.Recover != nil && b.supportsRecover()
+}
+
+// archFamily returns the architecture family of the target triple, for example
+// "arm" for both armv7m and thumbv6m.
+func (b *builder) archFamily() string {
+	arch := strings.Split(b.Triple, "-")[0]
+	switch {
+	case arch == "i386" || arch == "i686":
+		return "i386"
+	case arch == "arm64":
+		return "aarch64"
+	case strings.HasPrefix(arch, "arm") || strings.HasPrefix(arch, "thumb"):
+		return "arm"
+	default:
+		return arch
+	}
+}
+
+// isThumb returns whether the target executes Thumb instructions instead of
+// regular ARM instructions. It is only meaningful for the "arm" family.
+func (b *builder) isThumb() bool {
+	arch := strings.Split(b.Triple, "-")[0]
+	if strings.HasPrefix(arch, "thumb") {
+		return true
+	}
+	for _, feature := range b.Features {
+		if feature == "+thumb-mode" {
+			return true
+		}
+	}
+	// Cortex-M cores (the M profile) only support Thumb instructions.
+	return strings.HasSuffix(arch, "m") || strings.HasSuffix(arch, "m.base") || strings.HasSuffix(arch, "m.main")
+}
+
+// readStackPointer emits a LLVM intrinsic call that returns the current stack
+// pointer as an *i8.
+func (b *builder) readStackPointer() llvm.Value {
+	stacksave := b.mod.NamedFunction("llvm.stacksave")
+	if stacksave.IsNil() {
+		fnType := llvm.FunctionType(b.i8ptrType, nil, false)
+		stacksave = llvm.AddFunction(b.mod, "llvm.stacksave", fnType)
+	}
+	return b.CreateCall(stacksave, nil, "")
+}
+
+// createLandingPad fills in the landing pad block. This block runs the deferred
+// calls and then continues at the recover block, which returns to the parent
+// in an appropriate way. If the function is still panicking after the deferred
+// calls were run, the panic is re-raised in runtime.destroyDeferFrame.
+func (b *builder) createLandingPad() {
+	b.SetInsertPointAtEnd(b.landingpad)
+
+	// Add debug info, if needed.
+	// The location used is the closing bracket of the function.
+	if b.Debug && b.fn.Syntax() != nil {
+		pos := b.program.Fset.Position(b.fn.Syntax().End())
+		b.SetCurrentDebugLocation(uint(pos.Line), uint(pos.Column), b.difunc, llvm.Metadata{})
+	}
+
+	b.createRunDefers()
+	b.CreateBr(b.blockEntries[b.fn.Recover])
+}
+
+// createInvokeCheckpoint creates a checkpoint for the calls that follow, so
+// that a panic in those calls continues at the landing pad. Code generation
+// continues in a new basic block.
+func (b *builder) createInvokeCheckpoint() {
+	isZero := b.createCheckpoint(b.deferFrame)
+	continueBB := b.ctx.AddBasicBlock(b.llvmFn, "")
+	b.CreateCondBr(isZero, continueBB, b.landingpad)
+	b.SetInsertPointAtEnd(continueBB)
+	if b.currentBlock != nil {
+		b.blockExits[b.currentBlock] = continueBB // adjust outgoing block for phi nodes
+	}
+}
+
+// createCheckpoint creates a checkpoint (similar to setjmp). This emits inline
+// assembly that stores the program counter just past the inline assembly in
+// the JumpPC field of the given defer frame, and returns a boolean that is
+// true in the normal flow and false when tinygo_longjmp jumped back to it.
+//
+// All registers are marked as clobbered, so that tinygo_longjmp only needs to
+// restore the stack pointer. The return register is zeroed in the normal flow,
+// while tinygo_longjmp sets it to a non-zero value.
+func (b *builder) createCheckpoint(frame llvm.Value) llvm.Value {
+	var asmString, constraints string
+	switch b.archFamily() {
+	case "i386":
+		asmString = `
+xorl %eax, %eax
+movl $$1f, 4(%ebx)
+1:`
+		constraints = "={eax},{ebx},~{ebx},~{ecx},~{edx},~{esi},~{edi},~{ebp},~{xmm0},~{xmm1},~{xmm2},~{xmm3},~{xmm4},~{xmm5},~{xmm6},~{xmm7},~{fpsr},~{fpcr},~{flags},~{dirflag},~{memory}"
+	case "x86_64":
+		asmString = `
+leaq 1f(%rip), %rax
+movq %rax, 8(%rbx)
+xorq %rax, %rax
+1:`
+		constraints = "={rax},{rbx},~{rbx},~{rcx},~{rdx},~{rsi},~{rdi},~{rbp},~{r8},~{r9},~{r10},~{r11},~{r12},~{r13},~{r14},~{r15},~{xmm0},~{xmm1},~{xmm2},~{xmm3},~{xmm4},~{xmm5},~{xmm6},~{xmm7},~{xmm8},~{xmm9},~{xmm10},~{xmm11},~{xmm12},~{xmm13},~{xmm14},~{xmm15},~{fpsr},~{fpcr},~{flags},~{dirflag},~{memory}"
+	case "arm":
+		// Reading the PC gives the address of the current instruction plus 4
+		// (Thumb) or 8 (ARM), which in both cases is the address just past
+		// the inline assembly.
+		if b.isThumb() {
+			asmString = `
+movs r0, #0
+mov r2, pc
+str r2, [r1, #4]`
+		} else {
+			asmString = `
+str pc, [r1, #4]
+movs r0, #0`
+		}
+		constraints = "={r0},{r1},~{r1},~{r2},~{r3},~{r4},~{r5},~{r6},~{r7},~{r8},~{r9},~{r10},~{r11},~{r12},~{lr},~{cpsr},~{memory}"
+		if strings.Contains(b.Triple, "-linux") || strings.Contains(b.Triple, "v7em-") {
+			// Cores with a floating point unit (Cortex-A on Linux, usually
+			// Cortex-M4 and Cortex-M7).
+			constraints += ",~{d0},~{d1},~{d2},~{d3},~{d4},~{d5},~{d6},~{d7},~{d8},~{d9},~{d10},~{d11},~{d12},~{d13},~{d14},~{d15}"
+		}
+	case "aarch64":
+		asmString = `
+adr x2, 1f
+str x2, [x1, #8]
+mov x0, #0
+1:`
+		constraints = "={x0},{x1},~{x1},~{x2},~{x3},~{x4},~{x5},~{x6},~{x7},~{x8},~{x9},~{x10},~{x11},~{x12},~{x13},~{x14},~{x15},~{x16},~{x17},~{x19},~{x20},~{x21},~{x22},~{x23},~{x24},~{x25},~{x26},~{x27},~{x28},~{fp},~{lr},~{q0},~{q1},~{q2},~{q3},~{q4},~{q5},~{q6},~{q7},~{q8},~{q9},~{q10},~{q11},~{q12},~{q13},~{q14},~{q15},~{q16},~{q17},~{q18},~{q19},~{q20},~{q21},~{q22},~{q23},~{q24},~{q25},~{q26},~{q27},~{q28},~{q29},~{q30},~{q31},~{nzcv},~{memory}"
+	case "riscv32", "riscv64":
+		store := "sw"
+		if b.archFamily() == "riscv64" {
+			store = "sd"
+		}
+		asmString = `
+la a2, 1f
+` + store + ` a2, ` + strconv.Itoa(int(b.targetData.PointerSize())) + `(a1)
+li a0, 0
+1:`
+		constraints = "={a0},{a1},~{a1},~{a2},~{a3},~{a4},~{a5},~{a6},~{a7},~{s0},~{s1},~{s2},~{s3},~{s4},~{s5},~{s6},~{s7},~{s8},~{s9},~{s10},~{s11},~{t0},~{t1},~{t2},~{t3},~{t4},~{t5},~{t6},~{ra},~{memory}"
+		for _, feature := range b.Features {
+			if feature == "+f" || feature == "+d" {
+				// Callee-saved floating point registers.
+				constraints += ",~{fs0},~{fs1},~{fs2},~{fs3},~{fs4},~{fs5},~{fs6},~{fs7},~{fs8},~{fs9},~{fs10},~{fs11}"
+				break
+			}
+		}
+	default:
+		// This case should have been caught by b.supportsRecover().
+		panic("unknown architecture for defer frame: " + b.archFamily())
+	}
+	asmType := llvm.FunctionType(b.uintptrType, []llvm.Type{frame.Type()}, false)
+	asm := llvm.InlineAsm(asmType, asmString, constraints, true, false, 0)
+	result := b.CreateCall(asm, []llvm.Value{frame}, "setjmp")
+	result.AddCallSiteAttribute(-1, b.ctx.CreateEnumAttribute(llvm.AttributeKindID("returns_twice"), 0))
+	return b.CreateICmp(llvm.IntEQ, result, llvm.ConstInt(b.uintptrType, 0, false), "setjmp.result")
 }
 
 // isInLoop checks if there is a path from a basic block to itself.
@@ -217,7 +432,8 @@ func (b *builder) createDefer(instr *ssa.Defer) {
 		// This may be hit a variable number of times, so use a heap allocation.
 		size := b.targetData.TypeAllocSize(deferFrameType)
 		sizeValue := llvm.ConstInt(b.uintptrType, size, false)
-		allocCall := b.createRuntimeCall("alloc", []llvm.Value{sizeValue}, "defer.alloc.call")
+		layoutValue := llvmutil.CreateObjectLayout(b.mod, deferFrameType)
+		allocCall := b.createRuntimeCall("alloc", []llvm.Value{sizeValue, layoutValue}, "defer.alloc.call")
 		alloca = b.CreateBitCast(allocCall, llvm.PointerType(deferFrameType, 0), "defer.alloc")
 	}
 	if b.NeedsStackObjects {
@@ -286,6 +502,14 @@ func (b *builder) createRunDefers() {
 		block := b.ctx.AddBasicBlock(b.llvmFn, "rundefers.callback")
 		sw.AddCase(llvm.ConstInt(b.uintptrType, uint64(i), false), block)
 		b.SetInsertPointAtEnd(block)
+		if b.hasDeferFrame() {
+			// A panic in a deferred call must continue running the remaining
+			// deferred calls.
+			isZero := b.createCheckpoint(b.deferFrame)
+			callBlock := b.ctx.AddBasicBlock(b.llvmFn, "rundefers.call")
+			b.CreateCondBr(isZero, callBlock, b.landingpad)
+			b.SetInsertPointAtEnd(callBlock)
+		}
 		switch callback := callback.(type) {
 		case *ssa.CallCommon:
 			// Call on an value or interface value.
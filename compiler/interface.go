@@ -31,7 +31,7 @@ func (b *builder) createMakeInterface(val llvm.Value, typ types.Type, pos token.
 		itfConcreteTypeGlobal = llvm.AddGlobal(b.mod, typeInInterface, "typeInInterface:"+itfTypeCodeGlobal.Name())
 		itfConcreteTypeGlobal.SetInitializer(llvm.ConstNamedStruct(typeInInterface, []llvm.Value{itfTypeCodeGlobal, itfMethodSetGlobal}))
 		itfConcreteTypeGlobal.SetGlobalConstant(true)
-		itfConcreteTypeGlobal.SetLinkage(llvm.PrivateLinkage)
+		itfConcreteTypeGlobal.SetLinkage(llvm.LinkOnceODRLinkage)
 	}
 	itfTypeCode := b.CreatePtrToInt(itfConcreteTypeGlobal, b.uintptrType, "")
 	itf := llvm.Undef(b.getLLVMRuntimeType("_interface"))
@@ -42,7 +42,9 @@ func (b *builder) createMakeInterface(val llvm.Value, typ types.Type, pos token.
 
 // getTypeCode returns a reference to a type code.
 // It returns a pointer to an external global which should be replaced with the
-// real type in the interface lowering pass.
+// real type in the interface lowering pass. Type codes that carry extra
+// information have linkonce_odr linkage, as they may be created in multiple
+// packages and must be merged when these packages are linked together.
 func (c *compilerContext) getTypeCode(typ types.Type) llvm.Value {
 	globalName := "reflect/types.type:" + getTypeCodeName(typ)
 	global := c.mod.NamedGlobal(globalName)
@@ -52,7 +54,7 @@ func (c *compilerContext) getTypeCode(typ types.Type) llvm.Value {
 		// Some type classes contain more information for underlying types or
 		// element types. Store it directly in the typecode global to make
 		// reflect lowering simpler.
-		var references llvm.Value
+		var references, key llvm.Value
 		var length int64
 		switch typ := typ.(type) {
 		case *types.Named:
@@ -66,10 +68,18 @@ func (c *compilerContext) getTypeCode(typ types.Type) llvm.Value {
 		case *types.Array:
 			references = c.getTypeCode(typ.Elem())
 			length = typ.Len()
+		case *types.Map:
+			references = c.getTypeCode(typ.Elem())
+			key = c.getTypeCode(typ.Key())
 		case *types.Struct:
 			// Take a pointer to the typecodeID of the first field (if it exists).
 			structGlobal := c.makeStructTypeFields(typ)
 			references = llvm.ConstBitCast(structGlobal, global.Type())
+		case *types.Interface:
+			if typ.NumMethods() != 0 {
+				methodsGlobal := c.makeInterfaceTypeMethods(typ)
+				references = llvm.ConstBitCast(methodsGlobal, global.Type())
+			}
 		}
 		if !references.IsNil() {
 			// Set the 'references' field of the runtime.typecodeID struct.
@@ -79,8 +89,11 @@ func (c *compilerContext) getTypeCode(typ types.Type) llvm.Value {
 				lengthValue := llvm.ConstInt(c.uintptrType, uint64(length), false)
 				globalValue = llvm.ConstInsertValue(globalValue, lengthValue, []uint32{1})
 			}
+			if !key.IsNil() {
+				globalValue = llvm.ConstInsertValue(globalValue, key, []uint32{2})
+			}
 			global.SetInitializer(globalValue)
-			global.SetLinkage(llvm.PrivateLinkage)
+			global.SetLinkage(llvm.LinkOnceODRLinkage)
 		}
 		global.SetGlobalConstant(true)
 	}
@@ -121,6 +134,16 @@ func (c *compilerContext) makeStructTypeFields(typ *types.Struct) llvm.Value {
 			fieldEmbedded := llvm.ConstInt(c.ctx.Int1Type(), 1, false)
 			fieldGlobalValue = llvm.ConstInsertValue(fieldGlobalValue, fieldEmbedded, []uint32{3})
 		}
+		if !typ.Field(i).Exported() {
+			fieldPkgPath := c.makeGlobalArray([]byte(typ.Field(i).Pkg().Path()), "reflect/types.structFieldPkgPath", c.ctx.Int8Type())
+			fieldPkgPath.SetLinkage(llvm.PrivateLinkage)
+			fieldPkgPath.SetUnnamedAddr(true)
+			fieldPkgPath = llvm.ConstGEP(fieldPkgPath, []llvm.Value{
+				llvm.ConstInt(llvm.Int32Type(), 0, false),
+				llvm.ConstInt(llvm.Int32Type(), 0, false),
+			})
+			fieldGlobalValue = llvm.ConstInsertValue(fieldGlobalValue, fieldPkgPath, []uint32{4})
+		}
 		structGlobalValue = llvm.ConstInsertValue(structGlobalValue, fieldGlobalValue, []uint32{uint32(i)})
 	}
 	structGlobal.SetInitializer(structGlobalValue)
@@ -129,6 +152,30 @@ func (c *compilerContext) makeStructTypeFields(typ *types.Struct) llvm.Value {
 	return structGlobal
 }
 
+// makeInterfaceTypeMethods creates a new global with the method signatures of
+// the given interface type, as an array of pointers to strings like
+// "Add(int) int". It is used by the reflect package to check whether a type
+// implements an interface.
+func (c *compilerContext) makeInterfaceTypeMethods(typ *types.Interface) llvm.Value {
+	methods := make([]llvm.Value, typ.NumMethods())
+	for i := range methods {
+		signature := c.makeGlobalArray([]byte(methodSignature(typ.Method(i))), "reflect/types.interfaceMethod", c.ctx.Int8Type())
+		signature.SetLinkage(llvm.PrivateLinkage)
+		signature.SetUnnamedAddr(true)
+		methods[i] = llvm.ConstGEP(signature, []llvm.Value{
+			llvm.ConstInt(llvm.Int32Type(), 0, false),
+			llvm.ConstInt(llvm.Int32Type(), 0, false),
+		})
+	}
+	value := llvm.ConstArray(c.i8ptrType, methods)
+	global := llvm.AddGlobal(c.mod, value.Type(), "reflect/types.interfaceMethods")
+	global.SetInitializer(value)
+	global.SetGlobalConstant(true)
+	global.SetUnnamedAddr(true)
+	global.SetLinkage(llvm.PrivateLinkage)
+	return global
+}
+
 // getTypeCodeName returns a name for this type that can be used in the
 // interface lowering pass to assign type codes as expected by the reflect
 // package. See getTypeCodeNum.
@@ -186,7 +233,13 @@ func getTypeCodeName(t types.Type) string {
 	case *types.Interface:
 		methods := make([]string, t.NumMethods())
 		for i := 0; i < t.NumMethods(); i++ {
-			methods[i] = getTypeCodeName(t.Method(i).Type())
+			name := t.Method(i).Name()
+			if !token.IsExported(name) {
+				// Unexported methods are only identical when they are
+				// declared in the same package.
+				name = t.Method(i).Pkg().Path() + "." + name
+			}
+			methods[i] = name + ":" + getTypeCodeName(t.Method(i).Type())
 		}
 		return "interface:" + "{" + strings.Join(methods, ",") + "}"
 	case *types.Map:
@@ -214,7 +267,13 @@ func getTypeCodeName(t types.Type) string {
 			if t.Field(i).Embedded() {
 				embedded = "#"
 			}
-			elems[i] = embedded + t.Field(i).Name() + ":" + getTypeCodeName(t.Field(i).Type())
+			name := t.Field(i).Name()
+			if !t.Field(i).Exported() {
+				// Unexported fields are only identical when they are declared
+				// in the same package.
+				name = t.Field(i).Pkg().Path() + "." + name
+			}
+			elems[i] = embedded + name + ":" + getTypeCodeName(t.Field(i).Type())
 			if t.Tag(i) != "" {
 				elems[i] += "`" + t.Tag(i) + "`"
 			}
@@ -256,6 +315,8 @@ func (c *compilerContext) getTypeMethodSet(typ types.Type) llvm.Value {
 		methodInfo := llvm.ConstNamedStruct(interfaceMethodInfoType, []llvm.Value{
 			signatureGlobal,
 			llvm.ConstPtrToInt(wrapper, c.uintptrType),
+			c.getTypeCode(methodFuncSignature(fn.Signature)),
+			llvm.ConstPtrToInt(llvmFn, c.uintptrType),
 		})
 		methods[i] = methodInfo
 	}
@@ -264,7 +325,7 @@ func (c *compilerContext) getTypeMethodSet(typ types.Type) llvm.Value {
 	global = llvm.AddGlobal(c.mod, arrayType, typ.String()+"$methodset")
 	global.SetInitializer(value)
 	global.SetGlobalConstant(true)
-	global.SetLinkage(llvm.PrivateLinkage)
+	global.SetLinkage(llvm.LinkOnceODRLinkage)
 	return llvm.ConstGEP(global, []llvm.Value{zero, zero})
 }
 
@@ -295,7 +356,7 @@ func (c *compilerContext) getInterfaceMethodSet(typ types.Type) llvm.Value {
 	global = llvm.AddGlobal(c.mod, value.Type(), name+"$interface")
 	global.SetInitializer(value)
 	global.SetGlobalConstant(true)
-	global.SetLinkage(llvm.PrivateLinkage)
+	global.SetLinkage(llvm.LinkOnceODRLinkage)
 	return llvm.ConstGEP(global, []llvm.Value{zero, zero})
 }
 
@@ -512,6 +573,21 @@ func methodSignature(method *types.Func) string {
 	return method.Name() + signature(method.Type().(*types.Signature))
 }
 
+// methodFuncSignature returns the signature of the given method as a plain
+// function, with the receiver as the first parameter. This is the type of
+// reflect.Method.Func.
+func methodFuncSignature(sig *types.Signature) *types.Signature {
+	if sig.Recv() == nil {
+		return sig
+	}
+	params := make([]*types.Var, 0, sig.Params().Len()+1)
+	params = append(params, sig.Recv())
+	for i := 0; i < sig.Params().Len(); i++ {
+		params = append(params, sig.Params().At(i))
+	}
+	return types.NewSignature(nil, types.NewTuple(params...), sig.Results(), sig.Variadic())
+}
+
 // Make a readable version of a function (pointer) signature.
 // Examples:
 //
@@ -0,0 +1,142 @@
+package llvmutil
+
+// This file contains helper functions to describe the memory layout of heap
+// objects for the precise garbage collector. See src/runtime/gc_precise.go for
+// a description of the layout format.
+
+import (
+	"fmt"
+	"math/big"
+
+	"tinygo.org/x/go-llvm"
+)
+
+// GetPointerBitmap scans the given LLVM type for pointers and sets bits in a
+// bigint at the word offset that contains a pointer. This scan is recursive.
+func GetPointerBitmap(targetData llvm.TargetData, typ llvm.Type, name string) *big.Int {
+	alignment := targetData.PrefTypeAlignment(llvm.PointerType(typ.Context().Int8Type(), 0))
+	switch typ.TypeKind() {
+	case llvm.IntegerTypeKind, llvm.FloatTypeKind, llvm.DoubleTypeKind:
+		return big.NewInt(0)
+	case llvm.PointerTypeKind:
+		return big.NewInt(1)
+	case llvm.StructTypeKind:
+		ptrs := big.NewInt(0)
+		for i, subtyp := range typ.StructElementTypes() {
+			subptrs := GetPointerBitmap(targetData, subtyp, name)
+			if subptrs.BitLen() == 0 {
+				continue
+			}
+			offset := targetData.ElementOffset(typ, i)
+			if offset%uint64(alignment) != 0 {
+				panic("precise GC: type contains unaligned pointer: " + name)
+			}
+			subptrs.Lsh(subptrs, uint(offset)/uint(alignment))
+			ptrs.Or(ptrs, subptrs)
+		}
+		return ptrs
+	case llvm.ArrayTypeKind:
+		subtyp := typ.ElementType()
+		subptrs := GetPointerBitmap(targetData, subtyp, name)
+		ptrs := big.NewInt(0)
+		if subptrs.BitLen() == 0 {
+			return ptrs
+		}
+		elementSize := targetData.TypeAllocSize(subtyp)
+		for i := 0; i < typ.ArrayLength(); i++ {
+			ptrs.Lsh(ptrs, uint(elementSize)/uint(alignment))
+			ptrs.Or(ptrs, subptrs)
+		}
+		return ptrs
+	default:
+		panic("unknown type kind: " + name)
+	}
+}
+
+// CreateObjectLayout returns the object layout value that should be passed to
+// runtime.alloc for a heap object of the given type (or an array of the given
+// type). The result is an *i8, which is either an inline bitmap encoded as an
+// integer or a pointer to a constant global with the bitmap.
+func CreateObjectLayout(mod llvm.Module, t llvm.Type) llvm.Value {
+	ctx := mod.Context()
+	targetData := llvm.NewTargetData(mod.DataLayout())
+	defer targetData.Dispose()
+	i8ptrType := llvm.PointerType(ctx.Int8Type(), 0)
+	uintptrType := ctx.IntType(targetData.PointerSize() * 8)
+	ptrBits := uint64(targetData.PointerSize() * 8)
+	ptrAlign := uint64(targetData.PrefTypeAlignment(i8ptrType))
+
+	// Arrays and single-field structs have the same layout as their element
+	// type, because the layout repeats for each element anyway.
+	for {
+		if t.TypeKind() == llvm.ArrayTypeKind {
+			t = t.ElementType()
+		} else if t.TypeKind() == llvm.StructTypeKind && len(t.StructElementTypes()) == 1 {
+			t = t.StructElementTypes()[0]
+		} else {
+			break
+		}
+	}
+
+	// Objects that are smaller than a pointer or that do not contain pointers
+	// at all do not need to be scanned.
+	pointerFree := llvm.ConstIntToPtr(llvm.ConstInt(uintptrType, 1<<1|1, false), i8ptrType)
+	size := targetData.TypeAllocSize(t)
+	if size < uint64(targetData.PointerSize()) {
+		return pointerFree
+	}
+	bitmap := GetPointerBitmap(targetData, t, t.String())
+	if bitmap.BitLen() == 0 {
+		return pointerFree
+	}
+	words := (size + ptrAlign - 1) / ptrAlign
+
+	// Try to store the layout inline in the pointer value.
+	// The number of bits used for the size must match sizeFieldBits in the
+	// runtime.
+	sizeFieldBits := uint64(4)
+	switch ptrBits {
+	case 32:
+		sizeFieldBits = 5
+	case 64:
+		sizeFieldBits = 6
+	}
+	if words <= ptrBits-1-sizeFieldBits {
+		layout := new(big.Int).Lsh(bitmap, uint(sizeFieldBits+1))
+		layout.Or(layout, big.NewInt(int64(words<<1|1)))
+		return llvm.ConstIntToPtr(llvm.ConstInt(uintptrType, layout.Uint64(), false), i8ptrType)
+	}
+
+	// The layout doesn't fit in a pointer, so store it in a global. Use a name
+	// that is unique for this layout, so that it can be deduplicated across
+	// packages.
+	globalName := fmt.Sprintf("runtime/gc.layout:%d-%x", words, bitmap)
+	global := mod.NamedGlobal(globalName)
+	if !global.IsNil() {
+		return llvm.ConstBitCast(global, i8ptrType)
+	}
+	bitmapBytes := make([]llvm.Value, (words+7)/8)
+	bigEndian := bitmap.Bytes()
+	for i := range bitmapBytes {
+		var b byte
+		if i < len(bigEndian) {
+			b = bigEndian[len(bigEndian)-1-i]
+		}
+		bitmapBytes[i] = llvm.ConstInt(ctx.Int8Type(), uint64(b), false)
+	}
+	initializer := ctx.ConstStruct([]llvm.Value{
+		llvm.ConstInt(uintptrType, words, false),
+		llvm.ConstArray(ctx.Int8Type(), bitmapBytes),
+	}, false)
+	global = llvm.AddGlobal(mod, initializer.Type(), globalName)
+	global.SetInitializer(initializer)
+	global.SetGlobalConstant(true)
+	global.SetUnnamedAddr(true)
+	global.SetLinkage(llvm.LinkOnceODRLinkage)
+	if targetData.ABITypeAlignment(uintptrType) < 2 {
+		// The lowest bit is used to distinguish inline layouts from pointers,
+		// so make sure it is always zero (this matters on AVR).
+		global.SetAlignment(2)
+	}
+	return llvm.ConstBitCast(global, i8ptrType)
+}
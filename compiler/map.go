@@ -6,6 +6,7 @@ import (
 	"go/token"
 	"go/types"
 
+	"github.com/tinygo-org/tinygo/compiler/llvmutil"
 	"golang.org/x/tools/go/ssa"
 	"tinygo.org/x/go-llvm"
 )
@@ -41,7 +42,16 @@ func (b *builder) createMakeMap(expr *ssa.MakeMap) (llvm.Value, error) {
 			return llvm.Value{}, err
 		}
 	}
-	hashmap := b.createRuntimeCall("hashmapMake", []llvm.Value{llvmKeySize, llvmValueSize, sizeHint}, "")
+	// The layout of a bucket must match runtime.hashmapBucket followed by 8
+	// keys and 8 values. It is packed, just like in the runtime.
+	bucketType := b.ctx.StructType([]llvm.Type{
+		llvm.ArrayType(b.ctx.Int8Type(), 8), // tophash
+		b.i8ptrType,                         // next
+		llvm.ArrayType(llvmKeyType, 8),
+		llvm.ArrayType(llvmValueType, 8),
+	}, true)
+	bucketLayout := llvmutil.CreateObjectLayout(b.mod, bucketType)
+	hashmap := b.createRuntimeCall("hashmapMake", []llvm.Value{llvmKeySize, llvmValueSize, sizeHint, bucketLayout}, "")
 	return hashmap, nil
 }
 
@@ -172,6 +172,23 @@ func (c *compilerContext) getFunction(fn *ssa.Function) llvm.Value {
 		}
 	}
 
+	// Synthetic functions are functions that do not appear in the source code,
+	// they are artificially constructed. Usually they are wrapper functions
+	// that are not referenced anywhere except in a SSA call instruction so
+	// should be created right away.
+	// The exception is the package initializer, which does appear in the
+	// *ssa.Package members and so shouldn't be created here.
+	// They may be created in multiple packages, which is why they get
+	// linkonce_odr linkage: the linker will pick one of them.
+	if fn.Synthetic != "" && fn.Synthetic != "package initializer" && fn.Blocks != nil {
+		irbuilder := c.ctx.NewBuilder()
+		b := newBuilder(c, irbuilder, fn)
+		b.createFunction()
+		irbuilder.Dispose()
+		llvmFn.SetLinkage(llvm.LinkOnceODRLinkage)
+		llvmFn.SetUnnamedAddr(true)
+	}
+
 	return llvmFn
 }
 
@@ -289,25 +306,23 @@ type globalInfo struct {
 
 // loadASTComments loads comments on globals from the AST, for use later in the
 // program. In particular, they are required for //go:extern pragmas on globals.
-func (c *compilerContext) loadASTComments(lprogram *loader.Program) {
+func (c *compilerContext) loadASTComments(pkg *loader.Package) {
 	c.astComments = map[string]*ast.CommentGroup{}
-	for _, pkgInfo := range lprogram.Sorted() {
-		for _, file := range pkgInfo.Files {
-			for _, decl := range file.Decls {
-				switch decl := decl.(type) {
-				case *ast.GenDecl:
-					switch decl.Tok {
-					case token.VAR:
-						if len(decl.Specs) != 1 {
-							continue
-						}
-						for _, spec := range decl.Specs {
-							switch spec := spec.(type) {
-							case *ast.ValueSpec: // decl.Tok == token.VAR
-								for _, name := range spec.Names {
-									id := pkgInfo.Pkg.Path() + "." + name.Name
-									c.astComments[id] = decl.Doc
-								}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				switch decl.Tok {
+				case token.VAR:
+					if len(decl.Specs) != 1 {
+						continue
+					}
+					for _, spec := range decl.Specs {
+						switch spec := spec.(type) {
+						case *ast.ValueSpec: // decl.Tok == token.VAR
+							for _, name := range spec.Names {
+								id := pkg.Pkg.Path() + "." + name.Name
+								c.astComments[id] = decl.Doc
 							}
 						}
 					}
@@ -318,7 +333,9 @@ func (c *compilerContext) loadASTComments(lprogram *loader.Program) {
 }
 
 // getGlobal returns a LLVM IR global value for a Go SSA global. It is added to
-// the LLVM IR if it has not been added already.
+// the LLVM IR if it has not been added already. The returned global is only a
+// declaration: globals are defined by createPackage in the package they belong
+// to.
 func (c *compilerContext) getGlobal(g *ssa.Global) llvm.Value {
 	info := c.getGlobalInfo(g)
 	llvmGlobal := c.mod.NamedGlobal(info.linkName)
@@ -326,46 +343,45 @@ func (c *compilerContext) getGlobal(g *ssa.Global) llvm.Value {
 		typ := g.Type().(*types.Pointer).Elem()
 		llvmType := c.getLLVMType(typ)
 		llvmGlobal = llvm.AddGlobal(c.mod, llvmType, info.linkName)
-		if !info.extern {
-			llvmGlobal.SetInitializer(llvm.ConstNull(llvmType))
-			llvmGlobal.SetLinkage(llvm.InternalLinkage)
-		}
 
 		// Set alignment from the //go:align comment.
-		var alignInBits uint32
 		if info.align < 0 || info.align&(info.align-1) != 0 {
 			// Check for power-of-two (or 0).
 			// See: https://stackoverflow.com/a/108360
 			c.addError(g.Pos(), "global variable alignment must be a positive power of two")
-		} else {
+		} else if info.align > c.targetData.ABITypeAlignment(llvmType) {
 			// Set the alignment only when it is a power of two.
-			alignInBits = uint32(info.align) ^ uint32(info.align-1)
-			if info.align > c.targetData.ABITypeAlignment(llvmType) {
-				llvmGlobal.SetAlignment(info.align)
-			}
-		}
-
-		if c.Debug && !info.extern {
-			// Add debug info.
-			// TODO: this should be done for every global in the program, not just
-			// the ones that are referenced from some code.
-			pos := c.program.Fset.Position(g.Pos())
-			diglobal := c.dibuilder.CreateGlobalVariableExpression(c.difiles[pos.Filename], llvm.DIGlobalVariableExpression{
-				Name:        g.RelString(nil),
-				LinkageName: info.linkName,
-				File:        c.getDIFile(pos.Filename),
-				Line:        pos.Line,
-				Type:        c.getDIType(typ),
-				LocalToUnit: false,
-				Expr:        c.dibuilder.CreateExpression(nil),
-				AlignInBits: alignInBits,
-			})
-			llvmGlobal.AddMetadata(0, diglobal)
+			llvmGlobal.SetAlignment(info.align)
 		}
 	}
 	return llvmGlobal
 }
 
+// createGlobalDebugInfo attaches debug information to a global that is defined
+// in the package currently being compiled.
+func (c *compilerContext) createGlobalDebugInfo(g *ssa.Global, llvmGlobal llvm.Value, info globalInfo) {
+	if !c.Debug {
+		return
+	}
+	var alignInBits uint32
+	if info.align > 0 && info.align&(info.align-1) == 0 {
+		alignInBits = uint32(info.align) ^ uint32(info.align-1)
+	}
+	typ := g.Type().(*types.Pointer).Elem()
+	pos := c.program.Fset.Position(g.Pos())
+	diglobal := c.dibuilder.CreateGlobalVariableExpression(c.difiles[pos.Filename], llvm.DIGlobalVariableExpression{
+		Name:        g.RelString(nil),
+		LinkageName: info.linkName,
+		File:        c.getDIFile(pos.Filename),
+		Line:        pos.Line,
+		Type:        c.getDIType(typ),
+		LocalToUnit: false,
+		Expr:        c.dibuilder.CreateExpression(nil),
+		AlignInBits: alignInBits,
+	})
+	llvmGlobal.AddMetadata(0, diglobal)
+}
+
 // getGlobalInfo returns some information about a specific global.
 func (c *compilerContext) getGlobalInfo(g *ssa.Global) globalInfo {
 	info := globalInfo{}
@@ -142,6 +142,12 @@ func (r *runner) run(fn *function, params []value, parentMem *memoryView, indent
 			}
 		case llvm.Call:
 			// A call instruction can either be a regular call or a runtime intrinsic.
+			if v, ok := operands[0].(localValue); ok && !v.value.IsAInlineAsm().IsNil() {
+				// Inline assembly (for example the checkpoints used to
+				// unwind the stack on a panic) cannot be interpreted, so the
+				// function has to be run at runtime instead.
+				return nil, mem, r.errorAt(inst, errUnsupportedInst)
+			}
 			fnPtr, err := operands[0].asPointer(r)
 			if err != nil {
 				return nil, mem, r.errorAt(inst, err)
@@ -354,7 +360,14 @@ func (r *runner) run(fn *function, params []value, parentMem *memoryView, indent
 				hashmapPointerType := inst.llvmInst.Type()
 				keySize := uint32(operands[1].Uint())
 				valueSize := uint32(operands[2].Uint())
-				m := newMapValue(r, hashmapPointerType, keySize, valueSize)
+				// The bucket layout is normally a constant created by the
+				// compiler, so it can be used directly in the resulting
+				// global. Fall back to an unknown layout otherwise.
+				bucketLayout := inst.llvmInst.Operand(3)
+				if bucketLayout.IsAConstant().IsNil() {
+					bucketLayout = llvm.ConstNull(r.i8ptrType)
+				}
+				m := newMapValue(r, hashmapPointerType, keySize, valueSize, bucketLayout)
 				alloc := object{
 					llvmType:   hashmapPointerType,
 					globalName: r.pkgName + "$map",
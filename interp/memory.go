@@ -638,6 +638,7 @@ type mapValue struct {
 	values      []rawValue
 	keySize     uint32
 	valueSize   uint32
+	layout      llvm.Value // object layout of a bucket, as passed to runtime.hashmapMake
 }
 
 type mapStringKey struct {
@@ -646,7 +647,7 @@ type mapStringKey struct {
 	data []uint64
 }
 
-func newMapValue(r *runner, hashmapPointerType llvm.Type, keySize, valueSize uint32) *mapValue {
+func newMapValue(r *runner, hashmapPointerType llvm.Type, keySize, valueSize uint32, layout llvm.Value) *mapValue {
 	size := uint32(r.targetData.TypeAllocSize(hashmapPointerType.ElementType()))
 	return &mapValue{
 		r:         r,
@@ -654,6 +655,7 @@ func newMapValue(r *runner, hashmapPointerType llvm.Type, keySize, valueSize uin
 		size:      size,
 		keySize:   keySize,
 		valueSize: valueSize,
+		layout:    layout,
 	}
 }
 
@@ -818,6 +820,7 @@ func (v *mapValue) toLLVMValue(hashmapType llvm.Type, mem *memoryView) llvm.Valu
 		llvm.ConstInt(ctx.Int8Type(), uint64(v.keySize), false),                        // keySize
 		llvm.ConstInt(ctx.Int8Type(), uint64(v.valueSize), false),                      // valueSize
 		llvm.ConstInt(ctx.Int8Type(), 0, false),                                        // bucketBits
+		v.layout,                                                                       // layout
 	})
 
 	v.hashmap = hashmap
@@ -2,7 +2,7 @@ target datalayout = "e-m:e-i64:64-f80:128-n8:16:32:64-S128"
 target triple = "x86_64--linux"
 
 %runtime.typecodeID = type { %runtime.typecodeID*, i64 }
-%runtime.interfaceMethodInfo = type { i8*, i64 }
+%runtime.interfaceMethodInfo = type { i8*, i64, %runtime.typecodeID*, i64 }
 %runtime.typeInInterface = type { %runtime.typecodeID*, %runtime.interfaceMethodInfo* }
 
 @main.v1 = global i1 0
@@ -2,14 +2,14 @@ target datalayout = "e-m:e-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64"
 target triple = "armv6m-none-eabi"
 
 %runtime._string = type { i8*, i32 }
-%runtime.hashmap = type { %runtime.hashmap*, i8*, i32, i8, i8, i8 }
+%runtime.hashmap = type { %runtime.hashmap*, i8*, i32, i8, i8, i8, i8* }
 
 @main.m = global %runtime.hashmap* null
 @main.binaryMap = global %runtime.hashmap* null
 @main.stringMap = global %runtime.hashmap* null
 @main.init.string = internal unnamed_addr constant [7 x i8] c"CONNECT"
 
-declare %runtime.hashmap* @runtime.hashmapMake(i8, i8, i32, i8* %context, i8* %parentHandle)
+declare %runtime.hashmap* @runtime.hashmapMake(i8, i8, i32, i8*, i8* %context, i8* %parentHandle)
 declare void @runtime.hashmapBinarySet(%runtime.hashmap*, i8*, i8*, i8* %context, i8* %parentHandle)
 declare void @runtime.hashmapStringSet(%runtime.hashmap*, i8*, i32, i8*, i8* %context, i8* %parentHandle)
 declare void @llvm.lifetime.end.p0i8(i64, i8*)
@@ -26,7 +26,7 @@ entry:
 ; Test that hashmap optimizations generally work (even with lifetimes).
   %hashmap.key = alloca i8
   %hashmap.value = alloca %runtime._string
-  %0 = call %runtime.hashmap* @runtime.hashmapMake(i8 1, i8 8, i32 1, i8* undef, i8* null)
+  %0 = call %runtime.hashmap* @runtime.hashmapMake(i8 1, i8 8, i32 1, i8* null, i8* undef, i8* null)
   %hashmap.value.bitcast = bitcast %runtime._string* %hashmap.value to i8*
   call void @llvm.lifetime.start.p0i8(i64 8, i8* %hashmap.value.bitcast)
   store %runtime._string { i8* getelementptr inbounds ([7 x i8], [7 x i8]* @main.init.string, i32 0, i32 0), i32 7 }, %runtime._string* %hashmap.value
@@ -49,7 +49,7 @@ define internal void @main.testNonConstantBinarySet() {
   %hashmap.key = alloca i8
   %hashmap.value = alloca i8
   ; Create hashmap from global.
-  %map.new = call %runtime.hashmap* @runtime.hashmapMake(i8 1, i8 1, i32 1, i8* undef, i8* null)
+  %map.new = call %runtime.hashmap* @runtime.hashmapMake(i8 1, i8 1, i32 1, i8* inttoptr (i32 3 to i8*), i8* undef, i8* null)
   store %runtime.hashmap* %map.new, %runtime.hashmap** @main.binaryMap
   %map = load %runtime.hashmap*, %runtime.hashmap** @main.binaryMap
   ; Do the binary set to the newly loaded map.
@@ -64,7 +64,7 @@ define internal void @main.testNonConstantBinarySet() {
 define internal void @main.testNonConstantStringSet() {
   %hashmap.value = alloca i8
   ; Create hashmap from global.
-  %map.new = call %runtime.hashmap* @runtime.hashmapMake(i8 8, i8 1, i32 1, i8* undef, i8* null)
+  %map.new = call %runtime.hashmap* @runtime.hashmapMake(i8 8, i8 1, i32 1, i8* null, i8* undef, i8* null)
   store %runtime.hashmap* %map.new, %runtime.hashmap** @main.stringMap
   %map = load %runtime.hashmap*, %runtime.hashmap** @main.stringMap
   ; Do the string set to the newly loaded map.
@@ -2,6 +2,7 @@ package loader
 
 import (
 	"bytes"
+	"crypto/sha512"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 	"go/token"
 	"go/types"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -50,6 +52,7 @@ type PackageJSON struct {
 	GoFiles  []string
 	CgoFiles []string
 	CFiles   []string
+	HFiles   []string
 
 	// Dependency information
 	Imports   []string
@@ -67,10 +70,12 @@ type PackageJSON struct {
 type Package struct {
 	PackageJSON
 
-	program *Program
-	Files   []*ast.File
-	Pkg     *types.Package
-	info    types.Info
+	program    *Program
+	Files      []*ast.File
+	FileHashes map[string][]byte
+	Pkg        *types.Package
+	CGoHeader  string // contents of _cgo_export.h, if this package uses CGo
+	info       types.Info
 }
 
 // Load loads the given package with all dependencies (including the runtime
@@ -122,7 +127,8 @@ func Load(config *compileopts.Config, inputPkgs []string, clangHeaders string, t
 	decoder := json.NewDecoder(buf)
 	for {
 		pkg := &Package{
-			program: p,
+			program:    p,
+			FileHashes: make(map[string][]byte),
 			info: types.Info{
 				Types:      make(map[ast.Expr]types.TypeAndValue),
 				Defs:       make(map[*ast.Ident]types.Object),
@@ -280,18 +286,29 @@ func (p *Program) Parse() error {
 	return nil
 }
 
-// parseFile is a wrapper around parser.ParseFile.
-func (p *Program) parseFile(path string, mode parser.Mode) (*ast.File, error) {
-	if p.fset == nil {
-		p.fset = token.NewFileSet()
+// parseFile is a wrapper around parser.ParseFile. It also stores a hash of the
+// file contents, which is used for build caching.
+func (p *Package) parseFile(path string, mode parser.Mode) (*ast.File, error) {
+	originalPath := p.program.getOriginalPath(path)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
+	sum := sha512.Sum512_224(data)
+	p.FileHashes[originalPath] = sum[:]
+	return parser.ParseFile(p.program.fset, originalPath, data, mode)
+}
 
-	rd, err := os.Open(path)
+// hashFile stores a hash of a file that is not parsed as Go code but still
+// influences the build, such as C files and headers used by CGo.
+func (p *Package) hashFile(path string) error {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rd.Close()
-	return parser.ParseFile(p.fset, p.getOriginalPath(path), rd, mode)
+	sum := sha512.Sum512_224(data)
+	p.FileHashes[p.program.getOriginalPath(path)] = sum[:]
+	return nil
 }
 
 // Parse parses and typechecks this package.
@@ -367,7 +384,7 @@ func (p *Package) parseFiles() ([]*ast.File, error) {
 		if !filepath.IsAbs(file) {
 			file = filepath.Join(p.Dir, file)
 		}
-		f, err := p.program.parseFile(file, parser.ParseComments)
+		f, err := p.parseFile(file, parser.ParseComments)
 		if err != nil {
 			fileErrs = append(fileErrs, err)
 			return
@@ -389,12 +406,26 @@ func (p *Package) parseFiles() ([]*ast.File, error) {
 		if p.program.clangHeaders != "" {
 			cflags = append(cflags, "-Xclang", "-internal-isystem", "-Xclang", p.program.clangHeaders)
 		}
-		generated, ldflags, errs := cgo.Process(files, p.program.workingDir, p.program.fset, cflags)
+		generated, ldflags, header, includes, errs := cgo.Process(files, p.program.workingDir, p.program.fset, cflags)
 		if errs != nil {
 			fileErrs = append(fileErrs, errs...)
 		}
 		files = append(files, generated)
+		p.CGoHeader = header
 		p.program.LDFlags = append(p.program.LDFlags, ldflags...)
+
+		// Changes to headers and C files must invalidate the build cache, so
+		// include them in the file hashes.
+		for _, include := range includes {
+			if err := p.hashFile(include); err != nil {
+				fileErrs = append(fileErrs, err)
+			}
+		}
+	}
+	for _, file := range append(p.CFiles, p.HFiles...) {
+		if err := p.hashFile(filepath.Join(p.Dir, file)); err != nil {
+			fileErrs = append(fileErrs, err)
+		}
 	}
 
 	// Only return an error after CGo processing, so that errors in parsing and
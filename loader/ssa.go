@@ -4,7 +4,8 @@ import (
 	"golang.org/x/tools/go/ssa"
 )
 
-// LoadSSA constructs the SSA form of the loaded packages.
+// LoadSSA constructs the SSA form of the loaded packages. The SSA of each
+// package is not yet built, this is done when compiling each package.
 //
 // The program must already be parsed and type-checked with the .Parse() method.
 func (p *Program) LoadSSA() *ssa.Program {
@@ -16,11 +17,3 @@ func (p *Program) LoadSSA() *ssa.Program {
 
 	return prog
 }
-
-// LoadSSA constructs the SSA form of this package.
-//
-// The program must already be parsed and type-checked with the .Parse() method.
-func (p *Package) LoadSSA() *ssa.Package {
-	prog := ssa.NewProgram(p.program.fset, ssa.SanityCheckFunctions|ssa.BareInits|ssa.GlobalDebug)
-	return prog.CreatePackage(p.Pkg, p.Files, &p.info, true)
-}
@@ -134,15 +134,21 @@ func Build(pkgName, outpath string, options *compileopts.Options) error {
 	})
 }
 
-// Test runs the tests in the given package.
-func Test(pkgName string, options *compileopts.Options, testCompileOnly bool, outpath string) error {
-	options.TestConfig.CompileTestBinary = true
-	config, err := builder.NewConfig(options)
+// Test runs the tests in the given package. The output of the test binary is
+// written to stdout, followed by a go test style summary line. It returns
+// whether the tests passed.
+func Test(pkgName string, stdout io.Writer, options *compileopts.Options, testCompileOnly bool, outpath string) (bool, error) {
+	// Copy the options, as packages may be tested in parallel.
+	testOptions := *options
+	testOptions.TestConfig.CompileTestBinary = true
+	config, err := builder.NewConfig(&testOptions)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return builder.Build(pkgName, outpath, config, func(result builder.BuildResult) error {
+	passed := false
+	var duration time.Duration
+	err = builder.Build(pkgName, outpath, config, func(result builder.BuildResult) error {
 		if testCompileOnly || outpath != "" {
 			// Write test binary to the specified file name.
 			if outpath == "" {
@@ -154,53 +160,155 @@ func Test(pkgName string, options *compileopts.Options, testCompileOnly bool, ou
 		}
 		if testCompileOnly {
 			// Do not run the test.
+			passed = true
 			return nil
 		}
+		// Pass the test flags as command line arguments, if the target
+		// supports them. Otherwise they have been stored in the binary.
+		var flags []string
+		if config.CommandLineArgs() {
+			flags = config.TestConfig.Flags()
+		}
+		start := time.Now()
 		if len(config.Target.Emulator) == 0 {
 			// Run directly.
-			cmd := executeCommand(config.Options, result.Binary)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
+			cmd := executeCommand(config.Options, result.Binary, flags...)
+			cmd.Stdout = stdout
+			cmd.Stderr = stdout
 			cmd.Dir = result.MainDir
 			err := cmd.Run()
+			duration = time.Since(start)
 			if err != nil {
-				// Propagate the exit code
-				if err, ok := err.(*exec.ExitError); ok {
-					if status, ok := err.Sys().(syscall.WaitStatus); ok {
-						os.Exit(status.ExitStatus())
-					}
-					os.Exit(1)
+				if _, ok := err.(*exec.ExitError); ok {
+					// The test binary exited with a non-zero exit code, so
+					// some tests failed.
+					return nil
 				}
 				return &commandError{"failed to run compiled binary", result.Binary, err}
 			}
+			passed = true
 			return nil
 		} else {
 			// Run in an emulator.
 			args := append(config.Target.Emulator[1:], result.Binary)
+			args = append(args, flags...)
 			cmd := executeCommand(config.Options, config.Target.Emulator[0], args...)
 			buf := &bytes.Buffer{}
-			w := io.MultiWriter(os.Stdout, buf)
+			w := io.MultiWriter(stdout, buf)
 			cmd.Stdout = w
-			cmd.Stderr = os.Stderr
+			cmd.Stderr = stdout
 			err := cmd.Run()
+			duration = time.Since(start)
 			if err != nil {
-				if err, ok := err.(*exec.ExitError); !ok || !err.Exited() {
-					// Workaround for QEMU which always exits with an error.
-					return &commandError{"failed to run emulator with", result.Binary, err}
+				if err, ok := err.(*exec.ExitError); ok && err.Exited() {
+					// The test binary exited with a non-zero exit code (for
+					// example through semihosting), so some tests failed.
+					return nil
 				}
+				return &commandError{"failed to run emulator with", result.Binary, err}
 			}
+			// Not all emulators can report the exit code of the program, so
+			// also check the output. The test only passed if the output ends
+			// with the word "PASS". It failed if it ends with the word "FAIL"
+			// or with a panic of some sort.
 			testOutput := string(buf.Bytes())
-			if testOutput == "PASS\n" || strings.HasSuffix(testOutput, "\nPASS\n") {
-				// Test passed.
-				return nil
-			} else {
-				// Test failed, either by ending with the word "FAIL" or with a
-				// panic of some sort.
-				os.Exit(1)
-				return nil // unreachable
-			}
+			passed = testOutput == "PASS\n" || strings.HasSuffix(testOutput, "\nPASS\n")
+			return nil
 		}
 	})
+	if err != nil {
+		return false, err
+	}
+	if testCompileOnly {
+		return true, nil
+	}
+
+	// Print a summary line, like go test does.
+	if passed {
+		fmt.Fprintf(stdout, "ok  \t%s\t%.3fs\n", pkgName, duration.Seconds())
+	} else {
+		fmt.Fprintf(stdout, "FAIL\t%s\t%.3fs\n", pkgName, duration.Seconds())
+	}
+	return passed, nil
+}
+
+// testPackages runs the tests of all given packages, which are built and run
+// in parallel. The output of each package is printed in order, as go test
+// does. When there is more than one package, the output of packages that
+// passed is only shown with -v. It returns whether all tests passed.
+func testPackages(pkgNames []string, options *compileopts.Options, testCompileOnly bool, outpath string, jsonOutput bool) bool {
+	type testResult struct {
+		output bytes.Buffer
+		passed bool
+		err    error
+		done   chan struct{}
+	}
+	results := make([]*testResult, len(pkgNames))
+	for i := range results {
+		results[i] = &testResult{done: make(chan struct{})}
+	}
+
+	// Limit the number of packages that are tested at the same time.
+	semaphore := make(chan struct{}, runtime.NumCPU())
+	for i, pkgName := range pkgNames {
+		go func(pkgName string, result *testResult) {
+			semaphore <- struct{}{}
+			defer func() {
+				<-semaphore
+				close(result.done)
+			}()
+			var stdout io.Writer = &result.output
+			if len(pkgNames) == 1 && !jsonOutput {
+				// Show the output while the test is running.
+				stdout = os.Stdout
+			}
+			if jsonOutput {
+				converter := newTestJSONWriter(stdout, pkgName)
+				defer converter.Close()
+				stdout = converter
+			}
+			result.passed, result.err = Test(pkgName, stdout, options, testCompileOnly, outpath)
+			if result.err != nil {
+				fmt.Fprintf(stdout, "FAIL\t%s [build failed]\n", pkgName)
+			}
+		}(pkgName, results[i])
+	}
+
+	allPassed := true
+	for _, result := range results {
+		<-result.done
+		if result.err != nil {
+			printCompilerError(func(args ...interface{}) {
+				fmt.Fprintln(os.Stderr, args...)
+			}, result.err)
+		}
+		if !result.passed {
+			allPassed = false
+		}
+		if len(pkgNames) > 1 && result.passed && !options.TestConfig.Verbose && !jsonOutput {
+			// Only print the summary line of packages that passed.
+			lines := strings.SplitAfter(strings.TrimSuffix(result.output.String(), "\n"), "\n")
+			os.Stdout.WriteString(lines[len(lines)-1] + "\n")
+			continue
+		}
+		os.Stdout.Write(result.output.Bytes())
+	}
+	return allPassed
+}
+
+// listPackages expands package patterns like ./... to a list of import paths,
+// using go list.
+func listPackages(config *compileopts.Config, patterns []string) ([]string, error) {
+	cmd, err := loader.List(config, []string{"-f", "{{.ImportPath}}"}, patterns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run `go list`: %w", err)
+	}
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run `go list`: %w", err)
+	}
+	return strings.Fields(string(output)), nil
 }
 
 // Flash builds and flashes the built binary to the given serial port.
@@ -509,7 +617,8 @@ func FlashGDB(pkgName string, ocdOutput bool, options *compileopts.Options) erro
 // Run compiles and runs the given program. Depending on the target provided in
 // the options, it will run the program directly on the host or will run it in
 // an emulator. For example, -target=wasm will cause the binary to be run inside
-// of a WebAssembly VM.
+// of a WebAssembly VM. If the program exits with a non-zero exit code, the
+// returned error is an *exec.ExitError.
 func Run(pkgName string, options *compileopts.Options) error {
 	config, err := builder.NewConfig(options)
 	if err != nil {
@@ -525,8 +634,8 @@ func Run(pkgName string, options *compileopts.Options) error {
 			err := cmd.Run()
 			if err != nil {
 				if err, ok := err.(*exec.ExitError); ok && err.Exited() {
-					// Workaround for QEMU which always exits with an error.
-					return nil
+					// The program exited with a non-zero exit code.
+					return err
 				}
 				return &commandError{"failed to run compiled binary", result.Binary, err}
 			}
@@ -540,8 +649,9 @@ func Run(pkgName string, options *compileopts.Options) error {
 			err := cmd.Run()
 			if err != nil {
 				if err, ok := err.(*exec.ExitError); ok && err.Exited() {
-					// Workaround for QEMU which always exits with an error.
-					return nil
+					// The program exited with a non-zero exit code, which
+					// QEMU reports through semihosting.
+					return err
 				}
 				return &commandError{"failed to run emulator with", result.Binary, err}
 			}
@@ -715,6 +825,7 @@ func usage() {
 	fmt.Fprintln(os.Stderr, "  test:  test packages")
 	fmt.Fprintln(os.Stderr, "  flash: compile and flash to the device")
 	fmt.Fprintln(os.Stderr, "  gdb:   run/flash and immediately enter GDB")
+	fmt.Fprintln(os.Stderr, "  size:  print the size of an ELF file, or compare two ELF files")
 	fmt.Fprintln(os.Stderr, "  env:   list environment variables used during build")
 	fmt.Fprintln(os.Stderr, "  list:  run go list using the TinyGo root")
 	fmt.Fprintln(os.Stderr, "  clean: empty cache directory ("+goenv.Get("GOCACHE")+")")
@@ -824,7 +935,7 @@ func main() {
 	command := os.Args[1]
 
 	opt := flag.String("opt", "z", "optimization level: 0, 1, 2, s, z")
-	gc := flag.String("gc", "", "garbage collector to use (none, leaking, extalloc, conservative)")
+	gc := flag.String("gc", "", "garbage collector to use (none, leaking, extalloc, conservative, precise)")
 	panicStrategy := flag.String("panic", "print", "panic strategy (print, trap)")
 	scheduler := flag.String("scheduler", "", "which scheduler to use (none, coroutines, tasks)")
 	printIR := flag.Bool("printir", false, "print LLVM IR")
@@ -832,8 +943,12 @@ func main() {
 	verifyIR := flag.Bool("verifyir", false, "run extra verification steps on LLVM IR")
 	tags := flag.String("tags", "", "a space-separated list of extra build tags")
 	target := flag.String("target", "", "LLVM target | .json file with TargetSpec")
-	printSize := flag.String("size", "", "print sizes (none, short, full)")
+	printSize := flag.String("size", "", "print sizes (none, short, full, symbols)")
+	printSizeFormat := flag.String("size-format", "", "format of the size report (text, json)")
 	printStacks := flag.Bool("print-stacks", false, "print stack sizes of goroutines")
+	printWhy := flag.String("why", "", "print why the given function or global is included in the program")
+	printAllocs := flag.String("print-allocs", "", "regular expression of packages for which heap allocations are printed, with the reason they are not stack allocated")
+	embedDir := flag.String("embed-dir", "", "embed a directory as read-only filesystem, in the form dir:/prefix/")
 	printCommands := flag.Bool("x", false, "Print commands")
 	nodebug := flag.Bool("no-debug", false, "disable DWARF debug symbol generation")
 	ocdOutput := flag.Bool("ocd-output", false, "print OCD daemon output during debug")
@@ -844,17 +959,27 @@ func main() {
 	wasmAbi := flag.String("wasm-abi", "", "WebAssembly ABI conventions: js (no i64 params) or generic")
 
 	var flagJSON, flagDeps *bool
-	if command == "help" || command == "list" {
+	if command == "help" || command == "list" || command == "test" {
 		flagJSON = flag.Bool("json", false, "print data in JSON format")
+	}
+	if command == "help" || command == "list" {
 		flagDeps = flag.Bool("deps", false, "")
 	}
 	var outpath string
 	if command == "help" || command == "build" || command == "build-library" || command == "test" {
 		flag.StringVar(&outpath, "o", "", "output filename")
 	}
-	var testCompileOnlyFlag *bool
+	var testCompileOnlyFlag, testVerboseFlag, testShortFlag *bool
+	var testRunRegexp, testBenchRegexp, testBenchTime *string
+	var testCount *int
 	if command == "help" || command == "test" {
 		testCompileOnlyFlag = flag.Bool("c", false, "compile the test binary but do not run it")
+		testVerboseFlag = flag.Bool("v", false, "verbose: print additional output")
+		testShortFlag = flag.Bool("short", false, "short: run smaller test suite to save time")
+		testRunRegexp = flag.String("run", "", "run: regexp of tests to run")
+		testCount = flag.Int("count", 0, "count: run each test and benchmark n times")
+		testBenchRegexp = flag.String("bench", "", "bench: regexp of benchmarks to run")
+		testBenchTime = flag.String("benchtime", "", "benchtime: run each benchmark for duration d or n times (like 100x)")
 	}
 
 	// Early command processing, before commands are interpreted by the Go flag
@@ -871,21 +996,25 @@ func main() {
 
 	flag.CommandLine.Parse(os.Args[2:])
 	options := &compileopts.Options{
-		Target:        *target,
-		Opt:           *opt,
-		GC:            *gc,
-		PanicStrategy: *panicStrategy,
-		Scheduler:     *scheduler,
-		PrintIR:       *printIR,
-		DumpSSA:       *dumpSSA,
-		VerifyIR:      *verifyIR,
-		Debug:         !*nodebug,
-		PrintSizes:    *printSize,
-		PrintStacks:   *printStacks,
-		PrintCommands: *printCommands,
-		Tags:          *tags,
-		WasmAbi:       *wasmAbi,
-		Programmer:    *programmer,
+		Target:           *target,
+		Opt:              *opt,
+		GC:               *gc,
+		PanicStrategy:    *panicStrategy,
+		Scheduler:        *scheduler,
+		PrintIR:          *printIR,
+		DumpSSA:          *dumpSSA,
+		VerifyIR:         *verifyIR,
+		Debug:            !*nodebug,
+		PrintSizes:       *printSize,
+		PrintSizesFormat: *printSizeFormat,
+		PrintStacks:      *printStacks,
+		PrintWhy:         *printWhy,
+		PrintAllocs:      *printAllocs,
+		EmbedDir:         *embedDir,
+		PrintCommands:    *printCommands,
+		Tags:             *tags,
+		WasmAbi:          *wasmAbi,
+		Programmer:       *programmer,
 	}
 
 	if *cFlags != "" {
@@ -984,18 +1113,61 @@ func main() {
 		}
 		pkgName := filepath.ToSlash(flag.Arg(0))
 		err := Run(pkgName, options)
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			// Exit with the same exit code as the program.
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+				os.Exit(status.ExitStatus())
+			}
+			os.Exit(1)
+		}
 		handleCompilerError(err)
 	case "test":
-		pkgName := "."
-		if flag.NArg() == 1 {
-			pkgName = filepath.ToSlash(flag.Arg(0))
-		} else if flag.NArg() > 1 {
-			fmt.Fprintln(os.Stderr, "test only accepts a single positional argument: package name, but multiple were specified")
-			usage()
+		var pkgNames []string
+		for _, arg := range flag.Args() {
+			pkgNames = append(pkgNames, filepath.ToSlash(arg))
+		}
+		if len(pkgNames) == 0 {
+			pkgNames = []string{"."}
+		}
+		if (*testCompileOnlyFlag || outpath != "") && (len(pkgNames) > 1 || strings.Contains(pkgNames[0], "...")) {
+			fmt.Fprintln(os.Stderr, "cannot use -c or -o flag with multiple packages")
 			os.Exit(1)
 		}
-		err := Test(pkgName, options, *testCompileOnlyFlag, outpath)
+		options.TestConfig = compileopts.TestConfig{
+			Verbose:     *testVerboseFlag || *flagJSON,
+			Short:       *testShortFlag,
+			RunRegexp:   *testRunRegexp,
+			Count:       *testCount,
+			BenchRegexp: *testBenchRegexp,
+			BenchTime:   *testBenchTime,
+		}
+		config, err := builder.NewConfig(options)
 		handleCompilerError(err)
+		pkgNames, err = listPackages(config, pkgNames)
+		handleCompilerError(err)
+		if !testPackages(pkgNames, options, *testCompileOnlyFlag, outpath, *flagJSON) {
+			os.Exit(1)
+		}
+	case "size":
+		if flag.NArg() != 1 && flag.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "usage: tinygo size [-size=short|full|symbols] [-size-format=text|json] file.elf [new.elf]")
+			os.Exit(1)
+		}
+		var err error
+		if flag.NArg() == 2 {
+			// Show which packages and symbols changed in size.
+			err = builder.PrintSizeDiff(os.Stdout, flag.Arg(0), flag.Arg(1), options.PrintSizesFormat)
+		} else {
+			level := options.PrintSizes
+			if level == "" || level == "none" {
+				level = "full"
+			}
+			err = builder.PrintSizes(os.Stdout, flag.Arg(0), level, options.PrintSizesFormat)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
 	case "targets":
 		dir := filepath.Join(goenv.Get("TINYGOROOT"), "targets")
 		entries, err := ioutil.ReadDir(dir)
@@ -58,9 +58,21 @@ func TestCompiler(t *testing.T) {
 	if runtime.GOOS != "windows" {
 		t.Run("Host", func(t *testing.T) {
 			runPlatTests("", matches, t)
+			runTest("testdata/readdir/readdir.go", "", t)
 			if runtime.GOOS == "darwin" {
 				runTest("testdata/libc/env.go", "", t, []string{"ENV1=VALUE1", "ENV2=VALUE2"}...)
 			}
+			if runtime.GOOS == "linux" {
+				options := defaultTestOptions("")
+				options.EmbedDir = "testdata/filesystem/assets:/static/"
+				runTestWithOptions("testdata/filesystem/filesystem.go", options, t)
+
+				options = defaultTestOptions("")
+				options.GC = "precise"
+				runTestWithOptions("testdata/gcprecise/gcprecise.go", options, t)
+
+				runTest("testdata/machinesim/machinesim.go", "", t)
+			}
 		})
 	}
 
@@ -107,6 +119,7 @@ func TestCompiler(t *testing.T) {
 
 		t.Run("WASI", func(t *testing.T) {
 			runPlatTests("wasi", matches, t)
+			runTest("testdata/readdir/readdir.go", "wasi", t)
 			runTest("testdata/libc/env.go", "wasi", t, []string{"ENV1=VALUE1", "ENV2=VALUE2"}...)
 		})
 	}
@@ -117,6 +130,10 @@ func runPlatTests(target string, matches []string, t *testing.T) {
 
 	for _, path := range matches {
 		path := path // redefine to avoid race condition
+		if filepath.Base(path) == "recover.go" && (target == "wasm" || target == "wasi") {
+			// WebAssembly doesn't support unwinding the stack on a panic.
+			continue
+		}
 		t.Run(filepath.Base(path), func(t *testing.T) {
 			t.Parallel()
 			runTest(path, target, t)
@@ -124,6 +141,32 @@ func runPlatTests(target string, matches []string, t *testing.T) {
 	}
 }
 
+// TestTestFlags checks that the -run flag of `tinygo test` is passed to the test
+// binary, both on the host and on a target without command line arguments.
+func TestTestFlags(t *testing.T) {
+	targets := []string{""}
+	if !testing.Short() {
+		targets = append(targets, "cortex-m-qemu")
+	}
+	for _, target := range targets {
+		options := defaultTestOptions(target)
+		options.TestConfig.RunRegexp = "TestFoo[12]$"
+		stdout := &bytes.Buffer{}
+		buildLock.Lock()
+		passed, err := Test("./testdata/testflags", stdout, options, false, "")
+		buildLock.Unlock()
+		if err != nil {
+			printCompilerError(t.Log, err)
+			t.Errorf("target %q: could not run test", target)
+			continue
+		}
+		output := strings.Replace(stdout.String(), "\r\n", "\n", -1)
+		if !passed || !strings.HasPrefix(output, "TestFoo1\nTestFoo2\nPASS\n") {
+			t.Errorf("target %q: unexpected test output:\n%s", target, output)
+		}
+	}
+}
+
 // Due to some problems with LLD, we cannot run links in parallel, or in parallel with compiles.
 // Therefore, we put a lock around builds and run everything else in parallel.
 var buildLock sync.Mutex
@@ -136,7 +179,28 @@ func runBuild(src, out string, opts *compileopts.Options) error {
 	return Build(src, out, opts)
 }
 
+// defaultTestOptions returns the compiler options used for the tests in
+// testdata.
+func defaultTestOptions(target string) *compileopts.Options {
+	return &compileopts.Options{
+		Target:     target,
+		Opt:        "z",
+		PrintIR:    false,
+		DumpSSA:    false,
+		VerifyIR:   true,
+		Debug:      true,
+		PrintSizes: "",
+		WasmAbi:    "",
+	}
+}
+
 func runTest(path, target string, t *testing.T, environmentVars ...string) {
+	runTestWithOptions(path, defaultTestOptions(target), t, environmentVars...)
+}
+
+func runTestWithOptions(path string, config *compileopts.Options, t *testing.T, environmentVars ...string) {
+	target := config.Target
+
 	// Get the expected output for this test.
 	txtpath := path[:len(path)-3] + ".txt"
 	if path[len(path)-1] == os.PathSeparator {
@@ -160,17 +224,6 @@ func runTest(path, target string, t *testing.T, environmentVars ...string) {
 	}()
 
 	// Build the test binary.
-	config := &compileopts.Options{
-		Target:     target,
-		Opt:        "z",
-		PrintIR:    false,
-		DumpSSA:    false,
-		VerifyIR:   true,
-		Debug:      true,
-		PrintSizes: "",
-		WasmAbi:    "",
-	}
-
 	binary := filepath.Join(tmpdir, "test")
 	err = runBuild("./"+path, binary, config)
 	if err != nil {
@@ -199,6 +252,9 @@ func runTest(path, target string, t *testing.T, environmentVars ...string) {
 		}
 
 		if len(spec.Emulator) != 0 && spec.Emulator[0] == "wasmtime" {
+			// Give access to the current directory, for tests that read
+			// from the host filesystem.
+			cmd.Args = append(cmd.Args, "--dir=.")
 			for _, v := range environmentVars {
 				cmd.Args = append(cmd.Args, "--env", v)
 			}
@@ -0,0 +1,19 @@
+package riscv
+
+// Semihosting commands, the same as on ARM.
+// https://github.com/riscv/riscv-semihosting-spec
+const (
+	SemihostingExit         = 0x18
+	SemihostingExitExtended = 0x20
+)
+
+// Special codes for the semihosting exit calls.
+const (
+	SemihostingRunTimeErrorUnknown = 20023
+	SemihostingApplicationExit     = 20026
+)
+
+// Call a semihosting function. This only works when running in an emulator
+// or debugger with semihosting enabled, otherwise the processor will trap.
+//go:linkname SemihostingCall SemihostingCall
+func SemihostingCall(num int, arg uintptr) int
@@ -0,0 +1,13 @@
+// +build arduino
+
+package main
+
+import "machine"
+
+// Timer1 is a 16-bit timer, which supports a wide range of periods.
+var pwm = machine.Timer1
+
+const (
+	pinA = machine.D9
+	pinB = machine.D10
+)
@@ -0,0 +1,12 @@
+// +build feather_m4
+
+package main
+
+import "machine"
+
+var pwm = machine.TCC0
+
+const (
+	pinA = machine.D13
+	pinB = machine.D12
+)
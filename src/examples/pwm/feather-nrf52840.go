@@ -0,0 +1,13 @@
+// +build feather_nrf52840
+
+package main
+
+import "machine"
+
+// Any pin can be used with the PWM peripherals of the nRF52 chips.
+var pwm = machine.PWM0
+
+const (
+	pinA = machine.LED1
+	pinB = machine.LED2
+)
@@ -0,0 +1,12 @@
+// +build feather_stm32f405
+
+package main
+
+import "machine"
+
+var pwm = machine.TIM4
+
+const (
+	pinA = machine.D9
+	pinB = machine.D10
+)
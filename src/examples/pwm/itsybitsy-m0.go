@@ -0,0 +1,12 @@
+// +build itsybitsy_m0
+
+package main
+
+import "machine"
+
+var pwm = machine.TCC0
+
+const (
+	pinA = machine.D13
+	pinB = machine.D10
+)
@@ -1,64 +1,67 @@
 package main
 
+// This example demonstrates some features of the PWM support. Two channels of
+// the same PWM peripheral (timer) are used: one normal and one inverted. The
+// PWM peripheral and pins are defined in the board specific files.
+
 import (
 	"machine"
 	"time"
 )
 
-// This example assumes that an RGB LED is connected to pins 3, 5 and 6 on an Arduino.
-// Change the values below to use different pins.
-const (
-	redPin   = machine.D4
-	greenPin = machine.D5
-	bluePin  = machine.D6
-)
-
-// cycleColor is just a placeholder until math/rand or some equivalent is working.
-func cycleColor(color uint8) uint8 {
-	if color < 10 {
-		return color + 1
-	} else if color < 200 {
-		return color + 10
-	} else {
-		return 0
-	}
-}
+const delayBetweenPeriods = time.Second * 5
 
 func main() {
-	machine.InitPWM()
+	// Delay a bit on startup to easily catch the first messages.
+	time.Sleep(time.Second * 2)
 
-	red := machine.PWM{redPin}
-	err := red.Configure()
-	checkError(err, "failed to configure red pin")
+	// Configure the PWM with the given period.
+	err := pwm.Configure(machine.PWMConfig{
+		Period: 16384e3, // 16.384ms
+	})
+	checkError(err, "failed to configure PWM")
 
-	green := machine.PWM{greenPin}
-	err = green.Configure()
-	checkError(err, "failed to configure green pin")
+	channelA, err := pwm.Channel(pinA)
+	checkError(err, "failed to configure channel A")
+	channelB, err := pwm.Channel(pinB)
+	checkError(err, "failed to configure channel B")
 
-	blue := machine.PWM{bluePin}
-	err = blue.Configure()
-	checkError(err, "failed to configure blue pin")
+	// Invert one of the channels to demonstrate output polarity.
+	pwm.SetInverting(channelB, true)
 
-	var rc uint8
-	var gc uint8 = 20
-	var bc uint8 = 30
+	// Test out various duty cycles below, including some edge cases.
+	println("running at 0% duty cycle")
+	pwm.Set(channelA, 0)
+	pwm.Set(channelB, 0)
+	time.Sleep(delayBetweenPeriods)
 
-	for {
-		rc = cycleColor(rc)
-		gc = cycleColor(gc)
-		bc = cycleColor(bc)
+	println("running at 1")
+	pwm.Set(channelA, 1)
+	pwm.Set(channelB, 1)
+	time.Sleep(delayBetweenPeriods)
+
+	println("running at 25% duty cycle")
+	pwm.Set(channelA, pwm.Top()/4)
+	pwm.Set(channelB, pwm.Top()/4)
+	time.Sleep(delayBetweenPeriods)
 
-		red.Set(uint16(rc) << 8)
-		green.Set(uint16(gc) << 8)
-		blue.Set(uint16(bc) << 8)
+	println("running at top-1")
+	pwm.Set(channelA, pwm.Top()-1)
+	pwm.Set(channelB, pwm.Top()-1)
+	time.Sleep(delayBetweenPeriods)
 
-		time.Sleep(time.Millisecond * 500)
+	println("running at 100% duty cycle")
+	pwm.Set(channelA, pwm.Top())
+	pwm.Set(channelB, pwm.Top())
+	time.Sleep(delayBetweenPeriods)
+
+	for {
+		time.Sleep(time.Second)
 	}
 }
 
 func checkError(err error, msg string) {
 	if err != nil {
-		print(msg, ": ", err.Error())
-		println()
+		println(msg+":", err.Error())
 	}
 }
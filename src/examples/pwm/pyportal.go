@@ -0,0 +1,14 @@
+// +build pyportal
+
+package main
+
+import "machine"
+
+// The PWM outputs of the PyPortal are all in use, so this example drives two
+// of the LCD data pins.
+var pwm = machine.TCC1
+
+const (
+	pinA = machine.D34
+	pinB = machine.D35
+)
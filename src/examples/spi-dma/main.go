@@ -0,0 +1,46 @@
+package main
+
+// This example sends data over SPI in the background using DMA. StartTx
+// returns immediately, so the program can do other work (here: toggling the
+// LED) while the data is being sent. Wait blocks until the transfer is done,
+// letting other goroutines run in the meantime.
+
+import (
+	"machine"
+	"time"
+)
+
+func main() {
+	led := machine.LED
+	led.Configure(machine.PinConfig{Mode: machine.PinOutput})
+
+	spi := machine.SPI0
+	spi.Configure(machine.SPIConfig{
+		Frequency: 4000000,
+	})
+
+	// The buffer is allocated on the heap: some DMA controllers (such as
+	// EasyDMA on the nRF52) can't read data that is stored in flash.
+	buf := make([]byte, 1024)
+	for i := range buf {
+		buf[i] = byte(i)
+	}
+
+	on := false
+	for {
+		err := spi.StartTx(buf, nil)
+		if err != nil {
+			println("could not start transfer:", err.Error())
+		}
+
+		// Do something else while the transfer is in progress.
+		on = !on
+		led.Set(on)
+
+		err = spi.Wait()
+		if err != nil {
+			println("transfer failed:", err.Error())
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
@@ -45,6 +45,20 @@ func (q *Queue) Pop() *Task {
 	return t
 }
 
+// Contains returns whether the given task is in the queue.
+func (q *Queue) Contains(t *Task) bool {
+	i := interrupt.Disable()
+	found := false
+	for other := q.head; other != nil; other = other.Next {
+		if other == t {
+			found = true
+			break
+		}
+	}
+	interrupt.Restore(i)
+	return found
+}
+
 // Append pops the contents of another queue and pushes them onto the end of this queue.
 func (q *Queue) Append(other *Queue) {
 	i := interrupt.Disable()
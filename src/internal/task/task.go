@@ -15,6 +15,15 @@ type Task struct {
 	// Data is a field which can be used for storing state information.
 	Data uint
 
+	// DeferFrame stores a pointer to the (stack allocated) defer frame of the
+	// innermost function with a defer statement, used to unwind the stack
+	// when panicking.
+	DeferFrame unsafe.Pointer
+
+	// dumpInfo contains the information used for goroutine dumps, if they are
+	// supported (see GoroutineDumps).
+	dumpInfo
+
 	// state is the underlying running state of the task.
 	state state
 }
@@ -20,6 +20,12 @@ func noopState() *rawState
 
 // Resume the task until it pauses or completes.
 func (t *Task) Resume() {
+	if t.state.rawState == noopState() {
+		// The goroutine has returned, which sets the state back to the no-op
+		// state of createTask and schedules the task one last time.
+		t.removeLive()
+		return
+	}
 	t.state.resume()
 }
 
@@ -57,11 +63,14 @@ func (t *Task) getReturnPtr() unsafe.Pointer {
 	return t.Ptr
 }
 
-// createTask returns a new task struct initialized with a no-op state.
-func createTask() *Task {
-	return &Task{
+// createTask returns a new task struct initialized with a no-op state, for a
+// goroutine that starts with the given function.
+func createTask(fn uintptr) *Task {
+	t := &Task{
 		state: state{noopState()},
 	}
+	t.addLive(fn)
+	return t
 }
 
 // start invokes a function in a new goroutine. Calls to this are inserted by the compiler.
@@ -0,0 +1,99 @@
+// +build !baremetal cortexm,qemu cortexm,scheduler.tasks
+
+package task
+
+import (
+	"unsafe"
+)
+
+// GoroutineDumps is true if goroutines are tracked for goroutine dumps. This
+// takes a bit of memory for every goroutine, so it is disabled on most
+// baremetal systems. It is enabled on Cortex-M chips that use the tasks
+// scheduler, which already has a separate stack per goroutine, and in the
+// Cortex-M emulator that is used for testing.
+const GoroutineDumps = true
+
+type dumpInfo struct {
+	// waitReason and waitData describe what this goroutine is blocked on
+	// while it is paused, see SetWaitReason.
+	waitReason uint8
+	waitData   unsafe.Pointer
+
+	// entry is the function this goroutine was started with, used to look up
+	// its name.
+	entry uintptr
+
+	// prevLive and nextLive link together all goroutines that have not exited
+	// yet, see Live.
+	prevLive, nextLive *Task
+}
+
+// Goroutines that have not exited yet, from oldest to newest.
+var liveHead, liveTail *Task
+
+// addLive adds a newly started goroutine to the list of live goroutines.
+func (t *Task) addLive(entry uintptr) {
+	t.entry = entry
+	t.prevLive = liveTail
+	if liveTail != nil {
+		liveTail.nextLive = t
+	} else {
+		liveHead = t
+	}
+	liveTail = t
+}
+
+// removeLive removes an exited goroutine from the list of live goroutines.
+func (t *Task) removeLive() {
+	if t.prevLive != nil {
+		t.prevLive.nextLive = t.nextLive
+	} else {
+		liveHead = t.nextLive
+	}
+	if t.nextLive != nil {
+		t.nextLive.prevLive = t.prevLive
+	} else {
+		liveTail = t.prevLive
+	}
+	t.prevLive = nil
+	t.nextLive = nil
+}
+
+// Live returns the oldest goroutine that has not exited yet, or nil if there
+// is none. Together with NextLive it can be used to iterate over all live
+// goroutines in the order in which they were started.
+func Live() *Task {
+	return liveHead
+}
+
+// NextLive returns the next goroutine that was started after this one and has
+// not exited yet, or nil if there is none.
+func (t *Task) NextLive() *Task {
+	return t.nextLive
+}
+
+// EntryName returns the name of the function this goroutine was started with,
+// or the empty string if it is not known (for example, because the goroutine
+// was started with a function value).
+func (t *Task) EntryName() string {
+	return getGoroutineName(t.entry)
+}
+
+// SetWaitReason stores what this goroutine is about to block on. The reason
+// and data are defined by the runtime, and are only used for goroutine dumps.
+func (t *Task) SetWaitReason(reason uint8, data unsafe.Pointer) {
+	t.waitReason = reason
+	t.waitData = data
+}
+
+// WaitReason returns what this goroutine is blocked on, as set with
+// SetWaitReason.
+func (t *Task) WaitReason() (reason uint8, data unsafe.Pointer) {
+	return t.waitReason, t.waitData
+}
+
+// getGoroutineName is a compiler intrinsic that returns the name of the
+// function a goroutine was started with, or the empty string if it is not
+// known. It is implemented by a transform just before codegen, when it is
+// known which goroutines can still be started.
+func getGoroutineName(fn uintptr) string
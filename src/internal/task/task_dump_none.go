@@ -0,0 +1,40 @@
+// +build baremetal,!cortexm baremetal,!qemu,!scheduler.tasks
+
+package task
+
+import (
+	"unsafe"
+)
+
+// GoroutineDumps is false on baremetal systems other than Cortex-M with the
+// tasks scheduler, where goroutines are not tracked to save memory.
+const GoroutineDumps = false
+
+type dumpInfo struct{}
+
+func (t *Task) addLive(entry uintptr) {}
+
+func (t *Task) removeLive() {}
+
+// Live always returns nil, as goroutines are not tracked.
+func Live() *Task {
+	return nil
+}
+
+// NextLive always returns nil, as goroutines are not tracked.
+func (t *Task) NextLive() *Task {
+	return nil
+}
+
+// EntryName always returns the empty string, as goroutines are not tracked.
+func (t *Task) EntryName() string {
+	return ""
+}
+
+// SetWaitReason does nothing, as goroutines are not tracked.
+func (t *Task) SetWaitReason(reason uint8, data unsafe.Pointer) {}
+
+// WaitReason always returns zero values, as goroutines are not tracked.
+func (t *Task) WaitReason() (reason uint8, data unsafe.Pointer) {
+	return 0, nil
+}
@@ -45,8 +45,10 @@ func Pause() {
 	currentTask.state.pause()
 }
 
+// pause is called by tinygo_startTask when the goroutine returns, to exit it.
 //export tinygo_pause
 func pause() {
+	currentTask.removeLive()
 	Pause()
 }
 
@@ -96,6 +98,7 @@ func runqueuePushBack(*Task)
 // The new goroutine is scheduled to run later.
 func start(fn uintptr, args unsafe.Pointer, stackSize uintptr) {
 	t := &Task{}
+	t.addLive(fn)
 	t.state.initialize(fn, args, stackSize)
 	runqueuePushBack(t)
 }
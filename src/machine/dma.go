@@ -0,0 +1,53 @@
+// +build sam,atsamd51 nrf52 nrf52840 nrf52833 stm32f4
+// +build !scheduler.none
+
+package machine
+
+import (
+	"internal/task"
+	"runtime/interrupt"
+	"runtime/volatile"
+
+	_ "unsafe" // for go:linkname
+)
+
+// dmaWaiter lets a goroutine wait for a transfer that runs in the background,
+// usually using DMA, such as the ones started with SPI.StartTx. The interrupt
+// handler that notices the end of the transfer calls done, which puts the
+// waiting goroutine back on the run queue. Other goroutines keep running in
+// the meantime.
+type dmaWaiter struct {
+	busy volatile.Register8 // 1 while a transfer is in progress
+	task *task.Task         // goroutine blocked in wait, if any
+}
+
+// start marks the beginning of a transfer.
+func (w *dmaWaiter) start() {
+	w.busy.Set(1)
+}
+
+// wait blocks the current goroutine until the transfer is done. It returns
+// immediately if no transfer is in progress.
+func (w *dmaWaiter) wait() {
+	mask := interrupt.Disable()
+	if w.busy.Get() == 0 {
+		interrupt.Restore(mask)
+		return
+	}
+	w.task = task.Current()
+	interrupt.Restore(mask)
+	task.Pause()
+}
+
+// done marks the end of the transfer and wakes up the waiting goroutine. It is
+// called from an interrupt handler.
+func (w *dmaWaiter) done() {
+	w.busy.Set(0)
+	if t := w.task; t != nil {
+		w.task = nil
+		runqueuePushBack(t)
+	}
+}
+
+//go:linkname runqueuePushBack runtime.runqueuePushBack
+func runqueuePushBack(*task.Task)
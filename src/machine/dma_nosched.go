@@ -0,0 +1,35 @@
+// +build sam,atsamd51 nrf52 nrf52840 nrf52833 stm32f4
+// +build scheduler.none
+
+package machine
+
+import (
+	"device/arm"
+	"runtime/volatile"
+)
+
+// dmaWaiter lets the program wait for a transfer that runs in the background,
+// usually using DMA, such as the ones started with SPI.StartTx. Without a
+// scheduler there are no other goroutines to run, so wait sleeps until the
+// interrupt handler that notices the end of the transfer calls done.
+type dmaWaiter struct {
+	busy volatile.Register8 // 1 while a transfer is in progress
+}
+
+// start marks the beginning of a transfer.
+func (w *dmaWaiter) start() {
+	w.busy.Set(1)
+}
+
+// wait blocks until the transfer is done. It returns immediately if no
+// transfer is in progress.
+func (w *dmaWaiter) wait() {
+	for w.busy.Get() != 0 {
+		arm.Asm("wfe")
+	}
+}
+
+// done marks the end of the transfer. It is called from an interrupt handler.
+func (w *dmaWaiter) done() {
+	w.busy.Set(0)
+}
@@ -0,0 +1,11 @@
+// +build atsamd21 atsamd51 nrf52 nrf52840 nrf52833
+// +build !scheduler.none
+
+package machine
+
+import _ "unsafe" // for go:linkname
+
+// gosched lets other goroutines run while polling a peripheral, for example
+// while waiting for the controller in I2C target mode.
+//go:linkname gosched runtime.Gosched
+func gosched()
@@ -0,0 +1,9 @@
+// +build atsamd21 atsamd51 nrf52 nrf52840 nrf52833
+// +build scheduler.none
+
+package machine
+
+// gosched does nothing: without a scheduler there are no other goroutines
+// that could run while polling a peripheral.
+func gosched() {
+}
@@ -0,0 +1,49 @@
+// +build atsamd21 atsamd51 nrf52 nrf52840 nrf52833 !baremetal
+
+package machine
+
+// I2CTargetEvent is an event that happened on the bus while the I2C peripheral
+// is used as a target (previously called slave), returned by WaitForEvent.
+//
+// To use an I2C peripheral as a target, configure the pins with Configure and
+// then call Listen with the address the target should respond to. A typical
+// main loop looks like this:
+//
+//     i2c.Listen(0x42)
+//     buf := make([]byte, 16)
+//     for {
+//         evt, n, err := i2c.WaitForEvent(buf)
+//         if err != nil {
+//             continue
+//         }
+//         switch evt {
+//         case machine.I2CReceive:
+//             // The controller wrote buf[:n], for example a register number.
+//         case machine.I2CRequest:
+//             // The controller wants to read: send the data back.
+//             i2c.Reply(data)
+//         case machine.I2CFinish:
+//             // The controller sent a stop condition.
+//         }
+//     }
+//
+// A transaction starts with one or more I2CReceive or I2CRequest events (more
+// than one when the controller uses a repeated start, for example to write a
+// register number and then read it) and ends with I2CFinish.
+type I2CTargetEvent uint8
+
+const (
+	// I2CReceive means the controller has written data to this target. The
+	// data is stored in the buffer passed to WaitForEvent. When the buffer is
+	// full, any further bytes of the write are not acknowledged.
+	I2CReceive I2CTargetEvent = iota
+
+	// I2CRequest means the controller wants to read data from this target.
+	// The program must respond with a call to Reply, the clock is stretched
+	// until it does so.
+	I2CRequest
+
+	// I2CFinish means the controller has ended the transaction with a stop
+	// condition.
+	I2CFinish
+)
@@ -37,6 +37,11 @@ func (p Pin) Low() {
 	p.Set(false)
 }
 
+// PWM is a single pin that is used as a PWM output, with a 16-bit duty cycle.
+//
+// Deprecated: use the PWM peripherals of the chip instead (for example TCC0,
+// TIM1, PWM0 or Timer1), which are configured with a PWMConfig and can also set
+// the period. PWM is still supported on all chips where it was supported before.
 type PWM struct {
 	Pin Pin
 }
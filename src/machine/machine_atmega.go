@@ -119,12 +119,22 @@ type UART struct {
 	Buffer *RingBuffer
 }
 
-// Configure the UART on the AVR. Defaults to 9600 baud on Arduino.
-func (uart UART) Configure(config UARTConfig) {
+// Configure the UART on the AVR. Defaults to 9600 baud on Arduino. Hardware
+// flow control is not supported.
+func (uart UART) Configure(config UARTConfig) error {
 	if config.BaudRate == 0 {
 		config.BaudRate = 9600
 	}
 
+	if config.flowControl() {
+		return ErrUARTFlowControlNotSupported
+	}
+	dataBits, stopBits := config.format()
+	err := uart.SetFormat(dataBits, stopBits, config.Parity)
+	if err != nil {
+		return err
+	}
+
 	// Register the UART interrupt.
 	interrupt.New(irq_USART0_RX, func(intr interrupt.Interrupt) {
 		// Read register to clear it.
@@ -147,8 +157,37 @@ func (uart UART) Configure(config UARTConfig) {
 	// enable RX, TX and RX interrupt
 	avr.UCSR0B.Set(avr.UCSR0B_RXEN0 | avr.UCSR0B_TXEN0 | avr.UCSR0B_RXCIE0)
 
-	// 8-bits data
-	avr.UCSR0C.Set(avr.UCSR0C_UCSZ01 | avr.UCSR0C_UCSZ00)
+	return nil
+}
+
+// SetFormat changes the number of data bits (5 to 8), the parity and the
+// number of stop bits (1 or 2) of the UART.
+func (uart UART) SetFormat(dataBits, stopBits uint8, parity UARTParity) error {
+	if dataBits < 5 || dataBits > 8 {
+		// 9 bits is supported by the hardware, but doesn't fit in the
+		// receive buffer.
+		return ErrUARTFormatNotSupported
+	}
+	// UCSZ is 0 for 5 bits up to 3 for 8 bits.
+	ucsr0c := (dataBits - 5) << 1
+	switch stopBits {
+	case 1:
+	case 2:
+		ucsr0c |= avr.UCSR0C_USBS0
+	default:
+		return ErrUARTFormatNotSupported
+	}
+	switch parity {
+	case ParityNone:
+	case ParityEven:
+		ucsr0c |= avr.UCSR0C_UPM01
+	case ParityOdd:
+		ucsr0c |= avr.UCSR0C_UPM01 | avr.UCSR0C_UPM00
+	default:
+		return ErrUARTFormatNotSupported
+	}
+	avr.UCSR0C.Set(ucsr0c)
+	return nil
 }
 
 // WriteByte writes a byte of data to the UART.
@@ -0,0 +1,208 @@
+// +build avr,atmega328p
+
+package machine
+
+import (
+	"device/avr"
+	"runtime/volatile"
+)
+
+// Timer is one of the timers of the atmega328p, used as a PWM peripheral.
+// Every timer has two channels (A and B), each connected to a fixed pin.
+// Timer0 and Timer2 are 8-bit timers that only support a few periods, while
+// Timer1 is a 16-bit timer that supports a wide range of periods.
+type Timer struct {
+	num       uint8
+	inverting uint8 // bit set for every inverted channel
+}
+
+// The timers of the atmega328p. Timer0 and Timer2 always count to 0xff, so
+// their period is rounded up to the next period that is supported by the
+// prescaler.
+var (
+	Timer0 = &Timer{num: 0} // channel A on PD6, channel B on PD5
+	Timer1 = &Timer{num: 1} // channel A on PB1, channel B on PB2
+	Timer2 = &Timer{num: 2} // channel A on PB3, channel B on PD3
+)
+
+// timerPins lists the output pins of channel A and B of every timer.
+var timerPins = [3][2]Pin{
+	{PD6, PD5},
+	{PB1, PB2},
+	{PB3, PD3},
+}
+
+// The clock prescalers that are supported by the timers. The clock select
+// (CS) bits of a prescaler are its index plus one.
+var (
+	timerPrescalers  = [...]uint64{1, 8, 64, 256, 1024}          // Timer0, Timer1
+	timer2Prescalers = [...]uint64{1, 8, 32, 64, 128, 256, 1024} // Timer2
+)
+
+// Configure enables and configures this timer for PWM with the given period.
+// The outputs of all channels are disconnected until they are set with Set.
+func (t *Timer) Configure(config PWMConfig) error {
+	// Find the smallest prescaler that can be used for this period.
+	cycles := pwmCycles(config.Period, uint64(CPUFrequency()/1000000))
+	prescalers := timerPrescalers[:]
+	if t.num == 2 {
+		prescalers = timer2Prescalers[:]
+	}
+	maxTop := uint64(0xff)
+	if t.num == 1 {
+		maxTop = 0xffff
+	}
+	var cs uint8
+	var top uint64
+	for i, prescaler := range prescalers {
+		if cycles <= (maxTop+1)*prescaler {
+			cs = uint8(i) + 1
+			top = cycles / prescaler
+			break
+		}
+	}
+	if cs == 0 {
+		return ErrPWMPeriodTooLong
+	}
+	if top > 0 {
+		top-- // the counter counts from 0 to top (inclusive)
+	}
+
+	// Use fast PWM mode. The 8-bit timers use mode 3 (top is 0xff) and Timer1
+	// uses mode 14 (top is ICR1).
+	switch t.num {
+	case 0:
+		avr.TCCR0B.Set(0)
+		avr.TCCR0A.Set(avr.TCCR0A_WGM01 | avr.TCCR0A_WGM00)
+		avr.TCCR0B.Set(cs)
+	case 1:
+		avr.TCCR1B.Set(0)
+		avr.TCCR1A.Set(avr.TCCR1A_WGM11)
+		// 16-bit registers must be written with the high byte first.
+		avr.ICR1H.Set(uint8(top >> 8))
+		avr.ICR1L.Set(uint8(top))
+		avr.TCCR1B.Set(avr.TCCR1B_WGM13 | avr.TCCR1B_WGM12 | cs)
+	case 2:
+		avr.TCCR2B.Set(0)
+		avr.TCCR2A.Set(avr.TCCR2A_WGM21 | avr.TCCR2A_WGM20)
+		avr.TCCR2B.Set(cs)
+	}
+	return nil
+}
+
+// Top returns the current counter top, for use in duty cycle calculation. It
+// will only change with a call to Configure.
+//
+// The value returned here is hardware dependent. In general, it's best to treat
+// it as an opaque value that can be divided by some number and passed to Set
+// (see Set documentation for more information).
+func (t *Timer) Top() uint32 {
+	if t.num == 1 {
+		// 16-bit registers must be read with the low byte first.
+		low := avr.ICR1L.Get()
+		return uint32(avr.ICR1H.Get())<<8 | uint32(low)
+	}
+	return 0xff
+}
+
+// Channel returns a PWM channel for the given pin. The pin is configured as an
+// output, and must be one of the two pins of this timer. Otherwise,
+// ErrInvalidOutputPin is returned.
+func (t *Timer) Channel(pin Pin) (uint8, error) {
+	for channel, p := range timerPins[t.num] {
+		if p == pin {
+			pin.Configure(PinConfig{Mode: PinOutput})
+			pin.Set(t.inverting&(1<<uint8(channel)) != 0)
+			return uint8(channel), nil
+		}
+	}
+	return 0, ErrInvalidOutputPin
+}
+
+// SetInverting sets whether to invert the output of this channel.
+// Without inverting, a 25% duty cycle would mean the output is high for 25% of
+// the time and low for the rest. Inverting flips the output as if a NOT gate
+// was placed at the output, meaning that the output would be 25% low and 75%
+// high with a duty cycle of 25%.
+func (t *Timer) SetInverting(channel uint8, inverting bool) {
+	if inverting {
+		t.inverting |= 1 << channel
+	} else {
+		t.inverting &^= 1 << channel
+	}
+	tccrA := t.tccrA()
+	shift := 6 - channel*2
+	if (tccrA.Get()>>shift)&0x3 == 0 {
+		// The output is disconnected from the timer (duty cycle of 0).
+		timerPins[t.num][channel].Set(inverting)
+		return
+	}
+	tccrA.ReplaceBits(t.compareOutputMode(channel), 0x3, shift)
+}
+
+// Set updates the channel value. This is used to control the channel duty
+// cycle, in other words the fraction of time the channel output is high (or
+// low when inverted). For example, to set it to a 25% duty cycle, use:
+//
+//     t.Set(channel, t.Top() / 4)
+//
+// t.Set(channel, 0) will set the output to low and t.Set(channel, t.Top()) will
+// set the output to high, assuming the output isn't inverted.
+func (t *Timer) Set(channel uint8, value uint32) {
+	tccrA := t.tccrA()
+	shift := 6 - channel*2
+	if value == 0 {
+		// A compare value of 0 still results in a short pulse at the start of
+		// every period, so disconnect the output from the timer instead.
+		tccrA.ReplaceBits(0, 0x3, shift)
+		timerPins[t.num][channel].Set(t.inverting&(1<<channel) != 0)
+		return
+	}
+	switch t.num {
+	case 0:
+		if channel == 0 {
+			avr.OCR0A.Set(uint8(value))
+		} else {
+			avr.OCR0B.Set(uint8(value))
+		}
+	case 1:
+		// 16-bit registers must be written with the high byte first.
+		if channel == 0 {
+			avr.OCR1AH.Set(uint8(value >> 8))
+			avr.OCR1AL.Set(uint8(value))
+		} else {
+			avr.OCR1BH.Set(uint8(value >> 8))
+			avr.OCR1BL.Set(uint8(value))
+		}
+	case 2:
+		if channel == 0 {
+			avr.OCR2A.Set(uint8(value))
+		} else {
+			avr.OCR2B.Set(uint8(value))
+		}
+	}
+	tccrA.ReplaceBits(t.compareOutputMode(channel), 0x3, shift)
+}
+
+// tccrA returns the TCCRnA register of this timer, which contains the compare
+// output mode (COMnA and COMnB) bits of both channels.
+func (t *Timer) tccrA() *volatile.Register8 {
+	switch t.num {
+	case 0:
+		return avr.TCCR0A
+	case 1:
+		return avr.TCCR1A
+	default:
+		return avr.TCCR2A
+	}
+}
+
+// compareOutputMode returns the COMnx bits for a connected channel: 0b10
+// clears the output on a compare match (non-inverting) and 0b11 sets it
+// (inverting).
+func (t *Timer) compareOutputMode(channel uint8) uint8 {
+	if t.inverting&(1<<channel) != 0 {
+		return 0x3
+	}
+	return 0x2
+}
@@ -511,6 +511,13 @@ func (uart UART) Configure(config UARTConfig) error {
 		config.BaudRate = 115200
 	}
 
+	// Check the frame format before changing anything.
+	dataBits, stopBits := config.format()
+	form, ctrlb, err := uartFrameFormat(dataBits, stopBits, config.Parity)
+	if err != nil {
+		return err
+	}
+
 	// Use default pins if pins are not set.
 	if config.TX == 0 && config.RX == 0 {
 		// use default pins
@@ -526,13 +533,18 @@ func (uart UART) Configure(config UARTConfig) error {
 	var txPinOut uint32
 	// See table 25-9 of the datasheet (page 459) for how pads are mapped to
 	// pinout values.
-	switch txPad {
-	case 0:
+	switch {
+	case config.flowControl():
+		// TX on pad 0, RTS on pad 2 and CTS on pad 3.
+		if txPad != 0 {
+			return ErrInvalidOutputPin
+		}
+		txPinOut = 2
+	case txPad == 0:
 		txPinOut = 0
-	case 2:
+	case txPad == 2:
 		txPinOut = 1
 	default:
-		// TODO: flow control (RTS/CTS)
 		return ErrInvalidOutputPin
 	}
 
@@ -545,9 +557,28 @@ func (uart UART) Configure(config UARTConfig) error {
 	// are mapped directly.
 	rxPinOut := rxPad
 
+	// Determine the flow control pins, which must be on pad 2 (RTS) and pad
+	// 3 (CTS).
+	var rtsPinMode, ctsPinMode PinMode
+	if config.flowControl() {
+		var rtsPad, ctsPad uint32
+		rtsPinMode, rtsPad, ok = findPinPadMapping(uart.SERCOM, config.RTS)
+		if !ok || rtsPad != 2 {
+			return ErrInvalidOutputPin
+		}
+		ctsPinMode, ctsPad, ok = findPinPadMapping(uart.SERCOM, config.CTS)
+		if !ok || ctsPad != 3 {
+			return ErrInvalidInputPin
+		}
+	}
+
 	// configure pins
 	config.TX.Configure(PinConfig{Mode: txPinMode})
 	config.RX.Configure(PinConfig{Mode: rxPinMode})
+	if config.flowControl() {
+		config.RTS.Configure(PinConfig{Mode: rtsPinMode})
+		config.CTS.Configure(PinConfig{Mode: ctsPinMode})
+	}
 
 	// reset SERCOM0
 	uart.Bus.CTRLA.SetBits(sam.SERCOM_USART_CTRLA_SWRST)
@@ -564,19 +595,10 @@ func (uart UART) Configure(config UARTConfig) error {
 	// Set baud rate
 	uart.SetBaudRate(config.BaudRate)
 
-	// setup UART frame
-	// SERCOM_USART_CTRLA_FORM( (parityMode == SERCOM_NO_PARITY ? 0 : 1) ) |
-	// dataOrder << SERCOM_USART_CTRLA_DORD_Pos;
-	uart.Bus.CTRLA.SetBits((0 << sam.SERCOM_USART_CTRLA_FORM_Pos) | // no parity
+	// setup UART frame: parity, data order, character size and stop bits
+	uart.Bus.CTRLA.SetBits((form << sam.SERCOM_USART_CTRLA_FORM_Pos) |
 		(lsbFirst << sam.SERCOM_USART_CTRLA_DORD_Pos)) // data order
-
-	// set UART stop bits/parity
-	// SERCOM_USART_CTRLB_CHSIZE(charSize) |
-	// 	nbStopBits << SERCOM_USART_CTRLB_SBMODE_Pos |
-	// 	(parityMode == SERCOM_NO_PARITY ? 0 : parityMode) << SERCOM_USART_CTRLB_PMODE_Pos; //If no parity use default value
-	uart.Bus.CTRLB.SetBits((0 << sam.SERCOM_USART_CTRLB_CHSIZE_Pos) | // 8 bits is 0
-		(0 << sam.SERCOM_USART_CTRLB_SBMODE_Pos) | // 1 stop bit is zero
-		(0 << sam.SERCOM_USART_CTRLB_PMODE_Pos)) // no parity
+	uart.Bus.CTRLB.SetBits(ctrlb)
 
 	// set UART pads. This is not same as pins...
 	//  SERCOM_USART_CTRLA_TXPO(txPad) |
@@ -617,6 +639,62 @@ func (uart UART) SetBaudRate(br uint32) {
 		((baud / 8) << sam.SERCOM_USART_BAUD_FRAC_MODE_BAUD_Pos)))
 }
 
+// SetFormat changes the number of data bits (5 to 8), the parity and the
+// number of stop bits (1 or 2) of the UART.
+func (uart UART) SetFormat(dataBits, stopBits uint8, parity UARTParity) error {
+	form, ctrlb, err := uartFrameFormat(dataBits, stopBits, parity)
+	if err != nil {
+		return err
+	}
+
+	// The frame format can only be changed while the UART is disabled.
+	uart.Bus.CTRLA.ClearBits(sam.SERCOM_USART_CTRLA_ENABLE)
+	for uart.Bus.SYNCBUSY.HasBits(sam.SERCOM_USART_SYNCBUSY_ENABLE) {
+	}
+	uart.Bus.CTRLA.ClearBits(sam.SERCOM_USART_CTRLA_FORM_Msk)
+	uart.Bus.CTRLA.SetBits(form << sam.SERCOM_USART_CTRLA_FORM_Pos)
+	uart.Bus.CTRLB.ClearBits(sam.SERCOM_USART_CTRLB_CHSIZE_Msk | sam.SERCOM_USART_CTRLB_SBMODE | sam.SERCOM_USART_CTRLB_PMODE)
+	uart.Bus.CTRLB.SetBits(ctrlb)
+	uart.Bus.CTRLA.SetBits(sam.SERCOM_USART_CTRLA_ENABLE)
+	for uart.Bus.SYNCBUSY.HasBits(sam.SERCOM_USART_SYNCBUSY_ENABLE) {
+	}
+	return nil
+}
+
+// uartFrameFormat returns the value of the FORM field of CTRLA and the
+// CHSIZE, SBMODE and PMODE fields of CTRLB for the given frame format.
+func uartFrameFormat(dataBits, stopBits uint8, parity UARTParity) (form, ctrlb uint32, err error) {
+	switch dataBits {
+	case 5, 6, 7:
+		ctrlb = uint32(dataBits) << sam.SERCOM_USART_CTRLB_CHSIZE_Pos
+	case 8:
+		// CHSIZE is 0 for 8 bits.
+	default:
+		// 9 bits is supported by the hardware, but doesn't fit in the
+		// receive buffer.
+		return 0, 0, ErrUARTFormatNotSupported
+	}
+	switch stopBits {
+	case 1:
+	case 2:
+		ctrlb |= sam.SERCOM_USART_CTRLB_SBMODE
+	default:
+		return 0, 0, ErrUARTFormatNotSupported
+	}
+	switch parity {
+	case ParityNone:
+		form = 0 // USART frame
+	case ParityEven:
+		form = 1 // USART frame with parity
+	case ParityOdd:
+		form = 1
+		ctrlb |= sam.SERCOM_USART_CTRLB_PMODE
+	default:
+		return 0, 0, ErrUARTFormatNotSupported
+	}
+	return form, ctrlb, nil
+}
+
 // WriteByte writes a byte of data to the UART.
 func (uart UART) WriteByte(c byte) error {
 	// wait until ready to receive
@@ -885,6 +963,129 @@ func (i2c I2C) readByte() byte {
 	return byte(i2c.Bus.DATA.Get())
 }
 
+// I2C target commands, written to the CMD field of CTRLB.
+const (
+	i2cTargetCmdWaitStart = 2 // execute the acknowledge action, then wait for a start condition
+	i2cTargetCmdNextByte  = 3 // execute the acknowledge action, then continue with the next byte
+)
+
+// Listen switches the I2C peripheral to target mode, responding to the given
+// 7-bit address. Configure must be called first to set up the pins. Use
+// WaitForEvent to handle transactions from the controller.
+func (i2c I2C) Listen(addr uint16) error {
+	target := i2c.target()
+
+	// reset SERCOM
+	target.CTRLA.SetBits(sam.SERCOM_I2CS_CTRLA_SWRST)
+	for target.CTRLA.HasBits(sam.SERCOM_I2CS_CTRLA_SWRST) ||
+		target.SYNCBUSY.HasBits(sam.SERCOM_I2CS_SYNCBUSY_SWRST) {
+	}
+
+	// Set i2c target mode. Smart mode acknowledges received bytes when DATA is
+	// read, and continues with the next byte when DATA is written.
+	target.CTRLA.Set(sam.SERCOM_I2CS_CTRLA_MODE_I2C_SLAVE << sam.SERCOM_I2CS_CTRLA_MODE_Pos)
+	target.CTRLB.Set(sam.SERCOM_I2CS_CTRLB_SMEN)
+	target.ADDR.Set(uint32(addr) << sam.SERCOM_I2CS_ADDR_ADDR_Pos)
+
+	// Enable I2CS port.
+	target.CTRLA.SetBits(sam.SERCOM_I2CS_CTRLA_ENABLE)
+	for target.SYNCBUSY.HasBits(sam.SERCOM_I2CS_SYNCBUSY_ENABLE) {
+	}
+
+	return nil
+}
+
+// WaitForEvent blocks until the controller does something with this target,
+// see I2CTargetEvent. For an I2CReceive event, the received data is stored in
+// buf and the number of bytes is returned.
+//
+// Other goroutines keep running while waiting, but the bus is polled so the
+// CPU does not sleep.
+func (i2c I2C) WaitForEvent(buf []byte) (evt I2CTargetEvent, count int, err error) {
+	target := i2c.target()
+	for {
+		flags := target.INTFLAG.Get()
+		switch {
+		case flags&sam.SERCOM_I2CS_INTFLAG_ERROR != 0:
+			target.INTFLAG.Set(sam.SERCOM_I2CS_INTFLAG_ERROR)
+			return I2CReceive, 0, errI2CBusError
+		case flags&sam.SERCOM_I2CS_INTFLAG_AMATCH != 0:
+			// Acknowledge the address, which also clears the AMATCH flag.
+			read := target.STATUS.HasBits(sam.SERCOM_I2CS_STATUS_DIR)
+			target.CTRLB.ClearBits(sam.SERCOM_I2CS_CTRLB_ACKACT)
+			target.CTRLB.SetBits(i2cTargetCmdNextByte << sam.SERCOM_I2CS_CTRLB_CMD_Pos)
+			if read {
+				// The data is sent by Reply.
+				return I2CRequest, 0, nil
+			}
+
+			// Receive bytes until the controller sends a stop condition or
+			// a repeated start. Those flags are left set, so that they are
+			// handled in the next call.
+			for {
+				for !target.INTFLAG.HasBits(sam.SERCOM_I2CS_INTFLAG_DRDY | sam.SERCOM_I2CS_INTFLAG_PREC |
+					sam.SERCOM_I2CS_INTFLAG_AMATCH | sam.SERCOM_I2CS_INTFLAG_ERROR) {
+					gosched()
+				}
+				if !target.INTFLAG.HasBits(sam.SERCOM_I2CS_INTFLAG_DRDY) {
+					return I2CReceive, count, nil
+				}
+				if count == len(buf) {
+					// The buffer is full: don't acknowledge this byte.
+					target.CTRLB.SetBits(sam.SERCOM_I2CS_CTRLB_ACKACT)
+					target.DATA.Get()
+					continue
+				}
+				buf[count] = byte(target.DATA.Get())
+				count++
+			}
+		case flags&sam.SERCOM_I2CS_INTFLAG_PREC != 0:
+			target.INTFLAG.Set(sam.SERCOM_I2CS_INTFLAG_PREC)
+			return I2CFinish, 0, nil
+		}
+
+		// Nothing happened yet: let other goroutines run while waiting.
+		gosched()
+	}
+}
+
+// Reply sends data to the controller after an I2CRequest event. If the
+// controller reads more bytes than there are in buf, 0xff is sent for the
+// remaining bytes.
+func (i2c I2C) Reply(buf []byte) error {
+	target := i2c.target()
+	for i := 0; ; i++ {
+		for !target.INTFLAG.HasBits(sam.SERCOM_I2CS_INTFLAG_DRDY | sam.SERCOM_I2CS_INTFLAG_PREC |
+			sam.SERCOM_I2CS_INTFLAG_AMATCH | sam.SERCOM_I2CS_INTFLAG_ERROR) {
+			gosched()
+		}
+		if target.INTFLAG.HasBits(sam.SERCOM_I2CS_INTFLAG_ERROR) {
+			target.INTFLAG.Set(sam.SERCOM_I2CS_INTFLAG_ERROR)
+			return errI2CBusError
+		}
+		if !target.INTFLAG.HasBits(sam.SERCOM_I2CS_INTFLAG_DRDY) {
+			// Stop condition or repeated start, handled by WaitForEvent.
+			return nil
+		}
+		if i != 0 && target.STATUS.HasBits(sam.SERCOM_I2CS_STATUS_RXNACK) {
+			// The controller did not acknowledge the previous byte, so it
+			// doesn't want to read any more.
+			target.CTRLB.SetBits(i2cTargetCmdWaitStart << sam.SERCOM_I2CS_CTRLB_CMD_Pos)
+			return nil
+		}
+		data := byte(0xff)
+		if i < len(buf) {
+			data = buf[i]
+		}
+		target.DATA.Set(data)
+	}
+}
+
+// target returns the registers of the SERCOM peripheral in I2C target mode.
+func (i2c I2C) target() *sam.SERCOM_I2CS_Type {
+	return (*sam.SERCOM_I2CS_Type)(unsafe.Pointer(i2c.Bus))
+}
+
 // I2S on the SAMD21.
 
 // I2S
@@ -1266,201 +1467,268 @@ func (spi SPI) Transfer(w byte) (byte, error) {
 }
 
 // PWM
-const period = 0xFFFF
 
-// InitPWM initializes the PWM interface.
-func InitPWM() {
-	// turn on timer clocks used for PWM
-	sam.PM.APBCMASK.SetBits(sam.PM_APBCMASK_TCC0_ | sam.PM_APBCMASK_TCC1_ | sam.PM_APBCMASK_TCC2_)
+// TCC is one timer/counter for control applications peripheral (TCC) on the
+// SAMD21, which can be used for PWM. Each TCC has a single period but multiple
+// channels that can each have their own duty cycle.
+type TCC sam.TCC_Type
 
-	// Use GCLK0 for TCC0/TCC1
-	sam.GCLK.CLKCTRL.Set((sam.GCLK_CLKCTRL_ID_TCC0_TCC1 << sam.GCLK_CLKCTRL_ID_Pos) |
-		(sam.GCLK_CLKCTRL_GEN_GCLK0 << sam.GCLK_CLKCTRL_GEN_Pos) |
-		sam.GCLK_CLKCTRL_CLKEN)
-	for sam.GCLK.STATUS.HasBits(sam.GCLK_STATUS_SYNCBUSY) {
-	}
+// The TCC peripherals of the SAMD21. TCC0 has 4 channels and a 24-bit counter,
+// TCC1 has 2 channels and a 24-bit counter, and TCC2 has 2 channels and a
+// 16-bit counter.
+var (
+	TCC0 = (*TCC)(sam.TCC0)
+	TCC1 = (*TCC)(sam.TCC1)
+	TCC2 = (*TCC)(sam.TCC2)
+)
 
-	// Use GCLK0 for TCC2/TC3
-	sam.GCLK.CLKCTRL.Set((sam.GCLK_CLKCTRL_ID_TCC2_TC3 << sam.GCLK_CLKCTRL_ID_Pos) |
+// pwmPeripherals lists the TCCs that are used by the deprecated PWM API.
+var pwmPeripherals = [...]pwmPeripheral{TCC0, TCC1, TCC2}
+
+// The TCC prescaler values that can be selected in CTRLA.PRESCALER, in order.
+var tccPrescalers = [...]uint64{1, 2, 4, 8, 16, 64, 256, 1024}
+
+// Configure enables and configures this TCC for PWM with the given period. All
+// channels are set to a duty cycle of 0 (always low).
+func (tcc *TCC) Configure(config PWMConfig) error {
+	// Turn on the clock of this TCC and use GCLK0 (48MHz) as its source.
+	var clockID uint16
+	switch tcc.timer() {
+	case sam.TCC0:
+		sam.PM.APBCMASK.SetBits(sam.PM_APBCMASK_TCC0_)
+		clockID = sam.GCLK_CLKCTRL_ID_TCC0_TCC1
+	case sam.TCC1:
+		sam.PM.APBCMASK.SetBits(sam.PM_APBCMASK_TCC1_)
+		clockID = sam.GCLK_CLKCTRL_ID_TCC0_TCC1
+	case sam.TCC2:
+		sam.PM.APBCMASK.SetBits(sam.PM_APBCMASK_TCC2_)
+		clockID = sam.GCLK_CLKCTRL_ID_TCC2_TC3
+	}
+	sam.GCLK.CLKCTRL.Set((clockID << sam.GCLK_CLKCTRL_ID_Pos) |
 		(sam.GCLK_CLKCTRL_GEN_GCLK0 << sam.GCLK_CLKCTRL_GEN_Pos) |
 		sam.GCLK_CLKCTRL_CLKEN)
 	for sam.GCLK.STATUS.HasBits(sam.GCLK_STATUS_SYNCBUSY) {
 	}
-}
 
-// Configure configures a PWM pin for output.
-func (pwm PWM) Configure() error {
-	// figure out which TCCX timer for this pin
-	timer := pwm.getTimer()
-	if timer == nil {
-		return ErrInvalidOutputPin
+	// Calculate the prescaler and the top value for this period.
+	cycles := pwmCycles(config.Period, 48)
+	prescaler := 0
+	for cycles/tccPrescalers[prescaler] > tcc.maxTop()+1 {
+		prescaler++
+		if prescaler == len(tccPrescalers) {
+			return ErrPWMPeriodTooLong
+		}
 	}
-
-	// disable timer
-	timer.CTRLA.ClearBits(sam.TCC_CTRLA_ENABLE)
-	// Wait for synchronization
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_ENABLE) {
+	top := cycles / tccPrescalers[prescaler]
+	if top > 0 {
+		top-- // the counter counts from 0 to top (inclusive)
 	}
 
-	// Use "Normal PWM" (single-slope PWM)
-	timer.WAVE.SetBits(sam.TCC_WAVE_WAVEGEN_NPWM)
-	// Wait for synchronization
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_WAVE) {
-	}
+	// Disable the timer while it is being configured.
+	tcc.disable()
 
-	// Set the period (the number to count to (TOP) before resetting timer)
-	//TCC0->PER.reg = period;
-	timer.PER.Set(period)
-	// Wait for synchronization
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_PER) {
+	// Use "Normal PWM" (single-slope PWM) with the prescaler from above.
+	tcc.CTRLA.Set(uint32(prescaler) << sam.TCC_CTRLA_PRESCALER_Pos)
+	tcc.WAVE.Set(sam.TCC_WAVE_WAVEGEN_NPWM)
+	for tcc.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_WAVE) {
 	}
 
-	// Set pin as output
-	sam.PORT.DIRSET0.Set(1 << uint8(pwm.Pin))
-	// Set pin to low
-	sam.PORT.OUTCLR0.Set(1 << uint8(pwm.Pin))
-
-	// Enable the port multiplexer for pin
-	pwm.setPinCfg(sam.PORT_PINCFG0_PMUXEN)
-
-	// Connect TCCX timer to pin.
-	// we normally use the F channel aka ALT
-	pwmConfig := PinPWMAlt
-
-	// in the case of PA6 or PA7 we have to use E channel
-	if pwm.Pin == 6 || pwm.Pin == 7 {
-		pwmConfig = PinPWM
+	// Set the period (the number to count to (TOP) before resetting timer).
+	tcc.PER.Set(uint32(top))
+	for tcc.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_PER) {
 	}
 
-	if pwm.Pin&1 > 0 {
-		// odd pin, so save the even pins
-		val := pwm.getPMux() & sam.PORT_PMUX0_PMUXE_Msk
-		pwm.setPMux(val | uint8(pwmConfig<<sam.PORT_PMUX0_PMUXO_Pos))
-	} else {
-		// even pin, so save the odd pins
-		val := pwm.getPMux() & sam.PORT_PMUX0_PMUXO_Msk
-		pwm.setPMux(val | uint8(pwmConfig<<sam.PORT_PMUX0_PMUXE_Pos))
+	// Start with all channels at 0% duty cycle.
+	for channel := uint8(0); channel < tcc.channels(); channel++ {
+		tcc.Set(channel, 0)
 	}
 
+	tcc.enable()
 	return nil
 }
 
-// Set turns on the duty cycle for a PWM pin using the provided value.
-func (pwm PWM) Set(value uint16) {
-	// figure out which TCCX timer for this pin
-	timer := pwm.getTimer()
-	if timer == nil {
-		// The Configure call above cannot have succeeded, so simply ignore this
-		// error.
-		return
-	}
+// Top returns the current counter top, for use in duty cycle calculation. It
+// will only change with a call to Configure.
+//
+// The value returned here is hardware dependent. In general, it's best to treat
+// it as an opaque value that can be divided by some number and passed to Set
+// (see Set documentation for more information).
+func (tcc *TCC) Top() uint32 {
+	return tcc.PER.Get()
+}
 
-	// disable output
-	timer.CTRLA.ClearBits(sam.TCC_CTRLA_ENABLE)
+// Channel returns a PWM channel for the given pin. The pin is configured as a
+// PWM output of this TCC. Note that a pin can only be used with some of the
+// TCC peripherals, so this will return ErrInvalidOutputPin if the pin is not
+// connected to this TCC.
+//
+// Pins that are connected to the same channel share the same duty cycle.
+func (tcc *TCC) Channel(pin Pin) (uint8, error) {
+	// Find the output (WO) of this TCC that is connected to the pin.
+	timer := tcc.timer()
+	for _, mapping := range tccPinMapping {
+		if mapping.pin != pin || mapping.timer != timer {
+			continue
+		}
 
-	// Wait for synchronization
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_ENABLE) {
-	}
+		// Set pin as output, and make sure it is low until the timer drives it.
+		sam.PORT.DIRSET0.Set(1 << uint8(pin))
+		sam.PORT.OUTCLR0.Set(1 << uint8(pin))
 
-	// Set PWM signal to output duty cycle
-	pwm.setChannel(timer, uint32(value))
+		// Connect the TCC to the pin.
+		if pin&1 > 0 {
+			// odd pin, so save the even pins
+			val := pin.getPMux() & sam.PORT_PMUX0_PMUXE_Msk
+			pin.setPMux(val | uint8(mapping.mux<<sam.PORT_PMUX0_PMUXO_Pos))
+		} else {
+			// even pin, so save the odd pins
+			val := pin.getPMux() & sam.PORT_PMUX0_PMUXO_Msk
+			pin.setPMux(val | uint8(mapping.mux<<sam.PORT_PMUX0_PMUXE_Pos))
+		}
+		pin.setPinCfg(sam.PORT_PINCFG0_PMUXEN)
+
+		// Outputs wrap around the number of channels: for example, WO4 of
+		// TCC0 is controlled by channel 0.
+		return mapping.output % tcc.channels(), nil
+	}
+	return 0, ErrInvalidOutputPin
+}
+
+// SetInverting sets whether to invert the output of this channel.
+// Without inverting, a 25% duty cycle would mean the output is high for 25% of
+// the time and low for the rest. Inverting flips the output as if a NOT gate
+// was placed at the output, meaning that the output would be 25% low and 75%
+// high with a duty cycle of 25%.
+func (tcc *TCC) SetInverting(channel uint8, inverting bool) {
+	// The DRVCTRL register can only be written while the timer is disabled.
+	tcc.disable()
+	// Invert all outputs (WOx) that are controlled by this channel.
+	for output := channel; output < 8; output += tcc.channels() {
+		if inverting {
+			tcc.DRVCTRL.SetBits(sam.TCC_DRVCTRL_INVEN0 << output)
+		} else {
+			tcc.DRVCTRL.ClearBits(sam.TCC_DRVCTRL_INVEN0 << output)
+		}
+	}
+	tcc.enable()
+}
 
-	// Wait for synchronization on all channels
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CC0 |
+// Set updates the channel value. This is used to control the channel duty
+// cycle, in other words the fraction of time the channel output is high (or low
+// when inverted). For example, to set it to a 25% duty cycle, use:
+//
+//     tcc.Set(channel, tcc.Top() / 4)
+//
+// tcc.Set(channel, 0) will set the output to low and tcc.Set(channel,
+// tcc.Top()) will set the output to high, assuming the output isn't inverted.
+func (tcc *TCC) Set(channel uint8, value uint32) {
+	if value >= tcc.Top() {
+		// Make sure the output stays high at 100% duty cycle: the counter never
+		// reaches a value above the top.
+		value = tcc.Top() + 1
+	}
+	switch channel {
+	case 0:
+		tcc.CC0.Set(value)
+	case 1:
+		tcc.CC1.Set(value)
+	case 2:
+		tcc.CC2.Set(value)
+	case 3:
+		tcc.CC3.Set(value)
+	}
+	// Wait for synchronization on all channels.
+	for tcc.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CC0 |
 		sam.TCC_SYNCBUSY_CC1 |
 		sam.TCC_SYNCBUSY_CC2 |
 		sam.TCC_SYNCBUSY_CC3) {
 	}
-
-	// enable
-	timer.CTRLA.SetBits(sam.TCC_CTRLA_ENABLE)
-	// Wait for synchronization
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_ENABLE) {
-	}
-}
-
-// getPMux returns the value for the correct PMUX register for this pin.
-func (pwm PWM) getPMux() uint8 {
-	return pwm.Pin.getPMux()
-}
-
-// setPMux sets the value for the correct PMUX register for this pin.
-func (pwm PWM) setPMux(val uint8) {
-	pwm.Pin.setPMux(val)
 }
 
-// getPinCfg returns the value for the correct PINCFG register for this pin.
-func (pwm PWM) getPinCfg() uint8 {
-	return pwm.Pin.getPinCfg()
+// timer returns the underlying TCC peripheral.
+func (tcc *TCC) timer() *sam.TCC_Type {
+	return (*sam.TCC_Type)(tcc)
 }
 
-// setPinCfg sets the value for the correct PINCFG register for this pin.
-func (pwm PWM) setPinCfg(val uint8) {
-	pwm.Pin.setPinCfg(val)
-}
-
-// getTimer returns the timer to be used for PWM on this pin
-func (pwm PWM) getTimer() *sam.TCC_Type {
-	switch pwm.Pin {
-	case 6:
-		return sam.TCC1
-	case 7:
-		return sam.TCC1
-	case 8:
-		return sam.TCC1
-	case 9:
-		return sam.TCC1
-	case 14:
-		return sam.TCC0
-	case 15:
-		return sam.TCC0
-	case 16:
-		return sam.TCC0
-	case 17:
-		return sam.TCC0
-	case 18:
-		return sam.TCC0
-	case 19:
-		return sam.TCC0
-	case 20:
-		return sam.TCC0
-	case 21:
-		return sam.TCC0
-	default:
-		return nil // not supported on this pin
-	}
-}
-
-// setChannel sets the value for the correct channel for PWM on this pin
-func (pwm PWM) setChannel(timer *sam.TCC_Type, val uint32) {
-	switch pwm.Pin {
-	case 6:
-		timer.CC0.Set(val)
-	case 7:
-		timer.CC1.Set(val)
-	case 8:
-		timer.CC0.Set(val)
-	case 9:
-		timer.CC1.Set(val)
-	case 14:
-		timer.CC0.Set(val)
-	case 15:
-		timer.CC1.Set(val)
-	case 16:
-		timer.CC2.Set(val)
-	case 17:
-		timer.CC3.Set(val)
-	case 18:
-		timer.CC2.Set(val)
-	case 19:
-		timer.CC3.Set(val)
-	case 20:
-		timer.CC2.Set(val)
-	case 21:
-		timer.CC3.Set(val)
-	default:
-		return // not supported on this pin
+// channels returns the number of channels (compare registers) of this TCC.
+func (tcc *TCC) channels() uint8 {
+	if tcc.timer() == sam.TCC0 {
+		return 4
 	}
+	return 2
+}
+
+// maxTop returns the highest top value that can be used. It is one less than
+// the maximum value of the counter, so that a 100% duty cycle (a compare value
+// above the top) can be represented.
+func (tcc *TCC) maxTop() uint64 {
+	if tcc.timer() == sam.TCC2 {
+		return 0xfffe // 16-bit counter
+	}
+	return 0xfffffe // 24-bit counter
+}
+
+// disable disables the timer and waits until it is disabled.
+func (tcc *TCC) disable() {
+	tcc.CTRLA.ClearBits(sam.TCC_CTRLA_ENABLE)
+	for tcc.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_ENABLE) {
+	}
+}
+
+// enable enables the timer and waits until it is enabled.
+func (tcc *TCC) enable() {
+	tcc.CTRLA.SetBits(sam.TCC_CTRLA_ENABLE)
+	for tcc.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_ENABLE) {
+	}
+}
+
+// tccPin is an entry in the tccPinMapping table: it describes which TCC output
+// (WOx) is available on which pin, with which pin multiplexer setting.
+type tccPin struct {
+	pin    Pin
+	mux    PinMode
+	timer  *sam.TCC_Type
+	output uint8
+}
+
+// tccPinMapping lists which TCC outputs (WOx) are available on which pins, and
+// with which pin multiplexer setting (E or F). This is a copy of the table
+// "PORT Function Multiplexing" in the datasheet.
+var tccPinMapping = [...]tccPin{
+	{PA00, PinTimer, sam.TCC2, 0},
+	{PA01, PinTimer, sam.TCC2, 1},
+	{PA04, PinTimer, sam.TCC0, 0},
+	{PA05, PinTimer, sam.TCC0, 1},
+	{PA06, PinTimer, sam.TCC1, 0},
+	{PA07, PinTimer, sam.TCC1, 1},
+	{PA08, PinTimer, sam.TCC0, 0},
+	{PA08, PinTimerAlt, sam.TCC1, 2},
+	{PA09, PinTimer, sam.TCC0, 1},
+	{PA09, PinTimerAlt, sam.TCC1, 3},
+	{PA10, PinTimer, sam.TCC1, 0},
+	{PA10, PinTimerAlt, sam.TCC0, 2},
+	{PA11, PinTimer, sam.TCC1, 1},
+	{PA11, PinTimerAlt, sam.TCC0, 3},
+	{PA12, PinTimer, sam.TCC2, 0},
+	{PA12, PinTimerAlt, sam.TCC0, 6},
+	{PA13, PinTimer, sam.TCC2, 1},
+	{PA13, PinTimerAlt, sam.TCC0, 7},
+	{PA14, PinTimerAlt, sam.TCC0, 4},
+	{PA15, PinTimerAlt, sam.TCC0, 5},
+	{PA16, PinTimer, sam.TCC2, 0},
+	{PA16, PinTimerAlt, sam.TCC0, 6},
+	{PA17, PinTimer, sam.TCC2, 1},
+	{PA17, PinTimerAlt, sam.TCC0, 7},
+	{PA18, PinTimerAlt, sam.TCC0, 2},
+	{PA19, PinTimerAlt, sam.TCC0, 3},
+	{PA20, PinTimerAlt, sam.TCC0, 6},
+	{PA21, PinTimerAlt, sam.TCC0, 7},
+	{PA22, PinTimerAlt, sam.TCC0, 4},
+	{PA23, PinTimerAlt, sam.TCC0, 5},
+	{PA24, PinTimerAlt, sam.TCC1, 2},
+	{PA25, PinTimerAlt, sam.TCC1, 3},
+	{PA30, PinTimer, sam.TCC1, 0},
+	{PA31, PinTimer, sam.TCC1, 1},
 }
 
 // USBCDC is the USB CDC aka serial over USB interface on the SAMD21.
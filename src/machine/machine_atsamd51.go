@@ -942,6 +942,13 @@ func (uart UART) Configure(config UARTConfig) error {
 		config.BaudRate = 115200
 	}
 
+	// Check the frame format before changing anything.
+	dataBits, stopBits := config.format()
+	form, ctrlb, err := uartFrameFormat(dataBits, stopBits, config.Parity)
+	if err != nil {
+		return err
+	}
+
 	// determine pins
 	if config.TX == 0 && config.RX == 0 {
 		// use default pins
@@ -957,11 +964,16 @@ func (uart UART) Configure(config UARTConfig) error {
 	var txPinOut uint32
 	// See CTRLA.RXPO bits of the SERCOM USART peripheral (page 945-946) for how
 	// pads are mapped to pinout values.
-	switch txPad {
-	case 0:
+	switch {
+	case config.flowControl():
+		// TX on pad 0, RTS on pad 2 and CTS on pad 3.
+		if txPad != 0 {
+			return ErrInvalidOutputPin
+		}
+		txPinOut = 2
+	case txPad == 0:
 		txPinOut = 0
 	default:
-		// TODO: flow control (RTS/CTS)
 		return ErrInvalidOutputPin
 	}
 
@@ -974,9 +986,28 @@ func (uart UART) Configure(config UARTConfig) error {
 	// (page 945), input pins are mapped directly.
 	rxPinOut := rxPad
 
+	// Determine the flow control pins, which must be on pad 2 (RTS) and pad
+	// 3 (CTS).
+	var rtsPinMode, ctsPinMode PinMode
+	if config.flowControl() {
+		var rtsPad, ctsPad uint32
+		rtsPinMode, rtsPad, ok = findPinPadMapping(uart.SERCOM, config.RTS)
+		if !ok || rtsPad != 2 {
+			return ErrInvalidOutputPin
+		}
+		ctsPinMode, ctsPad, ok = findPinPadMapping(uart.SERCOM, config.CTS)
+		if !ok || ctsPad != 3 {
+			return ErrInvalidInputPin
+		}
+	}
+
 	// configure pins
 	config.TX.Configure(PinConfig{Mode: txPinMode})
 	config.RX.Configure(PinConfig{Mode: rxPinMode})
+	if config.flowControl() {
+		config.RTS.Configure(PinConfig{Mode: rtsPinMode})
+		config.CTS.Configure(PinConfig{Mode: ctsPinMode})
+	}
 
 	// reset SERCOM
 	uart.Bus.CTRLA.SetBits(sam.SERCOM_USART_INT_CTRLA_SWRST)
@@ -994,19 +1025,10 @@ func (uart UART) Configure(config UARTConfig) error {
 	// Set baud rate
 	uart.SetBaudRate(config.BaudRate)
 
-	// setup UART frame
-	// SERCOM_USART_CTRLA_FORM( (parityMode == SERCOM_NO_PARITY ? 0 : 1) ) |
-	// dataOrder << SERCOM_USART_CTRLA_DORD_Pos;
-	uart.Bus.CTRLA.SetBits((0 << sam.SERCOM_USART_INT_CTRLA_FORM_Pos) | // no parity
+	// setup UART frame: parity, data order, character size and stop bits
+	uart.Bus.CTRLA.SetBits((form << sam.SERCOM_USART_INT_CTRLA_FORM_Pos) |
 		(lsbFirst << sam.SERCOM_USART_INT_CTRLA_DORD_Pos)) // data order
-
-	// set UART stop bits/parity
-	// SERCOM_USART_CTRLB_CHSIZE(charSize) |
-	// 	nbStopBits << SERCOM_USART_CTRLB_SBMODE_Pos |
-	// 	(parityMode == SERCOM_NO_PARITY ? 0 : parityMode) << SERCOM_USART_CTRLB_PMODE_Pos; //If no parity use default value
-	uart.Bus.CTRLB.SetBits((0 << sam.SERCOM_USART_INT_CTRLB_CHSIZE_Pos) | // 8 bits is 0
-		(0 << sam.SERCOM_USART_INT_CTRLB_SBMODE_Pos) | // 1 stop bit is zero
-		(0 << sam.SERCOM_USART_INT_CTRLB_PMODE_Pos)) // no parity
+	uart.Bus.CTRLB.SetBits(ctrlb)
 
 	// set UART pads. This is not same as pins...
 	//  SERCOM_USART_CTRLA_TXPO(txPad) |
@@ -1052,6 +1074,62 @@ func (uart UART) SetBaudRate(br uint32) {
 		((baud / 8) << sam.SERCOM_USART_INT_BAUD_FRAC_MODE_BAUD_Pos)))
 }
 
+// SetFormat changes the number of data bits (5 to 8), the parity and the
+// number of stop bits (1 or 2) of the UART.
+func (uart UART) SetFormat(dataBits, stopBits uint8, parity UARTParity) error {
+	form, ctrlb, err := uartFrameFormat(dataBits, stopBits, parity)
+	if err != nil {
+		return err
+	}
+
+	// The frame format can only be changed while the UART is disabled.
+	uart.Bus.CTRLA.ClearBits(sam.SERCOM_USART_INT_CTRLA_ENABLE)
+	for uart.Bus.SYNCBUSY.HasBits(sam.SERCOM_USART_INT_SYNCBUSY_ENABLE) {
+	}
+	uart.Bus.CTRLA.ClearBits(sam.SERCOM_USART_INT_CTRLA_FORM_Msk)
+	uart.Bus.CTRLA.SetBits(form << sam.SERCOM_USART_INT_CTRLA_FORM_Pos)
+	uart.Bus.CTRLB.ClearBits(sam.SERCOM_USART_INT_CTRLB_CHSIZE_Msk | sam.SERCOM_USART_INT_CTRLB_SBMODE | sam.SERCOM_USART_INT_CTRLB_PMODE)
+	uart.Bus.CTRLB.SetBits(ctrlb)
+	uart.Bus.CTRLA.SetBits(sam.SERCOM_USART_INT_CTRLA_ENABLE)
+	for uart.Bus.SYNCBUSY.HasBits(sam.SERCOM_USART_INT_SYNCBUSY_ENABLE) {
+	}
+	return nil
+}
+
+// uartFrameFormat returns the value of the FORM field of CTRLA and the
+// CHSIZE, SBMODE and PMODE fields of CTRLB for the given frame format.
+func uartFrameFormat(dataBits, stopBits uint8, parity UARTParity) (form, ctrlb uint32, err error) {
+	switch dataBits {
+	case 5, 6, 7:
+		ctrlb = uint32(dataBits) << sam.SERCOM_USART_INT_CTRLB_CHSIZE_Pos
+	case 8:
+		// CHSIZE is 0 for 8 bits.
+	default:
+		// 9 bits is supported by the hardware, but doesn't fit in the
+		// receive buffer.
+		return 0, 0, ErrUARTFormatNotSupported
+	}
+	switch stopBits {
+	case 1:
+	case 2:
+		ctrlb |= sam.SERCOM_USART_INT_CTRLB_SBMODE
+	default:
+		return 0, 0, ErrUARTFormatNotSupported
+	}
+	switch parity {
+	case ParityNone:
+		form = 0 // USART frame
+	case ParityEven:
+		form = 1 // USART frame with parity
+	case ParityOdd:
+		form = 1
+		ctrlb |= sam.SERCOM_USART_INT_CTRLB_PMODE
+	default:
+		return 0, 0, ErrUARTFormatNotSupported
+	}
+	return form, ctrlb, nil
+}
+
 // WriteByte writes a byte of data to the UART.
 func (uart UART) WriteByte(c byte) error {
 	// wait until ready to receive
@@ -1324,6 +1402,129 @@ func (i2c I2C) readByte() byte {
 	return byte(i2c.Bus.DATA.Get())
 }
 
+// I2C target commands, written to the CMD field of CTRLB.
+const (
+	i2cTargetCmdWaitStart = 2 // execute the acknowledge action, then wait for a start condition
+	i2cTargetCmdNextByte  = 3 // execute the acknowledge action, then continue with the next byte
+)
+
+// Listen switches the I2C peripheral to target mode, responding to the given
+// 7-bit address. Configure must be called first to set up the pins. Use
+// WaitForEvent to handle transactions from the controller.
+func (i2c I2C) Listen(addr uint16) error {
+	target := i2c.target()
+
+	// reset SERCOM
+	target.CTRLA.SetBits(sam.SERCOM_I2CS_CTRLA_SWRST)
+	for target.CTRLA.HasBits(sam.SERCOM_I2CS_CTRLA_SWRST) ||
+		target.SYNCBUSY.HasBits(sam.SERCOM_I2CS_SYNCBUSY_SWRST) {
+	}
+
+	// Set i2c target mode (MODE 4). Smart mode acknowledges received bytes when
+	// DATA is read, and continues with the next byte when DATA is written.
+	target.CTRLA.Set(4 << sam.SERCOM_I2CS_CTRLA_MODE_Pos)
+	target.CTRLB.Set(sam.SERCOM_I2CS_CTRLB_SMEN)
+	target.ADDR.Set(uint32(addr) << sam.SERCOM_I2CS_ADDR_ADDR_Pos)
+
+	// Enable I2CS port.
+	target.CTRLA.SetBits(sam.SERCOM_I2CS_CTRLA_ENABLE)
+	for target.SYNCBUSY.HasBits(sam.SERCOM_I2CS_SYNCBUSY_ENABLE) {
+	}
+
+	return nil
+}
+
+// WaitForEvent blocks until the controller does something with this target,
+// see I2CTargetEvent. For an I2CReceive event, the received data is stored in
+// buf and the number of bytes is returned.
+//
+// Other goroutines keep running while waiting, but the bus is polled so the
+// CPU does not sleep.
+func (i2c I2C) WaitForEvent(buf []byte) (evt I2CTargetEvent, count int, err error) {
+	target := i2c.target()
+	for {
+		flags := target.INTFLAG.Get()
+		switch {
+		case flags&sam.SERCOM_I2CS_INTFLAG_ERROR != 0:
+			target.INTFLAG.Set(sam.SERCOM_I2CS_INTFLAG_ERROR)
+			return I2CReceive, 0, errI2CBusError
+		case flags&sam.SERCOM_I2CS_INTFLAG_AMATCH != 0:
+			// Acknowledge the address, which also clears the AMATCH flag.
+			read := target.STATUS.HasBits(sam.SERCOM_I2CS_STATUS_DIR)
+			target.CTRLB.ClearBits(sam.SERCOM_I2CS_CTRLB_ACKACT)
+			target.CTRLB.SetBits(i2cTargetCmdNextByte << sam.SERCOM_I2CS_CTRLB_CMD_Pos)
+			if read {
+				// The data is sent by Reply.
+				return I2CRequest, 0, nil
+			}
+
+			// Receive bytes until the controller sends a stop condition or
+			// a repeated start. Those flags are left set, so that they are
+			// handled in the next call.
+			for {
+				for !target.INTFLAG.HasBits(sam.SERCOM_I2CS_INTFLAG_DRDY | sam.SERCOM_I2CS_INTFLAG_PREC |
+					sam.SERCOM_I2CS_INTFLAG_AMATCH | sam.SERCOM_I2CS_INTFLAG_ERROR) {
+					gosched()
+				}
+				if !target.INTFLAG.HasBits(sam.SERCOM_I2CS_INTFLAG_DRDY) {
+					return I2CReceive, count, nil
+				}
+				if count == len(buf) {
+					// The buffer is full: don't acknowledge this byte.
+					target.CTRLB.SetBits(sam.SERCOM_I2CS_CTRLB_ACKACT)
+					target.DATA.Get()
+					continue
+				}
+				buf[count] = byte(target.DATA.Get())
+				count++
+			}
+		case flags&sam.SERCOM_I2CS_INTFLAG_PREC != 0:
+			target.INTFLAG.Set(sam.SERCOM_I2CS_INTFLAG_PREC)
+			return I2CFinish, 0, nil
+		}
+
+		// Nothing happened yet: let other goroutines run while waiting.
+		gosched()
+	}
+}
+
+// Reply sends data to the controller after an I2CRequest event. If the
+// controller reads more bytes than there are in buf, 0xff is sent for the
+// remaining bytes.
+func (i2c I2C) Reply(buf []byte) error {
+	target := i2c.target()
+	for i := 0; ; i++ {
+		for !target.INTFLAG.HasBits(sam.SERCOM_I2CS_INTFLAG_DRDY | sam.SERCOM_I2CS_INTFLAG_PREC |
+			sam.SERCOM_I2CS_INTFLAG_AMATCH | sam.SERCOM_I2CS_INTFLAG_ERROR) {
+			gosched()
+		}
+		if target.INTFLAG.HasBits(sam.SERCOM_I2CS_INTFLAG_ERROR) {
+			target.INTFLAG.Set(sam.SERCOM_I2CS_INTFLAG_ERROR)
+			return errI2CBusError
+		}
+		if !target.INTFLAG.HasBits(sam.SERCOM_I2CS_INTFLAG_DRDY) {
+			// Stop condition or repeated start, handled by WaitForEvent.
+			return nil
+		}
+		if i != 0 && target.STATUS.HasBits(sam.SERCOM_I2CS_STATUS_RXNACK) {
+			// The controller did not acknowledge the previous byte, so it
+			// doesn't want to read any more.
+			target.CTRLB.SetBits(i2cTargetCmdWaitStart << sam.SERCOM_I2CS_CTRLB_CMD_Pos)
+			return nil
+		}
+		data := byte(0xff)
+		if i < len(buf) {
+			data = buf[i]
+		}
+		target.DATA.Set(uint32(data))
+	}
+}
+
+// target returns the registers of the SERCOM peripheral in I2C target mode.
+func (i2c I2C) target() *sam.SERCOM_I2CS_Type {
+	return (*sam.SERCOM_I2CS_Type)(unsafe.Pointer(i2c.Bus))
+}
+
 // SPI
 type SPI struct {
 	Bus    *sam.SERCOM_SPIM_Type
@@ -1480,255 +1681,211 @@ const (
 )
 
 // PWM
-const period = 0xFFFF
 
-// Configure configures a PWM pin for output.
-func (pwm PWM) Configure() error {
-	// Set pin as output
-	sam.PORT.GROUP[0].DIRSET.Set(1 << uint8(pwm.Pin))
-	// Set pin to low
-	sam.PORT.GROUP[0].OUTCLR.Set(1 << uint8(pwm.Pin))
+// TCC is one timer/counter for control applications peripheral (TCC) on the
+// SAMD51, which can be used for PWM. Each TCC has a single period but multiple
+// channels that can each have their own duty cycle.
+type TCC sam.TCC_Type
 
-	// Enable the port multiplexer for pin
-	pwm.setPinCfg(sam.PORT_GROUP_PINCFG_PMUXEN)
-
-	// Connect timer/mux to pin.
-	pwmConfig := pwm.getMux()
-
-	if pwm.Pin&1 > 0 {
-		// odd pin, so save the even pins
-		val := pwm.getPMux() & sam.PORT_GROUP_PMUX_PMUXE_Msk
-		pwm.setPMux(val | uint8(pwmConfig<<sam.PORT_GROUP_PMUX_PMUXO_Pos))
-	} else {
-		// even pin, so save the odd pins
-		val := pwm.getPMux() & sam.PORT_GROUP_PMUX_PMUXO_Msk
-		pwm.setPMux(val | uint8(pwmConfig<<sam.PORT_GROUP_PMUX_PMUXE_Pos))
-	}
+// The TCC peripherals that are available on all SAMD51 chips. TCC0 has 6
+// channels and a 24-bit counter, TCC1 has 4 channels and a 24-bit counter, and
+// TCC2 has 3 channels and a 16-bit counter. Larger chips also have TCC3 and
+// TCC4, which both have 2 channels and a 16-bit counter.
+var (
+	TCC0 = (*TCC)(sam.TCC0)
+	TCC1 = (*TCC)(sam.TCC1)
+	TCC2 = (*TCC)(sam.TCC2)
+)
 
-	// figure out which TCCX timer for this pin
-	timer := pwm.getTimer()
-	if timer == nil {
-		return ErrInvalidOutputPin
+// The TCC prescaler values that can be selected in CTRLA.PRESCALER, in order.
+var tccPrescalers = [...]uint64{1, 2, 4, 8, 16, 64, 256, 1024}
+
+// tccPin is an entry in the tccPinMapping table of a chip: it describes which
+// TCC output (WOx) is available on which pin, with which pin multiplexer
+// setting.
+type tccPin struct {
+	pin    Pin
+	mux    PinMode
+	timer  *sam.TCC_Type
+	output uint8
+}
+
+// Configure enables and configures this TCC for PWM with the given period. All
+// channels are set to a duty cycle of 0 (always low).
+func (tcc *TCC) Configure(config PWMConfig) error {
+	// Turn on the clock of this TCC, which uses GCLK0 (120MHz) as its source.
+	tcc.enableClock()
+
+	// Calculate the prescaler and the top value for this period.
+	cycles := pwmCycles(config.Period, 120)
+	prescaler := 0
+	for cycles/tccPrescalers[prescaler] > tcc.maxTop()+1 {
+		prescaler++
+		if prescaler == len(tccPrescalers) {
+			return ErrPWMPeriodTooLong
+		}
 	}
-
-	// disable timer
-	timer.CTRLA.ClearBits(sam.TCC_CTRLA_ENABLE)
-	// Wait for synchronization
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_ENABLE) {
+	top := cycles / tccPrescalers[prescaler]
+	if top > 0 {
+		top-- // the counter counts from 0 to top (inclusive)
 	}
 
-	// Set prescaler to 1/256
-	// TCCx->CTRLA.reg = TCC_CTRLA_PRESCALER_DIV256 | TCC_CTRLA_PRESCSYNC_GCLK;
-	timer.CTRLA.SetBits(sam.TCC_CTRLA_PRESCALER_DIV256 | sam.TCC_CTRLA_PRESCSYNC_GCLK)
+	// Disable the timer while it is being configured.
+	tcc.disable()
 
-	// Use "Normal PWM" (single-slope PWM)
-	timer.WAVE.SetBits(sam.TCC_WAVE_WAVEGEN_NPWM)
-	// Wait for synchronization
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_WAVE) {
+	// Use "Normal PWM" (single-slope PWM) with the prescaler from above.
+	tcc.CTRLA.Set(uint32(prescaler) << sam.TCC_CTRLA_PRESCALER_Pos)
+	tcc.WAVE.Set(sam.TCC_WAVE_WAVEGEN_NPWM)
+	for tcc.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_WAVE) {
 	}
 
-	// while (TCCx->SYNCBUSY.bit.CC0 || TCCx->SYNCBUSY.bit.CC1);
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CC0) ||
-		timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CC1) {
+	// Set the period (the number to count to (TOP) before resetting timer).
+	tcc.PER.Set(uint32(top))
+	for tcc.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_PER) {
 	}
 
-	// Set the initial value
-	// TCCx->CC[tcChannel].reg = (uint32_t) value;
-	pwm.setChannel(timer, 0)
-
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CC0) ||
-		timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CC1) {
-	}
-
-	// Set the period (the number to count to (TOP) before resetting timer)
-	//TCC0->PER.reg = period;
-	timer.PER.Set(period)
-	// Wait for synchronization
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_PER) {
+	// Start with all channels at 0% duty cycle.
+	for channel := uint8(0); channel < tcc.channels(); channel++ {
+		tcc.CC[channel].Set(0)
+		tcc.CCBUF[channel].Set(0)
 	}
-
-	// enable timer
-	timer.CTRLA.SetBits(sam.TCC_CTRLA_ENABLE)
-	// Wait for synchronization
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_ENABLE) {
+	for tcc.SYNCBUSY.Get()&(sam.TCC_SYNCBUSY_CC0|sam.TCC_SYNCBUSY_CC1|sam.TCC_SYNCBUSY_CC2|
+		sam.TCC_SYNCBUSY_CC3|sam.TCC_SYNCBUSY_CC4|sam.TCC_SYNCBUSY_CC5) != 0 {
 	}
 
+	tcc.enable()
 	return nil
 }
 
-// Set turns on the duty cycle for a PWM pin using the provided value.
-func (pwm PWM) Set(value uint16) {
-	// figure out which TCCX timer for this pin
-	timer := pwm.getTimer()
-	if timer == nil {
-		// The Configure call above cannot have succeeded, so simply ignore this
-		// error.
-		return
-	}
+// Top returns the current counter top, for use in duty cycle calculation. It
+// will only change with a call to Configure.
+//
+// The value returned here is hardware dependent. In general, it's best to treat
+// it as an opaque value that can be divided by some number and passed to Set
+// (see Set documentation for more information).
+func (tcc *TCC) Top() uint32 {
+	return tcc.PER.Get()
+}
 
-	// Wait for synchronization
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CTRLB) {
-	}
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CC0) ||
-		timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CC1) {
-	}
+// Channel returns a PWM channel for the given pin. The pin is configured as a
+// PWM output of this TCC. Note that a pin can only be used with some of the
+// TCC peripherals, so this will return ErrInvalidOutputPin if the pin is not
+// connected to this TCC.
+//
+// Pins that are connected to the same channel share the same duty cycle.
+func (tcc *TCC) Channel(pin Pin) (uint8, error) {
+	// Find the output (WO) of this TCC that is connected to the pin.
+	timer := tcc.timer()
+	for _, mapping := range tccPinMapping {
+		if mapping.pin != pin || mapping.timer != timer {
+			continue
+		}
 
-	// TCCx->CCBUF[tcChannel].reg = (uint32_t) value;
-	pwm.setChannelBuffer(timer, uint32(value))
+		// Set pin as output, and make sure it is low until the timer drives it.
+		group, pinInGroup := pin.getPinGrouping()
+		sam.PORT.GROUP[group].DIRSET.Set(1 << pinInGroup)
+		sam.PORT.GROUP[group].OUTCLR.Set(1 << pinInGroup)
 
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CC0) ||
-		timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CC1) {
+		// Connect the TCC to the pin.
+		if pin&1 > 0 {
+			// odd pin, so save the even pins
+			val := pin.getPMux() & sam.PORT_GROUP_PMUX_PMUXE_Msk
+			pin.setPMux(val | uint8(mapping.mux<<sam.PORT_GROUP_PMUX_PMUXO_Pos))
+		} else {
+			// even pin, so save the odd pins
+			val := pin.getPMux() & sam.PORT_GROUP_PMUX_PMUXO_Msk
+			pin.setPMux(val | uint8(mapping.mux<<sam.PORT_GROUP_PMUX_PMUXE_Pos))
+		}
+		pin.setPinCfg(sam.PORT_GROUP_PINCFG_PMUXEN)
+
+		// Outputs wrap around the number of channels: for example, WO6 of
+		// TCC0 is controlled by channel 0.
+		return mapping.output % tcc.channels(), nil
+	}
+	return 0, ErrInvalidOutputPin
+}
+
+// SetInverting sets whether to invert the output of this channel.
+// Without inverting, a 25% duty cycle would mean the output is high for 25% of
+// the time and low for the rest. Inverting flips the output as if a NOT gate
+// was placed at the output, meaning that the output would be 25% low and 75%
+// high with a duty cycle of 25%.
+func (tcc *TCC) SetInverting(channel uint8, inverting bool) {
+	// The DRVCTRL register can only be written while the timer is disabled.
+	tcc.disable()
+	// Invert all outputs (WOx) that are controlled by this channel.
+	for output := channel; output < 8; output += tcc.channels() {
+		if inverting {
+			tcc.DRVCTRL.SetBits(sam.TCC_DRVCTRL_INVEN0 << output)
+		} else {
+			tcc.DRVCTRL.ClearBits(sam.TCC_DRVCTRL_INVEN0 << output)
+		}
 	}
+	tcc.enable()
+}
 
-	// TCCx->CTRLBCLR.bit.LUPD = 1;
-	timer.CTRLBCLR.SetBits(sam.TCC_CTRLBCLR_LUPD)
-	for timer.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_CTRLB) {
+// Set updates the channel value. This is used to control the channel duty
+// cycle, in other words the fraction of time the channel output is high (or low
+// when inverted). For example, to set it to a 25% duty cycle, use:
+//
+//     tcc.Set(channel, tcc.Top() / 4)
+//
+// tcc.Set(channel, 0) will set the output to low and tcc.Set(channel,
+// tcc.Top()) will set the output to high, assuming the output isn't inverted.
+//
+// The new value is buffered and takes effect at the start of the next period,
+// so that no glitches occur while changing the duty cycle.
+func (tcc *TCC) Set(channel uint8, value uint32) {
+	if value >= tcc.Top() {
+		// Make sure the output stays high at 100% duty cycle: the counter never
+		// reaches a value above the top.
+		value = tcc.Top() + 1
 	}
+	tcc.CCBUF[channel].Set(value)
 }
 
-// getPMux returns the value for the correct PMUX register for this pin.
-func (pwm PWM) getPMux() uint8 {
-	return pwm.Pin.getPMux()
+// timer returns the underlying TCC peripheral.
+func (tcc *TCC) timer() *sam.TCC_Type {
+	return (*sam.TCC_Type)(tcc)
 }
 
-// setPMux sets the value for the correct PMUX register for this pin.
-func (pwm PWM) setPMux(val uint8) {
-	pwm.Pin.setPMux(val)
+// channels returns the number of channels (compare registers) of this TCC.
+func (tcc *TCC) channels() uint8 {
+	switch tcc.timer() {
+	case sam.TCC0:
+		return 6
+	case sam.TCC1:
+		return 4
+	case sam.TCC2:
+		return 3
+	default:
+		return 2
+	}
 }
 
-// getPinCfg returns the value for the correct PINCFG register for this pin.
-func (pwm PWM) getPinCfg() uint8 {
-	return pwm.Pin.getPinCfg()
+// maxTop returns the highest top value that can be used. It is one less than
+// the maximum value of the counter, so that a 100% duty cycle (a compare value
+// above the top) can be represented.
+func (tcc *TCC) maxTop() uint64 {
+	switch tcc.timer() {
+	case sam.TCC0, sam.TCC1:
+		return 0xfffffe // 24-bit counter
+	default:
+		return 0xfffe // 16-bit counter
+	}
 }
 
-// setPinCfg sets the value for the correct PINCFG register for this pin.
-func (pwm PWM) setPinCfg(val uint8) {
-	pwm.Pin.setPinCfg(val)
-}
-
-// setChannel sets the value for the correct channel for PWM on this pin.
-func (pwm PWM) setChannel(timer *sam.TCC_Type, val uint32) {
-	switch pwm.Pin {
-	case PA14:
-		timer.CC[0].Set(val)
-	case PA15:
-		timer.CC[1].Set(val)
-	case PA16:
-		timer.CC[0].Set(val)
-	case PA17:
-		timer.CC[1].Set(val)
-	case PA18:
-		timer.CC[2].Set(val)
-	case PA19:
-		timer.CC[3].Set(val)
-	case PA20:
-		timer.CC[0].Set(val)
-	case PA21:
-		timer.CC[1].Set(val)
-	case PA22:
-		timer.CC[2].Set(val)
-	case PA23:
-		timer.CC[3].Set(val)
-	case PB12:
-		timer.CC[0].Set(val)
-	case PB13:
-		timer.CC[1].Set(val)
-	case PB14:
-		timer.CC[0].Set(val)
-	case PB15:
-		timer.CC[1].Set(val)
-	case PB16:
-		timer.CC[4].Set(val)
-	case PB17:
-		timer.CC[5].Set(val)
-	case PB31:
-		timer.CC[1].Set(val)
-	default:
-		return // not supported on this pin
-	}
-}
-
-// setChannelBuffer sets the value for the correct channel buffer for PWM on this pin
-func (pwm PWM) setChannelBuffer(timer *sam.TCC_Type, val uint32) {
-	switch pwm.Pin {
-	case PA14:
-		timer.CCBUF[0].Set(val)
-	case PA15:
-		timer.CCBUF[1].Set(val)
-	case PA16:
-		timer.CCBUF[0].Set(val)
-	case PA17:
-		timer.CCBUF[1].Set(val)
-	case PA18:
-		timer.CCBUF[2].Set(val)
-	case PA19:
-		timer.CCBUF[3].Set(val)
-	case PA20:
-		timer.CCBUF[0].Set(val)
-	case PA21:
-		timer.CCBUF[1].Set(val)
-	case PA22:
-		timer.CCBUF[2].Set(val)
-	case PA23:
-		timer.CCBUF[3].Set(val)
-	case PB12:
-		timer.CCBUF[0].Set(val)
-	case PB13:
-		timer.CCBUF[1].Set(val)
-	case PB14:
-		timer.CCBUF[0].Set(val)
-	case PB15:
-		timer.CCBUF[1].Set(val)
-	case PB16:
-		timer.CCBUF[4].Set(val)
-	case PB17:
-		timer.CCBUF[5].Set(val)
-	case PB31:
-		timer.CCBUF[1].Set(val)
-	default:
-		return // not supported on this pin
-	}
-}
-
-// getMux returns the pin mode mux to be used for PWM on this pin.
-func (pwm PWM) getMux() PinMode {
-	switch pwm.Pin {
-	case PA14:
-		return PinPWMF
-	case PA15:
-		return PinPWMF
-	case PA16:
-		return PinPWMF
-	case PA17:
-		return PinPWMF
-	case PA18:
-		return PinPWMF
-	case PA19:
-		return PinPWMF
-	case PA20:
-		return PinPWMG
-	case PA21:
-		return PinPWMG
-	case PA22:
-		return PinPWMG
-	case PA23:
-		return PinPWMG
-	case PB12:
-		return PinPWMF
-	case PB13:
-		return PinPWMF
-	case PB14:
-		return PinPWMF
-	case PB15:
-		return PinPWMF
-	case PB16:
-		return PinPWMG
-	case PB17:
-		return PinPWMG
-	case PB31:
-		return PinPWMF
-	default:
-		return 0 // not supported on this pin
+// disable disables the timer and waits until it is disabled.
+func (tcc *TCC) disable() {
+	tcc.CTRLA.ClearBits(sam.TCC_CTRLA_ENABLE)
+	for tcc.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_ENABLE) {
+	}
+}
+
+// enable enables the timer and waits until it is enabled.
+func (tcc *TCC) enable() {
+	tcc.CTRLA.SetBits(sam.TCC_CTRLA_ENABLE)
+	for tcc.SYNCBUSY.HasBits(sam.TCC_SYNCBUSY_ENABLE) {
 	}
 }
 
@@ -0,0 +1,263 @@
+// +build sam,atsamd51
+
+package machine
+
+// Asynchronous SPI and UART transfers on the SAMD51, using the DMA controller
+// (DMAC).
+//
+// Every SERCOM peripheral gets two DMA channels: channel 2*n to send data to
+// SERCOMn, and channel 2*n+1 to receive data from it. The DMAC reads the
+// configuration of a channel from a transfer descriptor in RAM. A descriptor
+// can transfer at most 65535 bytes, so longer transfers are split in blocks
+// and the next block is started from the DMAC interrupt.
+
+import (
+	"device/sam"
+	"errors"
+	"runtime/interrupt"
+	"runtime/volatile"
+	"unsafe"
+)
+
+var errDMATransfer = errors.New("DMA transfer error")
+
+// Number of DMA channels in use: two for each SERCOM.
+const dmaChannels = 16
+
+// dmaDescriptor is a DMAC transfer descriptor, see "Transfer Descriptors" in
+// the datasheet.
+type dmaDescriptor struct {
+	btctrl   volatile.Register16
+	btcnt    volatile.Register16
+	srcaddr  volatile.Register32
+	dstaddr  volatile.Register32
+	descaddr volatile.Register32
+}
+
+// Bits of the BTCTRL field of a transfer descriptor. The other fields are left
+// at zero: a beat is one byte, and the channel is disabled at the end of the
+// block.
+const (
+	dmaDescriptorValid  = 1 << 0  // VALID
+	dmaDescriptorSrcInc = 1 << 10 // SRCINC: increment the source address
+	dmaDescriptorDstInc = 1 << 11 // DSTINC: increment the destination address
+)
+
+// sercomDMA is the state of the transfer of a SERCOM peripheral.
+type sercomDMA struct {
+	waiter    dmaWaiter
+	data      uint32 // address of the DATA register of the SERCOM
+	w, r      []byte // data that remains to be sent and received
+	remaining int    // number of bytes that remain to be transferred
+	receive   bool   // whether to receive at the same time (SPI)
+	err       error
+}
+
+var (
+	// The descriptors and the write-back descriptors of all channels must be
+	// aligned to 16 bytes, so one extra descriptor is allocated to align the
+	// others.
+	dmaDescriptorMemory [dmaChannels*2 + 1]dmaDescriptor
+	dmaDescriptors      *[dmaChannels]dmaDescriptor
+	dmaTransfers        [8]sercomDMA
+
+	// Sent when there is no data to send, and written when received data is
+	// dropped.
+	dmaZero  byte
+	dmaDummy byte
+)
+
+// initDMA enables the DMAC and its interrupts, when it is used for the first
+// time.
+func initDMA() {
+	if dmaDescriptors != nil {
+		return
+	}
+	base := (uintptr(unsafe.Pointer(&dmaDescriptorMemory)) + 15) &^ 15
+	dmaDescriptors = (*[dmaChannels]dmaDescriptor)(unsafe.Pointer(base))
+
+	sam.MCLK.AHBMASK.SetBits(sam.MCLK_AHBMASK_DMAC_)
+
+	// reset DMAC
+	sam.DMAC.CTRL.ClearBits(sam.DMAC_CTRL_DMAENABLE)
+	sam.DMAC.CTRL.SetBits(sam.DMAC_CTRL_SWRST)
+	for sam.DMAC.CTRL.HasBits(sam.DMAC_CTRL_SWRST) {
+	}
+
+	sam.DMAC.BASEADDR.Set(uint32(base))
+	sam.DMAC.WRBADDR.Set(uint32(base + dmaChannels*unsafe.Sizeof(dmaDescriptor{})))
+
+	// Enable the DMAC with all priority levels.
+	sam.DMAC.CTRL.Set(sam.DMAC_CTRL_DMAENABLE | sam.DMAC_CTRL_LVLEN0 | sam.DMAC_CTRL_LVLEN1 |
+		sam.DMAC_CTRL_LVLEN2 | sam.DMAC_CTRL_LVLEN3)
+
+	// Channels 0-3 have their own interrupt, the others share one.
+	intr := interrupt.New(sam.IRQ_DMAC_0, handleDMACInterrupt)
+	intr.Enable()
+	intr = interrupt.New(sam.IRQ_DMAC_1, handleDMACInterrupt)
+	intr.Enable()
+	intr = interrupt.New(sam.IRQ_DMAC_2, handleDMACInterrupt)
+	intr.Enable()
+	intr = interrupt.New(sam.IRQ_DMAC_3, handleDMACInterrupt)
+	intr.Enable()
+	intr = interrupt.New(sam.IRQ_DMAC_OTHER, handleDMACInterrupt)
+	intr.Enable()
+}
+
+// start starts a new transfer on the given SERCOM, after waiting for the
+// previous one to finish.
+func (t *sercomDMA) start(sercom uint8, data *volatile.Register32, w, r []byte, receive bool) {
+	t.waiter.wait()
+	initDMA()
+
+	t.data = uint32(uintptr(unsafe.Pointer(data)))
+	t.w = w
+	t.r = r
+	t.remaining = len(w)
+	if len(r) > len(w) {
+		t.remaining = len(r)
+	}
+	t.receive = receive
+	t.err = nil
+	if t.remaining == 0 {
+		return
+	}
+
+	// The channels are enabled again for every block, with the trigger
+	// sources of this SERCOM: SERCOMn_RX is 0x04+2*n and SERCOMn_TX is
+	// 0x05+2*n. Only the channel that finishes last (the receive channel when
+	// receiving) raises an interrupt.
+	tx := &sam.DMAC.CHANNEL[sercom*2]
+	rx := &sam.DMAC.CHANNEL[sercom*2+1]
+	configureDMAChannel(tx, uint32(0x05+2*sercom), !receive)
+	if receive {
+		configureDMAChannel(rx, uint32(0x04+2*sercom), true)
+	}
+
+	t.waiter.start()
+	t.startBlock(sercom)
+}
+
+// configureDMAChannel resets a DMA channel and sets the peripheral that
+// triggers it. Every trigger transfers a single byte (a burst of one beat).
+func configureDMAChannel(ch *sam.DMAC_CHANNEL_Type, trigger uint32, enableInterrupt bool) {
+	ch.CHCTRLA.ClearBits(sam.DMAC_CHANNEL_CHCTRLA_ENABLE)
+	ch.CHCTRLA.SetBits(sam.DMAC_CHANNEL_CHCTRLA_SWRST)
+	for ch.CHCTRLA.HasBits(sam.DMAC_CHANNEL_CHCTRLA_SWRST) {
+	}
+	ch.CHCTRLA.Set((trigger << sam.DMAC_CHANNEL_CHCTRLA_TRIGSRC_Pos) |
+		(2 << sam.DMAC_CHANNEL_CHCTRLA_TRIGACT_Pos)) // TRIGACT_BURST
+	if enableInterrupt {
+		ch.CHINTENSET.Set(sam.DMAC_CHANNEL_CHINTENSET_TCMPL | sam.DMAC_CHANNEL_CHINTENSET_TERR)
+	}
+}
+
+// startBlock starts the transfer of the next block of at most 65535 bytes.
+func (t *sercomDMA) startBlock(sercom uint8) {
+	n := t.remaining
+	if n > 0xffff {
+		n = 0xffff
+	}
+	t.remaining -= n
+
+	// Note that an incrementing address must point to the end of the block.
+	desc := &dmaDescriptors[sercom*2]
+	btctrl := uint16(dmaDescriptorValid)
+	if len(t.w) != 0 {
+		desc.srcaddr.Set(uint32(uintptr(unsafe.Pointer(&t.w[0]))) + uint32(n))
+		btctrl |= dmaDescriptorSrcInc
+		t.w = t.w[n:]
+	} else {
+		desc.srcaddr.Set(uint32(uintptr(unsafe.Pointer(&dmaZero))))
+	}
+	desc.dstaddr.Set(t.data)
+	desc.btcnt.Set(uint16(n))
+	desc.btctrl.Set(btctrl)
+
+	if t.receive {
+		desc := &dmaDescriptors[sercom*2+1]
+		btctrl := uint16(dmaDescriptorValid)
+		desc.srcaddr.Set(t.data)
+		if len(t.r) != 0 {
+			desc.dstaddr.Set(uint32(uintptr(unsafe.Pointer(&t.r[0]))) + uint32(n))
+			btctrl |= dmaDescriptorDstInc
+			t.r = t.r[n:]
+		} else {
+			desc.dstaddr.Set(uint32(uintptr(unsafe.Pointer(&dmaDummy))))
+		}
+		desc.btcnt.Set(uint16(n))
+		desc.btctrl.Set(btctrl)
+
+		// Enable the receive channel first, so that no byte is missed.
+		sam.DMAC.CHANNEL[sercom*2+1].CHCTRLA.SetBits(sam.DMAC_CHANNEL_CHCTRLA_ENABLE)
+	}
+	sam.DMAC.CHANNEL[sercom*2].CHCTRLA.SetBits(sam.DMAC_CHANNEL_CHCTRLA_ENABLE)
+}
+
+// handleDMACInterrupt handles the end of a block on any channel: it starts the
+// next block or finishes the transfer.
+func handleDMACInterrupt(interrupt.Interrupt) {
+	for i := range dmaTransfers {
+		t := &dmaTransfers[i]
+		ch := &sam.DMAC.CHANNEL[i*2]
+		if t.receive {
+			ch = &sam.DMAC.CHANNEL[i*2+1]
+		}
+		flags := ch.CHINTFLAG.Get() & (sam.DMAC_CHANNEL_CHINTFLAG_TCMPL | sam.DMAC_CHANNEL_CHINTFLAG_TERR)
+		if flags == 0 {
+			continue
+		}
+		ch.CHINTFLAG.Set(flags) // clear flags
+		if flags&sam.DMAC_CHANNEL_CHINTFLAG_TERR != 0 {
+			// Stop the other channel as well, if it is still running.
+			sam.DMAC.CHANNEL[i*2].CHCTRLA.ClearBits(sam.DMAC_CHANNEL_CHCTRLA_ENABLE)
+			t.err = errDMATransfer
+			t.waiter.done()
+			continue
+		}
+		if t.remaining != 0 {
+			t.startBlock(uint8(i))
+			continue
+		}
+		t.waiter.done()
+	}
+}
+
+// StartTx starts a SPI transfer in the background using DMA, and returns
+// immediately. It sends and receives data like Tx: w and r must have the same
+// length unless one of them is nil. The buffers must not be used until Wait
+// returns.
+//
+// If a transfer is still in progress, StartTx first waits for it to finish.
+func (spi SPI) StartTx(w, r []byte) error {
+	if w != nil && r != nil && len(w) != len(r) {
+		return ErrTxInvalidSliceSize
+	}
+	dmaTransfers[spi.SERCOM].start(spi.SERCOM, &spi.Bus.DATA, w, r, true)
+	return nil
+}
+
+// Wait blocks until the transfer started with StartTx is done. Other
+// goroutines can run in the meantime.
+func (spi SPI) Wait() error {
+	t := &dmaTransfers[spi.SERCOM]
+	t.waiter.wait()
+	return t.err
+}
+
+// StartWrite starts sending data over the UART in the background using DMA,
+// and returns immediately. The data must not be modified until Wait returns.
+//
+// If a write is still in progress, StartWrite first waits for it to finish.
+func (uart UART) StartWrite(data []byte) error {
+	dmaTransfers[uart.SERCOM].start(uart.SERCOM, &uart.Bus.DATA, data, nil, false)
+	return nil
+}
+
+// Wait blocks until the data passed to StartWrite has been handed to the UART.
+// Other goroutines can run in the meantime.
+func (uart UART) Wait() error {
+	t := &dmaTransfers[uart.SERCOM]
+	t.waiter.wait()
+	return t.err
+}
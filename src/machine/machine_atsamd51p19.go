@@ -11,60 +11,51 @@ import "device/sam"
 
 const HSRAM_SIZE = 0x00030000
 
-// InitPWM initializes the PWM interface.
-func InitPWM() {
-	// turn on timer clocks used for PWM
-	sam.MCLK.APBBMASK.SetBits(sam.MCLK_APBBMASK_TCC0_ | sam.MCLK_APBBMASK_TCC1_)
-	sam.MCLK.APBCMASK.SetBits(sam.MCLK_APBCMASK_TCC2_ | sam.MCLK_APBCMASK_TCC3_)
-	sam.MCLK.APBDMASK.SetBits(sam.MCLK_APBDMASK_TCC4_)
+// The TCC peripherals that are only available on the larger SAMD51 chips.
+var (
+	TCC3 = (*TCC)(sam.TCC3)
+	TCC4 = (*TCC)(sam.TCC4)
+)
 
-	//use clock generator 0
-	sam.GCLK.PCHCTRL[sam.PCHCTRL_GCLK_TCC0].Set((sam.GCLK_PCHCTRL_GEN_GCLK0 << sam.GCLK_PCHCTRL_GEN_Pos) |
-		sam.GCLK_PCHCTRL_CHEN)
-	sam.GCLK.PCHCTRL[sam.PCHCTRL_GCLK_TCC2].Set((sam.GCLK_PCHCTRL_GEN_GCLK0 << sam.GCLK_PCHCTRL_GEN_Pos) |
-		sam.GCLK_PCHCTRL_CHEN)
-	sam.GCLK.PCHCTRL[sam.PCHCTRL_GCLK_TCC4].Set((sam.GCLK_PCHCTRL_GEN_GCLK0 << sam.GCLK_PCHCTRL_GEN_Pos) |
-		sam.GCLK_PCHCTRL_CHEN)
-}
+// pwmPeripherals lists the TCCs that are used by the deprecated PWM API.
+var pwmPeripherals = [...]pwmPeripheral{TCC0, TCC1, TCC2, TCC3, TCC4}
 
-// getTimer returns the timer to be used for PWM on this pin
-func (pwm PWM) getTimer() *sam.TCC_Type {
-	switch pwm.Pin {
-	case PA14:
-		return sam.TCC2
-	case PA15:
-		return sam.TCC2
-	case PA16:
-		return sam.TCC1
-	case PA17:
-		return sam.TCC1
-	case PA18:
-		return sam.TCC1
-	case PA19:
-		return sam.TCC1
-	case PA20:
-		return sam.TCC0
-	case PA21:
-		return sam.TCC0
-	case PA22:
-		return sam.TCC0
-	case PA23:
-		return sam.TCC0
-	case PB12:
-		return sam.TCC3
-	case PB13:
-		return sam.TCC3
-	case PB14:
-		return sam.TCC4
-	case PB15:
-		return sam.TCC4
-	case PB16:
-		return sam.TCC0
-	case PB17:
-		return sam.TCC0
-	case PB31:
-		return sam.TCC4
-	default:
-		return nil // not supported on this pin
+// enableClock turns on the clock of this TCC, using GCLK0 as its source.
+func (tcc *TCC) enableClock() {
+	switch tcc.timer() {
+	case sam.TCC0, sam.TCC1:
+		sam.MCLK.APBBMASK.SetBits(sam.MCLK_APBBMASK_TCC0_ | sam.MCLK_APBBMASK_TCC1_)
+		sam.GCLK.PCHCTRL[sam.PCHCTRL_GCLK_TCC0].Set((sam.GCLK_PCHCTRL_GEN_GCLK0 << sam.GCLK_PCHCTRL_GEN_Pos) |
+			sam.GCLK_PCHCTRL_CHEN)
+	case sam.TCC2, sam.TCC3:
+		sam.MCLK.APBCMASK.SetBits(sam.MCLK_APBCMASK_TCC2_ | sam.MCLK_APBCMASK_TCC3_)
+		sam.GCLK.PCHCTRL[sam.PCHCTRL_GCLK_TCC2].Set((sam.GCLK_PCHCTRL_GEN_GCLK0 << sam.GCLK_PCHCTRL_GEN_Pos) |
+			sam.GCLK_PCHCTRL_CHEN)
+	case sam.TCC4:
+		sam.MCLK.APBDMASK.SetBits(sam.MCLK_APBDMASK_TCC4_)
+		sam.GCLK.PCHCTRL[sam.PCHCTRL_GCLK_TCC4].Set((sam.GCLK_PCHCTRL_GEN_GCLK0 << sam.GCLK_PCHCTRL_GEN_Pos) |
+			sam.GCLK_PCHCTRL_CHEN)
 	}
 }
+
+// tccPinMapping lists which TCC outputs (WOx) are available on which pins, and
+// with which pin multiplexer setting.
+var tccPinMapping = [...]tccPin{
+	{PA14, PinPWMF, sam.TCC2, 0},
+	{PA15, PinPWMF, sam.TCC2, 1},
+	{PA16, PinPWMF, sam.TCC1, 0},
+	{PA17, PinPWMF, sam.TCC1, 1},
+	{PA18, PinPWMF, sam.TCC1, 2},
+	{PA19, PinPWMF, sam.TCC1, 3},
+	{PA20, PinPWMG, sam.TCC0, 0},
+	{PA21, PinPWMG, sam.TCC0, 1},
+	{PA22, PinPWMG, sam.TCC0, 2},
+	{PA23, PinPWMG, sam.TCC0, 3},
+	{PB12, PinPWMF, sam.TCC3, 0},
+	{PB13, PinPWMF, sam.TCC3, 1},
+	{PB14, PinPWMF, sam.TCC4, 0},
+	{PB15, PinPWMF, sam.TCC4, 1},
+	{PB16, PinPWMG, sam.TCC0, 4},
+	{PB17, PinPWMG, sam.TCC0, 5},
+	{PB31, PinPWMF, sam.TCC4, 1},
+}
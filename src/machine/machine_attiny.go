@@ -10,7 +10,8 @@ type UART struct {
 
 // Configure is a dummy implementation. UART has not been implemented for ATtiny
 // devices.
-func (uart UART) Configure(config UARTConfig) {
+func (uart UART) Configure(config UARTConfig) error {
+	return config.checkDefaultFormat()
 }
 
 // WriteByte is a dummy implementation. UART has not been implemented for ATtiny
@@ -264,11 +264,16 @@ type UART struct {
 	Buffer *RingBuffer
 }
 
-func (uart UART) Configure(config UARTConfig) {
+func (uart UART) Configure(config UARTConfig) error {
+	if err := config.checkDefaultFormat(); err != nil {
+		return err
+	}
+
 	if config.BaudRate == 0 {
 		config.BaudRate = 115200
 	}
 	uart.Bus.CLKDIV.Set(peripheralClock / config.BaudRate)
+	return nil
 }
 
 func (uart UART) WriteByte(b byte) error {
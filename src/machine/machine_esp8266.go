@@ -150,11 +150,16 @@ type UART struct {
 
 // Configure the UART baud rate. TX and RX pins are fixed by the hardware so
 // cannot be modified and will be ignored.
-func (uart UART) Configure(config UARTConfig) {
+func (uart UART) Configure(config UARTConfig) error {
+	if err := config.checkDefaultFormat(); err != nil {
+		return err
+	}
+
 	if config.BaudRate == 0 {
 		config.BaudRate = 115200
 	}
 	esp.UART0.UART_CLKDIV.Set(CPUFrequency() / config.BaudRate)
+	return nil
 }
 
 // WriteByte writes a single byte to the output buffer. Note that the hardware
@@ -60,7 +60,11 @@ var (
 	UART0 = UART{Bus: sifive.UART0, Buffer: NewRingBuffer()}
 )
 
-func (uart UART) Configure(config UARTConfig) {
+func (uart UART) Configure(config UARTConfig) error {
+	if err := config.checkDefaultFormat(); err != nil {
+		return err
+	}
+
 	// Assuming a 16Mhz Crystal (which is Y1 on the HiFive1), the divisor for a
 	// 115200 baud rate is 138.
 	sifive.UART0.DIV.Set(138)
@@ -70,6 +74,7 @@ func (uart UART) Configure(config UARTConfig) {
 	intr := interrupt.New(sifive.IRQ_UART0, UART0.handleInterrupt)
 	intr.SetPriority(5)
 	intr.Enable()
+	return nil
 }
 
 func (uart *UART) handleInterrupt(interrupt.Interrupt) {
@@ -4,6 +4,10 @@ package machine
 
 // Dummy machine package that calls out to external functions.
 
+import "errors"
+
+var errI2CTransfer = errors.New("I2C transfer failed")
+
 var (
 	SPI0  = SPI{0}
 	I2C0  = I2C{0}
@@ -122,8 +126,16 @@ func (i2c I2C) Configure(config I2CConfig) error {
 
 // Tx does a single I2C transaction at the specified address.
 func (i2c I2C) Tx(addr uint16, w, r []byte) error {
-	i2cTransfer(i2c.Bus, &w[0], len(w), &r[0], len(r))
-	// TODO: do something with the returned error code.
+	var wptr, rptr *byte
+	if len(w) != 0 {
+		wptr = &w[0]
+	}
+	if len(r) != 0 {
+		rptr = &r[0]
+	}
+	if i2cTransfer(i2c.Bus, addr, wptr, len(w), rptr, len(r)) != 0 {
+		return errI2CTransfer
+	}
 	return nil
 }
 
@@ -131,21 +143,63 @@ func (i2c I2C) Tx(addr uint16, w, r []byte) error {
 func i2cConfigure(bus uint8, scl Pin, sda Pin)
 
 //export __tinygo_i2c_transfer
-func i2cTransfer(bus uint8, w *byte, wlen int, r *byte, rlen int) int
+func i2cTransfer(bus uint8, addr uint16, w *byte, wlen int, r *byte, rlen int) int
 
-type UART struct {
-	Bus uint8
+// Listen switches the I2C peripheral to target mode, responding to the given
+// 7-bit address. Use WaitForEvent to handle transactions from the controller.
+func (i2c I2C) Listen(addr uint16) error {
+	i2cListen(i2c.Bus, addr)
+	return nil
 }
 
-type UARTConfig struct {
-	BaudRate uint32
-	TX       Pin
-	RX       Pin
+// WaitForEvent blocks until the controller does something with this target,
+// see I2CTargetEvent. For an I2CReceive event, the received data is stored in
+// buf and the number of bytes is returned.
+func (i2c I2C) WaitForEvent(buf []byte) (evt I2CTargetEvent, count int, err error) {
+	var bufptr *byte
+	if len(buf) != 0 {
+		bufptr = &buf[0]
+	}
+	result := i2cWaitEvent(i2c.Bus, bufptr, len(buf), &count)
+	if result < 0 {
+		return I2CReceive, 0, errI2CTransfer
+	}
+	return I2CTargetEvent(result), count, nil
+}
+
+// Reply sends data to the controller after an I2CRequest event.
+func (i2c I2C) Reply(buf []byte) error {
+	var bufptr *byte
+	if len(buf) != 0 {
+		bufptr = &buf[0]
+	}
+	if i2cReply(i2c.Bus, bufptr, len(buf)) != 0 {
+		return errI2CTransfer
+	}
+	return nil
+}
+
+//export __tinygo_i2c_listen
+func i2cListen(bus uint8, addr uint16)
+
+//export __tinygo_i2c_wait_event
+func i2cWaitEvent(bus uint8, buf *byte, bufLen int, count *int) int
+
+//export __tinygo_i2c_reply
+func i2cReply(bus uint8, buf *byte, bufLen int) int
+
+type UART struct {
+	Bus uint8
 }
 
 // Configure the UART.
-func (uart UART) Configure(config UARTConfig) {
+func (uart UART) Configure(config UARTConfig) error {
+	if err := config.checkDefaultFormat(); err != nil {
+		return err
+	}
+
 	uartConfigure(uart.Bus, config.TX, config.RX)
+	return nil
 }
 
 // Read from the UART.
@@ -0,0 +1,7 @@
+// +build linux,!baremetal,!nintendoswitch
+
+package machine
+
+// On Linux, the hooks called from machine_generic.go are implemented by a
+// hardware simulator. Tests can use the machine/sim package to control it.
+import _ "machine/sim"
@@ -341,7 +341,10 @@ var (
 	UART0 = UART{Bus: kendryte.UARTHS, Buffer: NewRingBuffer()}
 )
 
-func (uart UART) Configure(config UARTConfig) {
+func (uart UART) Configure(config UARTConfig) error {
+	if err := config.checkDefaultFormat(); err != nil {
+		return err
+	}
 
 	// Use default baudrate  if not set.
 	if config.BaudRate == 0 {
@@ -369,6 +372,7 @@ func (uart UART) Configure(config UARTConfig) {
 	intr := interrupt.New(kendryte.IRQ_UARTHS, UART0.handleInterrupt)
 	intr.SetPriority(5)
 	intr.Enable()
+	return nil
 }
 
 func (uart *UART) handleInterrupt(interrupt.Interrupt) {
@@ -45,7 +45,10 @@ func (uart *UART) resetTransmitting() {
 
 // Configure initializes a UART with the given UARTConfig and other default
 // settings.
-func (uart *UART) Configure(config UARTConfig) {
+func (uart *UART) Configure(config UARTConfig) error {
+	if err := config.checkDefaultFormat(); err != nil {
+		return err
+	}
 
 	const defaultUartFreq = 115200
 
@@ -155,6 +158,7 @@ func (uart *UART) Configure(config UARTConfig) {
 	uart.Interrupt.Enable()
 
 	uart.configured = true
+	return nil
 }
 
 // Disable disables the UART interface.
@@ -131,7 +131,10 @@ func (p Pin) SetInterrupt(change PinChange, callback func(Pin)) error {
 	return nil
 }
 
-// UART on the NRF.
+// UART on the NRF. This is the UART peripheral without EasyDMA (not UARTE):
+// data is received with an interrupt and sent one byte at a time with
+// WriteByte. On the nRF52, StartWrite temporarily switches the peripheral to
+// UARTE mode to send data in the background using EasyDMA.
 type UART struct {
 	Buffer *RingBuffer
 }
@@ -143,12 +146,18 @@ var (
 )
 
 // Configure the UART.
-func (uart UART) Configure(config UARTConfig) {
+func (uart UART) Configure(config UARTConfig) error {
 	// Default baud rate to 115200.
 	if config.BaudRate == 0 {
 		config.BaudRate = 115200
 	}
 
+	dataBits, stopBits := config.format()
+	err := uart.SetFormat(dataBits, stopBits, config.Parity)
+	if err != nil {
+		return err
+	}
+
 	uart.SetBaudRate(config.BaudRate)
 
 	// Set TX and RX pins
@@ -159,6 +168,12 @@ func (uart UART) Configure(config UARTConfig) {
 		uart.setPins(config.TX, config.RX)
 	}
 
+	// Set RTS and CTS pins, if hardware flow control is used.
+	if config.flowControl() {
+		uart.setFlowControlPins(config.RTS, config.CTS)
+		nrf.UART0.CONFIG.SetBits(nrf.UART_CONFIG_HWFC_Enabled << nrf.UART_CONFIG_HWFC_Pos)
+	}
+
 	nrf.UART0.ENABLE.Set(nrf.UART_ENABLE_ENABLE_Enabled)
 	nrf.UART0.TASKS_STARTTX.Set(1)
 	nrf.UART0.TASKS_STARTRX.Set(1)
@@ -168,6 +183,33 @@ func (uart UART) Configure(config UARTConfig) {
 	intr := interrupt.New(nrf.IRQ_UART0, NRF_UART0.handleInterrupt)
 	intr.SetPriority(0xc0) // low priority
 	intr.Enable()
+
+	return nil
+}
+
+// SetFormat changes the number of data bits, the parity and the number of stop
+// bits of the UART. The hardware only supports 8 data bits, no parity or even
+// parity, and on the nrf51 only one stop bit.
+func (uart UART) SetFormat(dataBits, stopBits uint8, parity UARTParity) error {
+	if dataBits != 8 {
+		return ErrUARTFormatNotSupported
+	}
+
+	// Keep the hardware flow control setting.
+	config := nrf.UART0.CONFIG.Get() & nrf.UART_CONFIG_HWFC_Msk
+	switch parity {
+	case ParityNone:
+	case ParityEven:
+		config |= nrf.UART_CONFIG_PARITY_Included << nrf.UART_CONFIG_PARITY_Pos
+	default:
+		return ErrUARTFormatNotSupported
+	}
+	stop, err := uartConfigStop(stopBits)
+	if err != nil {
+		return err
+	}
+	nrf.UART0.CONFIG.Set(config | stop)
+	return nil
 }
 
 // SetBaudRate sets the communication speed for the UART.
@@ -187,6 +229,7 @@ func (uart UART) SetBaudRate(br uint32) {
 
 // WriteByte writes a byte of data to the UART.
 func (uart UART) WriteByte(c byte) error {
+	uart.waitForWrite()
 	nrf.UART0.EVENTS_TXDRDY.Set(0)
 	nrf.UART0.TXD.Set(uint32(c))
 	for nrf.UART0.EVENTS_TXDRDY.Get() == 0 {
@@ -199,6 +242,7 @@ func (uart *UART) handleInterrupt(interrupt.Interrupt) {
 		uart.Receive(byte(nrf.UART0.RXD.Get()))
 		nrf.UART0.EVENTS_RXDRDY.Set(0x0)
 	}
+	uart.handleWriteInterrupt()
 }
 
 // I2C on the NRF.
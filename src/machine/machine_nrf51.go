@@ -24,6 +24,26 @@ func (uart UART) setPins(tx, rx Pin) {
 	nrf.UART0.PSELRXD.Set(uint32(rx))
 }
 
+// uartConfigStop returns the STOP field of the UART CONFIG register. The nrf51
+// only supports one stop bit.
+func uartConfigStop(stopBits uint8) (uint32, error) {
+	if stopBits != 1 {
+		return 0, ErrUARTFormatNotSupported
+	}
+	return 0, nil
+}
+
+func (uart UART) setFlowControlPins(rts, cts Pin) {
+	nrf.UART0.PSELRTS.Set(uint32(rts))
+	nrf.UART0.PSELCTS.Set(uint32(cts))
+}
+
+// waitForWrite does nothing, as there is no StartWrite on the nrf51.
+func (uart UART) waitForWrite() {}
+
+// handleWriteInterrupt does nothing, as there is no StartWrite on the nrf51.
+func (uart *UART) handleWriteInterrupt() {}
+
 func (i2c I2C) setPins(scl, sda Pin) {
 	i2c.Bus.PSELSCL.Set(uint32(scl))
 	i2c.Bus.PSELSDA.Set(uint32(sda))
@@ -56,14 +56,29 @@ func (uart UART) setPins(tx, rx Pin) {
 	nrf.UART0.PSELRXD.Set(uint32(rx))
 }
 
+func (uart UART) setFlowControlPins(rts, cts Pin) {
+	nrf.UART0.PSELRTS.Set(uint32(rts))
+	nrf.UART0.PSELCTS.Set(uint32(cts))
+}
+
 func (i2c I2C) setPins(scl, sda Pin) {
 	i2c.Bus.PSELSCL.Set(uint32(scl))
 	i2c.Bus.PSELSDA.Set(uint32(sda))
 }
 
+// getPins returns the pins set with setPins, for use by the I2C target
+// peripheral.
+func (i2c I2C) getPins() (scl, sda Pin) {
+	return Pin(i2c.Bus.PSELSCL.Get()), Pin(i2c.Bus.PSELSDA.Get())
+}
+
 // PWM
 var (
-	pwmChannelPins     = [3]uint32{0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF}
-	pwms               = [3]*nrf.PWM_Type{nrf.PWM0, nrf.PWM1, nrf.PWM2}
-	pwmChannelSequence [3]uint16
+	PWM0 = &PWMPeripheral{PWM: nrf.PWM0}
+	PWM1 = &PWMPeripheral{PWM: nrf.PWM1}
+	PWM2 = &PWMPeripheral{PWM: nrf.PWM2}
 )
+
+// pwmPeripherals lists the PWM peripherals that are used by the deprecated PWM
+// API.
+var pwmPeripherals = [...]pwmPeripheral{PWM0, PWM1, PWM2}
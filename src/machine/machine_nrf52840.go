@@ -72,14 +72,30 @@ func (uart UART) setPins(tx, rx Pin) {
 	nrf.UART0.PSEL.RXD.Set(uint32(rx))
 }
 
+func (uart UART) setFlowControlPins(rts, cts Pin) {
+	nrf.UART0.PSEL.RTS.Set(uint32(rts))
+	nrf.UART0.PSEL.CTS.Set(uint32(cts))
+}
+
 func (i2c I2C) setPins(scl, sda Pin) {
 	i2c.Bus.PSEL.SCL.Set(uint32(scl))
 	i2c.Bus.PSEL.SDA.Set(uint32(sda))
 }
 
+// getPins returns the pins set with setPins, for use by the I2C target
+// peripheral.
+func (i2c I2C) getPins() (scl, sda Pin) {
+	return Pin(i2c.Bus.PSEL.SCL.Get()), Pin(i2c.Bus.PSEL.SDA.Get())
+}
+
 // PWM
 var (
-	pwmChannelPins     = [4]uint32{0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF}
-	pwms               = [4]*nrf.PWM_Type{nrf.PWM0, nrf.PWM1, nrf.PWM2, nrf.PWM3}
-	pwmChannelSequence [4]uint16
+	PWM0 = &PWMPeripheral{PWM: nrf.PWM0}
+	PWM1 = &PWMPeripheral{PWM: nrf.PWM1}
+	PWM2 = &PWMPeripheral{PWM: nrf.PWM2}
+	PWM3 = &PWMPeripheral{PWM: nrf.PWM3}
 )
+
+// pwmPeripherals lists the PWM peripherals that are used by the deprecated PWM
+// API.
+var pwmPeripherals = [...]pwmPeripheral{PWM0, PWM1, PWM2, PWM3}
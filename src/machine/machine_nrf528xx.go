@@ -4,6 +4,9 @@ package machine
 
 import (
 	"device/nrf"
+	"errors"
+	"runtime/interrupt"
+	"runtime/volatile"
 	"unsafe"
 )
 
@@ -11,6 +14,111 @@ func CPUFrequency() uint32 {
 	return 64000000
 }
 
+// uartConfigStop returns the STOP field of the UART CONFIG register.
+func uartConfigStop(stopBits uint8) (uint32, error) {
+	switch stopBits {
+	case 1:
+		return nrf.UART_CONFIG_STOP_One << nrf.UART_CONFIG_STOP_Pos, nil
+	case 2:
+		return nrf.UART_CONFIG_STOP_Two << nrf.UART_CONFIG_STOP_Pos, nil
+	default:
+		return 0, ErrUARTFormatNotSupported
+	}
+}
+
+// uartWrite is the state of a write started with StartWrite. Like SPI
+// transfers, the data is sent in pieces of at most 255 bytes: the next piece is
+// started from the interrupt handler.
+var uartWrite struct {
+	waiter dmaWaiter
+	data   []byte // data that remains to be sent
+}
+
+// StartWrite starts sending data over the UART in the background using
+// EasyDMA, and returns immediately. The data must not be modified until Wait
+// returns.
+//
+// EasyDMA can only access RAM, so the data must be stored in RAM, like for
+// SPI.StartTx. The peripheral is switched to UARTE mode while the data is being
+// sent, so no data is received in the meantime.
+//
+// If a write is still in progress, StartWrite first waits for it to finish.
+func (uart UART) StartWrite(data []byte) error {
+	uartWrite.waiter.wait()
+	if len(data) == 0 {
+		return nil
+	}
+	if !isInRAM(data) {
+		return errDMANotInRAM
+	}
+
+	// The UART and UARTE share their registers, including the pins, baud rate
+	// and format, so the peripheral only needs to be switched over.
+	nrf.UART0.TASKS_STOPTX.Set(1)
+	nrf.UART0.TASKS_STOPRX.Set(1)
+	nrf.UART0.ENABLE.Set(nrf.UART_ENABLE_ENABLE_Disabled)
+	nrf.UARTE0.ENABLE.Set(nrf.UARTE_ENABLE_ENABLE_Enabled)
+
+	uartWrite.data = data
+	uartWrite.waiter.start()
+	nrf.UARTE0.EVENTS_ENDTX.Set(0)
+	nrf.UARTE0.INTENSET.Set(nrf.UARTE_INTENSET_ENDTX)
+	startUARTWritePiece()
+	return nil
+}
+
+// Wait blocks until the data passed to StartWrite has been sent. Other
+// goroutines can run in the meantime.
+func (uart UART) Wait() error {
+	uartWrite.waiter.wait()
+	return nil
+}
+
+// waitForWrite waits until a write started with StartWrite is done, so that
+// the peripheral is back in UART mode.
+func (uart UART) waitForWrite() {
+	uartWrite.waiter.wait()
+}
+
+// startUARTWritePiece starts sending the next piece of at most 255 bytes.
+func startUARTWritePiece() {
+	data := uartWrite.data
+	n := uint32(len(data))
+	if n > 255 {
+		n = 255
+	}
+	nrf.UARTE0.TXD.PTR.Set(uint32(uintptr(unsafe.Pointer(&data[0]))))
+	nrf.UARTE0.TXD.MAXCNT.Set(n)
+	uartWrite.data = data[n:]
+	nrf.UARTE0.TASKS_STARTTX.Set(1)
+}
+
+// handleWriteInterrupt starts sending the next piece of the data passed to
+// StartWrite, or switches the peripheral back to UART mode when all data has
+// been sent.
+func (uart *UART) handleWriteInterrupt() {
+	if nrf.UARTE0.EVENTS_ENDTX.Get() == 0 {
+		return
+	}
+	nrf.UARTE0.EVENTS_ENDTX.Set(0)
+	if len(uartWrite.data) != 0 {
+		startUARTWritePiece()
+		return
+	}
+
+	// The transmitter must be stopped before the UARTE can be disabled.
+	nrf.UARTE0.INTENCLR.Set(nrf.UARTE_INTENCLR_ENDTX)
+	nrf.UARTE0.EVENTS_TXSTOPPED.Set(0)
+	nrf.UARTE0.TASKS_STOPTX.Set(1)
+	for nrf.UARTE0.EVENTS_TXSTOPPED.Get() == 0 {
+	}
+	nrf.UARTE0.ENABLE.Set(nrf.UARTE_ENABLE_ENABLE_Disabled)
+	nrf.UART0.ENABLE.Set(nrf.UART_ENABLE_ENABLE_Enabled)
+	nrf.UART0.TASKS_STARTTX.Set(1)
+	nrf.UART0.TASKS_STARTRX.Set(1)
+	uartWrite.waiter.done()
+}
+
 // InitADC initializes the registers needed for ADC.
 func InitADC() {
 	return // no specific setup on nrf52 machine.
@@ -256,41 +364,388 @@ func (spi SPI) Tx(w, r []byte) error {
 	return nil
 }
 
-// InitPWM initializes the registers needed for PWM.
-func InitPWM() {
-	return
+var (
+	errDMANotInRAM        = errors.New("DMA buffer not in RAM")
+	errSPIDMANotSupported = errors.New("SPI: StartTx is only supported on SPIM0, SPIM1 and SPIM2")
+)
+
+// spiDMA is the state of a transfer started with StartTx. The transfer is done
+// in pieces of at most 255 bytes, like in Tx: the next piece is started from
+// the interrupt handler.
+type spiDMA struct {
+	waiter dmaWaiter
+	w, r   []byte // data that remains to be sent and received
 }
 
-// Configure configures a PWM pin for output.
-func (pwm PWM) Configure() {
+var (
+	spiTransfers         [3]spiDMA
+	spiInterruptsEnabled bool
+)
+
+// StartTx starts a SPI transfer in the background using EasyDMA, and returns
+// immediately. The buffers are handled like in Tx. They must not be used until
+// Wait returns.
+//
+// EasyDMA can only access RAM, so both buffers must be stored in RAM: copy
+// constant data (that may be stored in flash) to a buffer on the heap first.
+// StartTx is not supported on SPIM3, which only exists on some chips.
+//
+// If a transfer is still in progress, StartTx first waits for it to finish.
+func (spi SPI) StartTx(w, r []byte) error {
+	index := spi.index()
+	if index < 0 {
+		return errSPIDMANotSupported
+	}
+	t := &spiTransfers[index]
+	t.waiter.wait()
+	if len(w) == 0 && len(r) == 0 {
+		return nil
+	}
+	if !isInRAM(w) || !isInRAM(r) {
+		return errDMANotInRAM
+	}
+
+	if !spiInterruptsEnabled {
+		spiInterruptsEnabled = true
+		intr := interrupt.New(nrf.IRQ_SPIM0_SPIS0_TWIM0_TWIS0_SPI0_TWI0, func(interrupt.Interrupt) {
+			SPI0.handleInterrupt()
+		})
+		intr.Enable()
+		intr = interrupt.New(nrf.IRQ_SPIM1_SPIS1_TWIM1_TWIS1_SPI1_TWI1, func(interrupt.Interrupt) {
+			SPI1.handleInterrupt()
+		})
+		intr.Enable()
+		intr = interrupt.New(nrf.IRQ_SPIM2_SPIS2_SPI2, func(interrupt.Interrupt) {
+			SPI2.handleInterrupt()
+		})
+		intr.Enable()
+	}
+
+	t.w = w
+	t.r = r
+	t.waiter.start()
+	spi.Bus.INTENSET.Set(nrf.SPIM_INTENSET_END)
+	spi.startPiece(t)
+	return nil
 }
 
-// Set turns on the duty cycle for a PWM pin using the provided value.
-func (pwm PWM) Set(value uint16) {
-	for i := 0; i < len(pwmChannelPins); i++ {
-		if pwmChannelPins[i] == 0xFFFFFFFF || pwmChannelPins[i] == uint32(pwm.Pin) {
-			pwmChannelPins[i] = uint32(pwm.Pin)
-			pwmChannelSequence[i] = (value >> 2) | 0x8000 // set bit 15 to invert polarity
+// Wait blocks until the transfer started with StartTx is done. Other
+// goroutines can run in the meantime.
+func (spi SPI) Wait() error {
+	index := spi.index()
+	if index < 0 {
+		return nil // no transfer can be in progress
+	}
+	spiTransfers[index].waiter.wait()
+	return nil
+}
 
-			p := pwms[i]
+// startPiece starts the transfer of the next piece of at most 255 bytes.
+func (spi SPI) startPiece(t *spiDMA) {
+	n := uint32(0)
+	if len(t.r) != 0 {
+		spi.Bus.RXD.PTR.Set(uint32(uintptr(unsafe.Pointer(&t.r[0]))))
+		n = uint32(len(t.r))
+		if n > 255 {
+			n = 255
+		}
+		t.r = t.r[n:]
+	}
+	spi.Bus.RXD.MAXCNT.Set(n)
+	n = 0
+	if len(t.w) != 0 {
+		spi.Bus.TXD.PTR.Set(uint32(uintptr(unsafe.Pointer(&t.w[0]))))
+		n = uint32(len(t.w))
+		if n > 255 {
+			n = 255
+		}
+		t.w = t.w[n:]
+	}
+	spi.Bus.TXD.MAXCNT.Set(n)
+	spi.Bus.TASKS_START.Set(1)
+}
+
+// handleInterrupt starts the next piece of the transfer started with StartTx,
+// or finishes it.
+func (spi SPI) handleInterrupt() {
+	if spi.Bus.EVENTS_END.Get() == 0 {
+		return
+	}
+	spi.Bus.EVENTS_END.Set(0)
+	t := &spiTransfers[spi.index()]
+	if len(t.w) != 0 || len(t.r) != 0 {
+		spi.startPiece(t)
+		return
+	}
 
-			p.PSEL.OUT[0].Set(uint32(pwm.Pin))
-			p.PSEL.OUT[1].Set(uint32(pwm.Pin))
-			p.PSEL.OUT[2].Set(uint32(pwm.Pin))
-			p.PSEL.OUT[3].Set(uint32(pwm.Pin))
-			p.ENABLE.Set(nrf.PWM_ENABLE_ENABLE_Enabled << nrf.PWM_ENABLE_ENABLE_Pos)
-			p.PRESCALER.Set(nrf.PWM_PRESCALER_PRESCALER_DIV_2)
-			p.MODE.Set(nrf.PWM_MODE_UPDOWN_Up)
-			p.COUNTERTOP.Set(16384) // frequency
-			p.LOOP.Set(0)
-			p.DECODER.Set((nrf.PWM_DECODER_LOAD_Common << nrf.PWM_DECODER_LOAD_Pos) | (nrf.PWM_DECODER_MODE_RefreshCount << nrf.PWM_DECODER_MODE_Pos))
-			p.SEQ[0].PTR.Set(uint32(uintptr(unsafe.Pointer(&pwmChannelSequence[i]))))
-			p.SEQ[0].CNT.Set(1)
-			p.SEQ[0].REFRESH.Set(1)
-			p.SEQ[0].ENDDELAY.Set(0)
-			p.TASKS_SEQSTART[0].Set(1)
+	// Disable the interrupt again, Tx polls the END event.
+	spi.Bus.INTENCLR.Set(nrf.SPIM_INTENCLR_END)
+	t.waiter.done()
+}
 
-			break
+// index returns the number of this SPI peripheral, or -1 if it is not one of
+// the SPIM peripherals that support StartTx (SPIM3 on the nRF52833 and nRF52840
+// has a different interrupt and is not supported).
+func (spi SPI) index() int {
+	switch spi.Bus {
+	case nrf.SPIM0:
+		return 0
+	case nrf.SPIM1:
+		return 1
+	case nrf.SPIM2:
+		return 2
+	default:
+		return -1
+	}
+}
+
+// isInRAM returns whether the buffer is stored in RAM and can therefore be
+// accessed by EasyDMA. Data RAM starts at 0x20000000, everything below that
+// (such as flash) can't be read by EasyDMA. An empty buffer is always
+// accepted.
+func isInRAM(buf []byte) bool {
+	if len(buf) == 0 {
+		return true
+	}
+	return uintptr(unsafe.Pointer(&buf[0])) >= 0x20000000
+}
+
+// PWMPeripheral is one PWM peripheral, which consists of a counter and
+// multiple output channels (that can be connected to actual pins). The period
+// is set with Configure, and is shared by all the channels of this PWM
+// peripheral. It is not called PWM, as that name is used by the deprecated PWM
+// API that configures a single pin.
+type PWMPeripheral struct {
+	PWM *nrf.PWM_Type
+
+	// The channel values are read by the peripheral using EasyDMA. Bit 15 is
+	// the polarity: when it is set, the output is high for the first part of
+	// the period (the output is not inverted).
+	channelValues [4]volatile.Register16
+}
+
+// Configure enables and configures this PWM peripheral with the given period.
+// All channels are set to a duty cycle of 0 (always low).
+func (pwm *PWMPeripheral) Configure(config PWMConfig) error {
+	// The PWM peripheral runs from a 16MHz clock. Calculate the prescaler and
+	// the top value for this period: the counter top is 15 bits in size.
+	cycles := pwmCycles(config.Period, 16)
+	prescaler := uint32(0)
+	for cycles>>prescaler > 0x7fff {
+		prescaler++
+		if prescaler > nrf.PWM_PRESCALER_PRESCALER_DIV_128 {
+			return ErrPWMPeriodTooLong
 		}
 	}
+	top := cycles >> prescaler
+	if top < 3 {
+		top = 3 // the minimum value of COUNTERTOP
+	}
+
+	// Enable the peripheral.
+	pwm.PWM.ENABLE.Set(nrf.PWM_ENABLE_ENABLE_Enabled << nrf.PWM_ENABLE_ENABLE_Pos)
+
+	// Use up counting only.
+	pwm.PWM.MODE.Set(nrf.PWM_MODE_UPDOWN_Up << nrf.PWM_MODE_UPDOWN_Pos)
+	pwm.PWM.PRESCALER.Set(prescaler)
+	pwm.PWM.COUNTERTOP.Set(uint32(top))
+
+	// Indicate there are four channels that each have a different value.
+	pwm.PWM.DECODER.Set((nrf.PWM_DECODER_LOAD_Individual << nrf.PWM_DECODER_LOAD_Pos) | (nrf.PWM_DECODER_MODE_RefreshCount << nrf.PWM_DECODER_MODE_Pos))
+
+	// Start with all channels at 0% duty cycle.
+	for i := range pwm.channelValues {
+		pwm.channelValues[i].Set(0x8000)
+	}
+
+	// Set the EasyDMA buffer, which has 4 values (one for each channel). The
+	// sequence is played once, after which the last values are kept.
+	pwm.PWM.SEQ[0].PTR.Set(uint32(uintptr(unsafe.Pointer(&pwm.channelValues[0]))))
+	pwm.PWM.SEQ[0].CNT.Set(uint32(len(pwm.channelValues)))
+	pwm.PWM.SEQ[0].REFRESH.Set(0)
+	pwm.PWM.SEQ[0].ENDDELAY.Set(0)
+	pwm.PWM.LOOP.Set(0)
+	pwm.PWM.TASKS_SEQSTART[0].Set(1)
+
+	return nil
+}
+
+// Top returns the current counter top, for use in duty cycle calculation. It
+// will only change with a call to Configure.
+//
+// The value returned here is hardware dependent. In general, it's best to treat
+// it as an opaque value that can be divided by some number and passed to Set
+// (see Set documentation for more information).
+func (pwm *PWMPeripheral) Top() uint32 {
+	return pwm.PWM.COUNTERTOP.Get()
+}
+
+// Channel returns a PWM channel for the given pin. Every pin can be used, but
+// there are only four channels per PWM peripheral. ErrInvalidOutputPin is
+// returned if all channels of this peripheral are already in use by other pins.
+func (pwm *PWMPeripheral) Channel(pin Pin) (uint8, error) {
+	config := uint32(pin)
+	for ch := uint8(0); ch < 4; ch++ {
+		channelConfig := pwm.PWM.PSEL.OUT[ch].Get()
+		if channelConfig == 0xffffffff {
+			// Unused channel. Configure it.
+			pin.Configure(PinConfig{Mode: PinOutput})
+			pin.Low()
+			pwm.PWM.PSEL.OUT[ch].Set(config)
+			return ch, nil
+		}
+		if channelConfig == config {
+			// This channel is already used for this pin.
+			return ch, nil
+		}
+	}
+
+	// All four pins are already in use with other pins.
+	return 0, ErrInvalidOutputPin
+}
+
+// SetInverting sets whether to invert the output of this channel.
+// Without inverting, a 25% duty cycle would mean the output is high for 25% of
+// the time and low for the rest. Inverting flips the output as if a NOT gate
+// was placed at the output, meaning that the output would be 25% low and 75%
+// high with a duty cycle of 25%.
+func (pwm *PWMPeripheral) SetInverting(channel uint8, inverting bool) {
+	ptr := &pwm.channelValues[channel]
+	if inverting {
+		ptr.Set(ptr.Get() &^ 0x8000)
+	} else {
+		ptr.Set(ptr.Get() | 0x8000)
+	}
+	pwm.PWM.TASKS_SEQSTART[0].Set(1)
+}
+
+// Set updates the channel value. This is used to control the channel duty
+// cycle, in other words the fraction of time the channel output is high (or low
+// when inverted). For example, to set it to a 25% duty cycle, use:
+//
+//     pwm.Set(channel, pwm.Top() / 4)
+//
+// pwm.Set(channel, 0) will set the output to low and pwm.Set(channel,
+// pwm.Top()) will set the output to high, assuming the output isn't inverted.
+func (pwm *PWMPeripheral) Set(channel uint8, value uint32) {
+	if value > pwm.Top() {
+		value = pwm.Top()
+	}
+	// Update the channel value while retaining the polarity bit.
+	ptr := &pwm.channelValues[channel]
+	ptr.Set(ptr.Get()&0x8000 | uint16(value)&0x7fff)
+
+	// Restart the sequence, so that the new value is loaded by EasyDMA.
+	pwm.PWM.TASKS_SEQSTART[0].Set(1)
+}
+
+// Listen switches the I2C peripheral to target mode, responding to the given
+// 7-bit address. Configure must be called first to set up the pins. Use
+// WaitForEvent to handle transactions from the controller.
+//
+// Target mode uses the TWIS peripheral that shares its resources with the TWI
+// peripheral of this bus, so the bus can't be used as a controller anymore.
+func (i2c I2C) Listen(addr uint16) error {
+	scl, sda := i2c.getPins()
+	i2c.Bus.ENABLE.Set(nrf.TWI_ENABLE_ENABLE_Disabled)
+
+	target := i2c.target()
+	target.PSEL.SCL.Set(uint32(scl))
+	target.PSEL.SDA.Set(uint32(sda))
+	target.ADDRESS[0].Set(uint32(addr))
+	target.CONFIG.Set(nrf.TWIS_CONFIG_ADDRESS0)
+	target.ORC.Set(0xff) // sent when the controller reads past the Reply buffer
+
+	// Suspend (stretching the clock) on every read or write command, so that
+	// the buffers can be set up by WaitForEvent and Reply.
+	target.SHORTS.Set(nrf.TWIS_SHORTS_WRITE_SUSPEND | nrf.TWIS_SHORTS_READ_SUSPEND)
+	target.ENABLE.Set(nrf.TWIS_ENABLE_ENABLE_Enabled)
+
+	return nil
+}
+
+// WaitForEvent blocks until the controller does something with this target,
+// see I2CTargetEvent. For an I2CReceive event, the received data is stored in
+// buf and the number of bytes is returned.
+//
+// The data is received with EasyDMA, which can only access RAM: buf must not
+// be stored in flash. Other goroutines keep running while waiting.
+func (i2c I2C) WaitForEvent(buf []byte) (evt I2CTargetEvent, count int, err error) {
+	target := i2c.target()
+	for {
+		switch {
+		case target.EVENTS_ERROR.Get() != 0:
+			target.EVENTS_ERROR.Set(0)
+			target.ERRORSRC.Set(target.ERRORSRC.Get()) // write 1 to clear
+			return I2CReceive, 0, errI2CBusError
+		case target.EVENTS_WRITE.Get() != 0:
+			target.EVENTS_WRITE.Set(0)
+
+			// Receive into buf using EasyDMA. Bytes that don't fit are not
+			// acknowledged.
+			if len(buf) != 0 {
+				target.RXD.PTR.Set(uint32(uintptr(unsafe.Pointer(&buf[0]))))
+			}
+			target.RXD.MAXCNT.Set(uint32(len(buf)))
+			target.TASKS_PREPARERX.Set(1)
+			target.TASKS_RESUME.Set(1)
+
+			// Wait until the controller sends a stop condition or a repeated
+			// start. Those events are left set, so that they are handled in
+			// the next call.
+			for target.EVENTS_STOPPED.Get() == 0 && target.EVENTS_READ.Get() == 0 &&
+				target.EVENTS_WRITE.Get() == 0 && target.EVENTS_ERROR.Get() == 0 {
+				gosched()
+			}
+			return I2CReceive, int(target.RXD.AMOUNT.Get()), nil
+		case target.EVENTS_READ.Get() != 0:
+			// The data is sent by Reply.
+			target.EVENTS_READ.Set(0)
+			return I2CRequest, 0, nil
+		case target.EVENTS_STOPPED.Get() != 0:
+			target.EVENTS_STOPPED.Set(0)
+			return I2CFinish, 0, nil
+		}
+
+		// Nothing happened yet: let other goroutines run while waiting.
+		gosched()
+	}
+}
+
+// Reply sends data to the controller after an I2CRequest event. If the
+// controller reads more bytes than there are in buf, 0xff is sent for the
+// remaining bytes.
+//
+// The data is sent with EasyDMA, which can only access RAM: buf must not be
+// stored in flash, so copy constant data (for example a string or a global
+// that is never modified) to a buffer on the heap or stack first.
+func (i2c I2C) Reply(buf []byte) error {
+	target := i2c.target()
+	if len(buf) != 0 {
+		target.TXD.PTR.Set(uint32(uintptr(unsafe.Pointer(&buf[0]))))
+	}
+	target.TXD.MAXCNT.Set(uint32(len(buf)))
+	target.TASKS_PREPARETX.Set(1)
+	target.TASKS_RESUME.Set(1)
+
+	// Wait until the controller is done reading. The buffer must stay valid
+	// until then.
+	for target.EVENTS_STOPPED.Get() == 0 && target.EVENTS_READ.Get() == 0 &&
+		target.EVENTS_WRITE.Get() == 0 && target.EVENTS_ERROR.Get() == 0 {
+		gosched()
+	}
+	if target.EVENTS_ERROR.Get() != 0 {
+		target.EVENTS_ERROR.Set(0)
+		target.ERRORSRC.Set(target.ERRORSRC.Get()) // write 1 to clear
+		return errI2CBusError
+	}
+	return nil
+}
+
+// target returns the TWIS peripheral used for target mode on this bus.
+func (i2c I2C) target() *nrf.TWIS_Type {
+	if i2c.Bus == nrf.TWI1 {
+		return nrf.TWIS1
+	}
+	return nrf.TWIS0
 }
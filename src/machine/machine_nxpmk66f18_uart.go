@@ -116,8 +116,12 @@ func init() {
 }
 
 // Configure the UART.
-func (u *UART) Configure(config UARTConfig) {
+func (u *UART) Configure(config UARTConfig) error {
+	if err := config.checkDefaultFormat(); err != nil {
+		return err
+	}
 	u.configure(config, true)
+	return nil
 }
 
 func (u *UART) configure(config UARTConfig, canSched bool) {
@@ -4,6 +4,8 @@ package machine
 
 // Peripheral abstraction layer for the stm32.
 
+import "device/stm32"
+
 const (
 	portA Pin = iota * 16
 	portB
@@ -53,3 +55,34 @@ func (p Pin) Get() bool {
 	val := port.IDR.Get() & (1 << pin)
 	return (val > 0)
 }
+
+// The bit of the CR1 register that sets the word length (which includes the
+// parity bit) to 9 bits. It is called M0 on some chips.
+const uartCR1M = 1 << 12
+
+// uartFrameFormat returns the M, PCE and PS bits of CR1 and the STOP field of
+// CR2 for the given frame format.
+func uartFrameFormat(dataBits, stopBits uint8, parity UARTParity) (cr1, cr2 uint32, err error) {
+	// 7 data bits would need an 8 bit word with parity, but then the parity
+	// bit ends up in the received bytes.
+	if dataBits != 8 {
+		return 0, 0, ErrUARTFormatNotSupported
+	}
+	switch parity {
+	case ParityNone:
+	case ParityEven:
+		cr1 = uartCR1M | stm32.USART_CR1_PCE // 9 bit words
+	case ParityOdd:
+		cr1 = uartCR1M | stm32.USART_CR1_PCE | stm32.USART_CR1_PS
+	default:
+		return 0, 0, ErrUARTFormatNotSupported
+	}
+	switch stopBits {
+	case 1:
+	case 2:
+		cr2 = 2 << stm32.USART_CR2_STOP_Pos
+	default:
+		return 0, 0, ErrUARTFormatNotSupported
+	}
+	return cr1, cr2, nil
+}
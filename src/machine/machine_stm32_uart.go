@@ -26,12 +26,22 @@ type UART struct {
 }
 
 // Configure the UART.
-func (uart *UART) Configure(config UARTConfig) {
+func (uart *UART) Configure(config UARTConfig) error {
 	// Default baud rate to 115200.
 	if config.BaudRate == 0 {
 		config.BaudRate = 115200
 	}
 
+	// Check the frame format before changing anything.
+	dataBits, stopBits := config.format()
+	cr1, cr2, err := uartFrameFormat(dataBits, stopBits, config.Parity)
+	if err != nil {
+		return err
+	}
+	if config.flowControl() && !uart.flowControlSupported() {
+		return ErrUARTFlowControlNotSupported
+	}
+
 	// Set the GPIO pins to defaults if they're not set
 	if config.TX == 0 && config.RX == 0 {
 		config.TX = UART_TX_PIN
@@ -51,12 +61,41 @@ func (uart *UART) Configure(config UARTConfig) {
 	// Set baud rate
 	uart.SetBaudRate(config.BaudRate)
 
-	// Enable USART port, tx, rx and rx interrupts
-	uart.Bus.CR1.Set(stm32.USART_CR1_TE | stm32.USART_CR1_RE | stm32.USART_CR1_RXNEIE | stm32.USART_CR1_UE)
+	// Set stop bits and hardware flow control
+	uart.Bus.CR2.ClearBits(stm32.USART_CR2_STOP_Msk)
+	uart.Bus.CR2.SetBits(cr2)
+	if config.flowControl() {
+		uart.Bus.CR3.SetBits(stm32.USART_CR3_RTSE | stm32.USART_CR3_CTSE)
+	} else {
+		uart.Bus.CR3.ClearBits(stm32.USART_CR3_RTSE | stm32.USART_CR3_CTSE)
+	}
+
+	// Enable USART port, tx, rx and rx interrupts, and set word length and
+	// parity
+	uart.Bus.CR1.Set(cr1 | stm32.USART_CR1_TE | stm32.USART_CR1_RE | stm32.USART_CR1_RXNEIE | stm32.USART_CR1_UE)
 
 	// Enable RX IRQ
 	uart.Interrupt.SetPriority(0xc0)
 	uart.Interrupt.Enable()
+
+	return nil
+}
+
+// SetFormat changes the number of data bits, the parity and the number of stop
+// bits (1 or 2) of the UART. Only 8 data bits are supported.
+func (uart *UART) SetFormat(dataBits, stopBits uint8, parity UARTParity) error {
+	cr1, cr2, err := uartFrameFormat(dataBits, stopBits, parity)
+	if err != nil {
+		return err
+	}
+
+	// The frame format can only be changed while the USART is disabled.
+	uart.Bus.CR1.ClearBits(stm32.USART_CR1_UE)
+	uart.Bus.CR2.ClearBits(stm32.USART_CR2_STOP_Msk)
+	uart.Bus.CR2.SetBits(cr2)
+	uart.Bus.CR1.ClearBits(uartCR1M | stm32.USART_CR1_PCE | stm32.USART_CR1_PS)
+	uart.Bus.CR1.SetBits(cr1 | stm32.USART_CR1_UE)
+	return nil
 }
 
 // handleInterrupt should be called from the appropriate interrupt handler for
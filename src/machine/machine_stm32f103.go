@@ -104,6 +104,13 @@ func enableAltFuncClock(bus unsafe.Pointer) {
 
 //---------- UART related code
 
+// flowControlSupported returns whether this UART has RTS and CTS signals for
+// hardware flow control, which is the case for USART1 and USART2 (the only
+// ones supported here).
+func (uart *UART) flowControlSupported() bool {
+	return true
+}
+
 // Configure the TX and RX pins
 func (uart *UART) configurePins(config UARTConfig) {
 
@@ -122,6 +129,10 @@ func (uart *UART) configurePins(config UARTConfig) {
 	}
 	config.TX.Configure(PinConfig{Mode: PinOutput50MHz + PinOutputModeAltPushPull})
 	config.RX.Configure(PinConfig{Mode: PinInputModeFloating})
+	if config.flowControl() {
+		config.RTS.Configure(PinConfig{Mode: PinOutput50MHz + PinOutputModeAltPushPull})
+		config.CTS.Configure(PinConfig{Mode: PinInputModeFloating})
+	}
 }
 
 // Determine the divisor for USARTs to get the given baudrate
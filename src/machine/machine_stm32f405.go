@@ -35,10 +35,21 @@ const (
 
 // -- UART ---------------------------------------------------------------------
 
+// flowControlSupported returns whether this UART has RTS and CTS signals for
+// hardware flow control. UART4 and UART5 don't have them.
+func (uart *UART) flowControlSupported() bool {
+	return uart.Bus != stm32.UART4 && uart.Bus != stm32.UART5
+}
+
 func (uart *UART) configurePins(config UARTConfig) {
-	// enable the alternate functions on the TX and RX pins
+	// enable the alternate functions on the TX and RX pins, and the RTS and
+	// CTS pins when used
 	config.TX.ConfigureAltFunc(PinConfig{Mode: PinModeUARTTX}, uart.AltFuncSelector)
 	config.RX.ConfigureAltFunc(PinConfig{Mode: PinModeUARTRX}, uart.AltFuncSelector)
+	if config.flowControl() {
+		config.RTS.ConfigureAltFunc(PinConfig{Mode: PinModeUARTTX}, uart.AltFuncSelector)
+		config.CTS.ConfigureAltFunc(PinConfig{Mode: PinModeUARTRX}, uart.AltFuncSelector)
+	}
 }
 
 func (uart *UART) getBaudRateDivisor(baudRate uint32) uint32 {
@@ -34,11 +34,22 @@ const (
 
 //---------- UART related code
 
+// flowControlSupported returns whether this UART has RTS and CTS signals for
+// hardware flow control. UART4 and UART5 don't have them.
+func (uart *UART) flowControlSupported() bool {
+	return uart.Bus != stm32.UART4 && uart.Bus != stm32.UART5
+}
+
 // Configure the UART.
 func (uart *UART) configurePins(config UARTConfig) {
-	// enable the alternate functions on the TX and RX pins
+	// enable the alternate functions on the TX and RX pins, and the RTS and
+	// CTS pins when used
 	config.TX.ConfigureAltFunc(PinConfig{Mode: PinModeUARTTX}, uart.AltFuncSelector)
 	config.RX.ConfigureAltFunc(PinConfig{Mode: PinModeUARTRX}, uart.AltFuncSelector)
+	if config.flowControl() {
+		config.RTS.ConfigureAltFunc(PinConfig{Mode: PinModeUARTTX}, uart.AltFuncSelector)
+		config.CTS.ConfigureAltFunc(PinConfig{Mode: PinModeUARTRX}, uart.AltFuncSelector)
+	}
 }
 
 // UART baudrate calc based on the bus and clockspeed
@@ -0,0 +1,353 @@
+// +build stm32f4
+
+package machine
+
+// Asynchronous SPI and UART transfers on the stm32f4, using the DMA
+// controllers.
+//
+// Each peripheral can only use specific streams of DMA1 or DMA2, see the table
+// "DMA request mapping" in the reference manual. Some peripherals share a
+// stream (for example SPI2 and USART3), so their transfers are done one after
+// the other. A stream can transfer at most 65535 bytes, so longer transfers
+// are split in blocks and the next block is started from the DMA interrupt.
+
+import (
+	"device/stm32"
+	"errors"
+	"runtime/interrupt"
+	"runtime/volatile"
+	"unsafe"
+)
+
+var errDMATransfer = errors.New("DMA transfer error")
+
+// dmaStreamRegs is the register block of a single DMA stream. The device
+// package has separate names for the registers of every stream (S0CR, S1CR,
+// etc), so the registers are accessed through this struct instead. The bits of
+// the CR register are the same for all streams.
+type dmaStreamRegs struct {
+	CR   volatile.Register32
+	NDTR volatile.Register32
+	PAR  volatile.Register32
+	M0AR volatile.Register32
+	M1AR volatile.Register32
+	FCR  volatile.Register32
+}
+
+// dmaStream is a DMA stream together with the channel that connects it to a
+// peripheral.
+type dmaStream struct {
+	index   uint8 // 0-7 for the streams of DMA1, 8-15 for the streams of DMA2
+	channel uint8
+}
+
+// dmaTransfer is the state of a stream.
+type dmaTransfer struct {
+	waiter    dmaWaiter
+	w, r      []byte // data that remains to be sent and received
+	remaining int    // number of bytes that remain to be transferred
+	tx, rx    dmaStream
+	receive   bool                 // whether rx is used (SPI)
+	spi       *stm32.SPI_Type      // SPI peripheral, if this is a SPI transfer
+	data      *volatile.Register32 // data register of the peripheral
+	err       error
+}
+
+var (
+	dmaTransfers         [16]dmaTransfer
+	dmaInterruptsEnabled bool
+
+	// Sent when there is no data to send, and written when received data is
+	// dropped.
+	dmaZero  byte
+	dmaDummy byte
+)
+
+// regs returns the registers of this stream.
+func (s dmaStream) regs() *dmaStreamRegs {
+	dma := unsafe.Pointer(stm32.DMA1)
+	if s.index >= 8 {
+		dma = unsafe.Pointer(stm32.DMA2)
+	}
+	return (*dmaStreamRegs)(unsafe.Pointer(uintptr(dma) + 0x10 + 0x18*uintptr(s.index%8)))
+}
+
+// flags returns the interrupt flags of this stream, shifted down to the bit
+// positions of stream 0.
+func (s dmaStream) flags() uint32 {
+	dma := stm32.DMA1
+	if s.index >= 8 {
+		dma = stm32.DMA2
+	}
+	if s.index%8 < 4 {
+		return dma.LISR.Get() >> dmaFlagOffsets[s.index%4]
+	}
+	return dma.HISR.Get() >> dmaFlagOffsets[s.index%4]
+}
+
+// clearFlags clears all interrupt flags of this stream.
+func (s dmaStream) clearFlags() {
+	dma := stm32.DMA1
+	if s.index >= 8 {
+		dma = stm32.DMA2
+	}
+	if s.index%8 < 4 {
+		dma.LIFCR.Set(0x3d << dmaFlagOffsets[s.index%4])
+	} else {
+		dma.HIFCR.Set(0x3d << dmaFlagOffsets[s.index%4])
+	}
+}
+
+// Position of the flags of streams 0-3 in LISR (and streams 4-7 in HISR).
+var dmaFlagOffsets = [4]uint8{0, 6, 16, 22}
+
+// Interrupt flags of a stream, as returned by dmaStream.flags.
+const (
+	dmaFlagTEIF = 1 << 3 // transfer error
+	dmaFlagTCIF = 1 << 5 // transfer complete
+)
+
+// initDMA enables both DMA controllers and the interrupts of the streams that
+// are used to signal the end of a transfer, when they are used for the first
+// time.
+func initDMA() {
+	if dmaInterruptsEnabled {
+		return
+	}
+	dmaInterruptsEnabled = true
+	stm32.RCC.AHB1ENR.SetBits(stm32.RCC_AHB1ENR_DMA1EN | stm32.RCC_AHB1ENR_DMA2EN)
+
+	intr := interrupt.New(stm32.IRQ_DMA1_Stream0, func(interrupt.Interrupt) {
+		handleDMAInterrupt(0)
+	})
+	intr.Enable()
+	intr = interrupt.New(stm32.IRQ_DMA1_Stream3, func(interrupt.Interrupt) {
+		handleDMAInterrupt(3)
+	})
+	intr.Enable()
+	intr = interrupt.New(stm32.IRQ_DMA1_Stream4, func(interrupt.Interrupt) {
+		handleDMAInterrupt(4)
+	})
+	intr.Enable()
+	intr = interrupt.New(stm32.IRQ_DMA1_Stream6, func(interrupt.Interrupt) {
+		handleDMAInterrupt(6)
+	})
+	intr.Enable()
+	intr = interrupt.New(stm32.IRQ_DMA1_Stream7, func(interrupt.Interrupt) {
+		handleDMAInterrupt(7)
+	})
+	intr.Enable()
+	intr = interrupt.New(stm32.IRQ_DMA2_Stream0, func(interrupt.Interrupt) {
+		handleDMAInterrupt(8)
+	})
+	intr.Enable()
+	intr = interrupt.New(stm32.IRQ_DMA2_Stream6, func(interrupt.Interrupt) {
+		handleDMAInterrupt(14)
+	})
+	intr.Enable()
+	intr = interrupt.New(stm32.IRQ_DMA2_Stream7, func(interrupt.Interrupt) {
+		handleDMAInterrupt(15)
+	})
+	intr.Enable()
+}
+
+// startDMA starts a transfer from w to the data register of a peripheral, and
+// from the data register to r when receive is set. The transfer state is kept
+// by the stream that finishes last, which is rx when receiving and tx
+// otherwise. Transfers that use one of the same streams are waited for first.
+func startDMA(tx, rx dmaStream, receive bool, spi *stm32.SPI_Type, data *volatile.Register32, w, r []byte) {
+	last := tx
+	if receive {
+		dmaTransfers[rx.index].waiter.wait()
+		last = rx
+	}
+	dmaTransfers[tx.index].waiter.wait()
+	initDMA()
+
+	t := &dmaTransfers[last.index]
+	t.w = w
+	t.r = r
+	t.remaining = len(w)
+	if len(r) > len(w) {
+		t.remaining = len(r)
+	}
+	t.tx = tx
+	t.rx = rx
+	t.receive = receive
+	t.spi = spi
+	t.data = data
+	t.err = nil
+	if t.remaining == 0 {
+		return
+	}
+
+	if receive {
+		dmaTransfers[tx.index].waiter.start()
+	}
+	t.waiter.start()
+	t.startBlock()
+}
+
+// startBlock starts the transfer of the next block of at most 65535 bytes.
+func (t *dmaTransfer) startBlock() {
+	n := t.remaining
+	if n > 0xffff {
+		n = 0xffff
+	}
+	t.remaining -= n
+
+	// Only the stream that finishes last raises an interrupt.
+	cr := uint32(t.tx.channel)<<stm32.DMA_S0CR_CHSEL_Pos |
+		1<<stm32.DMA_S0CR_DIR_Pos // memory-to-peripheral
+	if !t.receive {
+		cr |= stm32.DMA_S0CR_TCIE | stm32.DMA_S0CR_TEIE
+	}
+	tx := t.tx.regs()
+	t.tx.clearFlags()
+	tx.PAR.Set(uint32(uintptr(unsafe.Pointer(t.data))))
+	if len(t.w) != 0 {
+		tx.M0AR.Set(uint32(uintptr(unsafe.Pointer(&t.w[0]))))
+		cr |= stm32.DMA_S0CR_MINC
+		t.w = t.w[n:]
+	} else {
+		tx.M0AR.Set(uint32(uintptr(unsafe.Pointer(&dmaZero))))
+	}
+	tx.NDTR.Set(uint32(n))
+
+	if t.receive {
+		cr := uint32(t.rx.channel)<<stm32.DMA_S0CR_CHSEL_Pos |
+			0<<stm32.DMA_S0CR_DIR_Pos | // peripheral-to-memory
+			stm32.DMA_S0CR_TCIE | stm32.DMA_S0CR_TEIE
+		rx := t.rx.regs()
+		t.rx.clearFlags()
+		rx.PAR.Set(uint32(uintptr(unsafe.Pointer(t.data))))
+		if len(t.r) != 0 {
+			rx.M0AR.Set(uint32(uintptr(unsafe.Pointer(&t.r[0]))))
+			cr |= stm32.DMA_S0CR_MINC
+			t.r = t.r[n:]
+		} else {
+			rx.M0AR.Set(uint32(uintptr(unsafe.Pointer(&dmaDummy))))
+		}
+		rx.NDTR.Set(uint32(n))
+
+		// Enable the receive stream first, so that no byte is missed.
+		rx.CR.Set(cr | stm32.DMA_S0CR_EN)
+	}
+	tx.CR.Set(cr | stm32.DMA_S0CR_EN)
+
+	// Let the peripheral request the data.
+	if t.spi != nil {
+		t.spi.CR2.SetBits(stm32.SPI_CR2_RXDMAEN | stm32.SPI_CR2_TXDMAEN)
+	}
+}
+
+// handleDMAInterrupt handles the end of a block on a stream: it starts the
+// next block or finishes the transfer.
+func handleDMAInterrupt(index uint8) {
+	t := &dmaTransfers[index]
+	last := t.tx
+	if t.receive {
+		last = t.rx
+	}
+	flags := last.flags()
+	last.clearFlags()
+	if flags&(dmaFlagTCIF|dmaFlagTEIF) == 0 {
+		return
+	}
+
+	if flags&dmaFlagTEIF == 0 && t.remaining != 0 {
+		if t.spi != nil {
+			// The DMA requests must be enabled again for the next block.
+			t.spi.CR2.ClearBits(stm32.SPI_CR2_RXDMAEN | stm32.SPI_CR2_TXDMAEN)
+		}
+		t.startBlock()
+		return
+	}
+
+	if flags&dmaFlagTEIF != 0 {
+		// Stop the other stream as well, if it is still running.
+		t.tx.regs().CR.ClearBits(stm32.DMA_S0CR_EN)
+		t.err = errDMATransfer
+	}
+	if t.spi != nil {
+		t.spi.CR2.ClearBits(stm32.SPI_CR2_RXDMAEN | stm32.SPI_CR2_TXDMAEN)
+	}
+	if t.receive {
+		dmaTransfers[t.tx.index].waiter.done()
+	}
+	t.waiter.done()
+}
+
+// dmaStreams returns the DMA streams used to send and receive data.
+func (spi SPI) dmaStreams() (tx, rx dmaStream) {
+	switch spi.Bus {
+	case stm32.SPI1:
+		return dmaStream{11, 3}, dmaStream{8, 3} // DMA2 stream 3 and 0
+	case stm32.SPI2:
+		return dmaStream{4, 0}, dmaStream{3, 0} // DMA1 stream 4 and 3
+	default: // SPI3
+		return dmaStream{5, 0}, dmaStream{0, 0} // DMA1 stream 5 and 0
+	}
+}
+
+// StartTx starts a SPI transfer in the background using DMA, and returns
+// immediately. It sends and receives data like Tx: w and r must have the same
+// length unless one of them is nil. The buffers must not be used until Wait
+// returns.
+//
+// If a transfer that uses the same DMA streams is still in progress, StartTx
+// first waits for it to finish.
+func (spi SPI) StartTx(w, r []byte) error {
+	if w != nil && r != nil && len(w) != len(r) {
+		return ErrTxInvalidSliceSize
+	}
+	tx, rx := spi.dmaStreams()
+	startDMA(tx, rx, true, spi.Bus, &spi.Bus.DR, w, r)
+	return nil
+}
+
+// Wait blocks until the transfer started with StartTx is done. Other
+// goroutines can run in the meantime.
+func (spi SPI) Wait() error {
+	_, rx := spi.dmaStreams()
+	t := &dmaTransfers[rx.index]
+	t.waiter.wait()
+	return t.err
+}
+
+// dmaStream returns the DMA stream used to send data.
+func (uart *UART) dmaStream() dmaStream {
+	switch uart.Bus {
+	case stm32.USART1:
+		return dmaStream{15, 4} // DMA2 stream 7
+	case stm32.USART2:
+		return dmaStream{6, 4} // DMA1 stream 6
+	case stm32.USART3:
+		return dmaStream{3, 4} // DMA1 stream 3
+	case stm32.UART4:
+		return dmaStream{4, 4} // DMA1 stream 4
+	case stm32.UART5:
+		return dmaStream{7, 4} // DMA1 stream 7
+	default: // USART6
+		return dmaStream{14, 5} // DMA2 stream 6
+	}
+}
+
+// StartWrite starts sending data over the UART in the background using DMA,
+// and returns immediately. The data must not be modified until Wait returns.
+//
+// If a transfer that uses the same DMA stream is still in progress,
+// StartWrite first waits for it to finish.
+func (uart *UART) StartWrite(data []byte) error {
+	uart.Bus.CR3.SetBits(stm32.USART_CR3_DMAT)
+	startDMA(uart.dmaStream(), dmaStream{}, false, nil, &uart.Bus.DR, data, nil)
+	return nil
+}
+
+// Wait blocks until the data passed to StartWrite has been handed to the UART.
+// Other goroutines can run in the meantime.
+func (uart *UART) Wait() error {
+	t := &dmaTransfers[uart.dmaStream().index]
+	t.waiter.wait()
+	return t.err
+}
@@ -0,0 +1,265 @@
+// +build stm32f4
+
+package machine
+
+// PWM support for the stm32f4, using the general purpose and advanced-control
+// timers.
+
+import (
+	"device/stm32"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// TIM is one timer peripheral, which can be used for PWM. Each timer has a
+// single period but multiple channels that can each have their own duty cycle.
+type TIM struct {
+	Device *stm32.TIM_Type
+}
+
+// The timers that can be used for PWM. TIM3 is missing because it is used by
+// the runtime for sleeping, and TIM6 and TIM7 don't have any outputs.
+//
+// TIM1, TIM2, TIM4, TIM5 and TIM8 have 4 channels, TIM9 and TIM12 have 2
+// channels, and the other timers have a single channel. The counters of TIM2
+// and TIM5 are 32 bits, the others are 16 bits.
+var (
+	TIM1  = &TIM{Device: stm32.TIM1}
+	TIM2  = &TIM{Device: stm32.TIM2}
+	TIM4  = &TIM{Device: stm32.TIM4}
+	TIM5  = &TIM{Device: stm32.TIM5}
+	TIM8  = &TIM{Device: stm32.TIM8}
+	TIM9  = &TIM{Device: stm32.TIM9}
+	TIM10 = &TIM{Device: stm32.TIM10}
+	TIM11 = &TIM{Device: stm32.TIM11}
+	TIM12 = &TIM{Device: stm32.TIM12}
+	TIM13 = &TIM{Device: stm32.TIM13}
+	TIM14 = &TIM{Device: stm32.TIM14}
+)
+
+// pwmPeripherals lists the timers that are used by the deprecated PWM API.
+var pwmPeripherals = [...]pwmPeripheral{TIM1, TIM2, TIM4, TIM5, TIM8, TIM9, TIM10, TIM11, TIM12, TIM13, TIM14}
+
+// Configure enables and configures this timer for PWM with the given period.
+// All channels are set to a duty cycle of 0 (always low).
+func (t *TIM) Configure(config PWMConfig) error {
+	// Calculate the prescaler and the top value for this period. The prescaler
+	// can divide the clock by any value between 1 and 65536.
+	cycles := pwmCycles(config.Period, t.clockFrequency()/1000000)
+	prescaler := (cycles + t.maxTop()) / (t.maxTop() + 1)
+	if prescaler == 0 {
+		prescaler = 1
+	}
+	if prescaler > 0x10000 {
+		return ErrPWMPeriodTooLong
+	}
+	top := cycles / prescaler
+	if top > 0 {
+		top-- // the counter counts from 0 to top (inclusive)
+	}
+
+	enableAltFuncClock(unsafe.Pointer(t.Device))
+
+	// Disable the timer while it is being configured, and buffer the top value
+	// so that it can't be changed in the middle of a period.
+	t.Device.CR1.Set(stm32.TIM_CR1_ARPE)
+	t.Device.PSC.Set(uint32(prescaler - 1))
+	t.Device.ARR.Set(uint32(top))
+
+	// Use PWM mode 1 on all channels, with a 0% duty cycle: the output is high
+	// as long as the counter is below the compare value.
+	for channel := uint8(0); channel < t.channels(); channel++ {
+		// The OCxM field (output compare mode) is 6 for PWM mode 1, and the
+		// OCxPE bit (preload enable) makes sure new compare values only take
+		// effect at the start of a new period. The CCxS field (0b00) configures
+		// the channel as an output.
+		ccmr := &t.Device.CCMR1_Output
+		if channel >= 2 {
+			ccmr = &t.Device.CCMR2_Output
+		}
+		ccmr.ReplaceBits(6<<4|1<<3, 0xff, (channel%2)*8)
+		t.Set(channel, 0)
+	}
+
+	// Load the prescaler, top and compare values by generating an update
+	// event.
+	t.Device.EGR.Set(stm32.TIM_EGR_UG)
+
+	// The advanced-control timers also need the main output to be enabled.
+	if t.Device == stm32.TIM1 || t.Device == stm32.TIM8 {
+		t.Device.BDTR.SetBits(stm32.TIM_BDTR_MOE)
+	}
+
+	t.Device.CR1.SetBits(stm32.TIM_CR1_CEN)
+	return nil
+}
+
+// Top returns the current counter top, for use in duty cycle calculation. It
+// will only change with a call to Configure.
+//
+// The value returned here is hardware dependent. In general, it's best to treat
+// it as an opaque value that can be divided by some number and passed to Set
+// (see Set documentation for more information).
+func (t *TIM) Top() uint32 {
+	return t.Device.ARR.Get()
+}
+
+// Channel returns a PWM channel for the given pin. The pin is configured as a
+// PWM output of this timer. Note that a pin can only be used with some of the
+// timers, so this will return ErrInvalidOutputPin if the pin is not connected
+// to this timer.
+func (t *TIM) Channel(pin Pin) (uint8, error) {
+	for _, mapping := range timPinMapping {
+		if mapping.pin != pin || mapping.timer != t.Device {
+			continue
+		}
+		pin.ConfigureAltFunc(PinConfig{Mode: PinModePWMOutput}, mapping.altFunc)
+		t.Device.CCER.SetBits(stm32.TIM_CCER_CC1E << (mapping.channel * 4))
+		return mapping.channel, nil
+	}
+	return 0, ErrInvalidOutputPin
+}
+
+// SetInverting sets whether to invert the output of this channel.
+// Without inverting, a 25% duty cycle would mean the output is high for 25% of
+// the time and low for the rest. Inverting flips the output as if a NOT gate
+// was placed at the output, meaning that the output would be 25% low and 75%
+// high with a duty cycle of 25%.
+func (t *TIM) SetInverting(channel uint8, inverting bool) {
+	if inverting {
+		t.Device.CCER.SetBits(stm32.TIM_CCER_CC1P << (channel * 4))
+	} else {
+		t.Device.CCER.ClearBits(stm32.TIM_CCER_CC1P << (channel * 4))
+	}
+}
+
+// Set updates the channel value. This is used to control the channel duty
+// cycle, in other words the fraction of time the channel output is high (or low
+// when inverted). For example, to set it to a 25% duty cycle, use:
+//
+//     t.Set(channel, t.Top() / 4)
+//
+// t.Set(channel, 0) will set the output to low and t.Set(channel, t.Top())
+// will set the output to high, assuming the output isn't inverted.
+//
+// The new value takes effect at the start of the next period, so that no
+// glitches occur while changing the duty cycle.
+func (t *TIM) Set(channel uint8, value uint32) {
+	if value >= t.Top() {
+		// Make sure the output stays high at 100% duty cycle: the counter never
+		// reaches a value above the top.
+		value = t.Top() + 1
+	}
+	t.ccr(channel).Set(value)
+}
+
+// ccr returns the capture/compare register of the given channel.
+func (t *TIM) ccr(channel uint8) *volatile.Register32 {
+	switch channel {
+	case 0:
+		return &t.Device.CCR1
+	case 1:
+		return &t.Device.CCR2
+	case 2:
+		return &t.Device.CCR3
+	default:
+		return &t.Device.CCR4
+	}
+}
+
+// channels returns the number of channels of this timer.
+func (t *TIM) channels() uint8 {
+	switch t.Device {
+	case stm32.TIM1, stm32.TIM2, stm32.TIM4, stm32.TIM5, stm32.TIM8:
+		return 4
+	case stm32.TIM9, stm32.TIM12:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// maxTop returns the highest top value that can be used. It is one less than
+// the maximum value of the counter, so that a 100% duty cycle (a compare value
+// above the top) can be represented.
+func (t *TIM) maxTop() uint64 {
+	switch t.Device {
+	case stm32.TIM2, stm32.TIM5:
+		return 0xfffffffe // 32-bit counter
+	default:
+		return 0xfffe // 16-bit counter
+	}
+}
+
+// clockFrequency returns the frequency of the clock that drives the timer
+// counter, before the prescaler. Because the APB buses run slower than the
+// CPU, the timer clocks are twice the frequency of their bus.
+func (t *TIM) clockFrequency() uint64 {
+	switch t.Device {
+	case stm32.TIM1, stm32.TIM8, stm32.TIM9, stm32.TIM10, stm32.TIM11:
+		return uint64(CPUFrequency()) // APB2 runs at CPUFrequency() / 2
+	default:
+		return uint64(CPUFrequency()) / 2 // APB1 runs at CPUFrequency() / 4
+	}
+}
+
+// timPinMapping lists which timer channels are available on which pins, and
+// with which alternate function. This is a copy of the table "Alternate
+// function mapping" in the datasheet, excluding the complementary outputs.
+var timPinMapping = [...]struct {
+	pin     Pin
+	timer   *stm32.TIM_Type
+	channel uint8
+	altFunc uint8
+}{
+	{PA8, stm32.TIM1, 0, AF1_TIM1_2},
+	{PE9, stm32.TIM1, 0, AF1_TIM1_2},
+	{PA9, stm32.TIM1, 1, AF1_TIM1_2},
+	{PE11, stm32.TIM1, 1, AF1_TIM1_2},
+	{PA10, stm32.TIM1, 2, AF1_TIM1_2},
+	{PE13, stm32.TIM1, 2, AF1_TIM1_2},
+	{PA11, stm32.TIM1, 3, AF1_TIM1_2},
+	{PE14, stm32.TIM1, 3, AF1_TIM1_2},
+
+	{PA0, stm32.TIM2, 0, AF1_TIM1_2},
+	{PA5, stm32.TIM2, 0, AF1_TIM1_2},
+	{PA15, stm32.TIM2, 0, AF1_TIM1_2},
+	{PA1, stm32.TIM2, 1, AF1_TIM1_2},
+	{PB3, stm32.TIM2, 1, AF1_TIM1_2},
+	{PA2, stm32.TIM2, 2, AF1_TIM1_2},
+	{PB10, stm32.TIM2, 2, AF1_TIM1_2},
+	{PA3, stm32.TIM2, 3, AF1_TIM1_2},
+	{PB11, stm32.TIM2, 3, AF1_TIM1_2},
+
+	{PB6, stm32.TIM4, 0, AF2_TIM3_4_5},
+	{PD12, stm32.TIM4, 0, AF2_TIM3_4_5},
+	{PB7, stm32.TIM4, 1, AF2_TIM3_4_5},
+	{PD13, stm32.TIM4, 1, AF2_TIM3_4_5},
+	{PB8, stm32.TIM4, 2, AF2_TIM3_4_5},
+	{PD14, stm32.TIM4, 2, AF2_TIM3_4_5},
+	{PB9, stm32.TIM4, 3, AF2_TIM3_4_5},
+	{PD15, stm32.TIM4, 3, AF2_TIM3_4_5},
+
+	{PA0, stm32.TIM5, 0, AF2_TIM3_4_5},
+	{PA1, stm32.TIM5, 1, AF2_TIM3_4_5},
+	{PA2, stm32.TIM5, 2, AF2_TIM3_4_5},
+	{PA3, stm32.TIM5, 3, AF2_TIM3_4_5},
+
+	{PC6, stm32.TIM8, 0, AF3_TIM8_9_10_11},
+	{PC7, stm32.TIM8, 1, AF3_TIM8_9_10_11},
+	{PC8, stm32.TIM8, 2, AF3_TIM8_9_10_11},
+	{PC9, stm32.TIM8, 3, AF3_TIM8_9_10_11},
+
+	{PA2, stm32.TIM9, 0, AF3_TIM8_9_10_11},
+	{PE5, stm32.TIM9, 0, AF3_TIM8_9_10_11},
+	{PA3, stm32.TIM9, 1, AF3_TIM8_9_10_11},
+	{PE6, stm32.TIM9, 1, AF3_TIM8_9_10_11},
+
+	{PB8, stm32.TIM10, 0, AF3_TIM8_9_10_11},
+	{PB9, stm32.TIM11, 0, AF3_TIM8_9_10_11},
+
+	{PB14, stm32.TIM12, 0, AF9_CAN1_CAN2_TIM12_13_14},
+	{PB15, stm32.TIM12, 1, AF9_CAN1_CAN2_TIM12_13_14},
+	{PA6, stm32.TIM13, 0, AF9_CAN1_CAN2_TIM12_13_14},
+	{PA7, stm32.TIM14, 0, AF9_CAN1_CAN2_TIM12_13_14},
+}
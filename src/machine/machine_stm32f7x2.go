@@ -14,11 +14,22 @@ func CPUFrequency() uint32 {
 
 //---------- UART related code
 
+// flowControlSupported returns whether this UART has RTS and CTS signals for
+// hardware flow control, which is the case for all UARTs of this chip.
+func (uart *UART) flowControlSupported() bool {
+	return true
+}
+
 // Configure the UART.
 func (uart *UART) configurePins(config UARTConfig) {
-	// enable the alternate functions on the TX and RX pins
+	// enable the alternate functions on the TX and RX pins, and the RTS and
+	// CTS pins when used
 	config.TX.ConfigureAltFunc(PinConfig{Mode: PinModeUARTTX}, uart.AltFuncSelector)
 	config.RX.ConfigureAltFunc(PinConfig{Mode: PinModeUARTRX}, uart.AltFuncSelector)
+	if config.flowControl() {
+		config.RTS.ConfigureAltFunc(PinConfig{Mode: PinModeUARTTX}, uart.AltFuncSelector)
+		config.CTS.ConfigureAltFunc(PinConfig{Mode: PinModeUARTRX}, uart.AltFuncSelector)
+	}
 }
 
 // UART baudrate calc based on the bus and clockspeed
@@ -0,0 +1,50 @@
+// +build atmega328p atsamd21 atsamd51 nrf52 nrf52840 nrf52833 stm32f4
+
+package machine
+
+import "errors"
+
+var (
+	ErrPWMPeriodTooLong = errors.New("pwm: period too long")
+)
+
+// PWMConfig allows setting some configuration while configuring a PWM
+// peripheral. A zero PWMConfig is ready to use for a default configuration.
+//
+// All PWM peripherals (timers) implement the same interface:
+//
+//     Configure(config PWMConfig) error
+//     Channel(pin Pin) (channel uint8, err error)
+//     Set(channel uint8, value uint32)
+//     Top() uint32
+//     SetInverting(channel uint8, inverting bool)
+//
+// A timer has a single period that is shared by all of its channels, while
+// every channel has its own duty cycle. The duty cycle of a channel is set as
+// a value between 0 (always low) and Top() (always high). For example, to set
+// a channel to a 25% duty cycle:
+//
+//     pwm.Set(channel, pwm.Top()/4)
+type PWMConfig struct {
+	// PWM period in nanoseconds. Leaving this zero will pick a reasonable
+	// period for use with LEDs (2ms, or 500Hz).
+	//
+	// To configure a frequency instead of a period, use the following formula
+	// to calculate a period from a frequency:
+	//
+	//     period = 1e9 / frequency
+	//
+	Period uint64
+}
+
+// pwmDefaultPeriod is the period that is used when PWMConfig.Period is zero.
+const pwmDefaultPeriod = 1e9 / 500
+
+// pwmCycles returns the number of clock cycles in the given period (in
+// nanoseconds) for a timer with the given clock frequency (in MHz).
+func pwmCycles(period uint64, frequencyMHz uint64) uint64 {
+	if period == 0 {
+		period = pwmDefaultPeriod
+	}
+	return period * frequencyMHz / 1000
+}
@@ -0,0 +1,70 @@
+// +build atsamd21 atsamd51 nrf52 nrf52840 nrf52833 stm32f4
+
+package machine
+
+// This file implements the deprecated PWM API, which configures a single pin,
+// on top of the PWM peripherals of the chip. Every chip lists the peripherals
+// to use in pwmPeripherals.
+
+// pwmPeripheral is implemented by the PWM peripherals of all chips (TCC, TIM
+// and PWMPeripheral).
+type pwmPeripheral interface {
+	Configure(config PWMConfig) error
+	Channel(pin Pin) (uint8, error)
+	Set(channel uint8, value uint32)
+	Top() uint32
+}
+
+// pwmConfigured has a bit set for every entry in pwmPeripherals that has been
+// configured by the deprecated PWM API.
+var pwmConfigured uint32
+
+// InitPWM does nothing: the PWM peripherals are configured by PWM.Configure.
+//
+// Deprecated: use the Configure method of a PWM peripheral instead.
+func InitPWM() {
+}
+
+// Configure configures the pin as a PWM output with a duty cycle of 0. It uses
+// the first PWM peripheral that can be used with this pin, which is configured
+// with the default period (2ms) if it wasn't used by PWM yet.
+//
+// Deprecated: use the Configure and Channel methods of a PWM peripheral
+// instead.
+func (pwm PWM) Configure() error {
+	_, _, err := pwm.channel()
+	return err
+}
+
+// Set sets the duty cycle of the pin, from 0 (always low) to 0xffff (always
+// high). The pin must have been configured with Configure.
+//
+// Deprecated: use the Set method of a PWM peripheral instead.
+func (pwm PWM) Set(value uint16) {
+	peripheral, channel, err := pwm.channel()
+	if err != nil {
+		return
+	}
+	peripheral.Set(channel, uint32(uint64(value)*uint64(peripheral.Top())/0xffff))
+}
+
+// channel returns the PWM peripheral and channel of the pin, configuring the
+// peripheral on first use.
+func (pwm PWM) channel() (pwmPeripheral, uint8, error) {
+	for i, peripheral := range pwmPeripherals {
+		channel, err := peripheral.Channel(pwm.Pin)
+		if err != nil {
+			// This pin can't be used with this peripheral.
+			continue
+		}
+		if pwmConfigured&(1<<uint(i)) == 0 {
+			err := peripheral.Configure(PWMConfig{})
+			if err != nil {
+				return nil, 0, err
+			}
+			pwmConfigured |= 1 << uint(i)
+		}
+		return peripheral, channel, nil
+	}
+	return nil, 0, ErrInvalidOutputPin
+}
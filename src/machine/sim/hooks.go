@@ -0,0 +1,229 @@
+package sim
+
+// This file implements the hooks called by the generic machine package
+// (machine_generic.go). The signatures must match exactly.
+
+import "unsafe"
+
+// pinConfig is the same as machine.PinConfig.
+type pinConfig struct {
+	Mode uint8
+}
+
+//export __tinygo_gpio_configure
+func gpioConfigure(pin uint8, config pinConfig) {
+	p := &pins[pin]
+	p.mode = config.Mode
+	if !p.driven {
+		// Pull resistors determine the level of a pin without external
+		// driver.
+		switch config.Mode {
+		case pinInputPullup:
+			p.level = true
+		case pinInputPulldown:
+			p.level = false
+		}
+	}
+	trace = append(trace, Event{Kind: GPIOConfigure, Pin: pin, Value: uint16(config.Mode)})
+}
+
+//export __tinygo_gpio_set
+func gpioSet(pin uint8, value bool) {
+	pins[pin].level = value
+	event := Event{Kind: GPIOSet, Pin: pin}
+	if value {
+		event.Value = 1
+	}
+	trace = append(trace, event)
+}
+
+//export __tinygo_gpio_get
+func gpioGet(pin uint8) bool {
+	p := &pins[pin]
+	if len(p.inputs) != 0 {
+		p.level = p.inputs[0]
+		p.inputs = p.inputs[1:]
+	}
+	return p.level
+}
+
+//export __tinygo_spi_configure
+func spiConfigure(bus uint8, sck uint8, sdo uint8, sdi uint8) {
+}
+
+//export __tinygo_spi_transfer
+func spiTransfer(bus uint8, w uint8) uint8 {
+	var r uint8
+	for _, d := range spiDevices {
+		if d.bus == bus && (d.cs == noPin || !pins[d.cs].level) {
+			r = d.dev.Transfer(w)
+			break
+		}
+	}
+
+	// Merge consecutive transfers on the same bus into a single event, to
+	// keep the trace readable. Any other event in between (such as toggling
+	// the chip select pin) starts a new event.
+	if n := len(trace); n != 0 && trace[n-1].Kind == SPITransfer && trace[n-1].Bus == bus {
+		trace[n-1].Write = append(trace[n-1].Write, w)
+		trace[n-1].Read = append(trace[n-1].Read, r)
+	} else {
+		trace = append(trace, Event{Kind: SPITransfer, Bus: bus, Write: []byte{w}, Read: []byte{r}})
+	}
+	return r
+}
+
+//export __tinygo_adc_read
+func adcRead(pin uint8) uint16 {
+	return pins[pin].adc
+}
+
+//export __tinygo_pwm_set
+func pwmSet(pin uint8, value uint16) {
+	pins[pin].pwm = value
+	trace = append(trace, Event{Kind: PWMSet, Pin: pin, Value: value})
+}
+
+//export __tinygo_i2c_configure
+func i2cConfigure(bus uint8, scl uint8, sda uint8) {
+}
+
+// i2cTransfer returns 0 on success and 1 if the device did not respond or
+// returned an error.
+//export __tinygo_i2c_transfer
+func i2cTransfer(bus uint8, addr uint16, w *byte, wlen int, r *byte, rlen int) int {
+	wbuf := append([]byte(nil), bytesAt(w, wlen)...)
+	rbuf := bytesAt(r, rlen)
+	err := ErrNoDevice
+	for _, d := range i2cDevices {
+		if d.bus == bus && d.addr == addr {
+			err = d.dev.Tx(wbuf, rbuf)
+			break
+		}
+	}
+	trace = append(trace, Event{
+		Kind:  I2CTransfer,
+		Bus:   bus,
+		Addr:  addr,
+		Write: wbuf,
+		Read:  append([]byte(nil), rbuf...),
+		Err:   err,
+	})
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// Steps of a transfer queued with QueueI2CTransfer.
+const (
+	i2cTargetStart = iota // the transfer hasn't started yet
+	i2cTargetWrite        // the written data was received by the program
+	i2cTargetRead         // the program must send data with Reply
+	i2cTargetStop         // the controller is done, only the stop condition is left
+)
+
+// Values returned by __tinygo_i2c_wait_event, these are the same as the
+// machine.I2CTargetEvent constants.
+const (
+	i2cReceive = iota
+	i2cRequest
+	i2cFinish
+)
+
+//export __tinygo_i2c_listen
+func i2cListen(bus uint8, addr uint16) {
+	i2cTargets[bus] = addr
+}
+
+// i2cWaitEvent returns the next event of the queued transfers, or -1 when
+// there are none left.
+//export __tinygo_i2c_wait_event
+func i2cWaitEvent(bus uint8, buf *byte, bufLen int, count *int) int {
+	for len(i2cQueue[bus]) != 0 {
+		tx := i2cQueue[bus][0]
+		if addr, ok := i2cTargets[bus]; !ok || addr != tx.addr {
+			// The address is not acknowledged by the program.
+			i2cQueue[bus] = i2cQueue[bus][1:]
+			trace = append(trace, Event{Kind: I2CTargetTransfer, Bus: bus, Addr: tx.addr, Write: tx.w, Err: ErrNoDevice})
+			continue
+		}
+		switch tx.state {
+		case i2cTargetStart:
+			tx.state = i2cTargetWrite
+			if len(tx.w) != 0 {
+				// Bytes that don't fit in the buffer are not acknowledged.
+				*count = copy(bytesAt(buf, bufLen), tx.w)
+				tx.w = tx.w[:*count]
+				return i2cReceive
+			}
+		case i2cTargetWrite:
+			tx.state = i2cTargetRead
+			if tx.rlen != 0 {
+				return i2cRequest
+			}
+		case i2cTargetRead:
+			// The program did not reply.
+			tx.reply(nil)
+		case i2cTargetStop:
+			i2cQueue[bus] = i2cQueue[bus][1:]
+			trace = append(trace, Event{Kind: I2CTargetTransfer, Bus: bus, Addr: tx.addr, Write: tx.w, Read: tx.r})
+			return i2cFinish
+		}
+	}
+	return -1
+}
+
+// i2cReply returns 0 on success and 1 if the controller did not request any
+// data.
+//export __tinygo_i2c_reply
+func i2cReply(bus uint8, buf *byte, bufLen int) int {
+	if len(i2cQueue[bus]) == 0 || i2cQueue[bus][0].state != i2cTargetRead {
+		return 1
+	}
+	i2cQueue[bus][0].reply(bytesAt(buf, bufLen))
+	return 0
+}
+
+// reply sends data to the controller. Like on a real bus, the controller reads
+// 0xff after the end of the data.
+func (tx *i2cTargetTransfer) reply(data []byte) {
+	tx.r = make([]byte, tx.rlen)
+	n := copy(tx.r, data)
+	for i := n; i < len(tx.r); i++ {
+		tx.r[i] = 0xff
+	}
+	tx.state = i2cTargetStop
+}
+
+//export __tinygo_uart_configure
+func uartConfigure(bus uint8, tx uint8, rx uint8) {
+}
+
+//export __tinygo_uart_read
+func uartRead(bus uint8, buf *byte, bufLen int) int {
+	n := copy(bytesAt(buf, bufLen), uartInput[bus])
+	if n == 0 {
+		return 0
+	}
+	uartInput[bus] = uartInput[bus][n:]
+	trace = append(trace, Event{Kind: UARTRead, Bus: bus, Read: append([]byte(nil), bytesAt(buf, n)...)})
+	return n
+}
+
+//export __tinygo_uart_write
+func uartWrite(bus uint8, buf *byte, bufLen int) int {
+	data := append([]byte(nil), bytesAt(buf, bufLen)...)
+	uartOutput[bus] = append(uartOutput[bus], data...)
+	trace = append(trace, Event{Kind: UARTWrite, Bus: bus, Write: data})
+	return bufLen
+}
+
+// bytesAt returns a slice pointing to the given buffer passed by the machine
+// package.
+func bytesAt(buf *byte, length int) []byte {
+	if length == 0 {
+		return nil
+	}
+	return (*[1 << 30]byte)(unsafe.Pointer(buf))[:length:length]
+}
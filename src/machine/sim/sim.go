@@ -0,0 +1,292 @@
+// Package sim simulates the hardware of the machine package when running on a
+// host system like Linux. It implements the hooks called by the generic
+// machine package, so that programs using GPIO pins, SPI, I2C, UART, ADC and
+// PWM can run (and be tested) without a board.
+//
+// Tests can change GPIO inputs, attach mock SPI and I2C devices, act as an I2C
+// controller for programs that are an I2C target and read back a trace of all
+// hardware accesses. For example:
+//
+//     sensor := &sim.I2CRegisters{}
+//     sensor.Data[0x0f] = 0x33 // WHO_AM_I register
+//     sim.AttachI2C(0, 0x19, sensor)
+//     ... // run the driver
+//     for _, event := range sim.Trace() {
+//         println(event.String())
+//     }
+//
+// Pins are identified by their number, so convert a machine.Pin to uint8 when
+// calling the functions in this package.
+package sim
+
+import (
+	"errors"
+	"strconv"
+)
+
+// noPin is the same as machine.NoPin.
+const noPin = 0xff
+
+// Pin modes, these are the same as the machine.PinMode constants.
+const (
+	pinInput = iota
+	pinOutput
+	pinInputPullup
+	pinInputPulldown
+)
+
+// ErrNoDevice is returned by I2C transfers to an address without attached
+// device, like a missing ACK on a real bus.
+var ErrNoDevice = errors.New("sim: no I2C device at this address")
+
+// EventKind is the type of a hardware access in the trace.
+type EventKind uint8
+
+const (
+	GPIOConfigure EventKind = iota
+	GPIOSet
+	PWMSet
+	SPITransfer
+	I2CTransfer
+	UARTRead
+	UARTWrite
+	I2CTargetTransfer
+)
+
+// Event is a single hardware access done by the program, as recorded in the
+// trace.
+type Event struct {
+	Kind  EventKind
+	Pin   uint8  // pin number of a GPIO or PWM event
+	Bus   uint8  // bus number of a SPI, I2C or UART event
+	Addr  uint16 // I2C address
+	Value uint16 // pin mode, pin level (0 or 1) or PWM value
+	Write []byte // bytes sent by the program (by the controller for I2CTargetTransfer)
+	Read  []byte // bytes received by the program (by the controller for I2CTargetTransfer)
+	Err   error  // error returned by an I2C device
+}
+
+// String returns a readable representation of the event, like:
+//
+//     i2c0 addr=0x19 w=0f r=33
+func (e Event) String() string {
+	switch e.Kind {
+	case GPIOConfigure:
+		return "gpio configure pin=" + strconv.Itoa(int(e.Pin)) + " mode=" + strconv.Itoa(int(e.Value))
+	case GPIOSet:
+		return "gpio set pin=" + strconv.Itoa(int(e.Pin)) + " value=" + strconv.Itoa(int(e.Value))
+	case PWMSet:
+		return "pwm set pin=" + strconv.Itoa(int(e.Pin)) + " value=" + strconv.Itoa(int(e.Value))
+	case SPITransfer:
+		return "spi" + strconv.Itoa(int(e.Bus)) + " w=" + hexString(e.Write) + " r=" + hexString(e.Read)
+	case I2CTransfer:
+		s := "i2c" + strconv.Itoa(int(e.Bus)) + " addr=0x" + strconv.FormatUint(uint64(e.Addr), 16) + " w=" + hexString(e.Write) + " r=" + hexString(e.Read)
+		if e.Err != nil {
+			s += " err=" + e.Err.Error()
+		}
+		return s
+	case I2CTargetTransfer:
+		s := "i2c" + strconv.Itoa(int(e.Bus)) + " target addr=0x" + strconv.FormatUint(uint64(e.Addr), 16) + " w=" + hexString(e.Write) + " r=" + hexString(e.Read)
+		if e.Err != nil {
+			s += " err=" + e.Err.Error()
+		}
+		return s
+	case UARTRead:
+		return "uart" + strconv.Itoa(int(e.Bus)) + " read " + strconv.Quote(string(e.Read))
+	case UARTWrite:
+		return "uart" + strconv.Itoa(int(e.Bus)) + " write " + strconv.Quote(string(e.Write))
+	default:
+		return "unknown event"
+	}
+}
+
+func hexString(data []byte) string {
+	const digits = "0123456789abcdef"
+	buf := make([]byte, 0, len(data)*2)
+	for _, b := range data {
+		buf = append(buf, digits[b>>4], digits[b&0xf])
+	}
+	return string(buf)
+}
+
+// SPIDevice is a simulated device on a SPI bus.
+type SPIDevice interface {
+	// Transfer is called for every byte sent by the program while the device
+	// is selected. It returns the byte sent back by the device.
+	Transfer(w byte) byte
+}
+
+// I2CDevice is a simulated device on an I2C bus.
+type I2CDevice interface {
+	// Tx is called for every I2C transaction to the address of the device. The
+	// device should fill r with the data to send back.
+	Tx(w, r []byte) error
+}
+
+type pinState struct {
+	mode   uint8
+	level  bool
+	driven bool   // level was set by the test, not by the pin mode
+	inputs []bool // queued input levels
+	adc    uint16
+	pwm    uint16
+}
+
+type spiDevice struct {
+	bus uint8
+	cs  uint8
+	dev SPIDevice
+}
+
+type i2cDevice struct {
+	bus  uint8
+	addr uint16
+	dev  I2CDevice
+}
+
+// i2cTargetTransfer is a transfer queued with QueueI2CTransfer.
+type i2cTargetTransfer struct {
+	addr  uint16
+	w     []byte
+	rlen  int
+	r     []byte
+	state uint8
+}
+
+var (
+	pins       [256]pinState
+	spiDevices []spiDevice
+	i2cDevices []i2cDevice
+	i2cTargets = map[uint8]uint16{} // address of every bus in target mode
+	i2cQueue   = map[uint8][]*i2cTargetTransfer{}
+	uartInput  = map[uint8][]byte{}
+	uartOutput = map[uint8][]byte{}
+	trace      []Event
+)
+
+// Reset puts the simulated hardware back in its initial state: all pins are
+// low inputs, no devices are attached and the trace is cleared.
+func Reset() {
+	pins = [256]pinState{}
+	spiDevices = nil
+	i2cDevices = nil
+	i2cTargets = map[uint8]uint16{}
+	i2cQueue = map[uint8][]*i2cTargetTransfer{}
+	uartInput = map[uint8][]byte{}
+	uartOutput = map[uint8][]byte{}
+	trace = nil
+}
+
+// Trace returns all hardware accesses done by the program since the start or
+// since the last call to ClearTrace or Reset.
+func Trace() []Event {
+	return trace
+}
+
+// ClearTrace removes all events from the trace.
+func ClearTrace() {
+	trace = nil
+}
+
+// SetPin changes the level of an input pin, as if it was driven by an external
+// device. Any queued input levels are discarded.
+func SetPin(pin uint8, level bool) {
+	p := &pins[pin]
+	p.level = level
+	p.driven = true
+	p.inputs = nil
+}
+
+// QueuePin queues a sequence of input levels for a pin. Each time the program
+// reads the pin, the next level is returned. After the last one, the pin stays
+// at that level.
+func QueuePin(pin uint8, levels ...bool) {
+	p := &pins[pin]
+	p.inputs = append(p.inputs, levels...)
+	p.driven = true
+}
+
+// PinLevel returns the current level of a pin, usually the last value set by
+// the program on an output pin.
+func PinLevel(pin uint8) bool {
+	return pins[pin].level
+}
+
+// PinMode returns the mode a pin was configured with by the program, as a
+// machine.PinMode value.
+func PinMode(pin uint8) uint8 {
+	return pins[pin].mode
+}
+
+// SetADC sets the value read by the program from an ADC pin.
+func SetADC(pin uint8, value uint16) {
+	pins[pin].adc = value
+}
+
+// PWMValue returns the last value set by the program on a PWM pin.
+func PWMValue(pin uint8) uint16 {
+	return pins[pin].pwm
+}
+
+// AttachSPI attaches a simulated device to a SPI bus. The device only receives
+// data while its chip select pin cs is low. Use 0xff (machine.NoPin) for a
+// device that is always selected.
+func AttachSPI(bus uint8, cs uint8, dev SPIDevice) {
+	spiDevices = append(spiDevices, spiDevice{bus, cs, dev})
+}
+
+// AttachI2C attaches a simulated device to an I2C bus at the given address.
+func AttachI2C(bus uint8, addr uint16, dev I2CDevice) {
+	i2cDevices = append(i2cDevices, i2cDevice{bus, addr, dev})
+}
+
+// QueueI2CTransfer queues a transfer from a simulated I2C controller to the
+// program, which must be an I2C target (see machine.I2C.Listen). The controller
+// writes w and then reads rlen bytes, with a repeated start in between if both
+// are done.
+//
+// The transfer happens as the program calls WaitForEvent and Reply, and is
+// recorded in the trace as an I2CTargetTransfer event. Once all queued
+// transfers are done, WaitForEvent returns an error instead of blocking.
+func QueueI2CTransfer(bus uint8, addr uint16, w []byte, rlen int) {
+	tx := &i2cTargetTransfer{addr: addr, w: append([]byte(nil), w...), rlen: rlen}
+	i2cQueue[bus] = append(i2cQueue[bus], tx)
+}
+
+// SendUART queues data to be read by the program from the given UART.
+func SendUART(bus uint8, data []byte) {
+	uartInput[bus] = append(uartInput[bus], data...)
+}
+
+// ReceiveUART returns all data written by the program to the given UART since
+// the last call.
+func ReceiveUART(bus uint8) []byte {
+	data := uartOutput[bus]
+	delete(uartOutput, bus)
+	return data
+}
+
+// I2CRegisters is a simple I2C device with 256 registers of one byte each, as
+// is common in sensors. The first byte of a write selects the register, the
+// following bytes are written to that register and the ones after it. A read
+// returns the selected register and the ones after it.
+type I2CRegisters struct {
+	Data     [256]byte
+	register uint8
+}
+
+// Tx implements I2CDevice.
+func (d *I2CRegisters) Tx(w, r []byte) error {
+	if len(w) != 0 {
+		d.register = w[0]
+		for _, b := range w[1:] {
+			d.Data[d.register] = b
+			d.register++
+		}
+	}
+	for i := range r {
+		r[i] = d.Data[d.register]
+		d.register++
+	}
+	return nil
+}
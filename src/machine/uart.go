@@ -6,12 +6,6 @@ import "errors"
 
 var errUARTBufferEmpty = errors.New("UART buffer empty")
 
-type UARTConfig struct {
-	BaudRate uint32
-	TX       Pin
-	RX       Pin
-}
-
 // To implement the UART interface for a board, you must declare a concrete type as follows:
 //
 // 		type UART struct {
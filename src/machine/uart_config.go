@@ -0,0 +1,82 @@
+// +build avr esp nrf sam sifive stm32 k210 nxp !baremetal
+
+package machine
+
+import "errors"
+
+var (
+	ErrUARTFormatNotSupported      = errors.New("UART: data bits, parity or stop bits not supported")
+	ErrUARTFlowControlNotSupported = errors.New("UART: hardware flow control not supported")
+)
+
+// UARTParity is the parity bit that is sent after the data bits of every
+// character.
+type UARTParity uint8
+
+const (
+	ParityNone UARTParity = iota // no parity bit
+	ParityEven                   // the number of 1 bits, including the parity bit, is even
+	ParityOdd                    // the number of 1 bits, including the parity bit, is odd
+)
+
+// UARTConfig is the configuration of a UART, used by Configure. The zero value
+// of a field selects a default: the default baud rate of the chip (usually
+// 115200), the default pins of the board and 8 data bits, no parity and 1 stop
+// bit (8N1) without hardware flow control.
+type UARTConfig struct {
+	BaudRate uint32
+	TX       Pin
+	RX       Pin
+
+	// FlowControl enables hardware flow control using the RTS and CTS pins,
+	// which must both be set when it is enabled. RTS tells the other side it
+	// can send data, CTS is used by the other side to tell this UART it can
+	// send data. The pins are ignored when FlowControl is false.
+	FlowControl bool
+	RTS         Pin
+	CTS         Pin
+
+	// DataBits is the number of data bits of every character, usually 7 or 8.
+	// The default is 8.
+	DataBits uint8
+
+	// Parity is the parity bit that is sent after the data bits. The default
+	// is ParityNone.
+	Parity UARTParity
+
+	// StopBits is the number of stop bits, 1 or 2. The default is 1.
+	StopBits uint8
+}
+
+// format returns the data bits and stop bits of the config, with the defaults
+// filled in.
+func (config UARTConfig) format() (dataBits, stopBits uint8) {
+	dataBits = config.DataBits
+	if dataBits == 0 {
+		dataBits = 8
+	}
+	stopBits = config.StopBits
+	if stopBits == 0 {
+		stopBits = 1
+	}
+	return
+}
+
+// flowControl returns whether hardware flow control is configured.
+func (config UARTConfig) flowControl() bool {
+	return config.FlowControl
+}
+
+// checkDefaultFormat returns an error if the config asks for anything other
+// than 8 data bits, no parity and 1 stop bit without hardware flow control. It
+// is used by UARTs that only support this default.
+func (config UARTConfig) checkDefaultFormat() error {
+	dataBits, stopBits := config.format()
+	if dataBits != 8 || stopBits != 1 || config.Parity != ParityNone {
+		return ErrUARTFormatNotSupported
+	}
+	if config.flowControl() {
+		return ErrUARTFlowControlNotSupported
+	}
+	return nil
+}
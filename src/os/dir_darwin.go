@@ -0,0 +1,70 @@
+// +build darwin
+
+package os
+
+import (
+	"unsafe"
+)
+
+// Readdirnames returns the names of all entries in the directory.
+func (f unixFileHandle) Readdirnames() ([]string, error) {
+	// The directory stream takes ownership of the file descriptor and closes
+	// it in closedir, so give it a copy. The file handle keeps its own.
+	fd := libc_dup(int32(f))
+	if fd < 0 {
+		return nil, ErrInvalid
+	}
+	dir := libc_fdopendir(fd)
+	if dir == nil {
+		libc_close(fd)
+		return nil, ErrInvalid
+	}
+	var names []string
+	for {
+		entry := libc_readdir(dir)
+		if entry == nil {
+			// End of directory (or an error, which can't be distinguished
+			// here).
+			break
+		}
+		s := string((*[1 << 30]byte)(unsafe.Pointer(&entry.name))[:entry.namlen:entry.namlen])
+		if s != "." && s != ".." {
+			names = append(names, s)
+		}
+	}
+	libc_closedir(dir)
+	return names, nil
+}
+
+// dirent is the same as struct dirent in the darwin libc with 64-bit inodes.
+// The name continues past the end of the struct.
+type dirent struct {
+	ino     uint64
+	seekoff uint64
+	reclen  uint16
+	namlen  uint16
+	typ     uint8
+	name    byte
+}
+
+// int dup(int fd);
+//export dup
+func libc_dup(fd int32) int32
+
+// int close(int fd);
+//export close
+func libc_close(fd int32) int32
+
+// The $INODE64 variants use the dirent layout with 64-bit inodes above.
+
+// DIR *fdopendir(int fd);
+//export fdopendir$INODE64
+func libc_fdopendir(fd int32) unsafe.Pointer
+
+// struct dirent *readdir(DIR *dirp);
+//export readdir$INODE64
+func libc_readdir(dir unsafe.Pointer) *dirent
+
+// int closedir(DIR *dirp);
+//export closedir
+func libc_closedir(dir unsafe.Pointer) int32
@@ -0,0 +1,24 @@
+// +build linux,!baremetal,!wasi freebsd,!baremetal
+
+package os
+
+import (
+	"syscall"
+)
+
+// Readdirnames returns the names of all entries in the directory.
+func (f unixFileHandle) Readdirnames() ([]string, error) {
+	var names []string
+	buf := make([]byte, 4096)
+	for {
+		n, err := syscall.ReadDirent(int(f), buf)
+		if err != nil {
+			return names, handleSyscallError(err)
+		}
+		if n <= 0 {
+			// End of directory.
+			return names, nil
+		}
+		_, _, names = syscall.ParseDirent(buf[:n], -1, names)
+	}
+}
@@ -0,0 +1,57 @@
+// +build wasi
+
+package os
+
+import (
+	"unsafe"
+)
+
+// Readdirnames returns the names of all entries in the directory.
+func (f unixFileHandle) Readdirnames() ([]string, error) {
+	dir := libc_fdopendir(int32(f))
+	if dir == nil {
+		return nil, ErrInvalid
+	}
+	var names []string
+	for {
+		entry := libc_readdir(dir)
+		if entry == nil {
+			// End of directory (or an error, which can't be distinguished
+			// here).
+			break
+		}
+		name := &entry.name
+		length := 0
+		for *(*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(name)) + uintptr(length))) != 0 {
+			length++
+		}
+		s := string((*[1 << 30]byte)(unsafe.Pointer(name))[:length:length])
+		if s != "." && s != ".." {
+			names = append(names, s)
+		}
+	}
+	// Free the directory stream without closing the file descriptor, which is
+	// still owned by the file handle.
+	libc_fdclosedir(dir)
+	return names, nil
+}
+
+// dirent is the same as struct dirent in wasi-libc. The name continues past
+// the end of the struct until the terminating null byte.
+type dirent struct {
+	ino  uint64
+	typ  uint8
+	name byte
+}
+
+// DIR *fdopendir(int fd);
+//export fdopendir
+func libc_fdopendir(fd int32) unsafe.Pointer
+
+// struct dirent *readdir(DIR *dirp);
+//export readdir
+func libc_readdir(dir unsafe.Pointer) *dirent
+
+// int fdclosedir(DIR *dirp);
+//export fdclosedir
+func libc_fdclosedir(dir unsafe.Pointer) int32
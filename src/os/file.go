@@ -6,6 +6,7 @@
 package os
 
 import (
+	"io"
 	"syscall"
 )
 
@@ -37,10 +38,52 @@ func Remove(path string) error {
 	return nil
 }
 
+// Rename renames (moves) oldpath to newpath. Both paths must be on the same
+// mounted filesystem. If the operation fails, it will return an error of type
+// *LinkError.
+func Rename(oldpath, newpath string) error {
+	fs, oldSuffix := findMount(oldpath)
+	if fs == nil {
+		return &LinkError{"rename", oldpath, newpath, ErrNotExist}
+	}
+	newFS, newSuffix := findMount(newpath)
+	if newFS != fs {
+		return &LinkError{"rename", oldpath, newpath, ErrUnsupported}
+	}
+	renameFS, ok := fs.(RenameFilesystem)
+	if !ok {
+		return &LinkError{"rename", oldpath, newpath, ErrNotImplemented}
+	}
+	err := renameFS.Rename(oldSuffix, newSuffix)
+	if err != nil {
+		return &LinkError{"rename", oldpath, newpath, err}
+	}
+	return nil
+}
+
+// Truncate changes the size of the named file. If the operation fails, it
+// will return an error of type *PathError.
+func Truncate(name string, size int64) error {
+	f, err := OpenFile(name, O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	err = f.Truncate(size)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
 // File represents an open file descriptor.
 type File struct {
 	handle FileHandle
 	name   string
+
+	// dirnames contains the directory entries that haven't been returned yet by
+	// Readdir or Readdirnames, after the first call to one of those.
+	dirnames []string
+	dirRead  bool
 }
 
 // Name returns the name of the file with which it was opened.
@@ -59,7 +102,7 @@ func OpenFile(name string, flag int, perm FileMode) (*File, error) {
 	if err != nil {
 		return nil, &PathError{"open", name, err}
 	}
-	return &File{name: name, handle: handle}, nil
+	return &File{handle: handle, name: name}, nil
 }
 
 // Open opens the file named for reading.
@@ -72,11 +115,73 @@ func Create(name string) (*File, error) {
 	return OpenFile(name, O_RDWR|O_CREATE|O_TRUNC, 0666)
 }
 
+// ReadFile reads the named file and returns the contents. A successful call
+// returns err == nil, not err == EOF.
+func ReadFile(name string) ([]byte, error) {
+	f, err := Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var size int
+	if info, err := f.Stat(); err == nil {
+		size64 := info.Size()
+		if int64(int(size64)) == size64 {
+			size = int(size64)
+		}
+	}
+	size++ // one byte for final read at EOF
+
+	// If a file claims a small size, read at least 512 bytes. In particular,
+	// files in Linux's /proc claim size 0 but then do not work right if read
+	// in small pieces, so an initial read of 1 byte would not work correctly.
+	if size < 512 {
+		size = 512
+	}
+
+	data := make([]byte, 0, size)
+	for {
+		if len(data) >= cap(data) {
+			d := append(data[:cap(data)], 0)
+			data = d[:len(data)]
+		}
+		n, err := f.Read(data[len(data):cap(data)])
+		data = data[:len(data)+n]
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return data, err
+		}
+	}
+}
+
+// WriteFile writes data to the named file, creating it if necessary. If the
+// file does not exist, WriteFile creates it with permissions perm; otherwise
+// WriteFile truncates it before writing.
+func WriteFile(name string, data []byte, perm FileMode) error {
+	f, err := OpenFile(name, O_WRONLY|O_CREATE|O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
 // Read reads up to len(b) bytes from the File. It returns the number of bytes
 // read and any error encountered. At end of file, Read returns 0, io.EOF.
 func (f *File) Read(b []byte) (n int, err error) {
 	n, err = f.handle.Read(b)
-	if err != nil {
+	if n == 0 && len(b) > 0 && err == nil {
+		// Some file handles (like those of Unix systems) don't return io.EOF
+		// at the end of the file.
+		err = io.EOF
+	}
+	if err != nil && err != io.EOF {
 		err = &PathError{"read", f.name, err}
 	}
 	return
@@ -105,19 +210,109 @@ func (f *File) Close() (err error) {
 	return
 }
 
-// Readdir is a stub, not yet implemented
+// Seek sets the offset for the next Read or Write on file to offset,
+// interpreted according to whence: 0 means relative to the origin of the file,
+// 1 means relative to the current offset, and 2 means relative to the end. It
+// returns the new offset and an error, if any.
+func (f *File) Seek(offset int64, whence int) (ret int64, err error) {
+	handle, ok := f.handle.(SeekFileHandle)
+	if !ok {
+		return 0, &PathError{"seek", f.name, ErrNotImplemented}
+	}
+	ret, err = handle.Seek(offset, whence)
+	if err != nil {
+		err = &PathError{"seek", f.name, err}
+	}
+	return
+}
+
+// Truncate changes the size of the file. It does not change the I/O offset.
+func (f *File) Truncate(size int64) error {
+	handle, ok := f.handle.(TruncateFileHandle)
+	if !ok {
+		return &PathError{"truncate", f.name, ErrNotImplemented}
+	}
+	err := handle.Truncate(size)
+	if err != nil {
+		return &PathError{"truncate", f.name, err}
+	}
+	return nil
+}
+
+// Readdir reads the contents of the directory associated with file and returns
+// a slice of up to n FileInfo values, as would be returned by Lstat, in
+// directory order. Subsequent calls on the same file will yield further
+// FileInfos.
+//
+// If n > 0, Readdir returns at most n FileInfo structures. In this case, if
+// Readdir returns an empty slice, it will return a non-nil error explaining
+// why. At the end of a directory, the error is io.EOF.
+//
+// If n <= 0, Readdir returns all the FileInfo from the directory in a single
+// slice.
 func (f *File) Readdir(n int) ([]FileInfo, error) {
-	return nil, &PathError{"readdir", f.name, ErrNotImplemented}
+	names, err := f.readdirnames(n, "readdir")
+	infos := make([]FileInfo, 0, len(names))
+	for _, name := range names {
+		info, lstatErr := Lstat(f.name + "/" + name)
+		if lstatErr != nil {
+			// The file may have been removed in the meantime.
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, err
 }
 
-// Readdirnames is a stub, not yet implemented
+// Readdirnames reads the contents of the directory associated with file and
+// returns a slice of up to n names of files in the directory, in directory
+// order. Subsequent calls on the same file will yield further names. See
+// Readdir for the meaning of n.
 func (f *File) Readdirnames(n int) (names []string, err error) {
-	return nil, &PathError{"readdirnames", f.name, ErrNotImplemented}
+	return f.readdirnames(n, "readdirnames")
 }
 
-// Stat is a stub, not yet implemented
+func (f *File) readdirnames(n int, op string) ([]string, error) {
+	if !f.dirRead {
+		handle, ok := f.handle.(ReaddirFileHandle)
+		if !ok {
+			return nil, &PathError{op, f.name, ErrNotImplemented}
+		}
+		names, err := handle.Readdirnames()
+		if err != nil {
+			return nil, &PathError{op, f.name, err}
+		}
+		f.dirnames = names
+		f.dirRead = true
+	}
+	count := n
+	if count <= 0 || count > len(f.dirnames) {
+		count = len(f.dirnames)
+	}
+	names := f.dirnames[:count:count]
+	f.dirnames = f.dirnames[count:]
+	if len(names) == 0 && n > 0 {
+		return names, io.EOF
+	}
+	return names, nil
+}
+
+// Stat returns the FileInfo structure describing file. If there is an error,
+// it will be of type *PathError.
 func (f *File) Stat() (FileInfo, error) {
-	return nil, &PathError{"stat", f.name, ErrNotImplemented}
+	handle, ok := f.handle.(StatFileHandle)
+	if !ok {
+		return nil, &PathError{"stat", f.name, ErrNotImplemented}
+	}
+	info, err := handle.Stat()
+	if err != nil {
+		return nil, &PathError{"stat", f.name, err}
+	}
+	if info, ok := info.(*fileStat); ok && info.name == "" {
+		// The file handle doesn't know the name of the file.
+		info.name = basename(f.name)
+	}
+	return info, nil
 }
 
 // Sync is a stub, not yet implemented
@@ -155,6 +350,23 @@ func (e *PathError) Error() string {
 	return e.Op + " " + e.Path + ": " + e.Err.Error()
 }
 
+func (e *PathError) Unwrap() error { return e.Err }
+
+// LinkError records an error during a link or symlink or rename system call
+// and the paths that caused it.
+type LinkError struct {
+	Op  string
+	Old string
+	New string
+	Err error
+}
+
+func (e *LinkError) Error() string {
+	return e.Op + " " + e.Old + " " + e.New + ": " + e.Err.Error()
+}
+
+func (e *LinkError) Unwrap() error { return e.Err }
+
 type FileMode uint32
 
 // Mode constants, copied from the mainline Go source
@@ -181,9 +393,19 @@ const (
 	ModePerm FileMode = 0777 // Unix permission bits
 )
 
-// IsDir is a stub, always returning false
+// IsDir reports whether m describes a directory.
 func (m FileMode) IsDir() bool {
-	return false
+	return m&ModeDir != 0
+}
+
+// IsRegular reports whether m describes a regular file.
+func (m FileMode) IsRegular() bool {
+	return m&ModeType == 0
+}
+
+// Perm returns the Unix permission bits in m.
+func (m FileMode) Perm() FileMode {
+	return m & ModePerm
 }
 
 // Stub constants
@@ -208,14 +430,79 @@ type FileInfo interface {
 	Sys() interface{} // underlying data source (can return nil)
 }
 
-// Stat is a stub, not yet implemented
+// fileStat is a simple implementation of FileInfo, used by the filesystems
+// provided by the os package.
+type fileStat struct {
+	name string
+	size int64
+	mode FileMode
+	sys  interface{}
+}
+
+func (fs *fileStat) Name() string     { return fs.name }
+func (fs *fileStat) Size() int64      { return fs.size }
+func (fs *fileStat) Mode() FileMode   { return fs.mode }
+func (fs *fileStat) IsDir() bool      { return fs.mode.IsDir() }
+func (fs *fileStat) Sys() interface{} { return fs.sys }
+
+// Stat returns a FileInfo describing the named file. If there is an error, it
+// will be of type *PathError.
 func Stat(name string) (FileInfo, error) {
-	return nil, &PathError{"stat", name, ErrNotImplemented}
+	fs, suffix := findMount(name)
+	if fs == nil {
+		return nil, &PathError{"stat", name, ErrNotExist}
+	}
+	statFS, ok := fs.(StatFilesystem)
+	if !ok {
+		return nil, &PathError{"stat", name, ErrNotImplemented}
+	}
+	info, err := statFS.Stat(suffix)
+	if err != nil {
+		return nil, &PathError{"stat", name, err}
+	}
+	return info, nil
 }
 
-// Lstat is a stub, not yet implemented
+// Lstat returns a FileInfo describing the named file. If the file is a
+// symbolic link, the returned FileInfo describes the symbolic link. Filesystems
+// without symbolic links only need to implement Stat. If there is an error, it
+// will be of type *PathError.
 func Lstat(name string) (FileInfo, error) {
-	return nil, &PathError{"lstat", name, ErrNotImplemented}
+	fs, suffix := findMount(name)
+	if fs == nil {
+		return nil, &PathError{"lstat", name, ErrNotExist}
+	}
+	var info FileInfo
+	var err error
+	if lstatFS, ok := fs.(LstatFilesystem); ok {
+		info, err = lstatFS.Lstat(suffix)
+	} else if statFS, ok := fs.(StatFilesystem); ok {
+		info, err = statFS.Stat(suffix)
+	} else {
+		err = ErrNotImplemented
+	}
+	if err != nil {
+		return nil, &PathError{"lstat", name, err}
+	}
+	return info, nil
+}
+
+// basename removes trailing slashes and the leading directory name from path
+// name.
+func basename(name string) string {
+	i := len(name) - 1
+	// Remove trailing slashes
+	for ; i > 0 && name[i] == '/'; i-- {
+		name = name[:i]
+	}
+	// Remove leading directory name
+	for i--; i >= 0; i-- {
+		if name[i] == '/' {
+			name = name[i+1:]
+			break
+		}
+	}
+	return name
 }
 
 // Getwd is a stub (for now), always returning an empty string
@@ -1,4 +1,4 @@
-// +build baremetal wasm
+// +build baremetal wasm,!wasi
 
 package os
 
@@ -9,9 +9,9 @@ import (
 // Stdin, Stdout, and Stderr are open Files pointing to the standard input,
 // standard output, and standard error file descriptors.
 var (
-	Stdin  = &File{stdioFileHandle(0), "/dev/stdin"}
-	Stdout = &File{stdioFileHandle(1), "/dev/stdout"}
-	Stderr = &File{stdioFileHandle(2), "/dev/stderr"}
+	Stdin  = &File{handle: stdioFileHandle(0), name: "/dev/stdin"}
+	Stdout = &File{handle: stdioFileHandle(1), name: "/dev/stdout"}
+	Stderr = &File{handle: stdioFileHandle(2), name: "/dev/stderr"}
 )
 
 // isOS indicates whether we're running on a real operating system with
@@ -1,4 +1,4 @@
-// +build darwin linux,!baremetal,!wasi freebsd,!baremetal
+// +build darwin linux,!baremetal,!wasi freebsd,!baremetal wasi
 
 package os
 
@@ -15,9 +15,9 @@ func init() {
 // Stdin, Stdout, and Stderr are open Files pointing to the standard input,
 // standard output, and standard error file descriptors.
 var (
-	Stdin  = &File{unixFileHandle(0), "/dev/stdin"}
-	Stdout = &File{unixFileHandle(1), "/dev/stdout"}
-	Stderr = &File{unixFileHandle(2), "/dev/stderr"}
+	Stdin  = &File{handle: unixFileHandle(0), name: "/dev/stdin"}
+	Stdout = &File{handle: unixFileHandle(1), name: "/dev/stdout"}
+	Stderr = &File{handle: unixFileHandle(2), name: "/dev/stderr"}
 )
 
 // isOS indicates whether we're running on a real operating system with
@@ -37,6 +37,28 @@ func (fs unixFilesystem) Remove(path string) error {
 	return handleSyscallError(syscall.Unlink(path))
 }
 
+func (fs unixFilesystem) Rename(oldpath, newpath string) error {
+	return handleSyscallError(syscall.Rename(oldpath, newpath))
+}
+
+func (fs unixFilesystem) Stat(path string) (FileInfo, error) {
+	var st syscall.Stat_t
+	err := syscall.Stat(path, &st)
+	if err != nil {
+		return nil, handleSyscallError(err)
+	}
+	return fileInfoFromStat(basename(path), &st), nil
+}
+
+func (fs unixFilesystem) Lstat(path string) (FileInfo, error) {
+	var st syscall.Stat_t
+	err := syscall.Lstat(path, &st)
+	if err != nil {
+		return nil, handleSyscallError(err)
+	}
+	return fileInfoFromStat(basename(path), &st), nil
+}
+
 func (fs unixFilesystem) OpenFile(path string, flag int, perm FileMode) (FileHandle, error) {
 	// Map os package flags to syscall flags.
 	syscallFlag := 0
@@ -93,6 +115,61 @@ func (f unixFileHandle) Close() error {
 	return handleSyscallError(syscall.Close(int(f)))
 }
 
+// Seek sets the offset for the next Read or Write on the file.
+func (f unixFileHandle) Seek(offset int64, whence int) (int64, error) {
+	off, err := syscall.Seek(int(f), offset, whence)
+	return off, handleSyscallError(err)
+}
+
+// Truncate changes the size of the file.
+func (f unixFileHandle) Truncate(size int64) error {
+	return handleSyscallError(syscall.Ftruncate(int(f), size))
+}
+
+// Stat returns information about the file. The name is filled in by File.Stat.
+func (f unixFileHandle) Stat() (FileInfo, error) {
+	var st syscall.Stat_t
+	err := syscall.Fstat(int(f), &st)
+	if err != nil {
+		return nil, handleSyscallError(err)
+	}
+	return fileInfoFromStat("", &st), nil
+}
+
+// fileInfoFromStat converts the result of a stat system call to a FileInfo.
+func fileInfoFromStat(name string, st *syscall.Stat_t) *fileStat {
+	fs := &fileStat{
+		name: name,
+		size: int64(st.Size),
+		mode: FileMode(st.Mode & 0777),
+		sys:  st,
+	}
+	switch st.Mode & syscall.S_IFMT {
+	case syscall.S_IFBLK:
+		fs.mode |= ModeDevice
+	case syscall.S_IFCHR:
+		fs.mode |= ModeDevice | ModeCharDevice
+	case syscall.S_IFDIR:
+		fs.mode |= ModeDir
+	case syscall.S_IFIFO:
+		fs.mode |= ModeNamedPipe
+	case syscall.S_IFLNK:
+		fs.mode |= ModeSymlink
+	case syscall.S_IFSOCK:
+		fs.mode |= ModeSocket
+	}
+	if st.Mode&syscall.S_ISGID != 0 {
+		fs.mode |= ModeSetgid
+	}
+	if st.Mode&syscall.S_ISUID != 0 {
+		fs.mode |= ModeSetuid
+	}
+	if st.Mode&syscall.S_ISVTX != 0 {
+		fs.mode |= ModeSticky
+	}
+	return fs
+}
+
 // handleSyscallError converts syscall errors into regular os package errors.
 // The err parameter must be either nil or of type syscall.Errno.
 func handleSyscallError(err error) error {
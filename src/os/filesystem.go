@@ -53,6 +53,60 @@ type FileHandle interface {
 	Close() (err error)
 }
 
+// The interfaces below are optional. A filesystem or file handle that doesn't
+// implement one of them will return ErrNotImplemented for the corresponding
+// operation, so that simple drivers only need to implement the interfaces
+// above.
+
+// StatFilesystem is implemented by filesystems that support os.Stat.
+type StatFilesystem interface {
+	// Stat returns information about the named file.
+	Stat(name string) (FileInfo, error)
+}
+
+// LstatFilesystem is implemented by filesystems with symbolic links, to support
+// os.Lstat. Other filesystems only need to implement StatFilesystem.
+type LstatFilesystem interface {
+	// Lstat returns information about the named file, without following
+	// symbolic links.
+	Lstat(name string) (FileInfo, error)
+}
+
+// RenameFilesystem is implemented by filesystems that support os.Rename.
+type RenameFilesystem interface {
+	// Rename renames (moves) a file or directory. Both paths are on this
+	// filesystem.
+	Rename(oldname, newname string) error
+}
+
+// StatFileHandle is implemented by file handles that support File.Stat.
+type StatFileHandle interface {
+	// Stat returns information about the open file.
+	Stat() (FileInfo, error)
+}
+
+// SeekFileHandle is implemented by file handles that support File.Seek.
+type SeekFileHandle interface {
+	// Seek sets the offset for the next Read or Write, like io.Seeker.
+	Seek(offset int64, whence int) (int64, error)
+}
+
+// TruncateFileHandle is implemented by file handles that support File.Truncate
+// and os.Truncate.
+type TruncateFileHandle interface {
+	// Truncate changes the size of the file.
+	Truncate(size int64) error
+}
+
+// ReaddirFileHandle is implemented by directory handles that support
+// File.Readdir and File.Readdirnames.
+type ReaddirFileHandle interface {
+	// Readdirnames returns the names of all entries in the directory, in
+	// directory order, not including "." and "..". It is called at most once
+	// for each open file.
+	Readdirnames() ([]string, error)
+}
+
 // findMount returns the appropriate (mounted) filesystem to use for a given
 // filename plus the path relative to that filesystem.
 func findMount(path string) (Filesystem, string) {
@@ -0,0 +1,312 @@
+package os
+
+import (
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// RAMFilesystem is a read/write filesystem that is stored in memory. It
+// supports files and directories, but no permissions or links. It can be
+// mounted anywhere, for example:
+//
+//     os.Mount("/tmp/", os.NewRAMFilesystem())
+//
+// All data is lost when the program exits.
+type RAMFilesystem struct {
+	// files contains all files and directories, indexed by their cleaned path
+	// (which always starts with a slash).
+	files map[string]*ramFile
+}
+
+type ramFile struct {
+	mode FileMode
+	data []byte
+}
+
+// NewRAMFilesystem returns a new, empty RAM filesystem.
+func NewRAMFilesystem() *RAMFilesystem {
+	return &RAMFilesystem{
+		files: map[string]*ramFile{
+			"/": {mode: ModeDir | 0777},
+		},
+	}
+}
+
+// cleanPath returns the path relative to the root of a filesystem in a
+// canonical form, starting with a slash.
+func cleanPath(name string) string {
+	return path.Clean("/" + name)
+}
+
+// checkParent returns an error if the parent directory of the given (cleaned)
+// path doesn't exist.
+func (fs *RAMFilesystem) checkParent(p string) error {
+	parent := fs.files[path.Dir(p)]
+	if parent == nil {
+		return ErrNotExist
+	}
+	if !parent.mode.IsDir() {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// hasChildren returns whether there are files in the given directory.
+func (fs *RAMFilesystem) hasChildren(dir string) bool {
+	prefix := dir + "/"
+	if dir == "/" {
+		prefix = "/"
+	}
+	for p := range fs.files {
+		if p != "/" && strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenFile opens or creates the named file.
+func (fs *RAMFilesystem) OpenFile(name string, flag int, perm FileMode) (FileHandle, error) {
+	p := cleanPath(name)
+	f := fs.files[p]
+	writable := flag&(O_WRONLY|O_RDWR) != 0
+	if f == nil {
+		if flag&O_CREATE == 0 {
+			return nil, ErrNotExist
+		}
+		if err := fs.checkParent(p); err != nil {
+			return nil, err
+		}
+		f = &ramFile{mode: perm & ModePerm}
+		fs.files[p] = f
+	} else if flag&O_CREATE != 0 && flag&O_EXCL != 0 {
+		return nil, ErrExist
+	}
+	if f.mode.IsDir() && writable {
+		return nil, ErrInvalid
+	}
+	if flag&O_TRUNC != 0 && writable {
+		f.data = nil
+	}
+	return &ramFileHandle{
+		fs:   fs,
+		file: f,
+		path: p,
+		flag: flag,
+	}, nil
+}
+
+// Mkdir creates a new directory. The parent directory must exist.
+func (fs *RAMFilesystem) Mkdir(name string, perm FileMode) error {
+	p := cleanPath(name)
+	if fs.files[p] != nil {
+		return ErrExist
+	}
+	if err := fs.checkParent(p); err != nil {
+		return err
+	}
+	fs.files[p] = &ramFile{mode: ModeDir | perm&ModePerm}
+	return nil
+}
+
+// Remove removes the named file or (empty) directory.
+func (fs *RAMFilesystem) Remove(name string) error {
+	p := cleanPath(name)
+	f := fs.files[p]
+	if f == nil {
+		return ErrNotExist
+	}
+	if p == "/" || (f.mode.IsDir() && fs.hasChildren(p)) {
+		return ErrInvalid
+	}
+	delete(fs.files, p)
+	return nil
+}
+
+// Rename moves a file or directory. An existing file at the new path is
+// replaced, but an existing directory is not.
+func (fs *RAMFilesystem) Rename(oldname, newname string) error {
+	oldpath := cleanPath(oldname)
+	newpath := cleanPath(newname)
+	f := fs.files[oldpath]
+	if f == nil {
+		return ErrNotExist
+	}
+	if oldpath == newpath {
+		return nil
+	}
+	if oldpath == "/" || strings.HasPrefix(newpath, oldpath+"/") {
+		// Can't move a directory into itself.
+		return ErrInvalid
+	}
+	if err := fs.checkParent(newpath); err != nil {
+		return err
+	}
+	if existing := fs.files[newpath]; existing != nil && (existing.mode.IsDir() || f.mode.IsDir()) {
+		return ErrExist
+	}
+	delete(fs.files, oldpath)
+	fs.files[newpath] = f
+	if f.mode.IsDir() {
+		// Move all files in the directory.
+		for p, child := range fs.files {
+			if strings.HasPrefix(p, oldpath+"/") {
+				delete(fs.files, p)
+				fs.files[newpath+p[len(oldpath):]] = child
+			}
+		}
+	}
+	return nil
+}
+
+// Stat returns information about the named file.
+func (fs *RAMFilesystem) Stat(name string) (FileInfo, error) {
+	p := cleanPath(name)
+	f := fs.files[p]
+	if f == nil {
+		return nil, ErrNotExist
+	}
+	return f.stat(p), nil
+}
+
+func (f *ramFile) stat(p string) *fileStat {
+	return &fileStat{
+		name: basename(p),
+		size: int64(len(f.data)),
+		mode: f.mode,
+	}
+}
+
+// ramFileHandle is an open file or directory in a RAMFilesystem.
+type ramFileHandle struct {
+	fs     *RAMFilesystem
+	file   *ramFile
+	path   string
+	flag   int
+	offset int64
+	closed bool
+}
+
+func (f *ramFileHandle) Read(b []byte) (n int, err error) {
+	if f.closed {
+		return 0, ErrClosed
+	}
+	if f.flag&O_WRONLY != 0 {
+		return 0, ErrPermission
+	}
+	if f.file.mode.IsDir() {
+		return 0, ErrInvalid
+	}
+	if f.offset >= int64(len(f.file.data)) {
+		return 0, io.EOF
+	}
+	n = copy(b, f.file.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *ramFileHandle) Write(b []byte) (n int, err error) {
+	if f.closed {
+		return 0, ErrClosed
+	}
+	if f.flag&(O_WRONLY|O_RDWR) == 0 {
+		return 0, ErrPermission
+	}
+	if f.flag&O_APPEND != 0 {
+		f.offset = int64(len(f.file.data))
+	}
+	end := f.offset + int64(len(b))
+	if end > int64(len(f.file.data)) {
+		f.resize(end)
+	}
+	copy(f.file.data[f.offset:], b)
+	f.offset = end
+	return len(b), nil
+}
+
+// resize changes the size of the file, filling new space with zeroes.
+func (f *ramFileHandle) resize(size int64) {
+	if size <= int64(cap(f.file.data)) {
+		oldSize := len(f.file.data)
+		f.file.data = f.file.data[:size]
+		for i := int64(oldSize); i < size; i++ {
+			f.file.data[i] = 0
+		}
+		return
+	}
+	data := make([]byte, size, size+size/2)
+	copy(data, f.file.data)
+	f.file.data = data
+}
+
+func (f *ramFileHandle) Close() error {
+	if f.closed {
+		return ErrClosed
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *ramFileHandle) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, ErrClosed
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.file.data))
+	default:
+		return 0, ErrInvalid
+	}
+	if offset < 0 {
+		return 0, ErrInvalid
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *ramFileHandle) Truncate(size int64) error {
+	if f.closed {
+		return ErrClosed
+	}
+	if f.flag&(O_WRONLY|O_RDWR) == 0 {
+		return ErrPermission
+	}
+	if size < 0 {
+		return ErrInvalid
+	}
+	f.resize(size)
+	return nil
+}
+
+func (f *ramFileHandle) Stat() (FileInfo, error) {
+	if f.closed {
+		return nil, ErrClosed
+	}
+	return f.file.stat(f.path), nil
+}
+
+func (f *ramFileHandle) Readdirnames() ([]string, error) {
+	if f.closed {
+		return nil, ErrClosed
+	}
+	if !f.file.mode.IsDir() {
+		return nil, ErrInvalid
+	}
+	prefix := f.path + "/"
+	if f.path == "/" {
+		prefix = "/"
+	}
+	var names []string
+	for p := range f.fs.files {
+		if p != "/" && strings.HasPrefix(p, prefix) && strings.IndexByte(p[len(prefix):], '/') < 0 {
+			names = append(names, p[len(prefix):])
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
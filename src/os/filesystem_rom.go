@@ -0,0 +1,229 @@
+package os
+
+import (
+	"io"
+	"strings"
+)
+
+// These are set by the compiler when the -embed-dir flag is used. The files of
+// the embedded directory are mounted at the given prefix on startup.
+var (
+	embeddedFiles  string
+	embeddedPrefix string
+)
+
+func init() {
+	if embeddedFiles != "" {
+		Mount(embeddedPrefix, NewROMFilesystem(embeddedFiles))
+	}
+}
+
+// ROMFilesystem is a read-only filesystem that reads its files from a string,
+// which is usually stored in flash memory. This is the filesystem used for the
+// directory embedded with the -embed-dir flag, which can also be mounted at
+// other places with EmbeddedFilesystem.
+type ROMFilesystem struct {
+	data string
+}
+
+// NewROMFilesystem returns a read-only filesystem with the files stored in
+// data. For each file, in order, data contains the length of the path (a
+// 32-bit little endian integer), the path itself (starting with a slash), the
+// length of the file contents and the file contents. Directories are implied
+// by the paths of the files.
+func NewROMFilesystem(data string) *ROMFilesystem {
+	return &ROMFilesystem{data: data}
+}
+
+// EmbeddedFilesystem returns the directory embedded with the -embed-dir flag,
+// or nil if there is none.
+func EmbeddedFilesystem() *ROMFilesystem {
+	if embeddedFiles == "" {
+		return nil
+	}
+	return NewROMFilesystem(embeddedFiles)
+}
+
+// readString reads a string with its length in front of it, at the given
+// offset. It returns the string and the offset after it.
+func (fs *ROMFilesystem) readString(offset int) (string, int) {
+	length := int(fs.data[offset]) | int(fs.data[offset+1])<<8 | int(fs.data[offset+2])<<16 | int(fs.data[offset+3])<<24
+	offset += 4
+	return fs.data[offset : offset+length], offset + length
+}
+
+// forEach calls fn for every file in the filesystem, until it returns false.
+func (fs *ROMFilesystem) forEach(fn func(name, data string) bool) {
+	for offset := 0; offset < len(fs.data); {
+		var name, data string
+		name, offset = fs.readString(offset)
+		data, offset = fs.readString(offset)
+		if !fn(name, data) {
+			return
+		}
+	}
+}
+
+// lookup returns the file with the given (cleaned) path, and whether the path
+// exists and is a directory.
+func (fs *ROMFilesystem) lookup(p string) (data string, found, isDir bool) {
+	if p == "/" {
+		return "", true, true
+	}
+	fs.forEach(func(name, fileData string) bool {
+		if name == p {
+			data = fileData
+			found = true
+			return false
+		}
+		if strings.HasPrefix(name, p+"/") {
+			found = true
+			isDir = true
+			return false
+		}
+		return true
+	})
+	return
+}
+
+// OpenFile opens the named file. Files can only be opened for reading.
+func (fs *ROMFilesystem) OpenFile(name string, flag int, perm FileMode) (FileHandle, error) {
+	if flag&(O_WRONLY|O_RDWR|O_APPEND|O_CREATE|O_TRUNC) != 0 {
+		return nil, ErrPermission
+	}
+	p := cleanPath(name)
+	data, found, isDir := fs.lookup(p)
+	if !found {
+		return nil, ErrNotExist
+	}
+	return &romFileHandle{
+		fs:    fs,
+		path:  p,
+		data:  data,
+		isDir: isDir,
+	}, nil
+}
+
+// Mkdir is not supported on a read-only filesystem.
+func (fs *ROMFilesystem) Mkdir(name string, perm FileMode) error {
+	return ErrPermission
+}
+
+// Remove is not supported on a read-only filesystem.
+func (fs *ROMFilesystem) Remove(name string) error {
+	return ErrPermission
+}
+
+// Stat returns information about the named file.
+func (fs *ROMFilesystem) Stat(name string) (FileInfo, error) {
+	p := cleanPath(name)
+	data, found, isDir := fs.lookup(p)
+	if !found {
+		return nil, ErrNotExist
+	}
+	return romStat(p, data, isDir), nil
+}
+
+func romStat(p, data string, isDir bool) *fileStat {
+	if isDir {
+		return &fileStat{name: basename(p), mode: ModeDir | 0555}
+	}
+	return &fileStat{name: basename(p), size: int64(len(data)), mode: 0444}
+}
+
+// romFileHandle is an open file or directory in a ROMFilesystem.
+type romFileHandle struct {
+	fs     *ROMFilesystem
+	path   string
+	data   string
+	isDir  bool
+	offset int64
+	closed bool
+}
+
+func (f *romFileHandle) Read(b []byte) (n int, err error) {
+	if f.closed {
+		return 0, ErrClosed
+	}
+	if f.isDir {
+		return 0, ErrInvalid
+	}
+	if f.offset >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n = copy(b, f.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *romFileHandle) Write(b []byte) (n int, err error) {
+	return 0, ErrPermission
+}
+
+func (f *romFileHandle) Close() error {
+	if f.closed {
+		return ErrClosed
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *romFileHandle) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, ErrClosed
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.data))
+	default:
+		return 0, ErrInvalid
+	}
+	if offset < 0 {
+		return 0, ErrInvalid
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *romFileHandle) Stat() (FileInfo, error) {
+	if f.closed {
+		return nil, ErrClosed
+	}
+	return romStat(f.path, f.data, f.isDir), nil
+}
+
+func (f *romFileHandle) Readdirnames() ([]string, error) {
+	if f.closed {
+		return nil, ErrClosed
+	}
+	if !f.isDir {
+		return nil, ErrInvalid
+	}
+	prefix := f.path + "/"
+	if f.path == "/" {
+		prefix = "/"
+	}
+	var names []string
+	f.fs.forEach(func(name, data string) bool {
+		if !strings.HasPrefix(name, prefix) {
+			return true
+		}
+		// Only include the first path element after the directory, which is
+		// either a file or a subdirectory.
+		name = name[len(prefix):]
+		if index := strings.IndexByte(name, '/'); index >= 0 {
+			name = name[:index]
+		}
+		for _, existing := range names {
+			if existing == name {
+				return true
+			}
+		}
+		names = append(names, name)
+		return true
+	})
+	return names, nil
+}
@@ -0,0 +1,152 @@
+package reflect
+
+// This file creates object layouts for the precise GC at runtime, for heap
+// objects that are allocated by the reflect package. See
+// src/runtime/gc_precise.go for a description of the layout format. The layout
+// is ignored by the other GCs.
+
+import (
+	"unsafe"
+)
+
+const (
+	// gcWordSize is the size of a word as scanned by the GC.
+	gcWordSize = unsafe.Alignof(uintptr(0))
+
+	// gcSizeFieldBits must match sizeFieldBits in the runtime.
+	gcSizeFieldBits = 4 + unsafe.Sizeof(uintptr(0))/4
+
+	// gcMaxInlineWords is the largest object (in words) for which the layout
+	// can be stored inline.
+	gcMaxInlineWords = unsafe.Sizeof(uintptr(0))*8 - 1 - gcSizeFieldBits
+)
+
+// gcLayoutNoPointers is the layout of objects without any pointers. It must
+// match gcLayoutNoPointers in the runtime.
+var gcLayoutNoPointers = unsafe.Pointer(uintptr(1<<1 | 1))
+
+//go:linkname newGCLayout runtime.newGCLayout
+func newGCLayout(words uintptr) unsafe.Pointer
+
+// gcLayoutBuilder creates a single object layout. The layout is stored inline
+// when it fits, and is allocated on the heap otherwise.
+type gcLayoutBuilder struct {
+	words  uintptr
+	bitmap uintptr        // inline bitmap
+	layout unsafe.Pointer // heap allocated layout (nil when not needed)
+}
+
+func newGCLayoutBuilder(size uintptr) gcLayoutBuilder {
+	b := gcLayoutBuilder{
+		words: (size + gcWordSize - 1) / gcWordSize,
+	}
+	if b.words > gcMaxInlineWords {
+		b.layout = newGCLayout(b.words)
+	}
+	return b
+}
+
+// setPointer marks the word at the given byte offset as containing a pointer.
+func (b *gcLayoutBuilder) setPointer(offset uintptr) {
+	index := offset / gcWordSize
+	if b.words <= gcMaxInlineWords {
+		b.bitmap |= 1 << index
+	} else if b.layout != nil {
+		bitmapByte := (*uint8)(unsafe.Pointer(uintptr(b.layout) + unsafe.Sizeof(uintptr(0)) + index/8))
+		*bitmapByte |= 1 << (index % 8)
+	}
+}
+
+// finish returns the layout as it should be passed to runtime.alloc. It is nil
+// (an unknown layout) if the layout doesn't fit inline and the GC doesn't need
+// layouts.
+func (b *gcLayoutBuilder) finish() unsafe.Pointer {
+	if b.words <= gcMaxInlineWords {
+		return unsafe.Pointer(b.bitmap<<(gcSizeFieldBits+1) | b.words<<1 | 1)
+	}
+	return b.layout
+}
+
+// gcLayout returns the object layout for a value (or an array of values) of
+// this type.
+func (t Type) gcLayout() unsafe.Pointer {
+	size := t.Size()
+	if size < unsafe.Sizeof(uintptr(0)) || !t.hasPointers() {
+		return gcLayoutNoPointers
+	}
+	b := newGCLayoutBuilder(size)
+	t.setPointers(&b, 0)
+	return b.finish()
+}
+
+// hasPointers returns whether a value of this type may contain a pointer.
+func (t Type) hasPointers() bool {
+	switch t.Kind() {
+	case Bool, Int, Int8, Int16, Int32, Int64, Uint, Uint8, Uint16, Uint32, Uint64, Uintptr, Float32, Float64, Complex64, Complex128:
+		return false
+	case Array:
+		return t.Len() != 0 && t.Elem().hasPointers()
+	case Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Type.hasPointers() {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// setPointers marks all words of a value of this type (stored at the given
+// byte offset) that contain a pointer.
+func (t Type) setPointers(b *gcLayoutBuilder, offset uintptr) {
+	switch t.Kind() {
+	case String, UnsafePointer, Chan, Map, Ptr, Slice:
+		// The pointer is stored in the first word.
+		b.setPointer(offset)
+	case Interface:
+		// The typecode is stored in the first word, the value in the second.
+		b.setPointer(offset + unsafe.Sizeof(uintptr(0)))
+	case Array:
+		elem := t.Elem()
+		if !elem.hasPointers() {
+			return
+		}
+		elemSize := elem.Size()
+		for i := 0; i < t.Len(); i++ {
+			elem.setPointers(b, offset+elemSize*uintptr(i))
+		}
+	case Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			field.Type.setPointers(b, offset+field.Offset)
+		}
+	}
+}
+
+// mapBucketLayout returns the object layout of a runtime.hashmapBucket for a
+// map with the given key and element type, including the keys and values that
+// follow the bucket.
+func mapBucketLayout(keyType, elemType Type) unsafe.Pointer {
+	// The bucket starts with [8]uint8 and a pointer to the next bucket.
+	nextOffset := align(8, unsafe.Alignof(uintptr(0)))
+	keysOffset := nextOffset + unsafe.Sizeof(uintptr(0))
+	keySize := mapKeySize(keyType)
+	elemSize := elemType.Size()
+	valuesOffset := keysOffset + keySize*8
+	b := newGCLayoutBuilder(valuesOffset + elemSize*8)
+	b.setPointer(nextOffset)
+	keyIsInterface := mapKeyFormat(keyType) == mapKeyInterface
+	for i := uintptr(0); i < 8; i++ {
+		if keyIsInterface {
+			// The key is stored as an interface{}, with the value in the
+			// second word.
+			b.setPointer(keysOffset + keySize*i + unsafe.Sizeof(uintptr(0)))
+		} else {
+			keyType.setPointers(&b, keysOffset+keySize*i)
+		}
+		elemType.setPointers(&b, valuesOffset+elemSize*i)
+	}
+	return b.finish()
+}
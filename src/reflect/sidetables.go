@@ -19,6 +19,20 @@ var structNamesSidetable byte
 //go:extern reflect.arrayTypesSidetable
 var arrayTypesSidetable byte
 
+//go:extern reflect.mapTypesSidetable
+var mapTypesSidetable byte
+
+//go:extern reflect.typeNamesSidetable
+var typeNamesSidetable byte
+
+//go:extern reflect.methodSetsSidetable
+var methodSetsSidetable byte
+
+// This is an array of func values (the second word of a func value) of all
+// exported methods, indexed from methodSetsSidetable.
+//go:extern reflect.methodFuncsSidetable
+var methodFuncsSidetable uintptr
+
 // readStringSidetable reads a string from the given table (like
 // structNamesSidetable) and returns this string. No heap allocation is
 // necessary because it makes the string point directly to the raw bytes of the
@@ -31,6 +45,18 @@ func readStringSidetable(table unsafe.Pointer, index uintptr) string {
 	}))
 }
 
+// readStringAt reads a string stored as a varint length followed by the raw
+// bytes at the given pointer. It returns the string and a pointer to the data
+// just after the string. Like readStringSidetable, it doesn't allocate.
+func readStringAt(p unsafe.Pointer) (string, unsafe.Pointer) {
+	length, data := readVarint(p)
+	s := *(*string)(unsafe.Pointer(&StringHeader{
+		Data: uintptr(data),
+		Len:  length,
+	}))
+	return s, unsafe.Pointer(uintptr(data) + length)
+}
+
 // readVarint decodes a varint as used in the encoding/binary package.
 // It has an input pointer and returns the read varint and the pointer
 // incremented to the next field in the data structure, just after the varint.
@@ -246,3 +246,18 @@ func indexByteString(s string, c byte) int {
 	}
 	return -1
 }
+
+// itoa converts a non-negative integer to a decimal string.
+func itoa(n int) string {
+	if n == 0 {
+		return "0"
+	}
+	var buf [20]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = byte('0' + n%10)
+		n /= 10
+	}
+	return string(buf[i:])
+}
@@ -141,8 +141,69 @@ func PtrTo(t Type) Type {
 	return ptrType
 }
 
+// SliceOf returns the slice type with element type t.
+func SliceOf(t Type) Type {
+	sliceType := t<<5 | 7 // 0b0111 == 7
+	if sliceType>>5 != t {
+		panic("reflect: SliceOf type does not fit")
+	}
+	return sliceType
+}
+
+// String returns a string representation of the type, like "int" or
+// "*main.point". Function and interface types are not fully described.
 func (t Type) String() string {
-	return "T"
+	if pkgPath, name, ok := t.typeName(); ok {
+		// Named type. Use the last element of the package path as the package
+		// name.
+		for i := len(pkgPath) - 1; i >= 0; i-- {
+			if pkgPath[i] == '/' {
+				pkgPath = pkgPath[i+1:]
+				break
+			}
+		}
+		if pkgPath == "" {
+			return name
+		}
+		return pkgPath + "." + name
+	}
+	switch t.Kind() {
+	case Chan:
+		return "chan " + t.Elem().String()
+	case Interface:
+		return "interface {}"
+	case Ptr:
+		return "*" + t.Elem().String()
+	case Slice:
+		return "[]" + t.Elem().String()
+	case Array:
+		return "[" + itoa(t.Len()) + "]" + t.Elem().String()
+	case Func:
+		return "func"
+	case Map:
+		return "map[" + t.Key().String() + "]" + t.Elem().String()
+	case Struct:
+		numField := t.NumField()
+		if numField == 0 {
+			return "struct {}"
+		}
+		s := "struct {"
+		for i := 0; i < numField; i++ {
+			field := t.Field(i)
+			if i != 0 {
+				s += ";"
+			}
+			s += " "
+			if !field.Anonymous {
+				s += field.Name + " "
+			}
+			s += field.Type.String()
+		}
+		return s + " }"
+	default:
+		// Unnamed basic type.
+		return t.Kind().String()
+	}
 }
 
 func (t Type) Kind() Kind {
@@ -155,7 +216,7 @@ func (t Type) Kind() Kind {
 }
 
 // Elem returns the element type for channel, slice and array types, the
-// pointed-to value for pointer types, and the key type for map types.
+// pointed-to value for pointer types, and the value type for map types.
 func (t Type) Elem() Type {
 	switch t.Kind() {
 	case Chan, Ptr, Slice:
@@ -164,8 +225,14 @@ func (t Type) Elem() Type {
 		index := t.stripPrefix()
 		elem, _ := readVarint(unsafe.Pointer(uintptr(unsafe.Pointer(&arrayTypesSidetable)) + uintptr(index)))
 		return Type(elem)
-	default: // not implemented: Map
-		panic("unimplemented: (reflect.Type).Elem()")
+	case Map:
+		// skip past the key type
+		index := t.stripPrefix()
+		_, p := readVarint(unsafe.Pointer(uintptr(unsafe.Pointer(&mapTypesSidetable)) + uintptr(index)))
+		elem, _ := readVarint(p)
+		return Type(elem)
+	default:
+		panic(&TypeError{"Elem"})
 	}
 }
 
@@ -250,11 +317,10 @@ func (t Type) Field(i int) StructField {
 			// This field is exported.
 			field.PkgPath = ""
 		} else {
-			// This field is unexported.
-			// TODO: list the real package path here. Storing it should not
-			// significantly impact binary size as there is only a limited
-			// number of packages in any program.
-			field.PkgPath = "<unimplemented>"
+			// This field is unexported, so the package path follows.
+			var pkgPathNum uintptr
+			pkgPathNum, p = readVarint(p)
+			field.PkgPath = readStringSidetable(unsafe.Pointer(&structNamesSidetable), pkgPathNum)
 		}
 	}
 
@@ -339,7 +405,9 @@ func (t Type) Size() uintptr {
 			return 0
 		}
 		lastField := t.Field(numField - 1)
-		return lastField.Offset + lastField.Type.Size()
+		// Include the padding at the end of the struct, just like
+		// unsafe.Sizeof.
+		return align(lastField.Offset+lastField.Type.Size(), uintptr(t.Align()))
 	default:
 		panic("unimplemented: size of type")
 	}
@@ -400,23 +468,24 @@ func (t Type) FieldAlign() int {
 	return t.Align()
 }
 
-// AssignableTo returns whether a value of type u can be assigned to a variable
-// of type t.
+// AssignableTo returns whether a value of type t can be assigned to a variable
+// of type u.
 func (t Type) AssignableTo(u Type) bool {
 	if t == u {
 		return true
 	}
-	if t.Kind() == Interface {
-		panic("reflect: unimplemented: assigning to interface of different type")
+	if u.Kind() == Interface {
+		return t.implements(u)
 	}
 	return false
 }
 
+// Implements returns whether the type implements the interface type u.
 func (t Type) Implements(u Type) bool {
-	if t.Kind() != Interface {
+	if u.Kind() != Interface {
 		panic("reflect: non-interface type passed to Type.Implements")
 	}
-	return u.AssignableTo(t)
+	return t.implements(u)
 }
 
 // Comparable returns whether values of this type can be compared to each other.
@@ -457,20 +526,265 @@ func (t Type) Comparable() bool {
 	}
 }
 
+// ConvertibleTo returns whether a value of type t can be converted to type u.
 func (t Type) ConvertibleTo(u Type) bool {
-	panic("unimplemented: (reflect.Type).ConvertibleTo()")
+	if t == u || t.underlying() == u.underlying() {
+		return true
+	}
+	tk, uk := t.Kind(), u.Kind()
+	switch {
+	case uk == Interface:
+		return t.implements(u)
+	case tk == Ptr && uk == Ptr && !t.isNamed() && !u.isNamed():
+		return t.Elem().underlying() == u.Elem().underlying()
+	case tk >= Int && tk <= Float64 && uk >= Int && uk <= Float64:
+		// Integers and floats can be converted to each other.
+		return true
+	case (tk == Complex64 || tk == Complex128) && (uk == Complex64 || uk == Complex128):
+		return true
+	case uk == String && tk >= Int && tk <= Uintptr:
+		// Integer to string conversion.
+		return true
+	case uk == String && tk == Slice:
+		// Conversion from []byte or []rune to string.
+		elemKind := t.Elem().Kind()
+		return elemKind == Uint8 || elemKind == Int32
+	case tk == String && uk == Slice:
+		// Conversion from string to []byte or []rune.
+		elemKind := u.Elem().Kind()
+		return elemKind == Uint8 || elemKind == Int32
+	default:
+		return false
+	}
 }
 
-func (t Type) NumMethod() int {
-	panic("unimplemented: (reflect.Type).NumMethod()")
+// isNamed returns whether this is a named type.
+func (t Type) isNamed() bool {
+	if t%2 == 0 {
+		// Basic type. The upper bits indicate the named type number.
+		return t>>6 != 0
+	}
+	return (t>>4)%2 != 0
 }
 
+// underlying returns the underlying type of a (possibly named) type.
+func (t Type) underlying() Type {
+	if !t.isNamed() {
+		return t
+	}
+	if t%2 == 0 {
+		return t.Kind().basicType()
+	}
+	// Keep the kind bits, but replace the named type number with the contents
+	// of the underlying type.
+	return t.stripPrefix()<<5 | t%16
+}
+
+// typeName returns the package path and name of a named type, by looking it
+// up in the type names sidetable. The last return value is false if this is
+// not a named type.
+func (t Type) typeName() (pkgPath, name string, ok bool) {
+	if !t.isNamed() {
+		return "", "", false
+	}
+	p := unsafe.Pointer(&typeNamesSidetable)
+	for {
+		var typecode uintptr
+		typecode, p = readVarint(p)
+		if typecode == 0 {
+			// End of the table. This should not happen.
+			return "", "", false
+		}
+		pkgPath, p = readStringAt(p)
+		name, p = readStringAt(p)
+		if Type(typecode) == t {
+			return pkgPath, name, true
+		}
+	}
+}
+
+// Name returns the name of a named type, or the name of a predeclared type
+// such as "int". It returns the empty string for other unnamed types.
 func (t Type) Name() string {
-	panic("unimplemented: (reflect.Type).Name()")
+	if _, name, ok := t.typeName(); ok {
+		return name
+	}
+	switch kind := t.Kind(); {
+	case kind == UnsafePointer:
+		return "Pointer"
+	case kind < UnsafePointer:
+		return kind.String()
+	default:
+		return ""
+	}
+}
+
+// PkgPath returns the package path of a named type, or the empty string for
+// predeclared and unnamed types.
+func (t Type) PkgPath() string {
+	if pkgPath, _, ok := t.typeName(); ok {
+		return pkgPath
+	}
+	if t.Kind() == UnsafePointer {
+		return "unsafe"
+	}
+	return ""
+}
+
+// methodSet returns the number of exported methods of this type, the number of
+// unexported methods and a pointer to the first method in the method sets
+// sidetable. Exported methods come first, each sorted by signature.
+func (t Type) methodSet() (numExported, numUnexported uintptr, p unsafe.Pointer) {
+	p = unsafe.Pointer(&methodSetsSidetable)
+	for {
+		var typecode uintptr
+		typecode, p = readVarint(p)
+		if typecode == 0 {
+			// End of the table: this type has no methods.
+			return 0, 0, nil
+		}
+		numExported, p = readVarint(p)
+		numUnexported, p = readVarint(p)
+		if Type(typecode) == t {
+			return numExported, numUnexported, p
+		}
+		// Skip all methods of this type.
+		for i := uintptr(0); i < numExported+numUnexported; i++ {
+			_, p = readMethodAt(p)
+		}
+	}
+}
+
+// methodEntry is a single method as stored in the method sets sidetable.
+type methodEntry struct {
+	signature string  // like "Add(int) int"
+	typecode  uintptr // type code of the method as a func, or 0
+	funcIndex uintptr // index in methodFuncsSidetable, or 0
 }
 
+// readMethodAt reads a single method from the method sets sidetable and
+// returns it together with a pointer to the next method.
+func readMethodAt(p unsafe.Pointer) (methodEntry, unsafe.Pointer) {
+	var m methodEntry
+	m.signature, p = readStringAt(p)
+	m.typecode, p = readVarint(p)
+	m.funcIndex, p = readVarint(p)
+	return m, p
+}
+
+// name returns the method name, which is the part of the signature before the
+// opening parenthesis.
+func (m methodEntry) name() string {
+	for i := 0; i < len(m.signature); i++ {
+		if m.signature[i] == '(' {
+			return m.signature[:i]
+		}
+	}
+	return m.signature
+}
+
+// method returns the reflect.Method for this method entry.
+func (t Type) method(m methodEntry, index int) Method {
+	method := Method{
+		Name:  m.name(),
+		Index: index,
+	}
+	if m.typecode != 0 {
+		method.Type = Type(m.typecode)
+		code := *(*uintptr)(unsafe.Pointer(uintptr(unsafe.Pointer(&methodFuncsSidetable)) + m.funcIndex*unsafe.Sizeof(uintptr(0))))
+		method.Func = Value{
+			typecode: method.Type,
+			value:    unsafe.Pointer(&funcHeader{Code: unsafe.Pointer(code)}),
+			flags:    valueFlagExported,
+		}
+	}
+	return method
+}
+
+// implements returns whether all methods of interface type u are in the method
+// set of type t, which may also be an interface type.
+func (t Type) implements(u Type) bool {
+	uExported, uUnexported, up := u.methodSet()
+	if uExported+uUnexported == 0 {
+		// Every type implements the empty interface.
+		return true
+	}
+	tExported, tUnexported, tp := t.methodSet()
+	for i := uintptr(0); i < uExported+uUnexported; i++ {
+		var want methodEntry
+		want, up = readMethodAt(up)
+		found := false
+		p := tp
+		for j := uintptr(0); j < tExported+tUnexported; j++ {
+			var m methodEntry
+			m, p = readMethodAt(p)
+			if m.signature == want.signature {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// NumMethod returns the number of exported methods in the method set of this
+// type.
+func (t Type) NumMethod() int {
+	n, _, _ := t.methodSet()
+	return int(n)
+}
+
+// Method returns the i'th exported method in the method set of this type,
+// sorted by name. For interface types, the Type and Func fields are not set.
+func (t Type) Method(i int) Method {
+	n, _, p := t.methodSet()
+	if uint(i) >= uint(n) {
+		panic("reflect: Method index out of range")
+	}
+	var m methodEntry
+	for j := 0; j <= i; j++ {
+		m, p = readMethodAt(p)
+	}
+	return t.method(m, i)
+}
+
+// MethodByName returns the exported method with the given name in the method
+// set of this type, and whether it was found.
+func (t Type) MethodByName(name string) (Method, bool) {
+	n, _, p := t.methodSet()
+	for i := 0; i < int(n); i++ {
+		var m methodEntry
+		m, p = readMethodAt(p)
+		if m.name() == name {
+			return t.method(m, i), true
+		}
+	}
+	return Method{}, false
+}
+
+// Key returns the key type of a map type. It panics if the type kind is not
+// Map.
 func (t Type) Key() Type {
-	panic("unimplemented: (reflect.Type).Key()")
+	if t.Kind() != Map {
+		panic(&TypeError{"Key"})
+	}
+	index := t.stripPrefix()
+	key, _ := readVarint(unsafe.Pointer(uintptr(unsafe.Pointer(&mapTypesSidetable)) + uintptr(index)))
+	return Type(key)
+}
+
+// Method represents a single method. For concrete types, Type is the type of
+// the method as a function with the receiver as the first parameter and Func is
+// that function.
+type Method struct {
+	Name    string
+	PkgPath string // empty for exported methods
+	Type    Type
+	Func    Value
+	Index   int // index for Type.Method
 }
 
 // A StructField describes a single field in a struct.
@@ -51,6 +51,11 @@ func ValueOf(i interface{}) Value {
 }
 
 func (v Value) Interface() interface{} {
+	if v.Kind() == Interface {
+		// The value is a pointer to an interface (for example, an element of
+		// a map[string]interface{}), return that interface directly.
+		return *(*interface{})(v.value)
+	}
 	if v.isIndirect() && v.Type().Size() <= unsafe.Sizeof(uintptr(0)) {
 		// Value was indirect but must be put back directly in the interface
 		// value.
@@ -122,6 +127,14 @@ func (v Value) Pointer() uintptr {
 	}
 }
 
+// pointer returns the underlying pointer of a channel, map or pointer value.
+func (v Value) pointer() unsafe.Pointer {
+	if v.isIndirect() {
+		return *(*unsafe.Pointer)(v.value)
+	}
+	return v.value
+}
+
 func (v Value) IsValid() bool {
 	return v.typecode != 0
 }
@@ -131,12 +144,23 @@ func (v Value) CanInterface() bool {
 	return true
 }
 
+// CanAddr returns whether the address of this value can be taken with Addr.
+// This is the case for slice elements and values obtained by dereferencing a
+// pointer, and fields of those values.
 func (v Value) CanAddr() bool {
-	panic("unimplemented: (reflect.Value).CanAddr()")
+	return v.isIndirect()
 }
 
+// Addr returns a pointer to this value. It panics if CanAddr returns false.
 func (v Value) Addr() Value {
-	panic("unimplemented: (reflect.Value).Addr()")
+	if !v.CanAddr() {
+		panic("reflect: unaddressable value")
+	}
+	return Value{
+		typecode: PtrTo(v.Type()),
+		value:    v.value,
+		flags:    v.flags &^ valueFlagIndirect,
+	}
 }
 
 func (v Value) CanSet() bool {
@@ -296,12 +320,65 @@ func (v Value) String() string {
 	}
 }
 
+// Bytes returns the underlying byte slice of this value. It panics if the
+// value is not a byte slice.
 func (v Value) Bytes() []byte {
-	panic("unimplemented: (reflect.Value).Bytes()")
+	if v.Kind() != Slice || v.Type().Elem().Kind() != Uint8 {
+		panic(&ValueError{"Bytes"})
+	}
+	return *(*[]byte)(v.value)
 }
 
+// Slice returns v[i:j] for slices, strings and addressable arrays.
 func (v Value) Slice(i, j int) Value {
-	panic("unimplemented: (reflect.Value).Slice()")
+	switch v.Kind() {
+	case Slice:
+		hdr := *(*SliceHeader)(v.value)
+		if i < 0 || j < i || uintptr(j) > hdr.Cap {
+			panic("reflect: slice index out of bounds")
+		}
+		elemSize := v.Type().Elem().Size()
+		hdr.Data += elemSize * uintptr(i)
+		hdr.Len = uintptr(j - i)
+		hdr.Cap -= uintptr(i)
+		return Value{
+			typecode: v.typecode,
+			value:    unsafe.Pointer(&hdr),
+			flags:    v.flags &^ valueFlagIndirect,
+		}
+	case String:
+		s := *(*string)(v.value)
+		if i < 0 || j < i || j > len(s) {
+			panic("reflect: string index out of bounds")
+		}
+		s = s[i:j]
+		return Value{
+			typecode: v.typecode,
+			value:    unsafe.Pointer(&s),
+			flags:    v.flags &^ valueFlagIndirect,
+		}
+	case Array:
+		if !v.CanAddr() {
+			panic("reflect: slice of unaddressable array")
+		}
+		length := v.Type().Len()
+		if i < 0 || j < i || j > length {
+			panic("reflect: array index out of bounds")
+		}
+		elemType := v.Type().Elem()
+		hdr := SliceHeader{
+			Data: uintptr(v.value) + elemType.Size()*uintptr(i),
+			Len:  uintptr(j - i),
+			Cap:  uintptr(length - i),
+		}
+		return Value{
+			typecode: SliceOf(elemType),
+			value:    unsafe.Pointer(&hdr),
+			flags:    v.flags &^ valueFlagIndirect,
+		}
+	default:
+		panic(&ValueError{"Slice"})
+	}
 }
 
 //go:linkname maplen runtime.hashmapLenUnsafePointer
@@ -318,9 +395,9 @@ func (v Value) Len() int {
 	case Array:
 		return v.Type().Len()
 	case Chan:
-		return chanlen(v.value)
+		return chanlen(v.pointer())
 	case Map:
-		return maplen(v.value)
+		return maplen(v.pointer())
 	case Slice:
 		return int((*SliceHeader)(v.value).Len)
 	case String:
@@ -341,7 +418,7 @@ func (v Value) Cap() int {
 	case Array:
 		return v.Type().Len()
 	case Chan:
-		return chancap(v.value)
+		return chancap(v.pointer())
 	case Slice:
 		return int((*SliceHeader)(v.value).Cap)
 	default:
@@ -355,6 +432,12 @@ func (v Value) NumField() int {
 	return v.Type().NumField()
 }
 
+// NumMethod returns the number of exported methods in the method set of this
+// value.
+func (v Value) NumMethod() int {
+	return v.Type().NumMethod()
+}
+
 func (v Value) Elem() Value {
 	switch v.Kind() {
 	case Ptr:
@@ -474,11 +557,11 @@ func (v Value) Index(i int) Value {
 				flags:    v.flags,
 			}
 		}
-		if elemSize > unsafe.Sizeof(uintptr(0)) {
-			// The resulting value doesn't fit in a pointer so must be
-			// indirect. Also, because size != 0 this implies that the array
-			// length must be != 0, and thus that the total size is at least
-			// elemSize.
+		if v.isIndirect() || elemSize > unsafe.Sizeof(uintptr(0)) {
+			// The array is addressable or the resulting value doesn't fit in a
+			// pointer, so the element must be indirect. Also, because
+			// size != 0 this implies that the array length must be != 0, and
+			// thus that the total size is at least elemSize.
 			addr := uintptr(v.value) + elemSize*uintptr(i) // pointer to new value
 			return Value{
 				typecode: v.Type().Elem(),
@@ -532,36 +615,265 @@ func maskAndShift(value, offset, size uintptr) uintptr {
 	return (uintptr(value) >> (offset * 8)) & mask
 }
 
+//go:linkname alloc runtime.alloc
+func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer
+
+// valuePointer returns a pointer to the contents of this value. This may be a
+// pointer to a copy of the value if the value is stored directly in the Value
+// struct.
+func (v Value) valuePointer() unsafe.Pointer {
+	if v.isIndirect() || v.Type().Size() > unsafe.Sizeof(uintptr(0)) {
+		return v.value
+	}
+	value := v.value
+	return unsafe.Pointer(&value)
+}
+
+// loadValueFromPointer returns a (non-addressable) Value of the given type for
+// the value stored at the given pointer. The memory pointed to must not be
+// modified afterwards, as the returned Value may still refer to it.
+func loadValueFromPointer(typ Type, ptr unsafe.Pointer) Value {
+	size := typ.Size()
+	if size > unsafe.Sizeof(uintptr(0)) {
+		// The value doesn't fit in a pointer, so refer to the value instead.
+		return Value{
+			typecode: typ,
+			value:    ptr,
+			flags:    valueFlagExported,
+		}
+	}
+	return Value{
+		typecode: typ,
+		value:    unsafe.Pointer(loadValue(ptr, size)),
+		flags:    valueFlagExported,
+	}
+}
+
+//go:linkname hashmapMake runtime.hashmapMakeUnsafePointer
+func hashmapMake(keySize, valueSize uint8, sizeHint uintptr, bucketLayout unsafe.Pointer) unsafe.Pointer
+
+//go:linkname hashmapNext runtime.hashmapNextUnsafePointer
+func hashmapNext(m unsafe.Pointer, it unsafe.Pointer, key, value unsafe.Pointer) bool
+
+//go:linkname hashmapBinarySet runtime.hashmapBinarySetUnsafePointer
+func hashmapBinarySet(m unsafe.Pointer, key, value unsafe.Pointer)
+
+//go:linkname hashmapBinaryGet runtime.hashmapBinaryGetUnsafePointer
+func hashmapBinaryGet(m unsafe.Pointer, key, value unsafe.Pointer, valueSize uintptr) bool
+
+//go:linkname hashmapBinaryDelete runtime.hashmapBinaryDeleteUnsafePointer
+func hashmapBinaryDelete(m unsafe.Pointer, key unsafe.Pointer)
+
+//go:linkname hashmapStringSet runtime.hashmapStringSetUnsafePointer
+func hashmapStringSet(m unsafe.Pointer, key string, value unsafe.Pointer)
+
+//go:linkname hashmapStringGet runtime.hashmapStringGetUnsafePointer
+func hashmapStringGet(m unsafe.Pointer, key string, value unsafe.Pointer, valueSize uintptr) bool
+
+//go:linkname hashmapStringDelete runtime.hashmapStringDeleteUnsafePointer
+func hashmapStringDelete(m unsafe.Pointer, key string)
+
+//go:linkname hashmapInterfaceSet runtime.hashmapInterfaceSetUnsafePointer
+func hashmapInterfaceSet(m unsafe.Pointer, key interface{}, value unsafe.Pointer)
+
+//go:linkname hashmapInterfaceGet runtime.hashmapInterfaceGetUnsafePointer
+func hashmapInterfaceGet(m unsafe.Pointer, key interface{}, value unsafe.Pointer, valueSize uintptr) bool
+
+//go:linkname hashmapInterfaceDelete runtime.hashmapInterfaceDeleteUnsafePointer
+func hashmapInterfaceDelete(m unsafe.Pointer, key interface{})
+
+// The way keys are stored in a map. This must match the way the compiler
+// selects the hashmap functions to call, see compiler/map.go.
+const (
+	mapKeyBinary    = iota // compared with memequal
+	mapKeyString           // compared as strings
+	mapKeyInterface        // stored and compared as interface{}
+)
+
+// mapKeyFormat returns how keys of the given type are stored in a map.
+func mapKeyFormat(keyType Type) int {
+	if keyType.Kind() == String {
+		return mapKeyString
+	}
+	if isBinaryMapKey(keyType) {
+		return mapKeyBinary
+	}
+	return mapKeyInterface
+}
+
+// isBinaryMapKey returns true if this key type does not contain strings,
+// interfaces etc., so can be compared with runtime.memequal. It mirrors
+// hashmapIsBinaryKey in the compiler.
+func isBinaryMapKey(keyType Type) bool {
+	switch keyType.Kind() {
+	case Bool, Int, Int8, Int16, Int32, Int64, Uint, Uint8, Uint16, Uint32, Uint64, Uintptr:
+		return true
+	case Ptr:
+		return true
+	case Struct:
+		numField := keyType.NumField()
+		for i := 0; i < numField; i++ {
+			if !isBinaryMapKey(keyType.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	case Array:
+		return isBinaryMapKey(keyType.Elem())
+	default:
+		return false
+	}
+}
+
+// mapKeySize returns the size of a key as it is stored in the map.
+func mapKeySize(keyType Type) uintptr {
+	if mapKeyFormat(keyType) == mapKeyInterface {
+		return unsafe.Sizeof(interface{}(nil))
+	}
+	return keyType.Size()
+}
+
+// mapKeyLayout returns the object layout of a key as stored in a hashmap.
+func mapKeyLayout(keyType Type) unsafe.Pointer {
+	if mapKeyFormat(keyType) == mapKeyInterface {
+		return TypeOf((*interface{})(nil)).Elem().gcLayout()
+	}
+	return keyType.gcLayout()
+}
+
+// checkMapKey panics if the given key cannot be used as a key in the map v.
+func (v Value) checkMapKey(key Value) {
+	keyType := v.Type().Key()
+	if keyType.Kind() != Interface && key.Type() != keyType {
+		panic("reflect: map key has the wrong type")
+	}
+}
+
+// MapKeys returns a slice with all keys in this map, in unspecified order. It
+// panics if v is not a map.
 func (v Value) MapKeys() []Value {
-	panic("unimplemented: (reflect.Value).MapKeys()")
+	if v.Kind() != Map {
+		panic(&ValueError{"MapKeys"})
+	}
+	keys := make([]Value, 0, v.Len())
+	it := v.MapRange()
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	return keys
 }
 
+// MapIndex returns the value associated with key in the map v. It returns the
+// zero Value if the key is not present in the map.
 func (v Value) MapIndex(key Value) Value {
-	panic("unimplemented: (reflect.Value).MapIndex()")
+	if v.Kind() != Map {
+		panic(&ValueError{"MapIndex"})
+	}
+	v.checkMapKey(key)
+	m := v.pointer()
+	if m == nil {
+		// Looking up a key in a nil map is allowed.
+		return Value{}
+	}
+
+	keyType := v.Type().Key()
+	elemType := v.Type().Elem()
+	elemSize := elemType.Size()
+	elem := alloc(elemSize, elemType.gcLayout())
+	var ok bool
+	switch mapKeyFormat(keyType) {
+	case mapKeyString:
+		ok = hashmapStringGet(m, key.String(), elem, elemSize)
+	case mapKeyBinary:
+		ok = hashmapBinaryGet(m, key.valuePointer(), elem, elemSize)
+	default:
+		ok = hashmapInterfaceGet(m, key.Interface(), elem, elemSize)
+	}
+	if !ok {
+		return Value{}
+	}
+	return loadValueFromPointer(elemType, elem)
 }
 
+// MapRange returns an iterator over the map v. It panics if v is not a map.
 func (v Value) MapRange() *MapIter {
-	panic("unimplemented: (reflect.Value).MapRange()")
+	if v.Kind() != Map {
+		panic(&ValueError{"MapRange"})
+	}
+	return &MapIter{m: v}
 }
 
+// hashmapIterator is a copy of runtime.hashmapIterator and must be kept in
+// sync with it.
+type hashmapIterator struct {
+	bucketNumber uintptr
+	bucket       unsafe.Pointer
+	bucketIndex  uint8
+}
+
+// A MapIter is an iterator over a map. See Value.MapRange.
 type MapIter struct {
+	m     Value
+	it    hashmapIterator
+	key   Value
+	value Value
+	valid bool
 }
 
+// Key returns the key of the current map entry.
 func (it *MapIter) Key() Value {
-	panic("unimplemented: (*reflect.MapIter).Key()")
+	if !it.valid {
+		panic("reflect: MapIter.Key called before Next")
+	}
+	return it.key
 }
 
+// Value returns the value of the current map entry.
 func (it *MapIter) Value() Value {
-	panic("unimplemented: (*reflect.MapIter).Value()")
+	if !it.valid {
+		panic("reflect: MapIter.Value called before Next")
+	}
+	return it.value
 }
 
+// Next advances the iterator to the next map entry. It returns false when
+// there are no more entries in the map.
 func (it *MapIter) Next() bool {
-	panic("unimplemented: (*reflect.MapIter).Next()")
+	m := it.m.pointer()
+	keyType := it.m.Type().Key()
+	elemType := it.m.Type().Elem()
+
+	// Allocate new buffers for every entry, as previously returned values may
+	// still refer to the old buffers.
+	key := alloc(mapKeySize(keyType), mapKeyLayout(keyType))
+	elem := alloc(elemType.Size(), elemType.gcLayout())
+	if !hashmapNext(m, unsafe.Pointer(&it.it), key, elem) {
+		it.key = Value{}
+		it.value = Value{}
+		it.valid = false
+		return false
+	}
+
+	if mapKeyFormat(keyType) == mapKeyInterface && keyType.Kind() != Interface {
+		// The key was converted to an interface when it was stored in the
+		// map, so convert it back.
+		it.key = ValueOf(*(*interface{})(key))
+	} else {
+		it.key = loadValueFromPointer(keyType, key)
+	}
+	it.value = loadValueFromPointer(elemType, elem)
+	it.valid = true
+	return true
 }
 
 func (v Value) Set(x Value) {
 	v.checkAddressable()
-	if !v.Type().AssignableTo(x.Type()) {
+	if v.Kind() == Interface && x.Kind() != Interface {
+		// Store the value in the interface.
+		*(*interface{})(v.value) = x.Interface()
+		return
+	}
+	if !x.Type().AssignableTo(v.Type()) {
 		panic("reflect: cannot set")
 	}
 	size := v.Type().Size()
@@ -673,16 +985,54 @@ func (v Value) Convert(t Type) Value {
 	panic("unimplemented: (reflect.Value).Convert()")
 }
 
+// MakeSlice creates a new zero-initialized slice value of the specified slice
+// type, length, and capacity.
 func MakeSlice(typ Type, len, cap int) Value {
-	panic("unimplemented: reflect.MakeSlice()")
+	if typ.Kind() != Slice {
+		panic("reflect.MakeSlice of non-slice type")
+	}
+	if len < 0 || cap < len {
+		panic("reflect.MakeSlice: len out of range")
+	}
+	elem := typ.Elem()
+	hdr := SliceHeader{
+		Data: uintptr(alloc(elem.Size()*uintptr(cap), elem.gcLayout())),
+		Len:  uintptr(len),
+		Cap:  uintptr(cap),
+	}
+	return Value{
+		typecode: typ,
+		value:    unsafe.Pointer(&hdr),
+		flags:    valueFlagExported,
+	}
 }
 
+// Zero returns a Value representing the zero value for the specified type. The
+// returned value is neither addressable nor settable.
 func Zero(typ Type) Value {
-	panic("unimplemented: reflect.Zero()")
+	if typ.Size() <= unsafe.Sizeof(uintptr(0)) {
+		// The zero value is stored directly in the Value.
+		return Value{
+			typecode: typ,
+			value:    nil,
+			flags:    valueFlagExported,
+		}
+	}
+	return Value{
+		typecode: typ,
+		value:    alloc(typ.Size(), typ.gcLayout()),
+		flags:    valueFlagExported,
+	}
 }
 
+// New returns a Value representing a pointer to a new zero value for the
+// specified type.
 func New(typ Type) Value {
-	panic("unimplemented: reflect.New()")
+	return Value{
+		typecode: PtrTo(typ),
+		value:    alloc(typ.Size(), typ.gcLayout()),
+		flags:    valueFlagExported,
+	}
 }
 
 type funcHeader struct {
@@ -716,17 +1066,137 @@ func memcpy(dst, src unsafe.Pointer, size uintptr)
 // Copy copies the contents of src into dst until either
 // dst has been filled or src has been exhausted.
 func Copy(dst, src Value) int {
-	panic("unimplemented: reflect.Copy()")
+	var dstData, dstLen uintptr
+	switch dst.Kind() {
+	case Slice:
+		hdr := (*SliceHeader)(dst.value)
+		dstData, dstLen = hdr.Data, hdr.Len
+	case Array:
+		dst.checkAddressable()
+		dstData, dstLen = uintptr(dst.value), uintptr(dst.Type().Len())
+	default:
+		panic(&ValueError{"Copy"})
+	}
+	elemType := dst.Type().Elem()
+
+	var srcData, srcLen uintptr
+	switch src.Kind() {
+	case Slice:
+		hdr := (*SliceHeader)(src.value)
+		srcData, srcLen = hdr.Data, hdr.Len
+	case Array:
+		srcData, srcLen = uintptr(src.valuePointer()), uintptr(src.Type().Len())
+	case String:
+		if elemType.Kind() != Uint8 {
+			panic("reflect.Copy: string source requires a byte slice destination")
+		}
+		hdr := (*StringHeader)(src.value)
+		srcData, srcLen = hdr.Data, hdr.Len
+	default:
+		panic(&ValueError{"Copy"})
+	}
+	if src.Kind() != String && src.Type().Elem() != elemType {
+		panic("reflect.Copy: element types differ")
+	}
+
+	return sliceCopy(unsafe.Pointer(dstData), unsafe.Pointer(srcData), dstLen, srcLen, elemType.Size())
 }
 
+//go:linkname sliceCopy runtime.sliceCopy
+func sliceCopy(dst, src unsafe.Pointer, dstLen, srcLen uintptr, elemSize uintptr) int
+
+//go:linkname sliceAppend runtime.sliceAppend
+func sliceAppend(srcBuf, elemsBuf unsafe.Pointer, srcLen, srcCap, elemsLen uintptr, elemSize uintptr, elemLayout unsafe.Pointer) (unsafe.Pointer, uintptr, uintptr)
+
 // Append appends the values x to a slice s and returns the resulting slice.
 // As in Go, each x's value must be assignable to the slice's element type.
 func Append(s Value, x ...Value) Value {
-	panic("unimplemented: reflect.Append()")
+	if s.Kind() != Slice {
+		panic(&ValueError{"Append"})
+	}
+	elemType := s.Type().Elem()
+	elemSize := elemType.Size()
+	elemLayout := elemType.gcLayout()
+
+	// Store all values in a temporary buffer, so that they can be appended all
+	// at once.
+	elems := alloc(elemSize*uintptr(len(x)), elemLayout)
+	for i, v := range x {
+		elem := Value{
+			typecode: elemType,
+			value:    unsafe.Pointer(uintptr(elems) + elemSize*uintptr(i)),
+			flags:    valueFlagExported | valueFlagIndirect,
+		}
+		elem.Set(v)
+	}
+
+	hdr := *(*SliceHeader)(s.value)
+	data, length, capacity := sliceAppend(unsafe.Pointer(hdr.Data), elems, hdr.Len, hdr.Cap, uintptr(len(x)), elemSize, elemLayout)
+	hdr = SliceHeader{
+		Data: uintptr(data),
+		Len:  length,
+		Cap:  capacity,
+	}
+	return Value{
+		typecode: s.typecode,
+		value:    unsafe.Pointer(&hdr),
+		flags:    s.flags &^ valueFlagIndirect,
+	}
 }
 
+// SetMapIndex sets the element associated with key in the map v to elem. If
+// elem is the zero Value, the key is deleted from the map instead.
 func (v Value) SetMapIndex(key, elem Value) {
-	panic("unimplemented: (reflect.Value).SetMapIndex()")
+	if v.Kind() != Map {
+		panic(&ValueError{"SetMapIndex"})
+	}
+	if v.flags&valueFlagExported == 0 {
+		panic("reflect: cannot modify map obtained from unexported field")
+	}
+	v.checkMapKey(key)
+	m := v.pointer()
+	keyType := v.Type().Key()
+
+	if !elem.IsValid() {
+		// Delete the key from the map. This is a no-op for nil maps.
+		if m == nil {
+			return
+		}
+		switch mapKeyFormat(keyType) {
+		case mapKeyString:
+			hashmapStringDelete(m, key.String())
+		case mapKeyBinary:
+			hashmapBinaryDelete(m, key.valuePointer())
+		default:
+			hashmapInterfaceDelete(m, key.Interface())
+		}
+		return
+	}
+
+	if m == nil {
+		panic("assignment to entry in nil map")
+	}
+	elemType := v.Type().Elem()
+	var elemPtr unsafe.Pointer
+	if elemType.Kind() == Interface && elem.Kind() != Interface {
+		// The map stores interfaces, so convert the value to an interface
+		// first.
+		itf := elem.Interface()
+		elemPtr = unsafe.Pointer(&itf)
+	} else {
+		if !elem.Type().AssignableTo(elemType) {
+			panic("reflect: cannot set")
+		}
+		elemPtr = elem.valuePointer()
+	}
+	switch mapKeyFormat(keyType) {
+	case mapKeyString:
+		hashmapStringSet(m, key.String(), elemPtr)
+	case mapKeyBinary:
+		hashmapBinarySet(m, key.valuePointer(), elemPtr)
+	default:
+		hashmapInterfaceSet(m, key.Interface(), elemPtr)
+	}
 }
 
 // FieldByIndex returns the nested field corresponding to index.
@@ -740,5 +1210,14 @@ func (v Value) FieldByName(name string) Value {
 
 // MakeMap creates a new map with the specified type.
 func MakeMap(typ Type) Value {
-	panic("unimplemented: reflect.MakeMap()")
+	if typ.Kind() != Map {
+		panic(&ValueError{"MakeMap"})
+	}
+	keySize := mapKeySize(typ.Key())
+	elemSize := typ.Elem().Size()
+	return Value{
+		typecode: typ,
+		value:    hashmapMake(uint8(keySize), uint8(elemSize), 8, mapBucketLayout(typ.Key(), typ.Elem())),
+		flags:    valueFlagExported,
+	}
 }
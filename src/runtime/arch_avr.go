@@ -7,6 +7,10 @@ const GOARCH = "arm" // avr pretends to be arm
 // The bitness of the CPU (e.g. 8, 32, 64).
 const TargetBits = 8
 
+// cInt is the Go equivalent of the C int type (C.int), which is only 16 bits on
+// AVR.
+type cInt = int16
+
 // Align on a word boundary.
 func align(ptr uintptr) uintptr {
 	// No alignment necessary on the AVR.
@@ -50,3 +50,86 @@ func growHeap() bool {
 	// Heap has grown successfully.
 	return true
 }
+
+// The GC heap grows the linear memory, so the malloc of wasi-libc (which does
+// the same) can't be used next to it. Instead, libc allocates from the GC heap
+// through these functions, like on baremetal systems. C code may store pointers
+// anywhere in the returned memory, so the layout is not known and the objects
+// are scanned conservatively.
+//
+// The GC can't see pointers that are only stored in C globals, so all blocks
+// are kept in the allocs table until they are freed. This also records their
+// sizes for realloc.
+var allocs map[uintptr][]byte
+
+// mallocBlock allocates a block for libc and records it in allocs.
+func mallocBlock(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
+	if size == 0 {
+		// Returning nil is allowed, and avoids handing out the same zero-sized
+		// object twice.
+		return nil
+	}
+	ptr := alloc(size, layout)
+	if allocs == nil {
+		allocs = make(map[uintptr][]byte)
+	}
+	allocs[uintptr(ptr)] = (*[1 << 30]byte)(ptr)[:size:size]
+	return ptr
+}
+
+//export malloc
+func libc_malloc(size uintptr) unsafe.Pointer {
+	return mallocBlock(size, nil)
+}
+
+//export calloc
+func libc_calloc(nmemb, size uintptr) unsafe.Pointer {
+	if size != 0 && nmemb > ^uintptr(0)/size {
+		return nil // overflow
+	}
+	// Memory from alloc is already zeroed.
+	return mallocBlock(nmemb*size, nil)
+}
+
+//export realloc
+func libc_realloc(oldPtr unsafe.Pointer, size uintptr) unsafe.Pointer {
+	var oldBuf []byte
+	if oldPtr != nil {
+		var ok bool
+		oldBuf, ok = allocs[uintptr(oldPtr)]
+		if !ok {
+			runtimePanic("realloc: invalid pointer")
+		}
+	}
+	if size == 0 {
+		libc_free(oldPtr)
+		return nil
+	}
+	// Blocks can't be resized in place, so copy the contents (as far as they
+	// fit) to a new block.
+	ptr := mallocBlock(size, nil)
+	copy((*[1 << 30]byte)(ptr)[:size:size], oldBuf)
+	if oldPtr != nil {
+		delete(allocs, uintptr(oldPtr))
+	}
+	return ptr
+}
+
+//export free
+func libc_free(ptr unsafe.Pointer) {
+	if ptr == nil {
+		return
+	}
+	if _, ok := allocs[uintptr(ptr)]; !ok {
+		runtimePanic("free: invalid pointer")
+	}
+	// The GC can collect the block once it isn't referenced anymore.
+	delete(allocs, uintptr(ptr))
+}
+
+// cgoMalloc allocates memory for C.CString and C.CBytes. It is allocated like
+// libc_malloc, so that it can be freed with C.free. The memory only holds bytes
+// copied from Go, so it is known to be pointer-free.
+func cgoMalloc(size uintptr) unsafe.Pointer {
+	return mallocBlock(size, gcLayoutNoPointers)
+}
@@ -0,0 +1,9 @@
+// +build baremetal nintendoswitch wasm,!wasi
+
+package runtime
+
+// platformArgs returns a fake program name, as there are no command line
+// arguments on this system.
+func platformArgs() []string {
+	return []string{"/proc/self/exe"}
+}
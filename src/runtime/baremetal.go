@@ -38,7 +38,16 @@ func growHeap() bool {
 
 //export malloc
 func libc_malloc(size uintptr) unsafe.Pointer {
-	return alloc(size)
+	// C code may store pointers anywhere in the returned memory, so the layout
+	// is not known and the object is scanned conservatively.
+	return alloc(size, nil)
+}
+
+// cgoMalloc allocates memory for C.CString and C.CBytes. There is no separate
+// libc heap on baremetal systems, so it is allocated on the GC heap. The
+// memory only holds bytes copied from Go, so it is known to be pointer-free.
+func cgoMalloc(size uintptr) unsafe.Pointer {
+	return alloc(size, gcLayoutNoPointers)
 }
 
 //export free
@@ -48,7 +57,7 @@ func libc_free(ptr unsafe.Pointer) {
 
 //go:linkname syscall_Exit syscall.Exit
 func syscall_Exit(code int) {
-	abort()
+	exit(code)
 }
 
 const baremetal = true
@@ -0,0 +1,11 @@
+// +build baremetal
+// +build !cortexm !qemu
+// +build !virt !qemu
+
+package runtime
+
+// exit is called by os.Exit. Most baremetal systems have nowhere to report the
+// exit code to, so they abort instead.
+func exit(code int) {
+	abort()
+}
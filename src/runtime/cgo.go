@@ -0,0 +1,69 @@
+package runtime
+
+// This file implements the helper functions of CGo, like C.CString and
+// C.GoString. They are declared by the cgo package in every package that uses
+// them, and linked to the implementations below. Because they are called as C
+// functions, they must be exported and their signatures must match the
+// declarations in cgo.go of the cgo package.
+//
+// Memory returned by C.CString and C.CBytes is allocated with malloc from libc
+// where one is available, and must then be freed by the caller with C.free.
+// On other systems it is allocated on the garbage collected heap, and the
+// caller must keep a reference to it in Go for as long as it is used.
+
+import "unsafe"
+
+//export tinygo_cgo_CString
+func cgo_CString(s string) *byte {
+	buf := cgoMalloc(uintptr(len(s)) + 1)
+	memcpy(buf, unsafe.Pointer((*_string)(unsafe.Pointer(&s)).ptr), uintptr(len(s)))
+	*(*byte)(unsafe.Pointer(uintptr(buf) + uintptr(len(s)))) = 0
+	return (*byte)(buf)
+}
+
+//export tinygo_cgo_CBytes
+func cgo_CBytes(b []byte) unsafe.Pointer {
+	buf := cgoMalloc(uintptr(len(b)))
+	if len(b) != 0 {
+		memcpy(buf, unsafe.Pointer(&b[0]), uintptr(len(b)))
+	}
+	return buf
+}
+
+//export tinygo_cgo_GoString
+func cgo_GoString(cstr *byte) string {
+	if cstr == nil {
+		return ""
+	}
+	length := uintptr(0)
+	for *(*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(cstr)) + length)) != 0 {
+		length++
+	}
+	return cgoGoStringN(cstr, length)
+}
+
+//export tinygo_cgo_GoStringN
+func cgo_GoStringN(cstr *byte, length cInt) string {
+	if cstr == nil || length <= 0 {
+		return ""
+	}
+	return cgoGoStringN(cstr, uintptr(length))
+}
+
+// cgoGoStringN copies length bytes starting at cstr into a new Go string.
+func cgoGoStringN(cstr *byte, length uintptr) string {
+	buf := alloc(length, gcLayoutNoPointers)
+	memcpy(buf, unsafe.Pointer(cstr), length)
+	s := _string{ptr: (*byte)(buf), length: length}
+	return *(*string)(unsafe.Pointer(&s))
+}
+
+//export tinygo_cgo_GoBytes
+func cgo_GoBytes(ptr unsafe.Pointer, length cInt) []byte {
+	if ptr == nil || length <= 0 {
+		return []byte{}
+	}
+	buf := make([]byte, length)
+	memcpy(unsafe.Pointer(&buf[0]), ptr, uintptr(length))
+	return buf
+}
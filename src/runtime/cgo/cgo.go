@@ -1,3 +1,5 @@
+// Package cgo only exists for compatibility with the standard Go toolchain. It
+// contains no code: the CGo helper functions (C.CString, C.GoString,
+// C.GoStringN, C.GoBytes and C.CBytes) are declared by the cgo package of the
+// compiler and implemented in the runtime package.
 package cgo
-
-// dummy
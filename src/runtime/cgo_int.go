@@ -0,0 +1,7 @@
+// +build !avr
+
+package runtime
+
+// cInt is the Go equivalent of the C int type (C.int), which is 32 bits on
+// all supported targets except AVR.
+type cInt = int32
@@ -128,12 +128,13 @@ type channel struct {
 }
 
 // chanMake creates a new channel with the given element size and buffer length in number of elements.
+// The elementLayout is the object layout of a single element, used to allocate the buffer.
 // This is a compiler intrinsic.
-func chanMake(elementSize uintptr, bufSize uintptr) *channel {
+func chanMake(elementSize uintptr, bufSize uintptr, elementLayout unsafe.Pointer) *channel {
 	return &channel{
 		elementSize: elementSize,
 		bufSize:     bufSize,
-		buf:         alloc(elementSize * bufSize),
+		buf:         alloc(elementSize*bufSize, elementLayout),
 	}
 }
 
@@ -484,6 +485,7 @@ func chanSend(ch *channel, value unsafe.Pointer, blockedlist *channelBlockedList
 	sender := task.Current()
 	ch.state = chanStateSend
 	sender.Ptr = value
+	sender.SetWaitReason(waitReasonChanSend, unsafe.Pointer(ch))
 	*blockedlist = channelBlockedList{
 		next: ch.blocked,
 		t:    sender,
@@ -493,6 +495,7 @@ func chanSend(ch *channel, value unsafe.Pointer, blockedlist *channelBlockedList
 	interrupt.Restore(i)
 	task.Pause()
 	sender.Ptr = nil
+	sender.SetWaitReason(waitReasonNone, nil)
 }
 
 // chanRecv receives a single value over a channel.
@@ -519,6 +522,7 @@ func chanRecv(ch *channel, value unsafe.Pointer, blockedlist *channelBlockedList
 	receiver := task.Current()
 	ch.state = chanStateRecv
 	receiver.Ptr, receiver.Data = value, 1
+	receiver.SetWaitReason(waitReasonChanRecv, unsafe.Pointer(ch))
 	*blockedlist = channelBlockedList{
 		next: ch.blocked,
 		t:    receiver,
@@ -529,6 +533,7 @@ func chanRecv(ch *channel, value unsafe.Pointer, blockedlist *channelBlockedList
 	task.Pause()
 	ok := receiver.Data == 1
 	receiver.Ptr, receiver.Data = nil, 0
+	receiver.SetWaitReason(waitReasonNone, nil)
 	return ok
 }
 
@@ -582,6 +587,9 @@ func chanSelect(recvbuf unsafe.Pointer, states []chanSelectState, ops []channelB
 	}
 
 	// construct blocked operations
+	t := task.Current()
+	t.SetWaitReason(waitReasonForever, nil)
+	blocked := false
 	for i, v := range states {
 		if v.ch == nil {
 			// A nil channel receive will never complete.
@@ -592,11 +600,16 @@ func chanSelect(recvbuf unsafe.Pointer, states []chanSelectState, ops []channelB
 
 		ops[i] = channelBlockedList{
 			next:         v.ch.blocked,
-			t:            task.Current(),
+			t:            t,
 			s:            &states[i],
 			allSelectOps: ops,
 		}
 		v.ch.blocked = &ops[i]
+		if !blocked {
+			// Any of the blocked operations gives access to all of them.
+			t.SetWaitReason(waitReasonSelect, unsafe.Pointer(&ops[i]))
+			blocked = true
+		}
 		if v.value == nil {
 			// recv
 			switch v.ch.state {
@@ -626,13 +639,13 @@ func chanSelect(recvbuf unsafe.Pointer, states []chanSelectState, ops []channelB
 	}
 
 	// expose rx buffer
-	t := task.Current()
 	t.Ptr = recvbuf
 	t.Data = 1
 
 	// wait for one case to fire
 	interrupt.Restore(istate)
 	task.Pause()
+	t.SetWaitReason(waitReasonNone, nil)
 
 	// figure out which one fired and return the ok value
 	return (uintptr(t.Ptr) - uintptr(unsafe.Pointer(&states[0]))) / unsafe.Sizeof(chanSelectState{}), t.Data != 0
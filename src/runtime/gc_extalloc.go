@@ -403,6 +403,8 @@ func (t *memTreap) destroy() {
 
 			// Update used memory.
 			usedMem -= unsafe.Sizeof(memTreapNode{}) + n.size
+			gcFrees++
+			gcTotalFreed += uint64(unsafe.Sizeof(memTreapNode{}) + n.size)
 			if gcDebug {
 				println("collecting:", &n.base, "size:", n.size)
 				println("used memory:", usedMem)
@@ -443,6 +445,7 @@ func GC() {
 		}
 		gcrunning = true
 	}
+	start := ticks()
 
 	if gcDebug {
 		println("pre-GC allocations:")
@@ -510,6 +513,7 @@ runqueueScan:
 	if gcDebug {
 		println("GC finished")
 	}
+	gcFinished(start)
 
 	if gcAsserts {
 		gcrunning = false
@@ -527,7 +531,7 @@ var zeroSizedAlloc uint8
 // alloc tries to find some free space on the heap, possibly doing a garbage
 // collection cycle if needed. If no space is free, it panics.
 //go:noinline
-func alloc(size uintptr) unsafe.Pointer {
+func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 	if size == 0 {
 		return unsafe.Pointer(&zeroSizedAlloc)
 	}
@@ -620,6 +624,8 @@ func alloc(size uintptr) unsafe.Pointer {
 
 		// Update used memory.
 		usedMem += allocSize
+		gcMallocs++
+		gcTotalAlloc += uint64(allocSize)
 
 		if gcDebug {
 			println("allocated:", uintptr(ptr), "size:", size)
@@ -634,6 +640,23 @@ func free(ptr unsafe.Pointer) {
 	// Currently unimplemented due to bugs in coroutine lowering.
 }
 
+// readHeapStats fills in the fields of MemStats that describe the current state
+// of the heap. The memory is provided by an external allocator, so the heap
+// size is the bound at which the next collection cycle is started and the
+// largest free area is not known.
+func readHeapStats(m *MemStats) {
+	inuse := uint64(usedMem)
+	m.Alloc = inuse
+	m.Sys = uint64(heapBound)
+	if m.Sys < inuse {
+		m.Sys = inuse
+	}
+	m.HeapAlloc = inuse
+	m.HeapSys = m.Sys
+	m.HeapIdle = m.Sys - inuse
+	m.HeapInuse = inuse
+}
+
 func KeepAlive(x interface{}) {
 	// Unimplemented. Only required with SetFinalizer().
 }
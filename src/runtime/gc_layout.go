@@ -0,0 +1,12 @@
+package runtime
+
+import (
+	"unsafe"
+)
+
+// gcLayoutNoPointers is the object layout for heap objects that are known to
+// not contain any pointers, such as the backing arrays of strings and byte
+// slices. It must match the pointer-free layout created by the compiler in
+// compiler/llvmutil/gclayout.go. The layout is ignored by all GCs except the
+// precise GC.
+var gcLayoutNoPointers = unsafe.Pointer(uintptr(1<<1 | 1))
@@ -13,7 +13,7 @@ import (
 // Ever-incrementing pointer: no memory is freed.
 var heapptr = heapStart
 
-func alloc(size uintptr) unsafe.Pointer {
+func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 	// TODO: this can be optimized by not casting between pointers and ints so
 	// much. And by using platform-native data types (e.g. *uint8 for 8-bit
 	// systems).
@@ -32,6 +32,8 @@ func alloc(size uintptr) unsafe.Pointer {
 		ptr := (*uint32)(unsafe.Pointer(addr + i))
 		*ptr = 0
 	}
+	gcMallocs++
+	gcTotalAlloc += uint64(size)
 	return unsafe.Pointer(addr)
 }
 
@@ -43,6 +45,19 @@ func GC() {
 	// No-op.
 }
 
+// readHeapStats fills in the fields of MemStats that describe the current state
+// of the heap. All memory after heapptr is free.
+func readHeapStats(m *MemStats) {
+	inuse := uint64(heapptr - heapStart)
+	m.Alloc = inuse
+	m.Sys = uint64(heapEnd - heapStart)
+	m.HeapAlloc = inuse
+	m.HeapSys = m.Sys
+	m.HeapIdle = m.Sys - inuse
+	m.HeapInuse = inuse
+	m.HeapMaxFree = m.HeapIdle
+}
+
 func KeepAlive(x interface{}) {
 	// Unimplemented. Only required with SetFinalizer().
 }
@@ -0,0 +1,13 @@
+// +build !gc.precise
+
+package runtime
+
+import (
+	"unsafe"
+)
+
+// newGCLayout returns nil: object layouts are only used by the precise GC, so
+// there is no need to create them at runtime.
+func newGCLayout(words uintptr) unsafe.Pointer {
+	return nil
+}
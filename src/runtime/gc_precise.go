@@ -0,0 +1,90 @@
+// +build gc.precise
+
+package runtime
+
+// This file contains the parts of the precise GC that differ from the
+// conservative GC. The compiler passes an object layout to every runtime.alloc
+// call, which is stored in the first word of the heap object. When scanning the
+// object, only the words that contain a pointer according to this layout are
+// considered. This avoids keeping objects alive because of integers or other
+// data that happens to look like a heap pointer.
+//
+// The layout value describes a single element of the object: if the object is
+// bigger than the element (for example, when allocating the backing array of a
+// slice) the layout repeats. The value can take one of three forms:
+//
+//   - nil, when the layout is not known (for example, when allocating from the
+//     runtime or for coroutine frames). The object is scanned conservatively.
+//   - An integer with the lowest bit set. In this case the layout is stored
+//     inline: the next sizeFieldBits bits contain the element size in words
+//     and the remaining bits are a bitmap of the words that contain a pointer.
+//   - A pointer to a global. The global starts with the element size in words
+//     (as an uintptr) and is followed by a bitmap (one bit per word) of the
+//     words that contain a pointer.
+//
+// The compiler side of this can be found in compiler/llvmutil/gclayout.go.
+// The reflect package creates layouts at runtime (see src/reflect/gclayout.go).
+// Layouts that don't fit inline are allocated on the heap with newGCLayout, in
+// which case the object header is also scanned to keep the layout alive.
+
+import (
+	"unsafe"
+)
+
+// preciseHeap is true: the object layout is stored in heap objects.
+const preciseHeap = true
+
+// sizeFieldBits is the number of bits used to store the element size in an
+// inline object layout: 4 bits on 16-bit systems, 5 bits on 32-bit systems and
+// 6 bits on 64-bit systems.
+const sizeFieldBits = 4 + (unsafe.Sizeof(uintptr(0)) / 4)
+
+// gcLayout is the object layout as passed to runtime.alloc.
+type gcLayout uintptr
+
+// parseGCLayout returns the layout of the object at the given address, which
+// must be the start of an allocated object.
+func parseGCLayout(addr uintptr) gcLayout {
+	return *(*gcLayout)(unsafe.Pointer(addr))
+}
+
+// pointerFree returns whether the object is known to not contain any pointers.
+func (layout gcLayout) pointerFree() bool {
+	return layout&1 != 0 && layout>>(sizeFieldBits+1) == 0
+}
+
+// isPointer returns whether the layout is stored in a separate object: either a
+// global created by the compiler or a heap object created by newGCLayout.
+func (layout gcLayout) isPointer() bool {
+	return layout != 0 && layout&1 == 0
+}
+
+// newGCLayout allocates an object layout for objects of the given number of
+// words, with no pointer bits set yet. It is used by the reflect package for
+// types with a layout that doesn't fit inline.
+func newGCLayout(words uintptr) unsafe.Pointer {
+	layout := alloc(unsafe.Sizeof(words)+(words+7)/8, gcLayoutNoPointers)
+	*(*uintptr)(layout) = words
+	return layout
+}
+
+// hasPointer returns whether the word at the given index (counted from the
+// start of the object) may contain a pointer.
+func (layout gcLayout) hasPointer(index uintptr) bool {
+	switch {
+	case layout == 0:
+		// Unknown layout, so this word may contain a pointer.
+		return true
+	case layout&1 != 0:
+		// The layout is stored in the integer value itself.
+		size := uintptr(layout>>1) & (1<<sizeFieldBits - 1)
+		bitmap := uintptr(layout) >> (1 + sizeFieldBits)
+		return (bitmap>>(index%size))&1 != 0
+	default:
+		// The layout is stored in a separate global.
+		size := *(*uintptr)(unsafe.Pointer(layout))
+		bit := index % size
+		bitmapByte := *(*uint8)(unsafe.Pointer(uintptr(layout) + unsafe.Sizeof(size) + bit/8))
+		return (bitmapByte>>(bit%8))&1 != 0
+	}
+}
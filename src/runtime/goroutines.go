@@ -0,0 +1,184 @@
+package runtime
+
+// This file implements goroutine dumps: a list of all goroutines that have not
+// exited yet, with the function they were started with and what they are
+// blocked on. A dump is printed when all goroutines are blocked and nothing can
+// wake them up anymore, and it can be requested with Stack(buf, true).
+//
+// Goroutines are only tracked on baremetal systems for Cortex-M chips with the
+// tasks scheduler and in the Cortex-M emulator (see task.GoroutineDumps). On
+// real chips a goroutine may always be woken up by an interrupt, so a deadlock
+// can't be detected and a dump is only written by Stack.
+//
+// Example output:
+//
+//     goroutine 1 [chan receive]:
+//     	runtime.run$1
+//     	waiting on chan receive 0x0000000000412a40
+//
+//     goroutine 2 [select]:
+//     	main.worker
+//     	waiting on chan receive 0x0000000000412a80
+//     	waiting on chan send 0x0000000000412ac0
+
+import (
+	"internal/task"
+	"unsafe"
+)
+
+// Reasons why a goroutine is blocked, see task.Task.SetWaitReason.
+const (
+	waitReasonNone     uint8 = iota // not blocked on a channel operation
+	waitReasonChanSend              // WaitData is the *channel
+	waitReasonChanRecv              // WaitData is the *channel
+	waitReasonSelect                // WaitData is one of the blocked *channelBlockedList operations
+	waitReasonForever               // blocked forever, see deadlock
+)
+
+// goroutineDump writes a goroutine dump either to the output (see putchar) or
+// to a buffer.
+type goroutineDump struct {
+	toOutput bool
+	buf      []byte
+	n        int
+}
+
+func (d *goroutineDump) writeByte(c byte) {
+	if d.toOutput {
+		putchar(c)
+	} else if d.n < len(d.buf) {
+		d.buf[d.n] = c
+		d.n++
+	}
+}
+
+func (d *goroutineDump) writeString(s string) {
+	for i := 0; i < len(s); i++ {
+		d.writeByte(s[i])
+	}
+}
+
+func (d *goroutineDump) writeUint(n uintptr) {
+	var digits [20]byte
+	i := len(digits)
+	for {
+		i--
+		digits[i] = byte(n%10) + '0'
+		n /= 10
+		if n == 0 {
+			break
+		}
+	}
+	for ; i < len(digits); i++ {
+		d.writeByte(digits[i])
+	}
+}
+
+func (d *goroutineDump) writePointer(ptr unsafe.Pointer) {
+	n := uintptr(ptr)
+	d.writeString("0x")
+	for i := 0; i < int(unsafe.Sizeof(n))*2; i++ {
+		nibble := byte(n >> (unsafe.Sizeof(n)*8 - 4))
+		if nibble < 10 {
+			d.writeByte(nibble + '0')
+		} else {
+			d.writeByte(nibble - 10 + 'a')
+		}
+		n <<= 4
+	}
+}
+
+// writeGoroutines writes the state of the current goroutine, and of all other
+// live goroutines if all is set. Goroutines are numbered in the order in which
+// they were started, counting only those that have not exited yet.
+func (d *goroutineDump) writeGoroutines(all bool) {
+	if !hasScheduler || !task.GoroutineDumps {
+		// Goroutines are not tracked without a scheduler, or on most
+		// baremetal systems (to save memory).
+		return
+	}
+	current := task.Current()
+	var number uintptr
+	for t := task.Live(); t != nil; t = t.NextLive() {
+		number++
+		if t != current && !all {
+			continue
+		}
+		if number > 1 && all {
+			d.writeByte('\n')
+		}
+		d.writeString("goroutine ")
+		d.writeUint(number)
+		d.writeString(" [")
+		d.writeString(goroutineState(t, current))
+		d.writeString("]:\n\t")
+		name := t.EntryName()
+		if name == "" {
+			name = "?"
+		}
+		d.writeString(name)
+		d.writeByte('\n')
+		reason, data := t.WaitReason()
+		switch reason {
+		case waitReasonChanSend:
+			d.writeBlockedOn("chan send", data)
+		case waitReasonChanRecv:
+			d.writeBlockedOn("chan receive", data)
+		case waitReasonSelect:
+			for _, op := range (*channelBlockedList)(data).allSelectOps {
+				if op.t == nil {
+					// Nil channel, which is not blocked on.
+					continue
+				}
+				if op.s.value == nil {
+					d.writeBlockedOn("chan receive", unsafe.Pointer(op.s.ch))
+				} else {
+					d.writeBlockedOn("chan send", unsafe.Pointer(op.s.ch))
+				}
+			}
+		}
+	}
+}
+
+func (d *goroutineDump) writeBlockedOn(op string, ch unsafe.Pointer) {
+	d.writeString("\twaiting on ")
+	d.writeString(op)
+	d.writeByte(' ')
+	d.writePointer(ch)
+	d.writeByte('\n')
+}
+
+// goroutineState returns a short description of the state of the goroutine,
+// similar to the ones used by the Go runtime.
+func goroutineState(t, current *task.Task) string {
+	reason, _ := t.WaitReason()
+	switch reason {
+	case waitReasonChanSend:
+		return "chan send"
+	case waitReasonChanRecv:
+		return "chan receive"
+	case waitReasonSelect:
+		return "select"
+	case waitReasonForever:
+		return "blocked forever"
+	}
+	if t == current {
+		return "running"
+	}
+	for sleeping := sleepQueue; sleeping != nil; sleeping = sleeping.Next {
+		if sleeping == t {
+			return "sleep"
+		}
+	}
+	if runqueue.Contains(t) {
+		return "runnable"
+	}
+	// For example, waiting on a sync.Mutex or for an interrupt.
+	return "waiting"
+}
+
+// printGoroutines prints a goroutine dump of all live goroutines.
+func printGoroutines() {
+	d := goroutineDump{toOutput: true}
+	d.writeGoroutines(true)
+}
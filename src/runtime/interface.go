@@ -90,8 +90,10 @@ func interfaceTypeAssert(ok bool) {
 // See compiler/interface-lowering.go for details.
 
 type interfaceMethodInfo struct {
-	signature *uint8  // external *i8 with a name identifying the Go function signature
-	funcptr   uintptr // bitcast from the actual function pointer
+	signature *uint8      // external *i8 with a name identifying the Go function signature
+	funcptr   uintptr     // bitcast from the actual function pointer
+	typecode  *typecodeID // type of the method as a func with the receiver as first parameter
+	method    uintptr     // bitcast from the method itself (not the invoke wrapper)
 }
 
 type typecodeID struct {
@@ -99,14 +101,17 @@ type typecodeID struct {
 	// different:
 	// * basic types: null
 	// * named type: the underlying type
-	// * interface: null
-	// * chan/pointer/slice/array: the element type
+	// * interface: bitcast of global with method signature strings, or null
+	// * chan/pointer/slice/array/map: the element type
 	// * struct: bitcast of global with structField array
-	// * func/map: TODO
+	// * func: TODO
 	references *typecodeID
 
 	// The array length, for array types.
 	length uintptr
+
+	// The key type, for map types.
+	key *typecodeID
 }
 
 // structField is used by the compiler to pass information to the interface
@@ -116,6 +121,7 @@ type structField struct {
 	name     *uint8      // pointer to char array
 	tag      *uint8      // pointer to char array, or nil
 	embedded bool
+	pkgpath  *uint8 // pointer to char array for unexported fields, or nil
 }
 
 // Pseudo type used before interface lowering. By using a struct instead of a
@@ -0,0 +1,98 @@
+package runtime
+
+// This file implements ReadMemStats. The counters below are updated by the
+// garbage collector implementations that support them, while the current state
+// of the heap is read by readHeapStats (which is implemented by every garbage
+// collector).
+
+// A MemStats records statistics about the memory allocator. Only a subset of
+// the fields of the Go runtime is available, plus HeapMaxFree which is specific
+// to TinyGo.
+type MemStats struct {
+	// General statistics.
+
+	// Alloc is bytes of allocated heap objects. It is the same as HeapAlloc.
+	Alloc uint64
+
+	// TotalAlloc is cumulative bytes allocated for heap objects. Unlike Alloc
+	// and HeapAlloc, it does not decrease when objects are freed.
+	TotalAlloc uint64
+
+	// Sys is the total bytes of memory obtained for the heap, including the
+	// metadata of the garbage collector.
+	Sys uint64
+
+	// Mallocs is the cumulative count of heap objects allocated.
+	Mallocs uint64
+
+	// Frees is the cumulative count of heap objects freed.
+	Frees uint64
+
+	// Heap memory statistics.
+
+	// HeapAlloc is bytes of allocated heap objects, including unreachable
+	// objects that have not yet been freed by the garbage collector.
+	HeapAlloc uint64
+
+	// HeapSys is bytes of heap memory that can be used for heap objects.
+	HeapSys uint64
+
+	// HeapIdle is bytes in unused parts of the heap.
+	HeapIdle uint64
+
+	// HeapInuse is bytes in used parts of the heap.
+	HeapInuse uint64
+
+	// HeapObjects is the number of allocated heap objects.
+	HeapObjects uint64
+
+	// HeapMaxFree is the size in bytes of the largest contiguous free area of
+	// the heap: the biggest object that can be allocated without running the
+	// garbage collector or growing the heap. When it is much smaller than
+	// HeapIdle, the heap is fragmented. It is 0 when the memory allocator
+	// can't tell, for example with an external allocator.
+	HeapMaxFree uint64
+
+	// Garbage collector statistics.
+
+	// PauseTotalNs is the cumulative nanoseconds spent in garbage collection
+	// cycles. The whole program is paused during a cycle.
+	PauseTotalNs uint64
+
+	// NumGC is the number of completed garbage collection cycles.
+	NumGC uint32
+}
+
+// Counters that are reported by ReadMemStats. They are kept up to date by the
+// garbage collector, if it supports them.
+var (
+	gcTotalAlloc   uint64 // bytes allocated, including objects that were freed
+	gcTotalFreed   uint64 // bytes freed
+	gcMallocs      uint64 // number of objects allocated
+	gcFrees        uint64 // number of objects freed
+	gcNumGC        uint32 // number of collection cycles
+	gcPauseTotalNs uint64 // time spent in collection cycles
+)
+
+// gcFinished updates the statistics of the garbage collector after a collection
+// cycle that started at the given time.
+func gcFinished(start timeUnit) {
+	gcNumGC++
+	gcPauseTotalNs += uint64(ticksToNanoseconds(ticks() - start))
+}
+
+// ReadMemStats populates m with memory allocator statistics.
+//
+// The statistics are only complete with the conservative and precise garbage
+// collectors. Other garbage collectors leave some of them at zero.
+func ReadMemStats(m *MemStats) {
+	*m = MemStats{
+		TotalAlloc:   gcTotalAlloc,
+		Mallocs:      gcMallocs,
+		Frees:        gcFrees,
+		HeapObjects:  gcMallocs - gcFrees,
+		PauseTotalNs: gcPauseTotalNs,
+		NumGC:        gcNumGC,
+	}
+	readHeapStats(m)
+}
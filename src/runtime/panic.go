@@ -1,12 +1,54 @@
 package runtime
 
+import (
+	"unsafe"
+)
+
 // trap is a compiler hint that this function cannot be executed. It is
 // translated into either a trap instruction or a call to abort().
 //export llvm.trap
 func trap()
 
+// tinygo_longjmp is called when a panic needs to unwind the stack. It restores
+// the stack pointer and jumps to the program counter stored in the given defer
+// frame. It is implemented in assembly (see gc_*.S).
+//export tinygo_longjmp
+func tinygo_longjmp(frame *deferFrame)
+
+// supportsRecover is a compiler intrinsic that returns whether the stack can be
+// unwound on a panic for the current target. When this returns false, a panic
+// always aborts the program and recover() always returns nil.
+func supportsRecover() bool
+
+// deferFrame is set up by the compiler at the start of every function that
+// contains a defer statement. It is stack allocated and forms a linked list
+// with the defer frames of the calling functions. See compiler/defer.go for
+// details.
+//
+// The layout of the first two fields must be kept in sync with the compiler
+// and with tinygo_longjmp.
+type deferFrame struct {
+	JumpSP     unsafe.Pointer // stack pointer to return to
+	JumpPC     unsafe.Pointer // pc to return to
+	Previous   *deferFrame    // previous defer frame, of the calling function
+	Panicking  bool           // true iff this defer frame is panicking
+	PanicValue interface{}    // panic value, might be nil for panic(nil) for example
+}
+
 // Builtin function panic(msg), used as a compiler intrinsic.
 func _panic(message interface{}) {
+	if supportsRecover() {
+		frame := getDeferFrame()
+		if frame != nil {
+			// Jump to the landing pad of the function that set up this defer
+			// frame, which runs the deferred calls. If none of them recovers,
+			// the panic continues in destroyDeferFrame.
+			frame.PanicValue = message
+			frame.Panicking = true
+			tinygo_longjmp(frame)
+			// unreachable
+		}
+	}
 	printstring("panic: ")
 	printitf(message)
 	printnl()
@@ -15,15 +57,75 @@ func _panic(message interface{}) {
 
 // Cause a runtime panic, which is (currently) always a string.
 func runtimePanic(msg string) {
+	if supportsRecover() && getDeferFrame() != nil {
+		// There is a deferred call that may recover from this panic, so raise
+		// it as a regular panic with a runtime.Error value.
+		_panic(runtimeError{msg})
+	}
 	printstring("panic: runtime error: ")
 	println(msg)
 	abort()
 }
 
-// Try to recover a panicking goroutine.
-func _recover() interface{} {
-	// Deferred functions are currently not executed during panic, so there is
-	// no way this can return anything besides nil.
+// runtimeError is the panic value of panics raised by the runtime itself, such
+// as a nil pointer dereference or an out of bounds slice index.
+type runtimeError struct {
+	msg string
+}
+
+func (e runtimeError) Error() string {
+	return "runtime error: " + e.msg
+}
+
+// RuntimeError implements the runtime.Error interface.
+func (e runtimeError) RuntimeError() {}
+
+// Called at the start of a function that includes a deferred call. It gets
+// passed in the stack-allocated defer frame and configures it. Note that the
+// frame is not zeroed, so all fields that will be read must be initialized
+// here.
+//go:inline
+func setupDeferFrame(frame *deferFrame, jumpSP unsafe.Pointer) {
+	frame.Previous = getDeferFrame()
+	frame.JumpSP = jumpSP
+	frame.Panicking = false
+	setDeferFrame(frame)
+}
+
+// Called right before a function with a defer frame returns. It pops the defer
+// frame from the linked list of defer frames, and re-raises the panic if the
+// deferred calls did not recover from it.
+//go:inline
+func destroyDeferFrame(frame *deferFrame) {
+	setDeferFrame(frame.Previous)
+	if frame.Panicking {
+		// Still panicking, continue unwinding in the caller.
+		_panic(frame.PanicValue)
+	}
+}
+
+// _recover is the built-in recover() function. It tries to recover a currently
+// panicking goroutine.
+// useParentFrame is set when the caller of runtime._recover has a defer frame
+// itself. In that case, recover() shouldn't check that frame but the one of
+// the function that is running its deferred calls.
+func _recover(useParentFrame bool) interface{} {
+	if !supportsRecover() {
+		// The stack is never unwound, so deferred calls are not run while
+		// panicking and there is nothing to recover from.
+		return nil
+	}
+	frame := getDeferFrame()
+	if useParentFrame && frame != nil {
+		frame = frame.Previous
+	}
+	if frame != nil && frame.Panicking {
+		// Only the first call to recover returns the panic value, as it stops
+		// the panicking sequence.
+		frame.Panicking = false
+		return frame.PanicValue
+	}
+	// Not panicking, so return a nil interface.
 	return nil
 }
 
@@ -1,29 +1,234 @@
 package pprof
 
-// TinyGo does not implement pprof. However, a dummy shell is needed for the
-// testing package (and testing/internal/pprof).
+// This package writes CPU and heap profiles in the protobuf format read by
+// `go tool pprof`. Profiling is supported on linux/amd64 and linux/arm64. Heap
+// profiles are only recorded when using the conservative or precise garbage
+// collector (-gc=conservative or -gc=precise), on other systems they are empty.
+//
+// Stack traces are recorded by following frame pointers, which the compiler
+// only keeps when this package is imported. Only the system stack can be
+// walked: code running on a separate goroutine stack (with -scheduler=tasks)
+// is recorded with just the function that was interrupted in CPU profiles and
+// without a stack trace in heap profiles.
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"math"
+	"runtime"
+	"time"
 )
 
 var ErrUnimplemented = errors.New("runtime/pprof: unimplemented")
 
-type Profile struct {
+// These functions are implemented in the runtime.
+func startCPUProfile(hz int, buf, index []uintptr) bool
+func stopCPUProfile() (n int, lost int)
+
+// cpuProfileHz is the sampling rate of the CPU profiler, the same as the
+// default in the standard library.
+const cpuProfileHz = 100
+
+var cpu struct {
+	profiling bool
+	w         io.Writer
+	buf       []uintptr
+	start     time.Time
 }
 
+// StartCPUProfile enables CPU profiling for the current process. While
+// profiling, the profile will be buffered and written to w when
+// StopCPUProfile is called.
+//
+// StartCPUProfile returns an error if profiling is already enabled or is not
+// supported on this system.
 func StartCPUProfile(w io.Writer) error {
+	if cpu.profiling {
+		return errors.New("cpu profiling already in use")
+	}
+	// The buffer holds the unique stack traces, the index is a hash table to
+	// find them (its size must be a power of two).
+	buf := make([]uintptr, 128*1024)
+	index := make([]uintptr, 8192)
+	cpu.start = time.Now()
+	if !startCPUProfile(cpuProfileHz, buf, index) {
+		return errors.New("cpu profiling is not supported on this system")
+	}
+	cpu.profiling = true
+	cpu.w = w
+	cpu.buf = buf
 	return nil
 }
 
+// StopCPUProfile stops the current CPU profile, if any, and writes the profile
+// to the writer passed to StartCPUProfile.
 func StopCPUProfile() {
+	if !cpu.profiling {
+		return
+	}
+	n, lost := stopCPUProfile()
+	cpu.profiling = false
+
+	const period = 1e9 / cpuProfileHz
+	b := newProfileBuilder(cpu.start)
+	b.valueType(tagProfile_SampleType, "samples", "count")
+	b.valueType(tagProfile_SampleType, "cpu", "nanoseconds")
+	b.valueType(tagProfile_PeriodType, "cpu", "nanoseconds")
+	b.pb.int64(tagProfile_Period, period)
+	for i := 0; i < n; {
+		count := int64(cpu.buf[i])
+		depth := int(cpu.buf[i+1])
+		b.sample([]int64{count, count * period}, cpu.buf[i+2:i+2+depth])
+		i += 2 + depth
+	}
+	if lost != 0 {
+		b.comment(fmt.Sprintf("%d samples lost because the profile buffer was full", lost))
+	}
+	b.finish(cpu.w)
+	cpu.w = nil
+	cpu.buf = nil
+}
+
+// A Profile is a collection of stack traces. Only the "heap" and "allocs"
+// profiles are supported, which both show the memory allocations of the
+// program (sampled according to runtime.MemProfileRate).
+type Profile struct {
+	name string
 }
 
+var (
+	heapProfile   = &Profile{name: "heap"}
+	allocsProfile = &Profile{name: "allocs"}
+)
+
+// Lookup returns the profile with the given name, or nil if no such profile
+// exists.
 func Lookup(name string) *Profile {
-	return nil
+	switch name {
+	case "heap":
+		return heapProfile
+	case "allocs":
+		return allocsProfile
+	default:
+		return nil
+	}
 }
 
+// Profiles returns a slice of all the known profiles, sorted by name.
+func Profiles() []*Profile {
+	return []*Profile{allocsProfile, heapProfile}
+}
+
+// Name returns this profile's name, which can be passed to Lookup to reobtain
+// the profile.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// Count returns the number of execution stacks currently in the profile.
+func (p *Profile) Count() int {
+	n, _ := runtime.MemProfile(nil, true)
+	return n
+}
+
+// WriteTo writes a pprof-formatted snapshot of the profile to w. If debug is
+// 0, a protobuf is written. If debug is 1 or higher, the legacy text format
+// is written instead, without symbol information.
+//
+// The profile reflects the memory in use as of the most recently completed
+// garbage collection. Call runtime.GC before WriteTo for an up-to-date view.
 func (p *Profile) WriteTo(w io.Writer, debug int) error {
-	return ErrUnimplemented
+	// Get the memory profile records. The profile may grow between the two
+	// calls, so retry until it fits.
+	var records []runtime.MemProfileRecord
+	n, _ := runtime.MemProfile(nil, true)
+	for {
+		records = make([]runtime.MemProfileRecord, n+10)
+		var ok bool
+		n, ok = runtime.MemProfile(records, true)
+		if ok {
+			records = records[:n]
+			break
+		}
+	}
+
+	rate := int64(runtime.MemProfileRate)
+	if debug != 0 {
+		return writeHeapText(w, records, rate)
+	}
+
+	b := newProfileBuilder(time.Now())
+	b.valueType(tagProfile_SampleType, "alloc_objects", "count")
+	b.valueType(tagProfile_SampleType, "alloc_space", "bytes")
+	b.valueType(tagProfile_SampleType, "inuse_objects", "count")
+	b.valueType(tagProfile_SampleType, "inuse_space", "bytes")
+	b.valueType(tagProfile_PeriodType, "space", "bytes")
+	b.pb.int64(tagProfile_Period, rate)
+	if p.name == "allocs" {
+		b.pb.int64(tagProfile_DefaultSampleType, b.stringIndex("alloc_space"))
+	}
+	for i := range records {
+		r := &records[i]
+		allocObjects, allocBytes := scaleHeapSample(r.AllocObjects, r.AllocBytes, rate)
+		inuseObjects, inuseBytes := scaleHeapSample(r.InUseObjects(), r.InUseBytes(), rate)
+		b.sample([]int64{allocObjects, allocBytes, inuseObjects, inuseBytes}, r.Stack())
+	}
+	return b.finish(w)
+}
+
+// WriteHeapProfile is shorthand for Lookup("heap").WriteTo(w, 0).
+func WriteHeapProfile(w io.Writer) error {
+	return heapProfile.WriteTo(w, 0)
+}
+
+// writeHeapText writes the heap profile in the legacy text format.
+func writeHeapText(w io.Writer, records []runtime.MemProfileRecord, rate int64) error {
+	var total runtime.MemProfileRecord
+	for i := range records {
+		r := &records[i]
+		total.AllocBytes += r.AllocBytes
+		total.AllocObjects += r.AllocObjects
+		total.FreeBytes += r.FreeBytes
+		total.FreeObjects += r.FreeObjects
+	}
+	_, err := fmt.Fprintf(w, "heap profile: %d: %d [%d: %d] @ heap/%d\n",
+		total.InUseObjects(), total.InUseBytes(),
+		total.AllocObjects, total.AllocBytes,
+		2*rate)
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		r := &records[i]
+		fmt.Fprintf(w, "%d: %d [%d: %d] @",
+			r.InUseObjects(), r.InUseBytes(),
+			r.AllocObjects, r.AllocBytes)
+		for _, pc := range r.Stack() {
+			fmt.Fprintf(w, " %#x", pc)
+		}
+		_, err = fmt.Fprintf(w, "\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scaleHeapSample adjusts the data from a heap sample to account for its
+// probability of appearing in the collected data. Heap profiles are a
+// sampling of the memory allocations, so an estimate of the total is
+// reported.
+func scaleHeapSample(count, size, rate int64) (int64, int64) {
+	if count == 0 || size == 0 {
+		return 0, 0
+	}
+	if rate <= 1 {
+		// if rate==1 all samples were collected so no adjustment is needed.
+		// if rate<1 treat as unknown and skip scaling.
+		return count, size
+	}
+	avgSize := float64(size) / float64(count)
+	scale := 1 / (1 - math.Exp(-avgSize/float64(rate)))
+	return int64(float64(count) * scale), int64(float64(size) * scale)
 }
@@ -0,0 +1,196 @@
+package pprof
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field numbers of the messages in the pprof profile.proto file:
+// https://github.com/google/pprof/blob/master/proto/profile.proto
+const (
+	// message Profile
+	tagProfile_SampleType        = 1  // repeated ValueType
+	tagProfile_Sample            = 2  // repeated Sample
+	tagProfile_Mapping           = 3  // repeated Mapping
+	tagProfile_Location          = 4  // repeated Location
+	tagProfile_StringTable       = 6  // repeated string
+	tagProfile_TimeNanos         = 9  // int64
+	tagProfile_DurationNanos     = 10 // int64
+	tagProfile_PeriodType        = 11 // ValueType
+	tagProfile_Period            = 12 // int64
+	tagProfile_Comment           = 13 // repeated int64
+	tagProfile_DefaultSampleType = 14 // int64
+
+	// message ValueType
+	tagValueType_Type = 1 // int64 (string table index)
+	tagValueType_Unit = 2 // int64 (string table index)
+
+	// message Sample
+	tagSample_Location = 1 // repeated uint64
+	tagSample_Value    = 2 // repeated int64
+
+	// message Mapping
+	tagMapping_ID           = 1 // uint64
+	tagMapping_Start        = 2 // uint64
+	tagMapping_Limit        = 3 // uint64
+	tagMapping_Offset       = 4 // uint64
+	tagMapping_Filename     = 5 // int64 (string table index)
+	tagMapping_HasFunctions = 7 // bool
+
+	// message Location
+	tagLocation_ID        = 1 // uint64
+	tagLocation_MappingID = 2 // uint64
+	tagLocation_Address   = 3 // uint64
+)
+
+// profileBuilder writes a profile in the protobuf format used by pprof.
+//
+// Locations only contain an address and no function or line information,
+// because the runtime does not have a symbol table. The pprof tool adds this
+// information itself from the executable, which is found using the file name
+// in the memory mappings of the profile.
+type profileBuilder struct {
+	start     time.Time
+	pb        protobuf
+	strings   map[string]int64
+	locations map[uintptr]uint64
+	mappings  []memMap
+}
+
+// memMap is an executable memory mapping of the process.
+type memMap struct {
+	start, end uintptr
+	offset     uint64
+	file       string
+}
+
+func newProfileBuilder(start time.Time) *profileBuilder {
+	b := &profileBuilder{
+		start:     start,
+		strings:   map[string]int64{},
+		locations: map[uintptr]uint64{},
+	}
+	b.stringIndex("") // the first string must be the empty string
+	b.readMapping()
+	for i, m := range b.mappings {
+		var msg protobuf
+		msg.uint64(tagMapping_ID, uint64(i+1))
+		msg.uint64(tagMapping_Start, uint64(m.start))
+		msg.uint64(tagMapping_Limit, uint64(m.end))
+		msg.uint64(tagMapping_Offset, m.offset)
+		msg.int64(tagMapping_Filename, b.stringIndex(m.file))
+		msg.bool(tagMapping_HasFunctions, false)
+		b.pb.message(tagProfile_Mapping, &msg)
+	}
+	return b
+}
+
+// readMapping reads the executable memory mappings of this process from
+// /proc/self/maps. They are needed by pprof to symbolize the profile. On
+// systems without this file, the profile will have no mappings.
+func (b *profileBuilder) readMapping() {
+	f, err := os.Open("/proc/self/maps")
+	if err != nil {
+		return
+	}
+	data, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return
+	}
+	// Each line looks like this:
+	// 00400000-00452000 r-xp 00000000 08:02 173521 /usr/bin/dbus-daemon
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || !strings.Contains(fields[1], "x") || !strings.HasPrefix(fields[5], "/") {
+			continue
+		}
+		addresses := strings.SplitN(fields[0], "-", 2)
+		if len(addresses) != 2 {
+			continue
+		}
+		start, err1 := strconv.ParseUint(addresses[0], 16, 64)
+		end, err2 := strconv.ParseUint(addresses[1], 16, 64)
+		offset, err3 := strconv.ParseUint(fields[2], 16, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		b.mappings = append(b.mappings, memMap{
+			start:  uintptr(start),
+			end:    uintptr(end),
+			offset: offset,
+			file:   fields[5],
+		})
+	}
+}
+
+// stringIndex returns the index of the string in the string table, adding it
+// if needed.
+func (b *profileBuilder) stringIndex(s string) int64 {
+	index, ok := b.strings[s]
+	if !ok {
+		index = int64(len(b.strings))
+		b.strings[s] = index
+		b.pb.string(tagProfile_StringTable, s)
+	}
+	return index
+}
+
+// valueType writes a ValueType message with the given tag.
+func (b *profileBuilder) valueType(tag int, typ, unit string) {
+	var msg protobuf
+	msg.int64(tagValueType_Type, b.stringIndex(typ))
+	msg.int64(tagValueType_Unit, b.stringIndex(unit))
+	b.pb.message(tag, &msg)
+}
+
+// sample writes a single sample with the given stack trace.
+func (b *profileBuilder) sample(values []int64, stack []uintptr) {
+	locations := make([]uint64, len(stack))
+	for i, addr := range stack {
+		locations[i] = b.location(addr)
+	}
+	var msg protobuf
+	msg.uint64s(tagSample_Location, locations)
+	msg.int64s(tagSample_Value, values)
+	b.pb.message(tagProfile_Sample, &msg)
+}
+
+// location returns the location ID for the given address, writing a new
+// Location message if this address wasn't seen before.
+func (b *profileBuilder) location(addr uintptr) uint64 {
+	if id, ok := b.locations[addr]; ok {
+		return id
+	}
+	id := uint64(len(b.locations) + 1)
+	b.locations[addr] = id
+	var msg protobuf
+	msg.uint64(tagLocation_ID, id)
+	for i, m := range b.mappings {
+		if addr >= m.start && addr < m.end {
+			msg.uint64(tagLocation_MappingID, uint64(i+1))
+			break
+		}
+	}
+	msg.uint64(tagLocation_Address, uint64(addr))
+	b.pb.message(tagProfile_Location, &msg)
+	return id
+}
+
+// comment adds a free-form comment to the profile.
+func (b *profileBuilder) comment(s string) {
+	b.pb.int64(tagProfile_Comment, b.stringIndex(s))
+}
+
+// finish writes the profile to w. The profile is not compressed, which is
+// accepted by pprof just like a gzip-compressed profile.
+func (b *profileBuilder) finish(w io.Writer) error {
+	b.pb.int64(tagProfile_TimeNanos, b.start.UnixNano())
+	b.pb.int64(tagProfile_DurationNanos, int64(time.Since(b.start)))
+	_, err := w.Write(b.pb.data)
+	return err
+}
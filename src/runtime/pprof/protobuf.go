@@ -0,0 +1,85 @@
+package pprof
+
+// A minimal protocol buffer encoder, just enough to write profiles. See
+// https://developers.google.com/protocol-buffers/docs/encoding for a
+// description of the wire format.
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+type protobuf struct {
+	data []byte
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protobuf) key(tag int, wireType int) {
+	b.varint(uint64(tag)<<3 | uint64(wireType))
+}
+
+// uint64 writes an unsigned integer field. Zero values are the default, so
+// they are omitted.
+func (b *protobuf) uint64(tag int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.key(tag, wireVarint)
+	b.varint(x)
+}
+
+// int64 writes a signed integer field. Negative numbers are encoded as a 64-bit
+// two's complement number, as required by the int64 protobuf type.
+func (b *protobuf) int64(tag int, x int64) {
+	b.uint64(tag, uint64(x))
+}
+
+func (b *protobuf) bool(tag int, x bool) {
+	if x {
+		b.uint64(tag, 1)
+	}
+}
+
+// uint64s writes a packed repeated unsigned integer field.
+func (b *protobuf) uint64s(tag int, x []uint64) {
+	var packed protobuf
+	for _, v := range x {
+		packed.varint(v)
+	}
+	b.bytes(tag, packed.data)
+}
+
+// int64s writes a packed repeated signed integer field.
+func (b *protobuf) int64s(tag int, x []int64) {
+	var packed protobuf
+	for _, v := range x {
+		packed.varint(uint64(v))
+	}
+	b.bytes(tag, packed.data)
+}
+
+// string writes a string field. Unlike other fields, an empty string is still
+// written: this is needed for the string table, where the position matters.
+func (b *protobuf) string(tag int, s string) {
+	b.key(tag, wireBytes)
+	b.varint(uint64(len(s)))
+	b.data = append(b.data, s...)
+}
+
+func (b *protobuf) bytes(tag int, data []byte) {
+	b.key(tag, wireBytes)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+// message writes an embedded message field.
+func (b *protobuf) message(tag int, m *protobuf) {
+	b.bytes(tag, m.data)
+}
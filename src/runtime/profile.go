@@ -0,0 +1,94 @@
+package runtime
+
+// This file contains the parts of the memory profiler that are the same on all
+// systems. The sampling itself is only implemented on some systems, see
+// profile_linux.go.
+
+// MemProfileRate controls the fraction of memory allocations that are recorded
+// and reported in the memory profile. The profiler samples one allocation per
+// MemProfileRate bytes allocated. Set it to 1 to include every allocated block
+// in the profile, or to 0 to turn off profiling entirely.
+//
+// Memory profiles are only recorded by the conservative and precise garbage
+// collectors on linux/amd64 and linux/arm64. On other systems the memory
+// profile is always empty.
+var MemProfileRate int = 512 * 1024
+
+// A MemProfileRecord describes the live objects allocated by a particular call
+// sequence (stack trace).
+type MemProfileRecord struct {
+	AllocBytes, FreeBytes     int64       // number of bytes allocated, freed
+	AllocObjects, FreeObjects int64       // number of objects allocated, freed
+	Stack0                    [32]uintptr // stack trace for this record; ends at first 0 entry
+}
+
+// InUseBytes returns the number of bytes in use (AllocBytes - FreeBytes).
+func (r *MemProfileRecord) InUseBytes() int64 {
+	return r.AllocBytes - r.FreeBytes
+}
+
+// InUseObjects returns the number of objects in use (AllocObjects -
+// FreeObjects).
+func (r *MemProfileRecord) InUseObjects() int64 {
+	return r.AllocObjects - r.FreeObjects
+}
+
+// Stack returns the stack trace associated with the record, a prefix of
+// r.Stack0.
+func (r *MemProfileRecord) Stack() []uintptr {
+	for i, v := range r.Stack0 {
+		if v == 0 {
+			return r.Stack0[0:i]
+		}
+	}
+	return r.Stack0[0:]
+}
+
+// MemProfile returns a profile of memory allocated and freed per allocation
+// site.
+//
+// MemProfile returns n, the number of records in the current memory profile.
+// If len(p) >= n, MemProfile copies the profile into p and returns n, true. If
+// len(p) < n, MemProfile does not change p and returns n, false.
+//
+// If inuseZero is true, the profile includes allocation records where
+// r.AllocBytes > 0 but r.AllocBytes == r.FreeBytes. These are sites where
+// memory was allocated, but it has all been released back to the runtime.
+func MemProfile(p []MemProfileRecord, inuseZero bool) (n int, ok bool) {
+	for i := range memProfileRecords {
+		r := &memProfileRecords[i]
+		if r.AllocObjects == 0 || (!inuseZero && r.InUseBytes() == 0) {
+			continue
+		}
+		n++
+	}
+	if n > len(p) {
+		return n, false
+	}
+	n = 0
+	for i := range memProfileRecords {
+		r := &memProfileRecords[i]
+		if r.AllocObjects == 0 || (!inuseZero && r.InUseBytes() == 0) {
+			continue
+		}
+		p[n] = *r
+		n++
+	}
+	return n, true
+}
+
+// memProfileObject is a sampled heap object that has not yet been freed. It is
+// used to update the free counts in the memory profile when the object is
+// freed by the garbage collector.
+type memProfileObject struct {
+	addr   uintptr // start of the object, or 0 if this entry is unused
+	size   uintptr
+	record *MemProfileRecord
+}
+
+// free records that this object has been freed by the garbage collector.
+func (obj *memProfileObject) free() {
+	obj.record.FreeBytes += int64(obj.size)
+	obj.record.FreeObjects++
+	obj.addr = 0
+}
@@ -0,0 +1,285 @@
+// +build linux,!baremetal,!nintendoswitch
+// +build amd64 arm64
+
+package runtime
+
+// This file implements the sampling CPU profiler and the sampling of heap
+// allocations for the memory profile. Both record stack traces by following
+// the chain of frame pointers, which the compiler keeps on these systems when
+// runtime/pprof is imported (see compileopts.Config.FramePointers).
+//
+// The CPU profiler uses the ITIMER_PROF interval timer, which sends a SIGPROF
+// signal every time the process has used a given amount of CPU time. The
+// signal handler records the stack of the interrupted code and adds it to a
+// buffer provided by runtime/pprof. Identical stacks are merged in the signal
+// handler itself so that long-running profiles do not need an ever growing
+// buffer: this matters because there is no separate thread to drain the buffer
+// while the program is running.
+
+import (
+	"internal/task"
+	"unsafe"
+)
+
+//export sigaction
+func sigaction(signum int32, act, oldact *sigactiont) int32
+
+//export setitimer
+func setitimer(which int32, new, old *itimerval) int32
+
+// The sigaction struct as defined by glibc. It is the same on amd64 and arm64.
+type sigactiont struct {
+	handler  uintptr
+	mask     [16]uint64 // sigset_t
+	flags    int32
+	restorer uintptr
+}
+
+type timeval struct {
+	tv_sec  int
+	tv_usec int
+}
+
+type itimerval struct {
+	interval timeval
+	value    timeval
+}
+
+const (
+	_SIGPROF     = 27
+	_SA_SIGINFO  = 0x4
+	_SA_RESTART  = 0x10000000
+	_ITIMER_PROF = 2
+)
+
+// maxProfileStack is the maximum number of frames recorded in a stack trace.
+// It matches the size of MemProfileRecord.Stack0.
+const maxProfileStack = 32
+
+var (
+	cpuProfileBuf       []uintptr // samples: a count, the stack depth, and the stack
+	cpuProfileIndex     []uintptr // hash table with offsets (plus one) into cpuProfileBuf
+	cpuProfileLen       uintptr   // number of used words in cpuProfileBuf
+	cpuProfileNumStacks uintptr   // number of used entries in cpuProfileIndex
+	cpuProfileLost      uintptr   // number of samples dropped because the buffer was full
+	cpuProfileStack     [maxProfileStack]uintptr
+	sigprofInstalled    bool
+)
+
+// Start the CPU profiler at the given rate. Samples are stored in buf, while
+// index is used as a hash table to find identical stacks. The length of index
+// must be a power of two.
+//
+//go:linkname pprof_startCPUProfile runtime/pprof.startCPUProfile
+func pprof_startCPUProfile(hz int, buf, index []uintptr) bool {
+	if !sigprofInstalled {
+		// Install the signal handler once. It is never uninstalled: a SIGPROF
+		// that is still pending after the profiler is stopped would otherwise
+		// terminate the process.
+		act := sigactiont{
+			handler: sigprofHandlerAddress(),
+			flags:   _SA_SIGINFO | _SA_RESTART,
+		}
+		if sigaction(_SIGPROF, &act, nil) != 0 {
+			return false
+		}
+		sigprofInstalled = true
+	}
+	for i := range index {
+		index[i] = 0
+	}
+	cpuProfileLen = 0
+	cpuProfileNumStacks = 0
+	cpuProfileLost = 0
+	cpuProfileIndex = index
+	cpuProfileBuf = buf
+	return setProfileTimer(1000000/hz) == 0
+}
+
+// Stop the CPU profiler. It returns the number of words used in the buffer
+// passed to startCPUProfile and the number of samples that were dropped.
+//
+//go:linkname pprof_stopCPUProfile runtime/pprof.stopCPUProfile
+func pprof_stopCPUProfile() (n int, lost int) {
+	setProfileTimer(0)
+	n = int(cpuProfileLen)
+	lost = int(cpuProfileLost)
+	cpuProfileBuf = nil
+	cpuProfileIndex = nil
+	return
+}
+
+// setProfileTimer sets the ITIMER_PROF interval timer to the given interval in
+// microseconds. An interval of 0 stops the timer.
+func setProfileTimer(usec int) int32 {
+	t := itimerval{
+		interval: timeval{tv_sec: usec / 1000000, tv_usec: usec % 1000000},
+		value:    timeval{tv_sec: usec / 1000000, tv_usec: usec % 1000000},
+	}
+	return setitimer(_ITIMER_PROF, &t, nil)
+}
+
+// sigprofHandler is called by the OS on every SIGPROF signal. It must not
+// allocate heap memory, as the signal may arrive while the heap is being
+// modified.
+//
+//export tinygo_sigprof
+func sigprofHandler(sig int32, info unsafe.Pointer, context *ucontext) {
+	if cpuProfileBuf == nil {
+		// The profiler was stopped.
+		return
+	}
+	stack := cpuProfileStack[:]
+	stack[0] = context.pc()
+	n := 1
+	if task.OnSystemStack() {
+		// The bounds of goroutine stacks are not known here, so only the
+		// system stack is walked. Samples taken on a goroutine stack (with
+		// the tasks scheduler) only include the interrupted function.
+		n += walkFramePointers(context.fp(), context.sp(), stack[1:])
+	}
+	addCPUSample(stack[:n])
+}
+
+// addCPUSample adds the given stack to the CPU profile buffer, or increments
+// the count of an existing identical stack.
+func addCPUSample(stack []uintptr) {
+	hash := uintptr(len(stack))
+	for _, pc := range stack {
+		hash = hash*31 + pc
+	}
+	mask := uintptr(len(cpuProfileIndex) - 1)
+	for i := hash & mask; ; i = (i + 1) & mask {
+		offset := cpuProfileIndex[i]
+		if offset == 0 {
+			// This is a new stack. Add it to the end of the buffer, but keep
+			// the hash table at most half full so that lookups stay fast.
+			if cpuProfileLen+2+uintptr(len(stack)) > uintptr(len(cpuProfileBuf)) || cpuProfileNumStacks >= uintptr(len(cpuProfileIndex))/2 {
+				cpuProfileLost++
+				return
+			}
+			cpuProfileIndex[i] = cpuProfileLen + 1
+			cpuProfileNumStacks++
+			cpuProfileBuf[cpuProfileLen] = 1
+			cpuProfileBuf[cpuProfileLen+1] = uintptr(len(stack))
+			copy(cpuProfileBuf[cpuProfileLen+2:], stack)
+			cpuProfileLen += 2 + uintptr(len(stack))
+			return
+		}
+		record := cpuProfileBuf[offset-1:]
+		if record[1] == uintptr(len(stack)) && equalStacks(record[2:2+len(stack)], stack) {
+			record[0]++
+			return
+		}
+	}
+}
+
+func equalStacks(a, b []uintptr) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// walkFramePointers follows the chain of frame pointers starting at fp and
+// stores the return address of each frame in buf. It stops at the top of the
+// system stack or when the frame pointer doesn't look valid, which happens for
+// example in C code compiled without frame pointers. The frame pointer is only
+// dereferenced when it lies between sp and the top of the stack, so that a
+// garbage frame pointer can never cause a crash.
+//
+// Return addresses point to the instruction after the call, so one is
+// subtracted to get an address within the call instruction. That way, the
+// profile shows the line of the call.
+func walkFramePointers(fp, sp uintptr, buf []uintptr) int {
+	n := 0
+	for n < len(buf) {
+		if fp < sp || fp >= stackTop-unsafe.Sizeof(fp) || fp%unsafe.Alignof(fp) != 0 {
+			break
+		}
+		retAddr := *(*uintptr)(unsafe.Pointer(fp + unsafe.Sizeof(fp)))
+		if retAddr == 0 {
+			break
+		}
+		buf[n] = retAddr - 1
+		n++
+		next := *(*uintptr)(unsafe.Pointer(fp))
+		if next <= fp {
+			// The stack grows down, so the next frame must be higher up.
+			break
+		}
+		fp = next
+	}
+	return n
+}
+
+// Memory profile state. The records are stored in a fixed size array because
+// they are updated while allocating heap memory.
+var (
+	memProfileRecords   [128]MemProfileRecord
+	memProfileObjects   [512]memProfileObject
+	memProfileAllocated uintptr // bytes allocated since the last sample
+	memProfileStack     [maxProfileStack]uintptr
+)
+
+// memProfileAlloc is called by the heap allocator for every allocated object.
+// It decides whether to sample this allocation and if so, records the stack
+// trace of the allocation.
+//
+//go:noinline
+func memProfileAlloc(ptr unsafe.Pointer, size uintptr) {
+	if MemProfileRate <= 0 {
+		return
+	}
+	memProfileAllocated += size
+	if memProfileAllocated < uintptr(MemProfileRate) {
+		return
+	}
+	memProfileAllocated = 0
+
+	// Record the stack trace, skipping the first frame (which is in alloc).
+	stack := memProfileStack[:]
+	for i := range stack {
+		stack[i] = 0
+	}
+	n := 0
+	if task.OnSystemStack() {
+		n = walkFramePointers(getCallerFramePointer(), getCurrentStackPointer(), stack)
+	}
+	if n > 0 {
+		copy(stack, stack[1:n])
+		stack[n-1] = 0
+	}
+
+	// Find the record for this stack trace, or an empty record if there is
+	// none yet.
+	var record *MemProfileRecord
+	for i := range memProfileRecords {
+		r := &memProfileRecords[i]
+		if r.AllocObjects == 0 || r.Stack0 == memProfileStack {
+			record = r
+			break
+		}
+	}
+	if record == nil {
+		// All records are in use, so this sample is dropped.
+		return
+	}
+	record.Stack0 = memProfileStack
+	record.AllocBytes += int64(size)
+	record.AllocObjects++
+
+	// Keep track of this object, so that the garbage collector can tell when
+	// it is freed.
+	for i := range memProfileObjects {
+		obj := &memProfileObjects[i]
+		if obj.addr == 0 {
+			obj.addr = uintptr(ptr)
+			obj.size = size
+			obj.record = record
+			break
+		}
+	}
+}
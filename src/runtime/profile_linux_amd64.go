@@ -0,0 +1,31 @@
+// +build !baremetal,!nintendoswitch
+
+package runtime
+
+import (
+	"device"
+	"unsafe"
+)
+
+// The start of the ucontext_t struct as defined by glibc, up to and including
+// the general purpose registers in uc_mcontext.
+type ucontext struct {
+	_     [5]uintptr // uc_flags, uc_link, uc_stack
+	gregs [23]uintptr
+}
+
+func (c *ucontext) pc() uintptr { return c.gregs[16] } // REG_RIP
+func (c *ucontext) sp() uintptr { return c.gregs[15] } // REG_RSP
+func (c *ucontext) fp() uintptr { return c.gregs[10] } // REG_RBP
+
+// sigprofHandlerAddress returns the address of the SIGPROF signal handler, to
+// be passed to sigaction.
+func sigprofHandlerAddress() uintptr {
+	return device.AsmFull("leaq tinygo_sigprof(%rip), {}", nil)
+}
+
+// getCallerFramePointer returns the frame pointer of the calling function.
+//go:noinline
+func getCallerFramePointer() uintptr {
+	return *(*uintptr)(unsafe.Pointer(device.AsmFull("movq %rbp, {}", nil)))
+}
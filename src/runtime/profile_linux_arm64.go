@@ -0,0 +1,35 @@
+// +build !baremetal,!nintendoswitch
+
+package runtime
+
+import (
+	"device/arm"
+	"unsafe"
+)
+
+// The start of the ucontext_t struct as defined by glibc, up to and including
+// the registers in uc_mcontext.
+type ucontext struct {
+	_            [22]uint64 // uc_flags, uc_link, uc_stack, uc_sigmask, padding
+	faultAddress uint64
+	regs         [31]uint64
+	sp_          uint64
+	pc_          uint64
+	pstate       uint64
+}
+
+func (c *ucontext) pc() uintptr { return uintptr(c.pc_) }
+func (c *ucontext) sp() uintptr { return uintptr(c.sp_) }
+func (c *ucontext) fp() uintptr { return uintptr(c.regs[29]) } // x29
+
+// sigprofHandlerAddress returns the address of the SIGPROF signal handler, to
+// be passed to sigaction.
+func sigprofHandlerAddress() uintptr {
+	return arm.AsmFull("adrp {}, tinygo_sigprof\n\tadd {}, {}, :lo12:tinygo_sigprof", nil)
+}
+
+// getCallerFramePointer returns the frame pointer of the calling function.
+//go:noinline
+func getCallerFramePointer() uintptr {
+	return *(*uintptr)(unsafe.Pointer(arm.AsmFull("mov {}, x29", nil)))
+}
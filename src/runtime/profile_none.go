@@ -0,0 +1,26 @@
+// +build !linux baremetal nintendoswitch !amd64,!arm64
+
+package runtime
+
+import "unsafe"
+
+// Profiling is not supported on this system, so the memory profile is always
+// empty and the CPU profiler cannot be started.
+
+var (
+	memProfileRecords [0]MemProfileRecord
+	memProfileObjects [0]memProfileObject
+)
+
+func memProfileAlloc(ptr unsafe.Pointer, size uintptr) {
+}
+
+//go:linkname pprof_startCPUProfile runtime/pprof.startCPUProfile
+func pprof_startCPUProfile(hz int, buf, index []uintptr) bool {
+	return false
+}
+
+//go:linkname pprof_stopCPUProfile runtime/pprof.stopCPUProfile
+func pprof_stopCPUProfile() (n int, lost int) {
+	return 0, 0
+}
@@ -23,11 +23,16 @@ func GOROOT() string {
 	return "/usr/local/go"
 }
 
-// TODO: fill with real args.
-var args = []string{"/proc/self/exe"}
+// args contains the command line arguments, see os_runtime_args.
+var args []string
 
 //go:linkname os_runtime_args os.runtime_args
 func os_runtime_args() []string {
+	if args == nil {
+		// The arguments are only converted to Go strings when they are
+		// needed, as the heap is not yet initialized when the program starts.
+		args = platformArgs()
+	}
 	return args
 }
 
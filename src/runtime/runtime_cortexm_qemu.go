@@ -23,8 +23,19 @@ func main() {
 	run()
 
 	// Signal successful exit.
-	arm.SemihostingCall(arm.SemihostingReportException, arm.SemihostingApplicationExit)
-	abort()
+	exit(0)
+}
+
+// exit makes QEMU exit with the given exit code, using semihosting.
+func exit(code int) {
+	// SYS_EXIT_EXTENDED takes a pointer to the reason and the exit code.
+	block := [2]uintptr{arm.SemihostingApplicationExit, uintptr(code)}
+	arm.SemihostingCall(arm.SemihostingExitExtended, uintptr(unsafe.Pointer(&block)))
+
+	// Lock up forever (should be unreachable).
+	for {
+		arm.Asm("wfi")
+	}
 }
 
 const asyncScheduler = false
@@ -54,7 +65,11 @@ func putchar(c byte) {
 }
 
 func waitForEvents() {
-	arm.Asm("wfe")
+	// The emulated chip has no interrupts that could wake up the goroutines
+	// that are still blocked, so this is a deadlock. Show what they are
+	// waiting for, like on systems with an operating system.
+	printGoroutines()
+	runtimePanic("deadlocked: no event source")
 }
 
 func abort() {
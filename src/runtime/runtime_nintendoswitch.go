@@ -224,6 +224,13 @@ func growHeap() bool {
 	return false
 }
 
+// cgoMalloc allocates memory for C.CString and C.CBytes. There is no libc
+// malloc, so it is allocated on the GC heap. The memory only holds bytes copied
+// from Go, so it is known to be pointer-free.
+func cgoMalloc(size uintptr) unsafe.Pointer {
+	return alloc(size, gcLayoutNoPointers)
+}
+
 // getHeapBase returns the start address of the heap
 // this is externally linked by gonx
 func getHeapBase() uintptr {
@@ -21,7 +21,19 @@ func postinit() {}
 func main() {
 	preinit()
 	run()
-	abort()
+	exit(0)
+}
+
+// exit makes QEMU exit with the given exit code, using semihosting.
+func exit(code int) {
+	// SYS_EXIT_EXTENDED takes a pointer to the reason and the exit code.
+	block := [2]uintptr{riscv.SemihostingApplicationExit, uintptr(code)}
+	riscv.SemihostingCall(riscv.SemihostingExitExtended, uintptr(unsafe.Pointer(&block)))
+
+	// Lock up forever (should be unreachable).
+	for {
+		riscv.Asm("wfi")
+	}
 }
 
 const asyncScheduler = false
@@ -50,8 +62,6 @@ func ticks() timeUnit {
 var (
 	// UART0 output register.
 	stdoutWrite = (*volatile.Register8)(unsafe.Pointer(uintptr(0x10000000)))
-	// SiFive test finisher
-	testFinisher = (*volatile.Register16)(unsafe.Pointer(uintptr(0x100000)))
 )
 
 func putchar(c byte) {
@@ -59,8 +69,8 @@ func putchar(c byte) {
 }
 
 func abort() {
-	// Make sure the QEMU process exits.
-	testFinisher.Set(0x5555) // FINISHER_PASS
+	// Signal an abnormal exit. QEMU will exit with status 1.
+	riscv.SemihostingCall(riscv.SemihostingExit, riscv.SemihostingRunTimeErrorUnknown)
 
 	// Lock up forever (as a fallback).
 	for {
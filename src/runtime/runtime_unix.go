@@ -39,11 +39,19 @@ const CLOCK_MONOTONIC_RAW = 4
 
 var stackTop uintptr
 
+// The arguments passed to main, see platformArgs.
+var (
+	main_argc int32
+	main_argv *unsafe.Pointer
+)
+
 func postinit() {}
 
 // Entry point for Go. Initialize all packages and call main.main().
 //export main
-func main() int {
+func main(argc int32, argv *unsafe.Pointer) int {
+	main_argc = argc
+	main_argv = argv
 	preinit()
 
 	// Obtain the initial stack pointer right before calling the run() function.
@@ -56,6 +64,21 @@ func main() int {
 	return 0
 }
 
+// platformArgs returns the command line arguments passed to main. The strings
+// point directly to the C strings in argv, which are never freed.
+func platformArgs() []string {
+	args := make([]string, main_argc)
+	for i := range args {
+		arg := *(**byte)(unsafe.Pointer(uintptr(unsafe.Pointer(main_argv)) + uintptr(i)*unsafe.Sizeof(main_argv)))
+		length := uintptr(0)
+		for *(*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(arg)) + length)) != 0 {
+			length++
+		}
+		args[i] = *(*string)(unsafe.Pointer(&_string{ptr: arg, length: length}))
+	}
+	return args
+}
+
 // Must be a separate function to get the correct stack pointer.
 //go:noinline
 func runMain() {
@@ -101,6 +124,12 @@ func syscall_Exit(code int) {
 	exit(code)
 }
 
+// cgoMalloc allocates memory for C.CString and C.CBytes, which must be freed
+// with C.free.
+func cgoMalloc(size uintptr) unsafe.Pointer {
+	return malloc(size)
+}
+
 func extalloc(size uintptr) unsafe.Pointer {
 	return malloc(size)
 }
@@ -16,6 +16,30 @@ func _start() {
 	run()
 }
 
+// platformArgs returns the command line arguments as provided by the WASI
+// host.
+func platformArgs() []string {
+	var argc, argvBufSize uint32
+	args_sizes_get(&argc, &argvBufSize)
+	if argc == 0 {
+		return []string{}
+	}
+	argv := make([]*byte, argc)
+	argvBuf := make([]byte, argvBufSize)
+	args_get(&argv[0], &argvBuf[0])
+	args := make([]string, argc)
+	for i, arg := range argv {
+		// Each argument is a null terminated string in argvBuf.
+		start := uintptr(unsafe.Pointer(arg)) - uintptr(unsafe.Pointer(&argvBuf[0]))
+		end := start
+		for argvBuf[end] != 0 {
+			end++
+		}
+		args[i] = string(argvBuf[start:end])
+	}
+	return args
+}
+
 func ticksToNanoseconds(ticks timeUnit) int64 {
 	return int64(ticks)
 }
@@ -64,6 +88,14 @@ func ticks() timeUnit {
 //export clock_time_get
 func clock_time_get(clockid uint32, precision uint64, time *int64) (errno uint16)
 
+//go:wasm-module wasi_unstable
+//export args_sizes_get
+func args_sizes_get(argc *uint32, argvBufSize *uint32) (errno uint16)
+
+//go:wasm-module wasi_unstable
+//export args_get
+func args_get(argv **byte, argvBuf *byte) (errno uint16)
+
 //go:wasm-module wasi_unstable
 //export poll_oneoff
 func poll_oneoff(in *__wasi_subscription_t, out *__wasi_event_t, nsubscriptions uint32, nevents *uint32) (errno uint16)
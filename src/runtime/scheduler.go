@@ -4,7 +4,8 @@ package runtime
 // cooperative round robin scheduler, with a runqueue that contains a linked
 // list of goroutines (tasks) that should be run next, in order of when they
 // were added to the queue (first-in, first-out). It also contains a sleep queue
-// with sleeping goroutines in order of when they should be re-activated.
+// with sleeping goroutines in order of when they should be re-activated, and a
+// timer queue (see timer.go) with timers of the time package.
 //
 // The scheduler is used both for the coroutine based scheduler and for the task
 // based scheduler (see compiler/goroutine-lowering.go for a description). In
@@ -58,6 +59,7 @@ func scheduleLogChan(msg string, ch *channel, t *task.Task) {
 //go:noinline
 func deadlock() {
 	// call yield without requesting a wakeup
+	task.Current().SetWaitReason(waitReasonForever, nil)
 	task.Pause()
 	panic("unreachable")
 }
@@ -119,7 +121,7 @@ func scheduler() {
 	for !schedulerDone {
 		scheduleLog("")
 		scheduleLog("  schedule")
-		if sleepQueue != nil {
+		if sleepQueue != nil || len(timerQueue) != 0 {
 			now = ticks()
 		}
 
@@ -134,16 +136,32 @@ func scheduler() {
 			runqueue.Push(t)
 		}
 
+		// Run the callbacks of timers that have expired.
+		if len(timerQueue) != 0 {
+			runTimers(ticksToNanoseconds(now))
+		}
+
 		t := runqueue.Pop()
 		if t == nil {
-			if sleepQueue == nil {
+			if sleepQueue == nil && len(timerQueue) == 0 {
 				if asyncScheduler {
 					return
 				}
 				waitForEvents()
 				continue
 			}
-			timeLeft := timeUnit(sleepQueue.Data) - (now - sleepQueueBaseTime)
+			var timeLeft timeUnit
+			if sleepQueue != nil {
+				timeLeft = timeUnit(sleepQueue.Data) - (now - sleepQueueBaseTime)
+			}
+			if len(timerQueue) != 0 {
+				// Wake up at the next timer, if it expires before the first
+				// sleeping goroutine.
+				timerLeft := nanosecondsToTicks(timerQueue[0].when - ticksToNanoseconds(now))
+				if sleepQueue == nil || timerLeft < timeLeft {
+					timeLeft = timerLeft
+				}
+			}
 			if schedulerDebug {
 				println("  sleeping...", sleepQueue, uint(timeLeft))
 				for t := sleepQueue; t != nil; t = t.Next {
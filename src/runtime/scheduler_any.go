@@ -11,6 +11,40 @@ func sleep(duration int64) {
 	task.Pause()
 }
 
+//go:linkname startTimer time.startTimer
+func startTimer(t *timer) {
+	addTimer(t)
+}
+
+//go:linkname stopTimer time.stopTimer
+func stopTimer(t *timer) bool {
+	return removeTimer(t)
+}
+
+// resetTimer changes the expiration time of a timer, restarting it if it was
+// already stopped or expired. It returns whether the timer was still active.
+// The time package calls it through time_resetTimer, as the signature depends
+// on the Go version.
+func resetTimer(t *timer, when int64) bool {
+	active := removeTimer(t)
+	t.when = when
+	startTimer(t)
+	return active
+}
+
+// modTimer changes all properties of a timer, used since Go 1.15 to implement
+// Ticker.Reset.
+//go:linkname modTimer time.modTimer
+func modTimer(t *timer, when, period int64, f func(interface{}, uintptr), arg interface{}, seq uintptr) {
+	removeTimer(t)
+	t.when = when
+	t.period = period
+	t.f = f
+	t.arg = arg
+	t.seq = seq
+	startTimer(t)
+}
+
 // run is called by the program entry point to execute the go program.
 // With a scheduler, init and the main function are invoked in a goroutine before starting the scheduler.
 func run() {
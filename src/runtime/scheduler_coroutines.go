@@ -7,3 +7,19 @@ package runtime
 func getSystemStackPointer() uintptr {
 	return getCurrentStackPointer()
 }
+
+// currentDeferFrame is the innermost defer frame. A single global is enough,
+// because defer frames are only kept in functions that are not lowered to
+// coroutines (see transform/coroutines.go), so a goroutine can never be paused
+// while it has a defer frame.
+var currentDeferFrame *deferFrame
+
+// getDeferFrame returns the innermost defer frame of the current goroutine.
+func getDeferFrame() *deferFrame {
+	return currentDeferFrame
+}
+
+// setDeferFrame replaces the innermost defer frame of the current goroutine.
+func setDeferFrame(frame *deferFrame) {
+	currentDeferFrame = frame
+}
@@ -23,3 +23,17 @@ func run() {
 }
 
 const hasScheduler = false
+
+// currentDeferFrame is the innermost defer frame. There is only one goroutine
+// without a scheduler, so it can be stored in a global.
+var currentDeferFrame *deferFrame
+
+// getDeferFrame returns the innermost defer frame of the current goroutine.
+func getDeferFrame() *deferFrame {
+	return currentDeferFrame
+}
+
+// setDeferFrame replaces the innermost defer frame of the current goroutine.
+func setDeferFrame(frame *deferFrame) {
+	currentDeferFrame = frame
+}
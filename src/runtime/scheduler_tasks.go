@@ -2,7 +2,10 @@
 
 package runtime
 
-import "internal/task"
+import (
+	"internal/task"
+	"unsafe"
+)
 
 // getSystemStackPointer returns the current stack pointer of the system stack.
 // This is not necessarily the same as the current stack pointer.
@@ -15,3 +18,24 @@ func getSystemStackPointer() uintptr {
 	}
 	return sp
 }
+
+// systemDeferFrame is the innermost defer frame of code that is not running in
+// a goroutine, such as the scheduler itself.
+var systemDeferFrame *deferFrame
+
+// getDeferFrame returns the innermost defer frame of the current goroutine.
+func getDeferFrame() *deferFrame {
+	if t := task.Current(); t != nil {
+		return (*deferFrame)(t.DeferFrame)
+	}
+	return systemDeferFrame
+}
+
+// setDeferFrame replaces the innermost defer frame of the current goroutine.
+func setDeferFrame(frame *deferFrame) {
+	if t := task.Current(); t != nil {
+		t.DeferFrame = unsafe.Pointer(frame)
+		return
+	}
+	systemDeferFrame = frame
+}
@@ -15,6 +15,13 @@ func Caller(skip int) (pc uintptr, file string, line int, ok bool) {
 	return 0, "", 0, false
 }
 
+// Stack formats a description of the calling goroutine into buf and returns
+// the number of bytes written to buf. If all is true, the other goroutines that
+// have not exited yet are described as well. Stack traces are not available:
+// each goroutine is described with its state, the function it was started with
+// and the channels it is blocked on.
 func Stack(buf []byte, all bool) int {
-	return 0
+	d := goroutineDump{buf: buf}
+	d.writeGoroutines(all)
+	return d.n
 }
@@ -0,0 +1,105 @@
+package runtime
+
+// This file implements the timers used by the time package: time.Timer,
+// time.Ticker, time.After and time.AfterFunc. Active timers are kept in a
+// binary min-heap ordered by the time at which they expire, which is checked by
+// the scheduler just like the sleep queue. Timers are only supported when there
+// is a scheduler.
+//
+// Every timer stores its position in the heap (see heapIndex), so that stopping
+// or resetting a timer doesn't need to search the heap.
+
+// timerQueue is the heap of active timers, the first to expire first.
+var timerQueue []*timer
+
+// addTimer inserts the timer in the timer queue.
+func addTimer(t *timer) {
+	timerQueue = append(timerQueue, t)
+	i := len(timerQueue) - 1
+	t.setHeapIndex(i)
+	timerSiftUp(i)
+}
+
+// removeTimer removes the given timer from the timer queue. It returns whether
+// the timer was still active.
+func removeTimer(t *timer) bool {
+	i := t.heapIndex()
+	if i < 0 {
+		return false
+	}
+	last := len(timerQueue) - 1
+	if i != last {
+		// Move the last timer in the place of the removed timer, and restore
+		// the heap order from there.
+		timerQueue[i] = timerQueue[last]
+		timerQueue[i].setHeapIndex(i)
+	}
+	timerQueue[last] = nil
+	timerQueue = timerQueue[:last]
+	t.setHeapIndex(-1)
+	if i != last {
+		timerSiftDown(i)
+		timerSiftUp(i)
+	}
+	return true
+}
+
+// timerSiftUp moves the timer at index i up the heap until its parent expires
+// before it.
+func timerSiftUp(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if timerQueue[parent].when <= timerQueue[i].when {
+			break
+		}
+		timerSwap(i, parent)
+		i = parent
+	}
+}
+
+// timerSiftDown moves the timer at index i down the heap until both children
+// expire after it.
+func timerSiftDown(i int) {
+	for {
+		smallest := i
+		if left := 2*i + 1; left < len(timerQueue) && timerQueue[left].when < timerQueue[smallest].when {
+			smallest = left
+		}
+		if right := 2*i + 2; right < len(timerQueue) && timerQueue[right].when < timerQueue[smallest].when {
+			smallest = right
+		}
+		if smallest == i {
+			break
+		}
+		timerSwap(i, smallest)
+		i = smallest
+	}
+}
+
+// timerSwap swaps two timers in the heap and updates their positions.
+func timerSwap(i, j int) {
+	timerQueue[i], timerQueue[j] = timerQueue[j], timerQueue[i]
+	timerQueue[i].setHeapIndex(i)
+	timerQueue[j].setHeapIndex(j)
+}
+
+// runTimers runs the callbacks of all timers that expired at the given time (in
+// nanoseconds). Periodic timers (tickers) are put back in the timer queue.
+func runTimers(now int64) {
+	for len(timerQueue) != 0 && timerQueue[0].when <= now {
+		t := timerQueue[0]
+		removeTimer(t)
+		scheduleLog("  timer expired")
+
+		// The callback is implemented in the time package. It either sends
+		// the current time on a channel (without blocking) or starts a new
+		// goroutine, so it is safe to call it from the scheduler.
+		t.f(t.arg, t.seq)
+
+		if t.period > 0 && t.heapIndex() < 0 {
+			// Skip ticks that were missed, like the Go runtime does.
+			t.when += t.period * (1 + (now-t.when)/t.period)
+			addTimer(t)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// +build !go1.14
+
+package runtime
+
+// timer has the same layout as time.runtimeTimer in Go 1.13 and below.
+type timer struct {
+	tb uintptr
+	i  int
+
+	when   int64
+	period int64
+	f      func(interface{}, uintptr)
+	arg    interface{}
+	seq    uintptr
+}
+
+// heapIndex returns the position of the timer in timerQueue, or -1 if it is not
+// active. The i field is used for the same purpose by the Go runtime, but is
+// offset by one here so that new timers are not active.
+func (t *timer) heapIndex() int {
+	return t.i - 1
+}
+
+// setHeapIndex stores the position of the timer in timerQueue.
+func (t *timer) setHeapIndex(i int) {
+	t.i = i + 1
+}
@@ -0,0 +1,27 @@
+// +build go1.14
+
+package runtime
+
+// timer has the same layout as time.runtimeTimer in Go 1.14 and above.
+type timer struct {
+	pp       uintptr
+	when     int64
+	period   int64
+	f        func(interface{}, uintptr)
+	arg      interface{}
+	seq      uintptr
+	nextwhen int64
+	status   uint32
+}
+
+// heapIndex returns the position of the timer in timerQueue, or -1 if it is not
+// active. The pp field is not used by the time package, so it is used to store
+// the position plus one (so that new timers are not active).
+func (t *timer) heapIndex() int {
+	return int(t.pp) - 1
+}
+
+// setHeapIndex stores the position of the timer in timerQueue.
+func (t *timer) setHeapIndex(i int) {
+	t.pp = uintptr(i + 1)
+}
@@ -0,0 +1,9 @@
+// +build !scheduler.none,go1.14,!go1.16
+
+package runtime
+
+// In Go 1.14 and 1.15, time.resetTimer does not have a result.
+//go:linkname time_resetTimer time.resetTimer
+func time_resetTimer(t *timer, when int64) {
+	resetTimer(t, when)
+}
@@ -0,0 +1,9 @@
+// +build !scheduler.none,go1.16
+
+package runtime
+
+// Since Go 1.16, time.resetTimer returns whether the timer was still active.
+//go:linkname time_resetTimer time.resetTimer
+func time_resetTimer(t *timer, when int64) bool {
+	return resetTimer(t, when)
+}
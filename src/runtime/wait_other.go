@@ -4,5 +4,8 @@
 package runtime
 
 func waitForEvents() {
+	// There are no event sources that could wake up the goroutines that are
+	// still blocked, so show what they are waiting for.
+	printGoroutines()
 	runtimePanic("deadlocked: no event source")
 }
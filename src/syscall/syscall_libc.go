@@ -13,7 +13,10 @@ type sliceHeader struct {
 }
 
 func Close(fd int) (err error) {
-	return ENOSYS // TODO
+	if libc_close(int32(fd)) < 0 {
+		err = getErrno()
+	}
+	return
 }
 
 func Write(fd int, p []byte) (n int, err error) {
@@ -26,23 +29,61 @@ func Write(fd int, p []byte) (n int, err error) {
 }
 
 func Read(fd int, p []byte) (n int, err error) {
-	return 0, ENOSYS // TODO
+	buf, count := splitSlice(p)
+	n = libc_read(int32(fd), buf, uint(count))
+	if n < 0 {
+		err = getErrno()
+	}
+	return
 }
 
 func Seek(fd int, offset int64, whence int) (off int64, err error) {
-	return 0, ENOSYS // TODO
+	off = libc_lseek(int32(fd), offset, int32(whence))
+	if off < 0 {
+		err = getErrno()
+	}
+	return
 }
 
 func Open(path string, mode int, perm uint32) (fd int, err error) {
-	return 0, ENOSYS // TODO
+	data := cstring(path)
+	fd = int(libc_open(&data[0], int32(mode), perm))
+	if fd < 0 {
+		err = getErrno()
+	}
+	return
 }
 
 func Mkdir(path string, mode uint32) (err error) {
-	return ENOSYS // TODO
+	data := cstring(path)
+	if libc_mkdir(&data[0], mode) < 0 {
+		err = getErrno()
+	}
+	return
 }
 
 func Unlink(path string) (err error) {
-	return ENOSYS // TODO
+	data := cstring(path)
+	if libc_unlink(&data[0]) < 0 {
+		err = getErrno()
+	}
+	return
+}
+
+func Rename(from, to string) (err error) {
+	fromData := cstring(from)
+	toData := cstring(to)
+	if libc_rename(&fromData[0], &toData[0]) < 0 {
+		err = getErrno()
+	}
+	return
+}
+
+func Ftruncate(fd int, length int64) (err error) {
+	if libc_ftruncate(int32(fd), length) < 0 {
+		err = getErrno()
+	}
+	return
 }
 
 func Kill(pid int, sig Signal) (err error) {
@@ -76,10 +117,50 @@ func splitSlice(p []byte) (buf *byte, len uintptr) {
 	return slice.buf, slice.len
 }
 
+// cstring returns the string as a null terminated byte slice, for use in libc
+// calls.
+func cstring(s string) []byte {
+	data := make([]byte, len(s)+1)
+	copy(data, s)
+	return data
+}
+
 // ssize_t write(int fd, const void *buf, size_t count)
 //export write
 func libc_write(fd int32, buf *byte, count uint) int
 
+// ssize_t read(int fd, void *buf, size_t count);
+//export read
+func libc_read(fd int32, buf *byte, count uint) int
+
+// off_t lseek(int fd, off_t offset, int whence);
+//export lseek
+func libc_lseek(fd int32, offset int64, whence int32) int64
+
+// int open(const char *pathname, int flags, mode_t mode);
+//export open
+func libc_open(pathname *byte, flags int32, mode uint32) int32
+
+// int close(int fd);
+//export close
+func libc_close(fd int32) int32
+
+// int mkdir(const char *pathname, mode_t mode);
+//export mkdir
+func libc_mkdir(pathname *byte, mode uint32) int32
+
+// int unlink(const char *pathname);
+//export unlink
+func libc_unlink(pathname *byte) int32
+
+// int rename(const char *from, const char *to);
+//export rename
+func libc_rename(from, to *byte) int32
+
+// int ftruncate(int fd, off_t length);
+//export ftruncate
+func libc_ftruncate(fd int32, length int64) int32
+
 // char *getenv(const char *name);
 //export getenv
 func libc_getenv(name *byte) *byte
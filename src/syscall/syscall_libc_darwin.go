@@ -54,3 +54,83 @@ const (
 	O_TRUNC  = 0x400
 	O_EXCL   = 0x800
 )
+
+const (
+	S_IFBLK  = 0x6000
+	S_IFCHR  = 0x2000
+	S_IFDIR  = 0x4000
+	S_IFIFO  = 0x1000
+	S_IFLNK  = 0xa000
+	S_IFMT   = 0xf000
+	S_IFREG  = 0x8000
+	S_IFSOCK = 0xc000
+	S_ISGID  = 0x400
+	S_ISUID  = 0x800
+	S_ISVTX  = 0x200
+)
+
+type Timespec struct {
+	Sec  int64
+	Nsec int64
+}
+
+// Stat_t is copied from src/syscall/ztypes_darwin_amd64.go.
+type Stat_t struct {
+	Dev           int32
+	Mode          uint16
+	Nlink         uint16
+	Ino           uint64
+	Uid           uint32
+	Gid           uint32
+	Rdev          int32
+	Pad_cgo_0     [4]byte
+	Atimespec     Timespec
+	Mtimespec     Timespec
+	Ctimespec     Timespec
+	Birthtimespec Timespec
+	Size          int64
+	Blocks        int64
+	Blksize       int32
+	Flags         uint32
+	Gen           uint32
+	Lspare        int32
+	Qspare        [2]int64
+}
+
+func Stat(path string, st *Stat_t) (err error) {
+	data := cstring(path)
+	if libc_stat(&data[0], st) < 0 {
+		err = getErrno()
+	}
+	return
+}
+
+func Lstat(path string, st *Stat_t) (err error) {
+	data := cstring(path)
+	if libc_lstat(&data[0], st) < 0 {
+		err = getErrno()
+	}
+	return
+}
+
+func Fstat(fd int, st *Stat_t) (err error) {
+	if libc_fstat(int32(fd), st) < 0 {
+		err = getErrno()
+	}
+	return
+}
+
+// The $INODE64 variants use the struct stat layout with 64-bit inodes, which
+// matches Stat_t above.
+
+// int stat(const char *path, struct stat *buf);
+//export stat$INODE64
+func libc_stat(path *byte, buf *Stat_t) int32
+
+// int lstat(const char *path, struct stat *buf);
+//export lstat$INODE64
+func libc_lstat(path *byte, buf *Stat_t) int32
+
+// int fstat(int fd, struct stat *buf);
+//export fstat$INODE64
+func libc_fstat(fd int32, buf *Stat_t) int32
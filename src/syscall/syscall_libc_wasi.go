@@ -46,5 +46,106 @@ const (
 var libcErrno uintptr
 
 func getErrno() error {
+	// The errno values of wasi-libc are different from the Linux values used
+	// in this package. Convert the ones that are commonly checked for.
+	switch libcErrno {
+	case 2:
+		return EACCES
+	case 8:
+		return EBADF
+	case 20:
+		return EEXIST
+	case 28:
+		return EINVAL
+	case 31:
+		return EISDIR
+	case 44:
+		return ENOENT
+	case 52:
+		return ENOSYS
+	case 54:
+		return ENOTDIR
+	case 55:
+		return ENOTEMPTY
+	case 63:
+		return EPERM
+	case 75:
+		return EXDEV
+	}
 	return Errno(libcErrno)
 }
+
+// File types and permission bits in the Mode field of Stat_t. These have the
+// same values as in wasi-libc.
+const (
+	S_IFBLK  = 0x6000
+	S_IFCHR  = 0x2000
+	S_IFDIR  = 0x4000
+	S_IFIFO  = 0x1000
+	S_IFLNK  = 0xa000
+	S_IFMT   = 0xf000
+	S_IFREG  = 0x8000
+	S_IFSOCK = 0xc000
+	S_ISGID  = 0x400
+	S_ISUID  = 0x800
+	S_ISVTX  = 0x200
+)
+
+type Timespec struct {
+	Sec  int64
+	Nsec int32
+}
+
+// Stat_t is the same as struct stat in wasi-libc.
+type Stat_t struct {
+	Dev     uint64
+	Ino     uint64
+	Nlink   uint64
+	Mode    uint32
+	Uid     uint32
+	Gid     uint32
+	_       uint32
+	Rdev    uint64
+	Size    int64
+	Blksize int32
+	Blocks  int64
+	Atim    Timespec
+	Mtim    Timespec
+	Ctim    Timespec
+	_       [3]int64
+}
+
+func Stat(path string, st *Stat_t) (err error) {
+	data := cstring(path)
+	if libc_stat(&data[0], st) < 0 {
+		err = getErrno()
+	}
+	return
+}
+
+func Lstat(path string, st *Stat_t) (err error) {
+	data := cstring(path)
+	if libc_lstat(&data[0], st) < 0 {
+		err = getErrno()
+	}
+	return
+}
+
+func Fstat(fd int, st *Stat_t) (err error) {
+	if libc_fstat(int32(fd), st) < 0 {
+		err = getErrno()
+	}
+	return
+}
+
+// int stat(const char *path, struct stat *buf);
+//export stat
+func libc_stat(path *byte, buf *Stat_t) int32
+
+// int lstat(const char *path, struct stat *buf);
+//export lstat
+func libc_lstat(path *byte, buf *Stat_t) int32
+
+// int fstat(int fd, struct stat *buf);
+//export fstat
+func libc_fstat(fd int32, buf *Stat_t) int32
@@ -6,14 +6,26 @@
 
 package testing
 
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+)
+
 // B is a type passed to Benchmark functions to manage benchmark timing and to
 // specify the number of iterations to run.
-//
-// TODO: Implement benchmarks. This struct allows test files containing
-// benchmarks to compile and run, but will not run the benchmarks themselves.
 type B struct {
 	common
 	N int
+
+	benchFunc func(b *B)
+	benchTime benchTimeFlag
+	hasSub    bool  // benchmark has sub-benchmarks, so isn't measured itself
+	timerOn   bool  // the timer is running
+	duration  int64 // measured time in nanoseconds
+	bytes     int64 // bytes processed in one iteration, see SetBytes
+	result    BenchmarkResult
 }
 
 type InternalBenchmark struct {
@@ -21,14 +33,240 @@ type InternalBenchmark struct {
 	F    func(b *B)
 }
 
+// BenchmarkResult contains the results of a benchmark run.
+type BenchmarkResult struct {
+	N     int           // The number of iterations.
+	T     time.Duration // The total time taken.
+	Bytes int64         // Bytes processed in one iteration.
+}
+
+// NsPerOp returns the "ns/op" metric.
+func (r BenchmarkResult) NsPerOp() int64 {
+	if r.N <= 0 {
+		return 0
+	}
+	return r.T.Nanoseconds() / int64(r.N)
+}
+
+// mbPerSec returns the "MB/s" metric.
+func (r BenchmarkResult) mbPerSec() float64 {
+	if r.Bytes <= 0 || r.T <= 0 || r.N <= 0 {
+		return 0
+	}
+	return (float64(r.Bytes) * float64(r.N) / 1e6) / r.T.Seconds()
+}
+
+// String returns a summary of the benchmark results, in the same format as
+// upstream Go.
+func (r BenchmarkResult) String() string {
+	nsop := r.NsPerOp()
+	ns := fmt.Sprintf("%10d ns/op", nsop)
+	if r.N > 0 && nsop < 100 {
+		// The format specifiers here make sure that the ones digits line up
+		// for all three possible formats.
+		if nsop < 10 {
+			ns = fmt.Sprintf("%13.2f ns/op", float64(r.T.Nanoseconds())/float64(r.N))
+		} else {
+			ns = fmt.Sprintf("%12.1f ns/op", float64(r.T.Nanoseconds())/float64(r.N))
+		}
+	}
+	result := fmt.Sprintf("%8d\t%s", r.N, ns)
+	if mbs := r.mbPerSec(); mbs != 0 {
+		result += fmt.Sprintf("\t%7.2f MB/s", mbs)
+	}
+	return result
+}
+
+// SetBytes records the number of bytes processed in a single operation. If
+// this is called, the benchmark will report MB/s.
 func (b *B) SetBytes(n int64) {
-	panic("testing: unimplemented: B.SetBytes")
+	b.bytes = n
+}
+
+// ReportAllocs is not implemented, it is only provided for compatibility.
+func (b *B) ReportAllocs() {
+	// Unimplemented.
+}
+
+// StartTimer starts timing a test. This function is called automatically
+// before a benchmark starts, but it can also be used to resume timing after
+// a call to StopTimer.
+func (b *B) StartTimer() {
+	if !b.timerOn {
+		b.start = runtimeNano()
+		b.timerOn = true
+	}
+}
+
+// StopTimer stops timing a test. This can be used to pause the timer while
+// performing complex initialization that you don't want to measure.
+func (b *B) StopTimer() {
+	if b.timerOn {
+		b.duration += runtimeNano() - b.start
+		b.timerOn = false
+	}
 }
 
+// ResetTimer zeroes the elapsed benchmark time. It does not affect whether the
+// timer is running.
 func (b *B) ResetTimer() {
-	panic("testing: unimplemented: B.ResetTimer")
+	if b.timerOn {
+		b.start = runtimeNano()
+	}
+	b.duration = 0
 }
 
+// runN runs a single benchmark for the specified number of iterations.
+func (b *B) runN(n int) {
+	b.N = n
+	b.ResetTimer()
+	b.StartTimer()
+	b.benchFunc(b)
+	b.StopTimer()
+}
+
+// launch runs the benchmark function with increasing values of b.N until it
+// runs for the requested benchmark time (or iterations), like upstream Go.
+// The benchmark must already have run once with b.N = 1.
+func (b *B) launch() {
+	if b.benchTime.n > 0 {
+		b.runN(b.benchTime.n)
+	} else {
+		d := b.benchTime.d
+		for n := int64(1); !b.failed && b.duration < d.Nanoseconds() && n < 1e9; {
+			last := n
+			// Predict required iterations.
+			goalns := d.Nanoseconds()
+			prevIters := int64(b.N)
+			prevns := b.duration
+			if prevns <= 0 {
+				// Round up, to avoid div by zero.
+				prevns = 1
+			}
+			// Order of operations matters.
+			// For very fast benchmarks, prevIters ~= prevns.
+			// If you divide first, you get 0 or 1,
+			// which can hide an order of magnitude in execution time.
+			// So multiply first, then divide.
+			n = goalns * prevIters / prevns
+			// Run more iterations than we think we'll need (1.2x).
+			n += n / 5
+			// Don't grow too fast in case we had timing errors previously.
+			n = min64(n, 100*last)
+			// Be sure to run at least one more than last time.
+			n = max64(n, last+1)
+			// Don't run more than 1e9 times. (This also keeps n in int range on 32 bit platforms.)
+			n = min64(n, 1e9)
+			b.runN(int(n))
+		}
+	}
+	b.result = BenchmarkResult{N: b.N, T: time.Duration(b.duration), Bytes: b.bytes}
+}
+
+// run runs the benchmark once to find out whether it has sub-benchmarks, and if
+// not, measures it and prints the result.
+func (b *B) run() {
+	b.runN(1)
+	if b.hasSub || b.failed {
+		return
+	}
+	b.launch()
+	if !b.failed {
+		fmt.Printf("%s\t%s\n", b.name, b.result.String())
+	}
+}
+
+// report prints the logs of the benchmark, and whether it failed.
+func (b *B) report() {
+	if b.failed {
+		fmt.Printf("--- FAIL: %s\n", b.name)
+	} else if b.output.(*bytes.Buffer).Len() != 0 {
+		fmt.Printf("--- BENCH: %s\n", b.name)
+	}
+	fmt.Print(b.output)
+}
+
+// Run benchmarks f as a subbenchmark with the given name. It reports whether
+// there were any failures.
+//
+// A subbenchmark is like any other benchmark. A benchmark that calls Run at
+// least once will not be measured itself and will be called once with N=1.
 func (b *B) Run(name string, f func(b *B)) bool {
-	panic("testing: unimplemented: B.Run")
+	b.hasSub = true
+	sub := &B{
+		common: common{
+			name:   b.name + "/" + rewriteName(name),
+			output: &bytes.Buffer{},
+		},
+		benchFunc: f,
+		benchTime: b.benchTime,
+	}
+	if ok, _ := benchMatcher.match(sub.name); !ok {
+		return true
+	}
+	sub.run()
+	sub.report()
+	if sub.failed {
+		b.failed = true
+	}
+	return !sub.failed
+}
+
+// runBenchmarks runs all benchmarks that match -test.bench and returns the
+// number of failed benchmarks.
+func runBenchmarks(benchmarks []InternalBenchmark) int {
+	failures := 0
+	for i := 0; i < flagCount; i++ {
+		for _, benchmark := range benchmarks {
+			if ok, _ := benchMatcher.match(benchmark.Name); !ok {
+				continue
+			}
+			b := &B{
+				common: common{
+					name:   benchmark.Name,
+					output: &bytes.Buffer{},
+				},
+				benchFunc: benchmark.F,
+				benchTime: flagBenchTime,
+			}
+			b.run()
+			b.report()
+			if b.failed {
+				failures++
+			}
+		}
+	}
+	return failures
+}
+
+// Benchmark benchmarks a single function. It is useful for creating custom
+// benchmarks that do not use the "go test" command.
+func Benchmark(f func(b *B)) BenchmarkResult {
+	b := &B{
+		common: common{
+			output: os.Stdout,
+		},
+		benchFunc: f,
+		benchTime: flagBenchTime,
+	}
+	b.runN(1)
+	if b.failed {
+		return BenchmarkResult{}
+	}
+	b.launch()
+	return b.result
+}
+
+func min64(x, y int64) int64 {
+	if x > y {
+		return y
+	}
+	return x
+}
+
+func max64(x, y int64) int64 {
+	if x > y {
+		return x
+	}
+	return y
 }
@@ -0,0 +1,108 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+type InternalExample struct {
+	Name      string
+	F         func()
+	Output    string
+	Unordered bool
+}
+
+// runExample runs the example and compares what it writes to os.Stdout with
+// the expected output. It returns whether the example passed.
+func runExample(eg InternalExample) bool {
+	if flagVerbose {
+		fmt.Printf("=== RUN   %s\n", eg.Name)
+	}
+
+	// Redirect os.Stdout while running the example.
+	buf := &bytes.Buffer{}
+	stdout := os.Stdout
+	os.Stdout = captureFile(buf)
+	start := runtimeNano()
+	eg.F()
+	duration := formatDuration(runtimeNano() - start)
+	os.Stdout = stdout
+
+	got := strings.TrimSpace(buf.String())
+	want := strings.TrimSpace(eg.Output)
+	if eg.Unordered {
+		got = sortLines(got)
+		want = sortLines(want)
+	}
+	if got != want {
+		fmt.Printf("--- FAIL: %s (%s)\n", eg.Name, duration)
+		fmt.Printf("got:\n%s\nwant:\n%s\n", got, want)
+		return false
+	}
+	if flagVerbose {
+		fmt.Printf("--- PASS: %s (%s)\n", eg.Name, duration)
+	}
+	return true
+}
+
+func sortLines(output string) string {
+	lines := strings.Split(output, "\n")
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// The os package has no way to create an *os.File that writes to memory. To
+// capture the output of examples, a filesystem is mounted that contains a
+// single file which writes to a buffer.
+const captureMount = "/dev/tinygo-testing/"
+
+var captureFS *captureFilesystem
+
+// captureFile returns an *os.File that writes everything to buf.
+func captureFile(buf *bytes.Buffer) *os.File {
+	if captureFS == nil {
+		captureFS = &captureFilesystem{}
+		os.Mount(captureMount, captureFS)
+	}
+	captureFS.buf = buf
+	f, err := os.OpenFile(captureMount+"stdout", os.O_WRONLY, 0)
+	if err != nil {
+		panic("testing: could not capture output: " + err.Error())
+	}
+	return f
+}
+
+type captureFilesystem struct {
+	buf *bytes.Buffer
+}
+
+func (fs *captureFilesystem) OpenFile(name string, flag int, perm os.FileMode) (os.FileHandle, error) {
+	return captureHandle{fs.buf}, nil
+}
+
+func (fs *captureFilesystem) Mkdir(name string, perm os.FileMode) error {
+	return os.ErrNotImplemented
+}
+
+func (fs *captureFilesystem) Remove(name string) error {
+	return os.ErrNotImplemented
+}
+
+type captureHandle struct {
+	buf *bytes.Buffer
+}
+
+func (h captureHandle) Read(b []byte) (int, error) {
+	return 0, os.ErrUnsupported
+}
+
+func (h captureHandle) Write(b []byte) (int, error) {
+	return h.buf.Write(b)
+}
+
+func (h captureHandle) Close() error {
+	return nil
+}
@@ -0,0 +1,230 @@
+package testing
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	_ "unsafe" // for go:linkname
+)
+
+// testFlags contains the flags for the test binary separated by null bytes, set
+// by the compiler from the flags passed to `tinygo test`. This is only done for
+// targets without command line arguments, such as microcontrollers in an
+// emulator.
+var testFlags string
+
+var (
+	flagVerbose     bool
+	flagShort       bool
+	flagCount       = 1
+	flagRunRegexp   string
+	flagBenchRegexp string
+	flagBenchTime   = benchTimeFlag{d: time.Second}
+
+	runMatcher   *matcher
+	benchMatcher *matcher
+)
+
+// runtimeNano returns the current value of the runtime clock in nanoseconds.
+//go:linkname runtimeNano runtime.nanotime
+func runtimeNano() int64
+
+// Verbose reports whether the -test.v flag is set.
+func Verbose() bool {
+	return flagVerbose
+}
+
+// Short reports whether the -test.short flag is set.
+func Short() bool {
+	return flagShort
+}
+
+// parseFlags parses the flags set by the compiler and on the command line. Only
+// the flags supported by this package are accepted, with one or two dashes and
+// with the value either after an equals sign or as a separate argument.
+func parseFlags() error {
+	var args []string
+	if testFlags != "" {
+		args = strings.Split(testFlags, "\x00")
+	}
+	if len(os.Args) > 1 {
+		args = append(args, os.Args[1:]...)
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(arg) < 2 || arg[0] != '-' {
+			return fmt.Errorf("testing: unexpected argument %q", arg)
+		}
+		name := strings.TrimPrefix(arg[1:], "-")
+		value := ""
+		hasValue := false
+		if index := strings.IndexByte(name, '='); index >= 0 {
+			name, value = name[:index], name[index+1:]
+			hasValue = true
+		}
+
+		// Boolean flags don't need a value.
+		switch name {
+		case "test.v", "test.short":
+			if !hasValue {
+				value = "true"
+			}
+			b, ok := parseBool(value)
+			if !ok {
+				return fmt.Errorf("testing: invalid boolean value %q for -%s", value, name)
+			}
+			if name == "test.v" {
+				flagVerbose = b
+			} else {
+				flagShort = b
+			}
+			continue
+		}
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				return fmt.Errorf("testing: flag needs an argument: -%s", name)
+			}
+			i++
+			value = args[i]
+		}
+		switch name {
+		case "test.run":
+			flagRunRegexp = value
+		case "test.bench":
+			flagBenchRegexp = value
+		case "test.count":
+			n, ok := parseUint(value)
+			if !ok {
+				return fmt.Errorf("testing: invalid value %q for -test.count", value)
+			}
+			flagCount = n
+		case "test.benchtime":
+			if err := flagBenchTime.Set(value); err != nil {
+				return fmt.Errorf("testing: invalid value %q for -test.benchtime: %s", value, err)
+			}
+		default:
+			return fmt.Errorf("testing: flag provided but not defined: -%s", name)
+		}
+	}
+
+	var err error
+	runMatcher, err = newMatcher(flagRunRegexp)
+	if err != nil {
+		return fmt.Errorf("testing: invalid regexp for -test.run: %s", err)
+	}
+	if flagBenchRegexp != "" {
+		benchMatcher, err = newMatcher(flagBenchRegexp)
+		if err != nil {
+			return fmt.Errorf("testing: invalid regexp for -test.bench: %s", err)
+		}
+	}
+	return nil
+}
+
+// benchTimeFlag is the value of -test.benchtime: either a duration (like 1s)
+// or a number of iterations (like 100x).
+type benchTimeFlag struct {
+	d time.Duration
+	n int
+}
+
+func (f *benchTimeFlag) Set(s string) error {
+	if strings.HasSuffix(s, "x") {
+		n, ok := parseUint(s[:len(s)-1])
+		if !ok || n == 0 {
+			return errors.New("invalid count")
+		}
+		*f = benchTimeFlag{n: n}
+		return nil
+	}
+	d, ok := parseDuration(s)
+	if !ok || d <= 0 {
+		return errors.New("invalid duration")
+	}
+	*f = benchTimeFlag{d: d}
+	return nil
+}
+
+// The functions below parse flag values. They are used instead of the strconv
+// package and time.ParseDuration to keep test binaries small.
+
+// parseBool parses a boolean in the same forms as strconv.ParseBool.
+func parseBool(s string) (value, ok bool) {
+	switch s {
+	case "1", "t", "T", "true", "TRUE", "True":
+		return true, true
+	case "0", "f", "F", "false", "FALSE", "False":
+		return false, true
+	}
+	return false, false
+}
+
+// parseUint parses a non-negative decimal integer.
+func parseUint(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' || n > (1<<31-1)/10 {
+			return 0, false
+		}
+		n = n*10 + int(s[i]-'0')
+	}
+	return n, true
+}
+
+// parseDuration parses a duration like "1.5s" or "100ms": a decimal number
+// followed by one of the units ns, us, ms, s, m or h.
+func parseDuration(s string) (time.Duration, bool) {
+	end := 0
+	for end < len(s) && (s[end] == '.' || (s[end] >= '0' && s[end] <= '9')) {
+		end++
+	}
+	var unit time.Duration
+	switch s[end:] {
+	case "ns":
+		unit = time.Nanosecond
+	case "us", "µs":
+		unit = time.Microsecond
+	case "ms":
+		unit = time.Millisecond
+	case "s":
+		unit = time.Second
+	case "m":
+		unit = time.Minute
+	case "h":
+		unit = time.Hour
+	default:
+		return 0, false
+	}
+	number := s[:end]
+	fraction := ""
+	if index := strings.IndexByte(number, '.'); index >= 0 {
+		number, fraction = number[:index], number[index+1:]
+	}
+	if number == "" && fraction == "" {
+		return 0, false
+	}
+	whole := 0
+	if number != "" {
+		var ok bool
+		whole, ok = parseUint(number)
+		if !ok {
+			return 0, false
+		}
+	}
+	d := time.Duration(whole) * unit
+	scale := unit
+	for i := 0; i < len(fraction); i++ {
+		if fraction[i] < '0' || fraction[i] > '9' {
+			return 0, false
+		}
+		scale /= 10
+		d += time.Duration(fraction[i]-'0') * scale
+	}
+	return d, true
+}
@@ -0,0 +1,71 @@
+package testing
+
+import (
+	"regexp"
+	"strings"
+)
+
+// matcher filters tests and benchmarks by name, using the pattern given in
+// -test.run or -test.bench. The pattern is split by slashes into one regular
+// expression per level of subtests.
+type matcher struct {
+	filter []*regexp.Regexp
+}
+
+func newMatcher(pattern string) (*matcher, error) {
+	m := &matcher{}
+	if pattern == "" {
+		return m, nil
+	}
+	for _, s := range splitRegexp(pattern) {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, err
+		}
+		m.filter = append(m.filter, re)
+	}
+	return m, nil
+}
+
+// match reports whether the given (sub)test name matches. The partial result
+// is true when the name only matched the first levels of the pattern, so that
+// subtests may still match completely.
+func (m *matcher) match(name string) (ok, partial bool) {
+	if m == nil {
+		return true, false
+	}
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		if i >= len(m.filter) {
+			break
+		}
+		if !m.filter[i].MatchString(elem) {
+			return false, false
+		}
+	}
+	return true, len(elems) < len(m.filter)
+}
+
+// splitRegexp splits a pattern by slashes, except for slashes inside brackets
+// or parentheses.
+func splitRegexp(s string) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[', '(':
+			depth++
+		case ']', ')':
+			depth--
+		case '\\':
+			i++
+		case '/':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
 // common holds the elements common between T and B and
@@ -24,6 +25,7 @@ type common struct {
 	skipped  bool   // Test of benchmark has been skipped.
 	finished bool   // Test function has completed.
 	name     string // Name of test or benchmark.
+	start    int64  // Time the test or benchmark started, from runtimeNano.
 }
 
 // TB is the interface common to T and B.
@@ -167,26 +169,61 @@ func (t *T) Run(name string, f func(t *T)) bool {
 	sub := T{
 		indent: t.indent + "    ",
 		common: common{
-			name:   t.name + "/" + name,
+			name:   t.name + "/" + rewriteName(name),
 			output: &bytes.Buffer{},
 		},
 	}
+	if ok, _ := runMatcher.match(sub.name); !ok {
+		// Filtered out by -test.run.
+		return true
+	}
 
-	// Run the test.
-	fmt.Printf("=== RUN   %s\n", sub.name)
-	f(&sub)
-
-	// Process the result (pass or fail).
+	// Run the test. The result is written to the output of the parent test, so
+	// that it is printed below the parent test like in upstream Go.
+	sub.run(f, t.output)
 	if sub.failed {
 		t.failed = true
-		fmt.Printf(sub.indent+"--- FAIL: %s\n", sub.name)
-	} else {
-		fmt.Printf(sub.indent+"--- PASS: %s\n", sub.name)
 	}
-	fmt.Print(sub.output)
 	return !sub.failed
 }
 
+// run runs the test function and reports the result to w.
+func (t *T) run(f func(t *T), w io.Writer) {
+	if flagVerbose {
+		fmt.Printf("=== RUN   %s\n", t.name)
+	}
+	t.start = runtimeNano()
+	f(t)
+	t.finished = true
+	duration := formatDuration(runtimeNano() - t.start)
+
+	// Process the result (pass, fail or skip). Passed and skipped tests are
+	// only shown with -test.v.
+	switch {
+	case t.failed:
+		fmt.Fprintf(w, t.indent+"--- FAIL: %s (%s)\n", t.name, duration)
+	case !flagVerbose:
+		return
+	case t.skipped:
+		fmt.Fprintf(w, t.indent+"--- SKIP: %s (%s)\n", t.name, duration)
+	default:
+		fmt.Fprintf(w, t.indent+"--- PASS: %s (%s)\n", t.name, duration)
+	}
+	io.Copy(w, t.output.(*bytes.Buffer))
+}
+
+// rewriteName replaces spaces in a subtest name with underscores, like
+// upstream Go does.
+func rewriteName(name string) string {
+	return strings.Replace(name, " ", "_", -1)
+}
+
+// formatDuration formats a duration in nanoseconds as seconds with two
+// decimals, like "0.01s".
+func formatDuration(ns int64) string {
+	return fmt.Sprintf("%.2fs", float64(ns)/1e9)
+}
+
 // InternalTest is a reference to a test that should be called during a test suite run.
 type InternalTest struct {
 	Name string
@@ -196,34 +233,47 @@ type InternalTest struct {
 // M is a test suite.
 type M struct {
 	// tests is a list of the test names to execute
-	Tests []InternalTest
+	Tests      []InternalTest
+	Benchmarks []InternalBenchmark
+	Examples   []InternalExample
 }
 
 // Run the test suite.
 func (m *M) Run() int {
-	failures := 0
-	for _, test := range m.Tests {
-		t := &T{
-			common: common{
-				name:   test.Name,
-				output: &bytes.Buffer{},
-			},
-		}
-
-		fmt.Printf("=== RUN   %s\n", test.Name)
-		test.F(t)
+	if err := parseFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
 
-		if t.failed {
-			fmt.Printf("--- FAIL: %s\n", test.Name)
-		} else {
-			fmt.Printf("--- PASS: %s\n", test.Name)
+	failures := 0
+	for i := 0; i < flagCount; i++ {
+		for _, test := range m.Tests {
+			if ok, _ := runMatcher.match(test.Name); !ok {
+				continue
+			}
+			t := &T{
+				common: common{
+					name:   test.Name,
+					output: &bytes.Buffer{},
+				},
+			}
+			t.run(test.F, os.Stdout)
+			if t.failed {
+				failures++
+			}
 		}
-		fmt.Print(t.output)
-
-		if t.failed {
-			failures++
+		for _, example := range m.Examples {
+			if ok, _ := runMatcher.match(example.Name); !ok {
+				continue
+			}
+			if !runExample(example) {
+				failures++
+			}
 		}
 	}
+	if benchMatcher != nil {
+		failures += runBenchmarks(m.Benchmarks)
+	}
 
 	if failures > 0 {
 		fmt.Printf("exit status %d\n", failures)
@@ -240,13 +290,8 @@ func TestMain(m *M) {
 
 func MainStart(deps interface{}, tests []InternalTest, benchmarks []InternalBenchmark, examples []InternalExample) *M {
 	return &M{
-		Tests: tests,
+		Tests:      tests,
+		Benchmarks: benchmarks,
+		Examples:   examples,
 	}
 }
-
-type InternalExample struct {
-	Name      string
-	F         func()
-	Output    string
-	Unordered bool
-}
@@ -0,0 +1,7 @@
+// Test calling Go functions exported with //export, using the generated
+// header.
+#include "_cgo_export.h"
+
+int callSubtract(int a, int b) {
+	return subtract(a, b);
+}
@@ -3,4 +3,10 @@ package main
 // Make sure CGo supports multiple files.
 
 // int fortytwo(void);
+// int callSubtract(int a, int b);
 import "C"
+
+//export subtract
+func subtract(a, b C.int) C.int {
+	return a - b
+}
@@ -5,6 +5,7 @@ int fortytwo(void);
 #include "main.h"
 int mul(int, int);
 #include <string.h>
+#include <stdlib.h>
 */
 import "C"
 
@@ -35,6 +36,9 @@ func main() {
 	println("callback 1:", C.doCallback(20, 30, cb))
 	cb = C.binop_t(C.mul)
 	println("callback 2:", C.doCallback(20, 30, cb))
+	cb = C.binop_t(C.subtract) // not declared in the preamble
+	println("callback 3:", C.doCallback(20, 30, cb))
+	println("call from C file:", C.callSubtract(30, 20))
 
 	// variadic functions
 	println("variadic0:", C.variadic0())
@@ -123,6 +127,26 @@ func main() {
 	buf2 := make([]byte, len(buf1))
 	C.strcpy((*C.char)(unsafe.Pointer(&buf2[0])), (*C.char)(unsafe.Pointer(&buf1[0])))
 	println("copied string:", string(buf2[:C.strlen((*C.char)(unsafe.Pointer(&buf2[0])))]))
+
+	// CGo helper functions.
+	cstr := C.CString("hello")
+	println("CString length:", C.strlen(cstr))
+	println("GoString:", C.GoString(cstr))
+	println("GoStringN:", C.GoStringN(cstr, 4))
+	println("GoString nil:", C.GoString(nil) == "")
+	cbytes := C.CBytes([]byte{1, 2, 3})
+	gobytes := C.GoBytes(cbytes, 3)
+	println("GoBytes:", len(gobytes), gobytes[0], gobytes[1], gobytes[2])
+
+	// Memory from C.CString and C.CBytes is freed with C.free, like memory
+	// allocated by C code.
+	C.free(unsafe.Pointer(cstr))
+	C.free(cbytes)
+	cstr = C.CString("world")
+	dup := C.strdup(cstr)
+	C.free(unsafe.Pointer(cstr))
+	println("strdup:", C.GoString(dup))
+	C.free(unsafe.Pointer(dup))
 }
 
 func printUnion(union C.joined_t) C.joined_t {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 )
@@ -74,6 +75,8 @@ func main() {
 	time.Sleep(2 * time.Millisecond)
 
 	testCond()
+
+	testGoroutineDump()
 }
 
 func acquire(m *sync.Mutex) {
@@ -171,3 +174,41 @@ func testCond() {
 		panic("missing queued notification")
 	}
 }
+
+func testGoroutineDump() {
+	ch1 := make(chan int)
+	ch2 := make(chan int)
+	ch3 := make(chan int)
+	go blockedReceive(ch1)
+	go blockedSelect(ch2, ch3)
+	time.Sleep(time.Millisecond)
+
+	// Print the state of both goroutines, without the channel addresses.
+	buf := make([]byte, 4096)
+	dump := string(buf[:runtime.Stack(buf, true)])
+	for _, goroutine := range strings.Split(dump, "\n\n") {
+		lines := strings.Split(goroutine, "\n")
+		if len(lines) < 2 || !strings.HasPrefix(lines[1], "\tmain.blocked") {
+			continue
+		}
+		state := lines[0][strings.Index(lines[0], "["):]
+		channels := 0
+		for _, line := range lines[2:] {
+			if strings.HasPrefix(line, "\twaiting on chan ") {
+				channels++
+			}
+		}
+		println("goroutine", lines[1][1:], state, channels, "channels")
+	}
+}
+
+func blockedReceive(ch chan int) {
+	<-ch
+}
+
+func blockedSelect(send, receive chan int) {
+	select {
+	case send <- 1:
+	case <-receive:
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+// This program tests the RAM filesystem and the read-only filesystem of the
+// -embed-dir flag. It must be compiled with
+// -embed-dir=testdata/filesystem/assets:/static/.
+
+import (
+	"io"
+	"os"
+)
+
+func main() {
+	// Read the embedded directory.
+	data, err := os.ReadFile("/static/index.html")
+	println("read index.html:", string(data), err == nil)
+	printDir("/static/")
+	printDir("/static/css")
+	err = os.WriteFile("/static/new.txt", []byte("data"), 0666)
+	println("write to read-only filesystem:", err != nil)
+
+	// Use a RAM filesystem.
+	os.Mount("/ram/", os.NewRAMFilesystem())
+	check(os.Mkdir("/ram/dir", 0777))
+	check(os.WriteFile("/ram/dir/a.txt", []byte("hello"), 0666))
+	check(os.WriteFile("/ram/b.txt", []byte("world"), 0666))
+	printDir("/ram/")
+
+	f, err := os.OpenFile("/ram/b.txt", os.O_RDWR|os.O_APPEND, 0)
+	check(err)
+	_, err = f.Write([]byte("!"))
+	check(err)
+	_, err = f.Seek(1, io.SeekStart)
+	check(err)
+	buf := make([]byte, 10)
+	n, err := f.Read(buf)
+	println("read after seek:", string(buf[:n]), err == nil)
+	check(f.Truncate(3))
+	info, err := f.Stat()
+	check(err)
+	println("size after truncate:", info.Size())
+	check(f.Close())
+
+	check(os.Rename("/ram/dir", "/ram/moved"))
+	data, err = os.ReadFile("/ram/moved/a.txt")
+	println("read after rename:", string(data), err == nil)
+	_, err = os.Stat("/ram/dir/a.txt")
+	println("old path exists:", err == nil)
+	println("remove non-empty directory:", os.Remove("/ram/moved") != nil)
+	check(os.Remove("/ram/moved/a.txt"))
+	check(os.Remove("/ram/moved"))
+	printDir("/ram/")
+}
+
+func printDir(name string) {
+	f, err := os.Open(name)
+	check(err)
+	infos, err := f.Readdir(-1)
+	check(err)
+	println("directory", name)
+	for _, info := range infos {
+		println(" ", info.Name(), info.Size(), info.IsDir())
+	}
+	check(f.Close())
+}
+
+func check(err error) {
+	if err != nil {
+		println("error:", err.Error())
+	}
+}
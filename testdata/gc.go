@@ -1,5 +1,7 @@
 package main
 
+import "runtime"
+
 var xorshift32State uint32 = 1
 
 func xorshift32(x uint32) uint32 {
@@ -17,6 +19,7 @@ func randuint32() uint32 {
 
 func main() {
 	testNonPointerHeap()
+	testMemStats()
 }
 
 var scalarSlices [4][]byte
@@ -64,3 +67,24 @@ func testNonPointerHeap() {
 	}
 	println("ok")
 }
+
+var memStatsSink [10][]byte
+
+func testMemStats() {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := range memStatsSink {
+		memStatsSink[i] = make([]byte, 100)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	// The previous test left lots of garbage behind, which should be freed
+	// now.
+	println("allocated objects:", after.Mallocs-before.Mallocs >= uint64(len(memStatsSink)))
+	println("freed objects:", after.Frees > before.Frees)
+	println("collection cycles:", after.NumGC > before.NumGC)
+	println("heap in use:", after.HeapAlloc >= 100*uint64(len(memStatsSink)) && after.HeapAlloc <= after.HeapSys)
+	println("heap objects:", after.HeapObjects == after.Mallocs-after.Frees)
+	println("largest free area:", after.HeapMaxFree <= after.HeapIdle)
+}
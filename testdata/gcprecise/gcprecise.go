@@ -0,0 +1,88 @@
+package main
+
+// This test checks that the precise GC does not keep an object alive when the
+// only reference to it is an integer in another heap object that happens to
+// contain its address. It only passes with -gc=precise.
+
+import (
+	"reflect"
+	"runtime"
+	"unsafe"
+)
+
+type object [16]uintptr
+
+const numObjects = 100
+
+var (
+	sliceHolder   []uintptr
+	mapHolder     = map[int]uintptr{}
+	reflectHolder []uintptr
+	pointers      []*object
+)
+
+func main() {
+	// Allow a few objects to be kept alive by stale values on the stack,
+	// which is still scanned conservatively.
+	println("slice:", freedObjects(fillSlice) >= numObjects*9/10)
+	println("map:", freedObjects(fillMap) >= numObjects*9/10)
+	println("reflect:", freedObjects(fillReflect) >= numObjects*9/10)
+
+	// Objects that are referenced by a real pointer must stay alive.
+	freedObjects(fillPointers)
+	for i := 0; i < numObjects; i++ {
+		new(object)[0] = 1 // reuse freed memory, if any
+	}
+	ok := true
+	for i, obj := range pointers {
+		if obj[0] != uintptr(i)+1 {
+			ok = false
+		}
+	}
+	println("pointers:", ok)
+}
+
+// freedObjects returns the number of objects that were freed in a GC cycle
+// right after calling fill.
+func freedObjects(fill func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	fill()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	return after.Frees - before.Frees
+}
+
+//go:noinline
+func fillSlice() {
+	for i := 0; i < numObjects; i++ {
+		sliceHolder = append(sliceHolder, uintptr(unsafe.Pointer(new(object))))
+	}
+}
+
+//go:noinline
+func fillMap() {
+	for i := 0; i < numObjects; i++ {
+		mapHolder[i] = uintptr(unsafe.Pointer(new(object)))
+	}
+}
+
+//go:noinline
+func fillReflect() {
+	v := reflect.MakeSlice(reflect.TypeOf([]uintptr(nil)), numObjects, numObjects)
+	for i := 0; i < numObjects; i++ {
+		v.Index(i).SetUint(uint64(uintptr(unsafe.Pointer(new(object)))))
+	}
+	reflectHolder = v.Interface().([]uintptr)
+}
+
+//go:noinline
+func fillPointers() {
+	pointers = make([]*object, numObjects)
+	for i := range pointers {
+		obj := new(object)
+		obj[0] = uintptr(i) + 1
+		pointers[i] = obj
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+// This test drives GPIO pins and an I2C bus of the generic machine package
+// through the hardware simulator in machine/sim and prints the resulting trace.
+// It only runs on Linux, where the simulator is linked in.
+
+import (
+	"machine"
+	"machine/sim"
+)
+
+const (
+	led    = machine.Pin(13)
+	button = machine.Pin(2)
+)
+
+func main() {
+	// GPIO output.
+	led.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	led.High()
+	println("led:", sim.PinLevel(uint8(led)))
+	led.Low()
+	println("led:", sim.PinLevel(uint8(led)))
+
+	// GPIO input with queued levels.
+	button.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+	println("button:", button.Get())
+	sim.QueuePin(uint8(button), false, true)
+	println("button:", button.Get(), button.Get(), button.Get())
+
+	// I2C controller talking to a simulated sensor.
+	sensor := &sim.I2CRegisters{}
+	sensor.Data[0x0f] = 0x33
+	sim.AttachI2C(0, 0x19, sensor)
+	machine.I2C0.Configure(machine.I2CConfig{})
+	r := make([]byte, 1)
+	err := machine.I2C0.Tx(0x19, []byte{0x0f}, r)
+	println("whoami:", r[0], err == nil)
+	err = machine.I2C0.Tx(0x19, []byte{0x20, 0x57}, nil)
+	println("write:", sensor.Data[0x20], err == nil)
+	err = machine.I2C0.Tx(0x1a, []byte{0x0f}, r)
+	println("missing device:", err != nil)
+
+	// Print all hardware accesses.
+	for _, event := range sim.Trace() {
+		println(event.String())
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+// This program tests reading a directory of the host filesystem in chunks. It
+// must be run from the root of the repository.
+
+import (
+	"io"
+	"os"
+	"sort"
+)
+
+func main() {
+	f, err := os.Open("testdata/filesystem/assets")
+	if err != nil {
+		println("could not open directory:", err.Error())
+		return
+	}
+	defer f.Close()
+
+	var names []string
+	for {
+		chunk, err := f.Readdirnames(1)
+		if err == io.EOF {
+			println("end of directory, chunk size:", len(chunk))
+			break
+		}
+		if err != nil {
+			println("could not read directory:", err.Error())
+			return
+		}
+		println("chunk size:", len(chunk))
+		names = append(names, chunk...)
+	}
+
+	// The order of directory entries depends on the filesystem.
+	sort.Strings(names)
+	for _, name := range names {
+		println("entry:", name)
+	}
+
+	// Reading past the end keeps returning io.EOF.
+	_, err = f.Readdirnames(1)
+	println("read after end:", err == io.EOF)
+
+	// The FileInfo values of Readdir come from Lstat on each entry.
+	f2, err := os.Open("testdata/filesystem/assets")
+	if err != nil {
+		println("could not open directory:", err.Error())
+		return
+	}
+	defer f2.Close()
+	infos, err := f2.Readdir(0)
+	if err != nil {
+		println("could not read directory:", err.Error())
+		return
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+	for _, info := range infos {
+		println("info:", info.Name(), info.IsDir())
+	}
+
+	// Stat a file by name and a directory through its file handle.
+	info, err := os.Stat("testdata/filesystem/assets/index.html")
+	if err != nil {
+		println("could not stat file:", err.Error())
+		return
+	}
+	println("stat:", info.Name(), info.IsDir(), info.Size())
+	info, err = f2.Stat()
+	if err != nil {
+		println("could not stat directory:", err.Error())
+		return
+	}
+	println("stat:", info.Name(), info.IsDir())
+}
@@ -0,0 +1,81 @@
+package main
+
+var (
+	sliceGlobal []int
+	indexGlobal = 3
+)
+
+func main() {
+	println("# simple recover")
+	recoverSimple()
+
+	println("\n# recover runtime panic")
+	recoverRuntimePanic()
+
+	println("\n# panic in deferred call")
+	deferredPanic()
+
+	println("\n# panic through caller")
+	nestedPanic()
+
+	println("\n# set named result")
+	println("result:", namedResult())
+
+	println("\n# recover without panic")
+	println("recover:", recover() == nil)
+	println("recover in defer:", deferredRecover())
+}
+
+func recoverSimple() {
+	defer func() {
+		println("recovered:", recover().(string))
+	}()
+	println("running panic...")
+	panic("panic")
+}
+
+func recoverRuntimePanic() {
+	defer func() {
+		err := recover().(error)
+		println("recovered:", err.Error())
+	}()
+	println(sliceGlobal[indexGlobal])
+}
+
+func deferredPanic() {
+	defer func() {
+		println("recovered:", recover().(string))
+	}()
+	defer func() {
+		panic("deferred panic")
+	}()
+	println("returning normally...")
+}
+
+func nestedPanic() {
+	defer func() {
+		println("recovered:", recover().(string))
+	}()
+	callPanic()
+	println("not reached")
+}
+
+func callPanic() {
+	defer println("deferred call in panicking function")
+	panic("nested panic")
+}
+
+func namedResult() (n int) {
+	defer func() {
+		recover()
+		n = 5
+	}()
+	panic("panic with named result")
+}
+
+func deferredRecover() (isNil bool) {
+	defer func() {
+		isNil = recover() == nil
+	}()
+	return false
+}
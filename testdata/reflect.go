@@ -274,6 +274,15 @@ func main() {
 
 	println("\nstruct tags")
 	TestStructTag()
+
+	println("\nmaps")
+	TestMaps()
+
+	println("\nallocation")
+	TestAllocation()
+
+	println("\nnames")
+	TestNames()
 }
 
 func emptyFunc() {
@@ -325,7 +334,7 @@ func showValue(rv reflect.Value, indent string) {
 		println(indent + "  interface")
 		println(indent+"  nil:", rv.IsNil())
 	case reflect.Map:
-		println(indent + "  map")
+		println(indent+"  map:", rt.Key().Kind().String(), rt.Elem().Kind().String(), rv.Len())
 		println(indent+"  nil:", rv.IsNil())
 	case reflect.Ptr:
 		println(indent+"  pointer:", rv.Pointer() != 0, rt.Elem().Kind().String())
@@ -363,6 +372,22 @@ func assertSize(ok bool, typ string) {
 
 type unreferencedType int
 
+func (p point) Add(q point) point {
+	return point{p.X + q.X, p.Y + q.Y}
+}
+
+func (p point) Abs() int16 {
+	return p.X*p.X + p.Y*p.Y
+}
+
+type absoluter interface {
+	Abs() int16
+}
+
+func (p point) scale(n int16) point {
+	return point{p.X * n, p.Y * n}
+}
+
 func TestStructTag() {
 	type S struct {
 		F string `species:"gopher" color:"blue"`
@@ -373,3 +398,121 @@ func TestStructTag() {
 	field := st.Field(0)
 	println(field.Tag.Get("color"), field.Tag.Get("species"))
 }
+
+func TestMaps() {
+	m := map[string]int{"one": 1, "two": 2, "three": 3}
+	rv := reflect.ValueOf(m)
+
+	// Look up values.
+	println("two:", rv.MapIndex(reflect.ValueOf("two")).Int())
+	println("four exists:", rv.MapIndex(reflect.ValueOf("four")).IsValid())
+
+	// Iterate over all keys.
+	keys := rv.MapKeys()
+	total := 0
+	for _, key := range keys {
+		total += int(rv.MapIndex(key).Int())
+	}
+	println("keys:", len(keys), total)
+
+	// Iterate using a MapIter.
+	keyLen := 0
+	total = 0
+	it := rv.MapRange()
+	for it.Next() {
+		keyLen += len(it.Key().String())
+		total += int(it.Value().Int())
+	}
+	println("range:", keyLen, total)
+
+	// Modify the map.
+	rv.SetMapIndex(reflect.ValueOf("four"), reflect.ValueOf(4))
+	rv.SetMapIndex(reflect.ValueOf("one"), reflect.Value{})
+	println("modified:", len(m), m["four"], m["one"])
+
+	// Create a new map with a struct key.
+	mm := reflect.MakeMap(reflect.TypeOf(map[point]string{}))
+	mm.SetMapIndex(reflect.ValueOf(point{1, 2}), reflect.ValueOf("a"))
+	println("struct key:", mm.MapIndex(reflect.ValueOf(point{1, 2})).String(), mm.Interface().(map[point]string)[point{1, 2}])
+
+	// Keys that are stored as interfaces in the map.
+	mf := reflect.ValueOf(map[float64]bool{1.5: true})
+	println("float key:", mf.MapKeys()[0].Float(), mf.MapIndex(reflect.ValueOf(1.5)).Bool())
+
+	// Interface values.
+	mi := reflect.ValueOf(map[string]interface{}{"a": 5})
+	elem := mi.MapIndex(reflect.ValueOf("a"))
+	println("interface value:", elem.Kind().String(), elem.Interface().(int))
+	mi.SetMapIndex(reflect.ValueOf("b"), reflect.ValueOf("foo"))
+	println("interface value:", mi.MapIndex(reflect.ValueOf("b")).Interface().(string))
+}
+
+func TestAllocation() {
+	// New and Zero.
+	p := reflect.New(reflect.TypeOf(point{}))
+	p.Elem().Field(1).SetInt(7)
+	println("new:", p.Interface().(*point).Y, p.Elem().CanAddr())
+	println("zero:", reflect.Zero(reflect.TypeOf(0)).Int(), reflect.Zero(reflect.TypeOf("")).String() == "")
+	pa := p.Elem().Addr()
+	println("addr:", pa.Interface().(*point) == p.Interface().(*point))
+
+	// MakeSlice and Append.
+	s := reflect.MakeSlice(reflect.TypeOf([]int{}), 2, 4)
+	s.Index(1).SetInt(5)
+	s = reflect.Append(s, reflect.ValueOf(6), reflect.ValueOf(7), reflect.ValueOf(8))
+	ints := s.Interface().([]int)
+	println("append:", len(ints), ints[0], ints[1], ints[2], ints[3], ints[4])
+
+	// Slicing.
+	sub := s.Slice(1, 3)
+	println("slice:", sub.Len(), sub.Index(0).Int(), sub.Index(1).Int())
+	println("string slice:", reflect.ValueOf("hello world").Slice(6, 11).String())
+	arr := reflect.New(reflect.TypeOf([3]uint8{})).Elem()
+	arr.Index(2).SetUint(3)
+	println("array slice:", len(arr.Slice(1, 3).Bytes()), arr.Slice(1, 3).Bytes()[1])
+
+	// Copy.
+	dst := reflect.MakeSlice(reflect.TypeOf([]byte{}), 5, 5)
+	n := reflect.Copy(dst, reflect.ValueOf("foobar"))
+	println("copy:", n, string(dst.Bytes()))
+	n = reflect.Copy(s, reflect.ValueOf([]int{1, 2}))
+	println("copy:", n, ints[0], ints[1], ints[2])
+
+	// Interface elements.
+	is := reflect.Append(reflect.ValueOf([]interface{}{}), reflect.ValueOf("str"), reflect.ValueOf(3))
+	println("interface slice:", is.Index(0).Interface().(string), is.Index(1).Interface().(int))
+}
+
+func TestNames() {
+	// Names of types.
+	for _, v := range []interface{}{0, myint(0), "", point{}, &point{}, []myint{}, [3]byte{}, map[string]point{}, unsafe.Pointer(nil), struct {
+		A int
+		point
+	}{}} {
+		rt := reflect.TypeOf(v)
+		println("type:", rt.String(), rt.Name(), rt.PkgPath())
+	}
+
+	// Unexported struct fields have a package path.
+	rt := reflect.TypeOf(mystruct{})
+	println("field:", rt.Field(0).Name, rt.Field(0).PkgPath, rt.Field(4).Name, rt.Field(4).PkgPath == "")
+
+	// Method sets.
+	rt = reflect.TypeOf(point{})
+	println("methods:", rt.NumMethod(), rt.Method(0).Name, rt.Method(1).Name)
+	_, found := rt.MethodByName("scale")
+	println("unexported method:", found)
+	println("methods of int:", reflect.ValueOf(0).NumMethod())
+	abs := rt.Method(0)
+	println("method func:", abs.Type.Kind().String(), abs.Func.Interface().(func(point) int16)(point{3, 4}))
+	add, _ := rt.MethodByName("Add")
+	println("method func:", add.Func.Interface().(func(point, point) point)(point{1, 2}, point{3, 4}).X)
+
+	// Interface types.
+	it := reflect.TypeOf((*absoluter)(nil)).Elem()
+	println("interface methods:", it.NumMethod(), it.Method(0).Name)
+	println("implements:", rt.Implements(it), reflect.TypeOf(0).Implements(it), rt.ConvertibleTo(it), reflect.TypeOf(myint(0)).ConvertibleTo(it))
+
+	// Convertibility.
+	println("convertible:", reflect.TypeOf(myint(0)).ConvertibleTo(reflect.TypeOf(0)), reflect.TypeOf(0).ConvertibleTo(reflect.TypeOf(0.0)), reflect.TypeOf("").ConvertibleTo(reflect.TypeOf(myslice{})), reflect.TypeOf("").ConvertibleTo(reflect.TypeOf(0)))
+}
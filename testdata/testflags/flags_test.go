@@ -0,0 +1,22 @@
+package testflags
+
+// This package is used by TestTestFlags in main_test.go to check that the flags
+// of `tinygo test` reach the test binary.
+
+import "testing"
+
+func TestFoo1(t *testing.T) {
+	println("TestFoo1")
+}
+
+func TestFoo2(t *testing.T) {
+	println("TestFoo2")
+}
+
+func TestFoo10(t *testing.T) {
+	println("TestFoo10")
+}
+
+func TestBar(t *testing.T) {
+	println("TestBar")
+}
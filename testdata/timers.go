@@ -0,0 +1,56 @@
+package main
+
+import "time"
+
+func main() {
+	// Wait for a single timer.
+	timer := time.NewTimer(time.Millisecond)
+	<-timer.C
+	println("timer fired")
+
+	// Use a timer as timeout in a select.
+	ch := make(chan int)
+	select {
+	case <-ch:
+		println("received from channel")
+	case <-time.After(time.Millisecond):
+		println("timeout")
+	}
+
+	// Run a function after some time.
+	done := make(chan struct{})
+	time.AfterFunc(time.Millisecond, func() {
+		println("AfterFunc called")
+		close(done)
+	})
+	<-done
+
+	// Stop a timer before it fires.
+	timer = time.NewTimer(time.Hour)
+	println("stop active timer:", timer.Stop())
+	println("stop stopped timer:", timer.Stop())
+
+	// Reset a timer to fire earlier.
+	timer = time.NewTimer(time.Hour)
+	timer.Reset(time.Millisecond)
+	<-timer.C
+	println("reset timer fired")
+
+	// Timers fire in order of expiration, not in the order they were started.
+	results := make(chan int, 3)
+	for _, n := range []int{3, 1, 2} {
+		n := n
+		time.AfterFunc(time.Duration(n)*time.Millisecond, func() {
+			results <- n
+		})
+	}
+	println("order:", <-results, <-results, <-results)
+
+	// Receive a few ticks.
+	ticker := time.NewTicker(time.Millisecond)
+	for i := 0; i < 3; i++ {
+		<-ticker.C
+		println("tick", i)
+	}
+	ticker.Stop()
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// testEvent is a single event in the output of `tinygo test -json`. It is
+// the same as the events written by `go test -json` (see `go doc test2json`).
+type testEvent struct {
+	Time    time.Time
+	Action  string
+	Package string  `json:",omitempty"`
+	Test    string  `json:",omitempty"`
+	Elapsed float64 `json:",omitempty"` // seconds
+	Output  string  `json:",omitempty"`
+}
+
+// testJSONWriter converts the verbose output of a test binary (followed by the
+// summary line printed by Test) into a stream of JSON events, like test2json.
+type testJSONWriter struct {
+	w    io.Writer
+	pkg  string
+	test string // test that is currently running or last reported
+	line []byte
+}
+
+func newTestJSONWriter(w io.Writer, pkg string) *testJSONWriter {
+	return &testJSONWriter{
+		w:   w,
+		pkg: pkg,
+	}
+}
+
+// Write implements io.Writer. Events are written for each complete line.
+func (c *testJSONWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		c.line = append(c.line, b)
+		if b == '\n' {
+			c.handleLine(string(c.line))
+			c.line = c.line[:0]
+		}
+	}
+	return len(p), nil
+}
+
+// Close writes an event for the last line, if it wasn't terminated by a
+// newline.
+func (c *testJSONWriter) Close() error {
+	if len(c.line) != 0 {
+		c.handleLine(string(c.line))
+		c.line = nil
+	}
+	return nil
+}
+
+// Prefixes of the lines that report the result of a test, and the
+// corresponding actions.
+var testReportActions = []struct {
+	prefix string
+	action string
+}{
+	{"--- PASS: ", "pass"},
+	{"--- FAIL: ", "fail"},
+	{"--- SKIP: ", "skip"},
+	{"--- BENCH: ", "bench"},
+}
+
+func (c *testJSONWriter) handleLine(line string) {
+	if strings.HasPrefix(line, "=== RUN   ") {
+		c.test = strings.TrimSpace(line[len("=== RUN   "):])
+		c.emit(testEvent{Action: "run", Test: c.test})
+		c.emit(testEvent{Action: "output", Test: c.test, Output: line})
+		return
+	}
+
+	// Subtests are reported indented below their parent test.
+	trimmed := strings.TrimLeft(line, " ")
+	for _, report := range testReportActions {
+		if !strings.HasPrefix(trimmed, report.prefix) {
+			continue
+		}
+		name, elapsed := parseTestReport(strings.TrimSpace(trimmed[len(report.prefix):]))
+		c.test = name
+		c.emit(testEvent{Action: "output", Test: name, Output: line})
+		c.emit(testEvent{Action: report.action, Test: name, Elapsed: elapsed})
+		return
+	}
+
+	switch {
+	case line == "PASS\n" || line == "FAIL\n":
+		c.test = ""
+		c.emit(testEvent{Action: "output", Output: line})
+	case strings.HasPrefix(line, "ok  \t") || strings.HasPrefix(line, "FAIL\t"):
+		// The summary line printed after the test binary exited.
+		c.test = ""
+		c.emit(testEvent{Action: "output", Output: line})
+		action := "pass"
+		if strings.HasPrefix(line, "FAIL") {
+			action = "fail"
+		}
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		elapsed, _ := strconv.ParseFloat(strings.TrimSuffix(fields[len(fields)-1], "s"), 64)
+		c.emit(testEvent{Action: action, Elapsed: elapsed})
+	default:
+		c.emit(testEvent{Action: "output", Test: c.test, Output: line})
+	}
+}
+
+// parseTestReport parses the part after "--- PASS: " (etc), which is the test
+// name optionally followed by the elapsed time, like "TestFoo (0.01s)".
+func parseTestReport(s string) (name string, elapsed float64) {
+	index := strings.LastIndex(s, " (")
+	if index < 0 || !strings.HasSuffix(s, "s)") {
+		return s, 0
+	}
+	elapsed, err := strconv.ParseFloat(s[index+2:len(s)-2], 64)
+	if err != nil {
+		return s, 0
+	}
+	return s[:index], elapsed
+}
+
+func (c *testJSONWriter) emit(event testEvent) {
+	event.Time = time.Now()
+	event.Package = c.pkg
+	data, err := json.Marshal(event)
+	if err != nil {
+		panic(err) // shouldn't happen
+	}
+	c.w.Write(append(data, '\n'))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTestJSONWriter(t *testing.T) {
+	output := "=== RUN   TestFoo\n" +
+		"=== RUN   TestFoo/bar\n" +
+		"--- FAIL: TestFoo (0.25s)\n" +
+		"    --- FAIL: TestFoo/bar (0.10s)\n" +
+		"\tsomething went wrong\n" +
+		"FAIL\n" +
+		"FAIL\texample.com/foo\t0.300s\n"
+	expected := []testEvent{
+		{Action: "run", Test: "TestFoo"},
+		{Action: "output", Test: "TestFoo", Output: "=== RUN   TestFoo\n"},
+		{Action: "run", Test: "TestFoo/bar"},
+		{Action: "output", Test: "TestFoo/bar", Output: "=== RUN   TestFoo/bar\n"},
+		{Action: "output", Test: "TestFoo", Output: "--- FAIL: TestFoo (0.25s)\n"},
+		{Action: "fail", Test: "TestFoo", Elapsed: 0.25},
+		{Action: "output", Test: "TestFoo/bar", Output: "    --- FAIL: TestFoo/bar (0.10s)\n"},
+		{Action: "fail", Test: "TestFoo/bar", Elapsed: 0.1},
+		{Action: "output", Test: "TestFoo/bar", Output: "\tsomething went wrong\n"},
+		{Action: "output", Output: "FAIL\n"},
+		{Action: "output", Output: "FAIL\texample.com/foo\t0.300s\n"},
+		{Action: "fail", Elapsed: 0.3},
+	}
+
+	buf := &bytes.Buffer{}
+	w := newTestJSONWriter(buf, "example.com/foo")
+	// Write in small pieces, to check that lines are reassembled.
+	for i := 0; i < len(output); i += 7 {
+		end := i + 7
+		if end > len(output) {
+			end = len(output)
+		}
+		w.Write([]byte(output[i:end]))
+	}
+	w.Close()
+
+	var events []testEvent
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var event testEvent
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatal("could not decode event:", err)
+		}
+		if event.Package != "example.com/foo" {
+			t.Errorf("unexpected package in event: %q", event.Package)
+		}
+		event.Package = ""
+		event.Time = time.Time{}
+		events = append(events, event)
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("unexpected events:\n%+v\nexpected:\n%+v", events, expected)
+	}
+}
@@ -6,6 +6,12 @@ package transform
 // interprocedural escape analysis.
 
 import (
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+
 	"tinygo.org/x/go-llvm"
 )
 
@@ -20,7 +26,11 @@ const maxStackAlloc = 256
 // whenever possible. It relies on the LLVM 'nocapture' flag for interprocedural
 // escape analysis, and within a function looks whether an allocation can escape
 // to the heap.
-func OptimizeAllocs(mod llvm.Module) {
+//
+// If printAllocs is not nil, the heap allocations that remain in packages
+// matching this regular expression are passed to logger, with the reason why
+// they could not be allocated on the stack.
+func OptimizeAllocs(mod llvm.Module, printAllocs *regexp.Regexp, logger func(token.Position, string)) {
 	allocator := mod.NamedFunction("runtime.alloc")
 	if allocator.IsNil() {
 		// nothing to optimize
@@ -31,15 +41,27 @@ func OptimizeAllocs(mod llvm.Module) {
 	i8ptrType := llvm.PointerType(mod.Context().Int8Type(), 0)
 	builder := mod.Context().NewBuilder()
 
+	var packageDirs map[string]string
+	if printAllocs != nil {
+		packageDirs = makePackageDirs(mod)
+	}
+
 	for _, heapalloc := range getUses(allocator) {
+		logAllocs := printAllocs != nil && printAllocs.MatchString(allocPackage(heapalloc, packageDirs))
 		if heapalloc.Operand(0).IsAConstant().IsNil() {
 			// Do not allocate variable length arrays on the stack.
+			if logAllocs {
+				logger(getPosition(heapalloc), "object allocated on the heap: size is not constant")
+			}
 			continue
 		}
 
 		size := heapalloc.Operand(0).ZExtValue()
 		if size > maxStackAlloc {
 			// The maximum size for a stack allocation.
+			if logAllocs {
+				logger(getPosition(heapalloc), fmt.Sprintf("object allocated on the heap: object size %d exceeds maximum stack allocation size %d", size, maxStackAlloc))
+			}
 			continue
 		}
 
@@ -56,7 +78,10 @@ func OptimizeAllocs(mod llvm.Module) {
 			bitcast = uses[0]
 		}
 
-		if mayEscape(bitcast) {
+		if reason := escapeReason(bitcast); reason != "" {
+			if logAllocs {
+				logger(getPosition(heapalloc), "object allocated on the heap: "+reason)
+			}
 			continue
 		}
 		// The pointer value does not escape.
@@ -85,9 +110,61 @@ func OptimizeAllocs(mod llvm.Module) {
 	}
 }
 
-// mayEscape returns whether the value might escape. It returns true if it might
-// escape, and false if it definitely doesn't. The value must be an instruction.
-func mayEscape(value llvm.Value) bool {
+// printHeapAllocs passes all heap allocations in packages matching printAllocs
+// to logger, without trying to allocate them on the stack. It is used instead
+// of OptimizeAllocs when optimizations are disabled, where every allocation
+// stays on the heap.
+func printHeapAllocs(mod llvm.Module, printAllocs *regexp.Regexp, logger func(token.Position, string)) {
+	allocator := mod.NamedFunction("runtime.alloc")
+	if allocator.IsNil() || printAllocs == nil {
+		return
+	}
+	packageDirs := makePackageDirs(mod)
+	for _, heapalloc := range getUses(allocator) {
+		if printAllocs.MatchString(allocPackage(heapalloc, packageDirs)) {
+			logger(getPosition(heapalloc), "object allocated on the heap: optimizations are disabled")
+		}
+	}
+}
+
+// allocPackage returns the package path of the source code that did the given
+// heap allocation. This is not necessarily the package of the function that
+// contains it, as the allocation may have been inlined from another package.
+// Therefore, the package is looked up by the directory of its debug location
+// when possible.
+func allocPackage(heapalloc llvm.Value, packageDirs map[string]string) string {
+	if pos := getPosition(heapalloc); pos.Filename != "" {
+		if pkg, ok := packageDirs[filepath.Dir(pos.Filename)]; ok {
+			return pkg
+		}
+	}
+	return functionPackage(heapalloc.InstructionParent().Parent().Name())
+}
+
+// makePackageDirs returns a map from source directory to package path, based
+// on the debug information of the functions that are defined in the module.
+func makePackageDirs(mod llvm.Module) map[string]string {
+	packageDirs := make(map[string]string)
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if fn.IsDeclaration() {
+			continue
+		}
+		pos := getPosition(fn)
+		pkg := functionPackage(fn.Name())
+		if pos.Filename == "" || pkg == "" {
+			continue
+		}
+		dir := filepath.Dir(pos.Filename)
+		if _, ok := packageDirs[dir]; !ok {
+			packageDirs[dir] = pkg
+		}
+	}
+	return packageDirs
+}
+
+// escapeReason returns why the value might escape, or the empty string if it
+// definitely doesn't. The value must be an instruction.
+func escapeReason(value llvm.Value) string {
 	uses := getUses(value)
 	for _, use := range uses {
 		if use.IsAInstruction().IsNil() {
@@ -95,13 +172,13 @@ func mayEscape(value llvm.Value) bool {
 		}
 		switch use.InstructionOpcode() {
 		case llvm.GetElementPtr:
-			if mayEscape(use) {
-				return true
+			if reason := escapeReason(use); reason != "" {
+				return reason
 			}
 		case llvm.BitCast:
 			// A bitcast escapes if the casted-to value escapes.
-			if mayEscape(use) {
-				return true
+			if reason := escapeReason(use); reason != "" {
+				return reason
 			}
 		case llvm.Load:
 			// Load does not escape.
@@ -109,21 +186,59 @@ func mayEscape(value llvm.Value) bool {
 			// Store only escapes when the value is stored to, not when the
 			// value is stored into another value.
 			if use.Operand(0) == value {
-				return true
+				if global := storedGlobal(use.Operand(1)); !global.IsNil() {
+					return "stored to global " + global.Name()
+				}
+				return "stored in another object"
 			}
 		case llvm.Call:
 			if !hasFlag(use, value, "nocapture") {
-				return true
+				callee := use.CalledValue()
+				if callee.IsAFunction().IsNil() {
+					return "escapes through an indirect call"
+				}
+				return "escapes through a call to " + callee.Name()
 			}
 		case llvm.ICmp:
 			// Comparing pointers don't let the pointer escape.
 			// This is often a compiler-inserted nil check.
+		case llvm.Ret:
+			return "returned from function"
 		default:
 			// Unknown instruction, might escape.
-			return true
+			return "used by an instruction that may let it escape"
 		}
 	}
 
 	// Checked all uses, and none let the pointer value escape.
-	return false
+	return ""
+}
+
+// storedGlobal returns the global that the given store address points into,
+// or a nil value if it is not (a field of) a global.
+func storedGlobal(ptr llvm.Value) llvm.Value {
+	for {
+		if !ptr.IsAGlobalVariable().IsNil() {
+			return ptr
+		}
+		if ptr.IsAGetElementPtrInst().IsNil() && ptr.IsABitCastInst().IsNil() && ptr.IsAConstantExpr().IsNil() {
+			return llvm.Value{}
+		}
+		if ptr.OperandsCount() == 0 {
+			return llvm.Value{}
+		}
+		ptr = ptr.Operand(0)
+	}
+}
+
+// functionPackage returns the package path of a Go function name like
+// "main.foo", "(*github.com/foo/bar.T).Method" or "runtime.alloc".
+func functionPackage(name string) string {
+	name = strings.TrimLeft(name, "(*")
+	slash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[slash+1:], '.')
+	if dot < 0 {
+		return ""
+	}
+	return name[:slash+1+dot]
 }
@@ -1,10 +1,82 @@
 package transform
 
 import (
+	"go/token"
+	"reflect"
+	"regexp"
+	"sort"
 	"testing"
+
+	"tinygo.org/x/go-llvm"
 )
 
 func TestAllocs(t *testing.T) {
 	t.Parallel()
-	testTransform(t, "testdata/allocs", OptimizeAllocs)
+	testTransform(t, "testdata/allocs", func(mod llvm.Module) {
+		OptimizeAllocs(mod, nil, nil)
+	})
+}
+
+func TestPrintAllocs(t *testing.T) {
+	t.Parallel()
+
+	// The functions in this test file are not part of a package, so match the
+	// empty package name.
+	messages := printAllocs(t, "testdata/allocs.ll", "^$", OptimizeAllocs)
+	expected := []string{
+		"object allocated on the heap: escapes through a call to escapeIntPtr",
+		"object allocated on the heap: escapes through a call to escapeIntPtrSometimes",
+		"object allocated on the heap: object size 1024 exceeds maximum stack allocation size 256",
+		"object allocated on the heap: returned from function",
+		"object allocated on the heap: size is not constant",
+		"object allocated on the heap: stored to global escapedPtr",
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("unexpected heap allocations printed:\n%#v", messages)
+	}
+
+	// Inlined allocations belong to the package of their debug location, not
+	// to the package of the function they were inlined in.
+	messages = printAllocs(t, "testdata/allocs-print.ll", "^example.com/lib$", OptimizeAllocs)
+	expected = []string{"/src/lib/lib.go:6:9: object allocated on the heap: escapes through a call to escapeIntPtr"}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("unexpected heap allocations printed for the lib package:\n%#v", messages)
+	}
+	messages = printAllocs(t, "testdata/allocs-print.ll", "^main$", OptimizeAllocs)
+	if len(messages) != 0 {
+		t.Errorf("unexpected heap allocations printed for the main package:\n%#v", messages)
+	}
+
+	// Without optimizations, all heap allocations are printed.
+	messages = printAllocs(t, "testdata/allocs-print.ll", "^example.com/lib$", printHeapAllocs)
+	expected = []string{"/src/lib/lib.go:6:9: object allocated on the heap: optimizations are disabled"}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("unexpected heap allocations printed without optimizations:\n%#v", messages)
+	}
+}
+
+// printAllocs runs the given allocation pass on the IR file and returns the
+// sorted messages it printed for packages matching the pattern. Messages with
+// a source location are prefixed with it.
+func printAllocs(t *testing.T, path, pattern string, pass func(llvm.Module, *regexp.Regexp, func(token.Position, string))) []string {
+	ctx := llvm.NewContext()
+	defer ctx.Dispose()
+	buf, err := llvm.NewMemoryBufferFromFile(path)
+	if err != nil {
+		t.Fatal("could not read file:", err)
+	}
+	mod, err := ctx.ParseIR(buf)
+	if err != nil {
+		t.Fatalf("could not load module:\n%v", err)
+	}
+
+	var messages []string
+	pass(mod, regexp.MustCompile(pattern), func(pos token.Position, msg string) {
+		if pos.IsValid() {
+			msg = pos.String() + ": " + msg
+		}
+		messages = append(messages, msg)
+	})
+	sort.Strings(messages)
+	return messages
 }
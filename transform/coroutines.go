@@ -73,6 +73,9 @@ func LowerCoroutines(mod llvm.Module, needStackSlots bool) error {
 		return err
 	}
 
+	// Async functions cannot be unwound on a panic.
+	pass.removeDeferFrames()
+
 	// Supply task operands to async calls.
 	pass.supplyTaskOperands()
 
@@ -411,6 +414,41 @@ func (c *coroutineLoweringPass) lowerStartSync(start llvm.Value) {
 	start.EraseFromParentAsInstruction()
 }
 
+// removeDeferFrames removes the defer frames (used to unwind the stack on a
+// panic, see compiler/defer.go) from async functions. The frame of a coroutine
+// does not live on the stack, so a panic cannot jump back into it. As a result,
+// panics are only recovered in functions that never block.
+func (c *coroutineLoweringPass) removeDeferFrames() {
+	setup := c.mod.NamedFunction("runtime.setupDeferFrame")
+	destroy := c.mod.NamedFunction("runtime.destroyDeferFrame")
+	if setup.IsNil() {
+		return
+	}
+	for _, call := range getUses(setup) {
+		if _, ok := c.asyncFuncs[call.InstructionParent().Parent()]; !ok {
+			continue
+		}
+
+		// Remove all uses of the stack allocated defer frame: the calls to
+		// setupDeferFrame and destroyDeferFrame and all checkpoints. A
+		// checkpoint now always continues in the normal flow.
+		frame := call.Operand(0)
+		for _, use := range getUses(frame) {
+			if use.IsACallInst().IsNil() {
+				continue
+			}
+			called := use.CalledValue()
+			switch {
+			case !called.IsAInlineAsm().IsNil():
+				use.ReplaceAllUsesWith(llvm.ConstNull(use.Type()))
+				use.EraseFromParentAsInstruction()
+			case called == setup || (!destroy.IsNil() && called == destroy):
+				use.EraseFromParentAsInstruction()
+			}
+		}
+	}
+}
+
 // supplyTaskOperands fills in the task operands of async calls.
 func (c *coroutineLoweringPass) supplyTaskOperands() {
 	var curCalls []llvm.Value
@@ -627,7 +665,8 @@ func (async *asyncFunc) hasValueStoreReturn() bool {
 func (c *coroutineLoweringPass) heapAlloc(t llvm.Type, name string) llvm.Value {
 	sizeT := c.alloc.FirstParam().Type()
 	size := llvm.ConstInt(sizeT, c.target.TypeAllocSize(t), false)
-	return c.builder.CreateCall(c.alloc, []llvm.Value{size, llvm.Undef(c.i8ptr), llvm.Undef(c.i8ptr)}, name)
+	layout := llvmutil.CreateObjectLayout(c.mod, t)
+	return c.builder.CreateCall(c.alloc, []llvm.Value{size, layout, llvm.Undef(c.i8ptr), llvm.Undef(c.i8ptr)}, name)
 }
 
 // lowerFuncFast lowers an async function that has no suspend points.
@@ -776,8 +815,10 @@ func (c *coroutineLoweringPass) lowerFuncCoro(fn *asyncFunc) {
 	}, "coro.id")
 	// %coro.size = call i32 @llvm.coro.size.i32()
 	coroSize := c.builder.CreateCall(c.coroSize, []llvm.Value{}, "coro.size")
-	// %coro.alloc = call i8* runtime.alloc(i32 %coro.size)
-	coroAlloc := c.builder.CreateCall(c.alloc, []llvm.Value{coroSize, llvm.Undef(c.i8ptr), llvm.Undef(c.i8ptr)}, "coro.alloc")
+	// %coro.alloc = call i8* runtime.alloc(i32 %coro.size, i8* null)
+	// The layout of the coroutine frame is not known, so it is scanned
+	// conservatively.
+	coroAlloc := c.builder.CreateCall(c.alloc, []llvm.Value{coroSize, llvm.ConstNull(c.i8ptr), llvm.Undef(c.i8ptr), llvm.Undef(c.i8ptr)}, "coro.alloc")
 	// %coro.state = call noalias i8* @llvm.coro.begin(token %coro.id, i8* %coro.alloc)
 	coroState := c.builder.CreateCall(c.coroBegin, []llvm.Value{coroId, coroAlloc}, "coro.state")
 	c.track(coroState)
@@ -973,8 +1014,10 @@ func (c *coroutineLoweringPass) lowerStart(start llvm.Value) {
 	paramTypes := fn.Type().ElementType().ParamTypes()
 	params := llvmutil.EmitPointerUnpack(c.builder, c.mod, start.Operand(1), paramTypes[:len(paramTypes)-1])
 
-	// Create task.
-	task := c.builder.CreateCall(c.createTask, []llvm.Value{llvm.Undef(c.i8ptr), llvm.Undef(c.i8ptr)}, "start.task")
+	// Create task. The function is marked as a goroutine entry point and
+	// passed along, so that its name can be printed in a goroutine dump.
+	fn.AddFunctionAttr(c.ctx.CreateStringAttribute("tinygo-goroutine", fn.Name()))
+	task := c.builder.CreateCall(c.createTask, []llvm.Value{start.Operand(0), llvm.Undef(c.i8ptr), llvm.Undef(c.i8ptr)}, "start.task")
 	rawTask := c.builder.CreateBitCast(task, c.i8ptr, "start.task.bitcast")
 	params = append(params, rawTask)
 
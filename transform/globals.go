@@ -32,3 +32,18 @@ func DisableTailCalls(mod llvm.Module) {
 		llvmFn = llvm.NextFunction(llvmFn)
 	}
 }
+
+// AddFramePointers adds the "frame-pointer"="all" function attribute to all
+// functions, so that the stack can be walked by following the chain of frame
+// pointers. This is the equivalent of passing -fno-omit-frame-pointer to a C
+// compiler.
+func AddFramePointers(mod llvm.Module) {
+	attribute := mod.Context().CreateStringAttribute("frame-pointer", "all")
+	llvmFn := mod.FirstFunction()
+	for !llvmFn.IsNil() {
+		if !llvmFn.IsDeclaration() {
+			llvmFn.AddFunctionAttr(attribute)
+		}
+		llvmFn = llvm.NextFunction(llvmFn)
+	}
+}
@@ -0,0 +1,73 @@
+package transform
+
+import (
+	"tinygo.org/x/go-llvm"
+)
+
+// CreateGoroutineNames implements the internal/task.getGoroutineName
+// intrinsic, which returns the name of the function a goroutine was started
+// with. Goroutine entry points are marked with the tinygo-gowrapper attribute
+// (for the start wrappers of the task based scheduler) or the
+// tinygo-goroutine attribute (for functions started by the coroutine lowering
+// pass). The intrinsic is implemented as a chain of comparisons against all
+// entry points that are still present in the module, so this pass should be
+// run after optimization.
+func CreateGoroutineNames(mod llvm.Module) {
+	getName := mod.NamedFunction("internal/task.getGoroutineName")
+	if getName.IsNil() || !getName.IsDeclaration() {
+		// Nothing to do.
+		return
+	}
+
+	ctx := mod.Context()
+	builder := ctx.NewBuilder()
+	defer builder.Dispose()
+
+	stringType := getName.Type().ElementType().ReturnType()
+	uintptrType := getName.Param(0).Type()
+	entryBlock := ctx.AddBasicBlock(getName, "entry")
+	builder.SetInsertPointAtEnd(entryBlock)
+	for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		name := goroutineName(fn)
+		if name == "" {
+			continue
+		}
+
+		// Create a constant string with the function name.
+		global := llvm.AddGlobal(mod, llvm.ArrayType(ctx.Int8Type(), len(name)), fn.Name()+"$name")
+		global.SetInitializer(ctx.ConstString(name, false))
+		global.SetLinkage(llvm.InternalLinkage)
+		global.SetGlobalConstant(true)
+		global.SetUnnamedAddr(true)
+		zero := llvm.ConstInt(ctx.Int32Type(), 0, false)
+		str := llvm.ConstNamedStruct(stringType, []llvm.Value{
+			llvm.ConstInBoundsGEP(global, []llvm.Value{zero, zero}),
+			llvm.ConstInt(uintptrType, uint64(len(name)), false),
+		})
+
+		// Return this string if the function pointer matches.
+		matchBlock := ctx.AddBasicBlock(getName, "match")
+		nextBlock := ctx.AddBasicBlock(getName, "next")
+		isMatch := builder.CreateICmp(llvm.IntEQ, getName.Param(0), llvm.ConstPtrToInt(fn, uintptrType), "")
+		builder.CreateCondBr(isMatch, matchBlock, nextBlock)
+		builder.SetInsertPointAtEnd(matchBlock)
+		builder.CreateRet(str)
+		builder.SetInsertPointAtEnd(nextBlock)
+	}
+
+	// Unknown function (or a goroutine started with a function value).
+	builder.CreateRet(llvm.ConstNull(stringType))
+	getName.SetLinkage(llvm.InternalLinkage)
+}
+
+// goroutineName returns the name of the function that is started as a
+// goroutine, if fn is a goroutine entry point.
+func goroutineName(fn llvm.Value) string {
+	for _, kind := range []string{"tinygo-gowrapper", "tinygo-goroutine"} {
+		attr := fn.GetStringAttributeAtIndex(-1, kind)
+		if !attr.IsNil() {
+			return attr.GetStringValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,14 @@
+package transform
+
+import (
+	"testing"
+
+	"tinygo.org/x/go-llvm"
+)
+
+func TestCreateGoroutineNames(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/goroutine-names", func(mod llvm.Module) {
+		CreateGoroutineNames(mod)
+	})
+}
@@ -67,7 +67,9 @@ func (s *signatureInfo) methodName() string {
 // methodInfo describes a single method on a concrete type.
 type methodInfo struct {
 	*signatureInfo
-	function llvm.Value
+	function llvm.Value // the interface invoke wrapper
+	typecode llvm.Value // type code of the method as a func, used by reflect
+	method   llvm.Value // the method itself, used by reflect
 }
 
 // typeInfo describes a single concrete Go type, which can be a basic or a named
@@ -435,6 +437,10 @@ func (p *lowerInterfacesPass) addTypeMethods(t *typeInfo, methodSet llvm.Value)
 			function:      function,
 			signatureInfo: signature,
 		}
+		if typecode := llvm.ConstExtractValue(methodData, []uint32{2}); !typecode.IsNull() {
+			method.typecode = typecode
+			method.method = llvm.ConstExtractValue(methodData, []uint32{3}).Operand(0)
+		}
 		signature.methods = append(signature.methods, method)
 		t.methods = append(t.methods, method)
 	}
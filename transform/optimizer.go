@@ -3,6 +3,7 @@ package transform
 import (
 	"errors"
 	"fmt"
+	"go/token"
 
 	"github.com/tinygo-org/tinygo/compileopts"
 	"github.com/tinygo-org/tinygo/compiler/ircheck"
@@ -74,7 +75,7 @@ func Optimize(mod llvm.Module, config *compileopts.Config, optLevel, sizeLevel i
 		// Run Go-specific optimization passes.
 		OptimizeMaps(mod)
 		OptimizeStringToBytes(mod)
-		OptimizeAllocs(mod)
+		OptimizeAllocs(mod, nil, nil)
 		err := LowerInterfaces(mod)
 		if err != nil {
 			return []error{err}
@@ -95,10 +96,20 @@ func Optimize(mod llvm.Module, config *compileopts.Config, optLevel, sizeLevel i
 		goPasses.Run(mod)
 
 		// Run TinyGo-specific interprocedural optimizations.
-		OptimizeAllocs(mod)
+		// Heap allocations are printed here (if requested), as no more
+		// allocations will be moved to the stack after this pass.
+		OptimizeAllocs(mod, config.PrintAllocs(), func(pos token.Position, msg string) {
+			fmt.Printf("%s: %s\n", pos, msg)
+		})
 		OptimizeStringToBytes(mod)
 
 	} else {
+		// Allocations are never moved to the stack without optimizations, so
+		// print all of them (if requested).
+		printHeapAllocs(mod, config.PrintAllocs(), func(pos token.Position, msg string) {
+			fmt.Printf("%s: %s\n", pos, msg)
+		})
+
 		// Must be run at any optimization level.
 		err := LowerInterfaces(mod)
 		if err != nil {
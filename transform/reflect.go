@@ -20,7 +20,7 @@ package transform
 //     multiple fields contained within. Most obviously structs can contain many
 //     types as fields. Also arrays contain not just the element type but also
 //     the length parameter which can be any arbitrary number and thus may not
-//     fit in a type code.
+//     fit in a type code, and maps contain both a key and an element type.
 //     These types are encoded using side tables.
 //
 // This distinction is also important for how named types are encoded. At the
@@ -31,6 +31,7 @@ import (
 	"encoding/binary"
 	"go/ast"
 	"math/big"
+	"sort"
 	"strings"
 
 	"tinygo.org/x/go-llvm"
@@ -80,6 +81,10 @@ type typeCodeAssignmentState struct {
 	// package (or are simply unused in the compiled program).
 	fallbackIndex int
 
+	// Map of types that use a fallback number (see fallbackIndex) to this
+	// number, so that the same type always gets the same type code.
+	fallbackTypes map[string]int
+
 	// This is the length of an uintptr. Only used occasionally to know whether
 	// a given number can be encoded as a varint.
 	uintptrLen int
@@ -94,6 +99,11 @@ type typeCodeAssignmentState struct {
 	arrayTypesSidetable      []byte
 	needsArrayTypesSidetable bool
 
+	// Map of map types to their type code.
+	mapTypes               map[string]int
+	mapTypesSidetable      []byte
+	needsMapTypesSidetable bool
+
 	// Map of struct types to their type code.
 	structTypes               map[string]int
 	structTypesSidetable      []byte
@@ -120,6 +130,14 @@ type typeCodeAssignmentState struct {
 	// all. If it is false, namedNonBasicTypesSidetable will contain simple
 	// monotonically increasing numbers.
 	needsNamedNonBasicTypesSidetable bool
+
+	// All named types that can be reached through the reflect package, by
+	// name. This is used to create reflect.typeNamesSidetable.
+	namedTypes              map[string]llvm.Value
+	needsTypeNamesSidetable bool
+
+	// Whether reflect.methodSetsSidetable needs to be created.
+	needsMethodSetsSidetable bool
 }
 
 // assignTypeCodes is used to assign a type code to each type in the program
@@ -132,16 +150,22 @@ func assignTypeCodes(mod llvm.Module, typeSlice typeInfoSlice) {
 	// Assign typecodes the way the reflect package expects.
 	state := typeCodeAssignmentState{
 		fallbackIndex:                    1,
+		fallbackTypes:                    make(map[string]int),
 		uintptrLen:                       llvm.NewTargetData(mod.DataLayout()).PointerSize() * 8,
 		namedBasicTypes:                  make(map[string]int),
 		namedNonBasicTypes:               make(map[string]int),
 		arrayTypes:                       make(map[string]int),
+		mapTypes:                         make(map[string]int),
 		structTypes:                      make(map[string]int),
 		structNames:                      make(map[string]int),
+		namedTypes:                       make(map[string]llvm.Value),
 		needsNamedNonBasicTypesSidetable: len(getUses(mod.NamedGlobal("reflect.namedNonBasicTypesSidetable"))) != 0,
 		needsStructTypesSidetable:        len(getUses(mod.NamedGlobal("reflect.structTypesSidetable"))) != 0,
 		needsStructNamesSidetable:        len(getUses(mod.NamedGlobal("reflect.structNamesSidetable"))) != 0,
 		needsArrayTypesSidetable:         len(getUses(mod.NamedGlobal("reflect.arrayTypesSidetable"))) != 0,
+		needsMapTypesSidetable:           len(getUses(mod.NamedGlobal("reflect.mapTypesSidetable"))) != 0,
+		needsTypeNamesSidetable:          len(getUses(mod.NamedGlobal("reflect.typeNamesSidetable"))) != 0,
+		needsMethodSetsSidetable:         len(getUses(mod.NamedGlobal("reflect.methodSetsSidetable"))) != 0,
 	}
 	for _, t := range typeSlice {
 		num := state.getTypeCodeNum(t.typecode)
@@ -155,6 +179,42 @@ func assignTypeCodes(mod llvm.Module, typeSlice typeInfoSlice) {
 		t.num = num.Uint64()
 	}
 
+	// The names of named types and the method sets of types are only stored
+	// when they are used by the reflect package, as they can be quite big.
+	if state.needsTypeNamesSidetable {
+		buf := state.makeTypeNamesSidetable(typeSlice)
+		global := replaceGlobalIntWithArray(mod, "reflect.typeNamesSidetable", buf)
+		global.SetLinkage(llvm.InternalLinkage)
+		global.SetUnnamedAddr(true)
+		global.SetGlobalConstant(true)
+	}
+	if state.needsMethodSetsSidetable {
+		buf, funcs := state.makeMethodSetsSidetable(mod, typeSlice)
+		global := replaceGlobalIntWithArray(mod, "reflect.methodSetsSidetable", buf)
+		global.SetLinkage(llvm.InternalLinkage)
+		global.SetUnnamedAddr(true)
+		global.SetGlobalConstant(true)
+
+		// The func values of all methods, indexed from the method sets
+		// sidetable. These can't be stored as bytes as they may need to be
+		// updated by the func value lowering pass.
+		oldGlobal := mod.NamedGlobal("reflect.methodFuncsSidetable")
+		if !oldGlobal.IsNil() {
+			value := llvm.ConstArray(oldGlobal.Type().ElementType(), funcs)
+			global := llvm.AddGlobal(mod, value.Type(), "reflect.methodFuncsSidetable.tmp")
+			global.SetInitializer(value)
+			global.SetLinkage(llvm.InternalLinkage)
+			global.SetUnnamedAddr(true)
+			global.SetGlobalConstant(true)
+			oldGlobal.ReplaceAllUsesWith(llvm.ConstGEP(global, []llvm.Value{
+				llvm.ConstInt(mod.Context().Int32Type(), 0, false),
+				llvm.ConstInt(mod.Context().Int32Type(), 0, false),
+			}))
+			oldGlobal.EraseFromParentAsGlobal()
+			global.SetName("reflect.methodFuncsSidetable")
+		}
+	}
+
 	// Only create this sidetable when it is necessary.
 	if state.needsNamedNonBasicTypesSidetable {
 		global := replaceGlobalIntWithArray(mod, "reflect.namedNonBasicTypesSidetable", state.namedNonBasicTypesSidetable)
@@ -168,6 +228,12 @@ func assignTypeCodes(mod llvm.Module, typeSlice typeInfoSlice) {
 		global.SetUnnamedAddr(true)
 		global.SetGlobalConstant(true)
 	}
+	if state.needsMapTypesSidetable {
+		global := replaceGlobalIntWithArray(mod, "reflect.mapTypesSidetable", state.mapTypesSidetable)
+		global.SetLinkage(llvm.InternalLinkage)
+		global.SetUnnamedAddr(true)
+		global.SetGlobalConstant(true)
+	}
 	if state.needsStructTypesSidetable {
 		global := replaceGlobalIntWithArray(mod, "reflect.structTypesSidetable", state.structTypesSidetable)
 		global.SetLinkage(llvm.InternalLinkage)
@@ -281,6 +347,9 @@ func (state *typeCodeAssignmentState) getNonBasicTypeCode(class string, typecode
 		// An array is basically a pair of (typecode, length) stored in a
 		// sidetable.
 		return big.NewInt(int64(state.getArrayTypeNum(typecode)))
+	case "map":
+		// A map is a pair of (key type, element type) stored in a sidetable.
+		return big.NewInt(int64(state.getMapTypeNum(typecode)))
 	case "struct":
 		// More complicated type kind. The upper bits contain the index to the
 		// struct type in the struct types sidetable.
@@ -288,9 +357,13 @@ func (state *typeCodeAssignmentState) getNonBasicTypeCode(class string, typecode
 	default:
 		// Type has not yet been implemented, so fall back by using a unique
 		// number.
-		num := big.NewInt(int64(state.fallbackIndex))
+		if index, ok := state.fallbackTypes[typecode.Name()]; ok {
+			return big.NewInt(int64(index))
+		}
+		index := state.fallbackIndex
 		state.fallbackIndex++
-		return num
+		state.fallbackTypes[typecode.Name()] = index
+		return big.NewInt(int64(index))
 	}
 }
 
@@ -362,6 +435,48 @@ func (state *typeCodeAssignmentState) getArrayTypeNum(typecode llvm.Value) int {
 	return index
 }
 
+// getMapTypeNum returns the map type number, which is an index into the
+// reflect.mapTypesSidetable or a unique number for this type if this table is
+// not used.
+func (state *typeCodeAssignmentState) getMapTypeNum(typecode llvm.Value) int {
+	name := typecode.Name()
+	if num, ok := state.mapTypes[name]; ok {
+		// This map type already has an entry in the sidetable. Don't store it
+		// twice.
+		return num
+	}
+
+	if !state.needsMapTypesSidetable {
+		// We don't need map sidetables, so we can just assign monotonically
+		// increasing numbers to each map type.
+		num := len(state.mapTypes)
+		state.mapTypes[name] = num
+		return num
+	}
+
+	elemTypeCode := llvm.ConstExtractValue(typecode.Initializer(), []uint32{0})
+	elemTypeNum := state.getTypeCodeNum(elemTypeCode)
+	if elemTypeNum.BitLen() > state.uintptrLen || !elemTypeNum.IsUint64() {
+		// TODO: make this a regular error
+		panic("map element type has a type code that is too big")
+	}
+	keyTypeCode := llvm.ConstExtractValue(typecode.Initializer(), []uint32{2})
+	keyTypeNum := state.getTypeCodeNum(keyTypeCode)
+	if keyTypeNum.BitLen() > state.uintptrLen || !keyTypeNum.IsUint64() {
+		// TODO: make this a regular error
+		panic("map key type has a type code that is too big")
+	}
+
+	// The map side table is a sequence of {key type, element type}.
+	buf := makeVarint(keyTypeNum.Uint64())
+	buf = append(buf, makeVarint(elemTypeNum.Uint64())...)
+
+	index := len(state.mapTypesSidetable)
+	state.mapTypes[name] = index
+	state.mapTypesSidetable = append(state.mapTypesSidetable, buf...)
+	return index
+}
+
 // getStructTypeNum returns the struct type number, which is an index into
 // reflect.structTypesSidetable or an unique number for every struct if this
 // sidetable is not needed in the to-be-compiled program.
@@ -422,6 +537,16 @@ func (state *typeCodeAssignmentState) getStructTypeNum(typecode llvm.Value) int
 		// The 'embedded' or 'anonymous' flag for this field.
 		embedded := llvm.ConstExtractValue(field, []uint32{3}).ZExtValue() != 0
 
+		// The package path, for unexported fields.
+		pkgPathGlobal := llvm.ConstExtractValue(field, []uint32{4})
+		hasPkgPath := false
+		pkgPathNumber := 0
+		if pkgPathGlobal != llvm.ConstPointerNull(pkgPathGlobal.Type()) {
+			hasPkgPath = true
+			pkgPathBytes := getGlobalBytes(pkgPathGlobal.Operand(0))
+			pkgPathNumber = state.getStructNameNumber(pkgPathBytes)
+		}
+
 		// The first byte in the struct types sidetable is a flags byte with
 		// two bits in it.
 		flagsByte := byte(0)
@@ -431,7 +556,7 @@ func (state *typeCodeAssignmentState) getStructTypeNum(typecode llvm.Value) int
 		if hasTag {
 			flagsByte |= 2
 		}
-		if ast.IsExported(string(fieldNameBytes)) {
+		if !hasPkgPath {
 			flagsByte |= 4
 		}
 		buf = append(buf, flagsByte)
@@ -452,6 +577,11 @@ func (state *typeCodeAssignmentState) getStructTypeNum(typecode llvm.Value) int
 		if hasTag {
 			buf = append(buf, makeVarint(uint64(tagNumber))...)
 		}
+
+		// Add the package path, if this field is unexported.
+		if hasPkgPath {
+			buf = append(buf, makeVarint(uint64(pkgPathNumber))...)
+		}
 	}
 
 	num := len(state.structTypesSidetable)
@@ -478,6 +608,214 @@ func (state *typeCodeAssignmentState) getStructNameNumber(nameBytes []byte) int
 	return n
 }
 
+// makeTypeNamesSidetable creates the reflect.typeNamesSidetable. It is a list
+// of entries, where each entry is the type code (as a varint) followed by the
+// package path and the name of the type (both encoded as a varint length
+// followed by the raw bytes). The list is terminated by a zero type code.
+// Only named types that can be reached from a type that is stored in an
+// interface are included, as other types can never be seen by the reflect
+// package.
+func (state *typeCodeAssignmentState) makeTypeNamesSidetable(typeSlice typeInfoSlice) []byte {
+	walkReflectTypes(typeSlice, func(typecode llvm.Value) {
+		if class, value := getClassAndValueFromTypeCode(typecode); class == "named" {
+			state.namedTypes[value] = typecode
+		}
+	})
+
+	// Sort the names, to make the output deterministic.
+	names := make([]string, 0, len(state.namedTypes))
+	for name := range state.namedTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf []byte
+	for _, name := range names {
+		// Type codes have already been assigned to all named types, so this
+		// only looks up the type code.
+		num := state.getTypeCodeNum(state.namedTypes[name])
+		if num.BitLen() > state.uintptrLen || !num.IsUint64() {
+			// TODO: make this a regular error
+			panic("named type has a type code that is too big")
+		}
+
+		// The name is in the form "path/to/pkg.Name".
+		pkgPath := ""
+		typeName := name
+		if index := strings.LastIndexByte(name, '.'); index >= 0 {
+			pkgPath = name[:index]
+			typeName = name[index+1:]
+		}
+		buf = append(buf, makeVarint(num.Uint64())...)
+		buf = append(buf, makeVarint(uint64(len(pkgPath)))...)
+		buf = append(buf, pkgPath...)
+		buf = append(buf, makeVarint(uint64(len(typeName)))...)
+		buf = append(buf, typeName...)
+	}
+	return append(buf, 0)
+}
+
+// walkReflectTypes calls the callback for every type that can be seen by the
+// reflect package: all types that are stored in an interface and all types that
+// can be reached from them through element types, key types, underlying types
+// and struct fields. Each type is visited only once.
+func walkReflectTypes(typeSlice typeInfoSlice, callback func(typecode llvm.Value)) {
+	visited := make(map[llvm.Value]struct{})
+	var walk func(typecode llvm.Value)
+	walk = func(typecode llvm.Value) {
+		if _, ok := visited[typecode]; ok {
+			return
+		}
+		visited[typecode] = struct{}{}
+		callback(typecode)
+
+		class, _ := getClassAndValueFromTypeCode(typecode)
+		switch class {
+		case "named", "chan", "pointer", "slice", "array":
+			walk(llvm.ConstExtractValue(typecode.Initializer(), []uint32{0}))
+		case "map":
+			walk(llvm.ConstExtractValue(typecode.Initializer(), []uint32{0}))
+			walk(llvm.ConstExtractValue(typecode.Initializer(), []uint32{2}))
+		case "struct":
+			structTypeGlobal := llvm.ConstExtractValue(typecode.Initializer(), []uint32{0}).Operand(0).Initializer()
+			for i := 0; i < structTypeGlobal.Type().ArrayLength(); i++ {
+				field := llvm.ConstExtractValue(structTypeGlobal, []uint32{uint32(i)})
+				walk(llvm.ConstExtractValue(field, []uint32{0}))
+			}
+		}
+	}
+	for _, t := range typeSlice {
+		if t.countMakeInterfaces != 0 {
+			walk(t.typecode)
+		}
+	}
+}
+
+// makeMethodSetsSidetable creates the reflect.methodSetsSidetable. It is a
+// list of entries, one for each type that has methods. Each entry is the type
+// code (as a varint), the number of exported methods (as a varint), the number
+// of unexported methods (as a varint) and the methods themselves, first the
+// exported and then the unexported methods, each sorted by signature. Every
+// method is stored as the signature string (like "Add(int) int", encoded as a
+// varint length followed by the raw bytes), the type code of the method as a
+// function with the receiver as first parameter and an index into the
+// returned list of func values (both as varints). The type code and func value
+// index are 0 for interface methods and unexported methods. The list is
+// terminated by a zero type code.
+func (state *typeCodeAssignmentState) makeMethodSetsSidetable(mod llvm.Module, typeSlice typeInfoSlice) ([]byte, []llvm.Value) {
+	uintptrType := mod.Context().IntType(state.uintptrLen)
+
+	// The func value of a method is its function pointer, except when func
+	// values are lowered to a switch (see func-lowering.go). In that case it
+	// must refer to a runtime.funcValueWithSignature global.
+	useFuncValueSwitch := !mod.NamedFunction("runtime.getFuncPtr").IsNil()
+	funcValueWithSignatureType := mod.GetTypeByName("runtime.funcValueWithSignature")
+
+	type methodEntry struct {
+		signature string
+		typecode  uint64
+		funcIndex uint64
+	}
+	funcs := []llvm.Value{llvm.ConstInt(uintptrType, 0, false)} // index 0 is unused
+	var buf []byte
+	addEntry := func(num uint64, exported, unexported []methodEntry) {
+		sort.Slice(exported, func(i, j int) bool {
+			return exported[i].signature < exported[j].signature
+		})
+		sort.Slice(unexported, func(i, j int) bool {
+			return unexported[i].signature < unexported[j].signature
+		})
+		buf = append(buf, makeVarint(num)...)
+		buf = append(buf, makeVarint(uint64(len(exported)))...)
+		buf = append(buf, makeVarint(uint64(len(unexported)))...)
+		for _, entry := range append(exported, unexported...) {
+			buf = append(buf, makeVarint(uint64(len(entry.signature)))...)
+			buf = append(buf, entry.signature...)
+			buf = append(buf, makeVarint(entry.typecode)...)
+			buf = append(buf, makeVarint(entry.funcIndex)...)
+		}
+	}
+
+	// Add the method sets of concrete types.
+	for _, t := range typeSlice {
+		if len(t.methods) != 0 {
+			var exported, unexported []methodEntry
+			for _, method := range t.methods {
+				entry := methodEntry{
+					signature: strings.TrimPrefix(method.signatureInfo.name, "func "),
+				}
+				if !ast.IsExported(method.signatureInfo.methodName()) {
+					unexported = append(unexported, entry)
+					continue
+				}
+				if !method.typecode.IsNil() && !method.method.IsNil() {
+					entry.typecode = state.getTypeCodeNum(method.typecode).Uint64()
+					funcValue := method.method
+					if useFuncValueSwitch {
+						funcValue = mod.NamedGlobal(method.method.Name() + "$withSignature")
+						if funcValue.IsNil() {
+							funcValue = llvm.AddGlobal(mod, funcValueWithSignatureType, method.method.Name()+"$withSignature")
+							funcValue.SetInitializer(llvm.ConstNamedStruct(funcValueWithSignatureType, []llvm.Value{
+								llvm.ConstPtrToInt(method.method, uintptrType),
+								method.typecode,
+							}))
+							funcValue.SetGlobalConstant(true)
+							funcValue.SetLinkage(llvm.InternalLinkage)
+						}
+					}
+					entry.funcIndex = uint64(len(funcs))
+					funcs = append(funcs, llvm.ConstPtrToInt(funcValue, uintptrType))
+				}
+				exported = append(exported, entry)
+			}
+			addEntry(t.num, exported, unexported)
+		}
+	}
+
+	// Add the method sets of interface types that can be reached through
+	// reflect, for Type.Implements and similar.
+	walkReflectTypes(typeSlice, func(typecode llvm.Value) {
+		signatures := getInterfaceSignatures(typecode)
+		if len(signatures) == 0 {
+			return
+		}
+		var exported, unexported []methodEntry
+		for _, signature := range signatures {
+			entry := methodEntry{signature: signature}
+			if ast.IsExported(signature[:strings.IndexByte(signature, '(')]) {
+				exported = append(exported, entry)
+			} else {
+				unexported = append(unexported, entry)
+			}
+		}
+		addEntry(state.getTypeCodeNum(typecode).Uint64(), exported, unexported)
+	})
+	return append(buf, 0), funcs
+}
+
+// getInterfaceSignatures returns the method signatures (like "Add(int) int") of
+// the given interface type code, or nil if it isn't an interface type or has
+// no methods.
+func getInterfaceSignatures(typecode llvm.Value) []string {
+	class, _ := getClassAndValueFromTypeCode(typecode)
+	if class == "named" {
+		typecode = llvm.ConstExtractValue(typecode.Initializer(), []uint32{0})
+		class, _ = getClassAndValueFromTypeCode(typecode)
+	}
+	if class != "interface" || typecode.Initializer().IsNil() {
+		return nil
+	}
+	// The compiler stores a reference to a list of signature strings.
+	methodsGlobal := llvm.ConstExtractValue(typecode.Initializer(), []uint32{0}).Operand(0)
+	methods := methodsGlobal.Initializer()
+	signatures := make([]string, methods.Type().ArrayLength())
+	for i := range signatures {
+		signatureGlobal := llvm.ConstExtractValue(methods, []uint32{uint32(i)}).Operand(0)
+		signatures[i] = string(getGlobalBytes(signatureGlobal))
+	}
+	return signatures
+}
+
 // makeVarint is a small helper function that returns the bytes of the number in
 // varint encoding.
 func makeVarint(n uint64) []byte {
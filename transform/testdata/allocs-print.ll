@@ -0,0 +1,36 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+declare nonnull i8* @runtime.alloc(i32)
+
+declare i32* @escapeIntPtr(i32*)
+
+; A heap allocation in the main package that was inlined from the lib package.
+; It should be attributed to the lib package, based on its debug location.
+define void @main.caller() !dbg !6 {
+  %1 = call i8* @runtime.alloc(i32 4), !dbg !10
+  %2 = bitcast i8* %1 to i32*
+  %3 = call i32* @escapeIntPtr(i32* %2), !dbg !9
+  ret void, !dbg !9
+}
+
+; A function of the lib package, so that its directory is known.
+define void @"example.com/lib.other"() !dbg !8 {
+  ret void, !dbg !11
+}
+
+!llvm.dbg.cu = !{!0}
+!llvm.module.flags = !{!3}
+
+!0 = distinct !DICompileUnit(language: DW_LANG_Go, file: !1, producer: "TinyGo", isOptimized: true, runtimeVersion: 0, emissionKind: FullDebug)
+!1 = !DIFile(filename: "main.go", directory: "/src/main")
+!2 = !DIFile(filename: "lib.go", directory: "/src/lib")
+!3 = !{i32 2, !"Debug Info Version", i32 3}
+!4 = !{}
+!5 = !DISubroutineType(types: !4)
+!6 = distinct !DISubprogram(name: "main.caller", linkageName: "main.caller", scope: !1, file: !1, line: 3, type: !5, scopeLine: 3, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !0)
+!7 = distinct !DISubprogram(name: "example.com/lib.New", linkageName: "example.com/lib.New", scope: !2, file: !2, line: 5, type: !5, scopeLine: 5, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !0)
+!8 = distinct !DISubprogram(name: "example.com/lib.other", linkageName: "example.com/lib.other", scope: !2, file: !2, line: 10, type: !5, scopeLine: 10, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !0)
+!9 = !DILocation(line: 4, column: 2, scope: !6)
+!10 = !DILocation(line: 6, column: 9, scope: !7, inlinedAt: !9)
+!11 = !DILocation(line: 11, column: 1, scope: !8)
@@ -1,6 +1,8 @@
 target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
 target triple = "armv7m-none-eabi"
 
+@escapedPtr = global i32* null
+
 declare nonnull i8* @runtime.alloc(i32)
 
 ; Test allocating a single int (i32) that should be allocated on the stack.
@@ -67,6 +69,29 @@ loop:
 end:
   ret void
 }
+; Store the allocated value in a global, which lets it escape.
+define void @testEscapingGlobal() {
+  %1 = call i8* @runtime.alloc(i32 4)
+  %2 = bitcast i8* %1 to i32*
+  store i32* %2, i32** @escapedPtr
+  ret void
+}
+
+; Allocate an object that is too big to be allocated on the stack.
+define void @testTooBig() {
+  %1 = call i8* @runtime.alloc(i32 1024)
+  %2 = bitcast i8* %1 to i32*
+  store i32 5, i32* %2
+  ret void
+}
+
+; Allocate an object with a size that is not known at compile time.
+define void @testDynamicSize(i32 %size) {
+  %1 = call i8* @runtime.alloc(i32 %size)
+  %2 = bitcast i8* %1 to i32*
+  store i32 5, i32* %2
+  ret void
+}
 
 declare i32* @escapeIntPtr(i32*)
 
@@ -9,7 +9,7 @@ declare void @"internal/task.Pause"(i8*, i8*)
 
 declare void @runtime.scheduler(i8*, i8*)
 
-declare i8* @runtime.alloc(i32, i8*, i8*)
+declare i8* @runtime.alloc(i32, i8*, i8*, i8*)
 declare void @runtime.free(i8*, i8*, i8*)
 
 declare %"internal/task.Task"* @"internal/task.Current"(i8*, i8*)
@@ -19,7 +19,7 @@ declare void @"(*internal/task.Task).setReturnPtr"(%"internal/task.Task"*, i8*,
 declare i8* @"(*internal/task.Task).getReturnPtr"(%"internal/task.Task"*, i8*, i8*)
 declare void @"(*internal/task.Task).returnTo"(%"internal/task.Task"*, i8*, i8*, i8*)
 declare void @"(*internal/task.Task).returnCurrent"(%"internal/task.Task"*, i8*, i8*)
-declare %"internal/task.Task"* @"internal/task.createTask"(i8*, i8*)
+declare %"internal/task.Task"* @"internal/task.createTask"(i32, i8*, i8*)
 
 declare void @callMain(i8*, i8*)
 
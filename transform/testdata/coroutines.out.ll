@@ -10,7 +10,7 @@ declare void @"internal/task.Pause"(i8*, i8*)
 
 declare void @runtime.scheduler(i8*, i8*)
 
-declare i8* @runtime.alloc(i32, i8*, i8*)
+declare i8* @runtime.alloc(i32, i8*, i8*, i8*)
 
 declare void @runtime.free(i8*, i8*, i8*)
 
@@ -26,7 +26,7 @@ declare void @"(*internal/task.Task).returnTo"(%"internal/task.Task"*, i8*, i8*,
 
 declare void @"(*internal/task.Task).returnCurrent"(%"internal/task.Task"*, i8*, i8*)
 
-declare %"internal/task.Task"* @"internal/task.createTask"(i8*, i8*)
+declare %"internal/task.Task"* @"internal/task.createTask"(i32, i8*, i8*)
 
 declare void @callMain(i8*, i8*)
 
@@ -66,7 +66,7 @@ entry:
 define void @ditchTail(i32 %0, i64 %1, i8* %2, i8* %parentHandle) {
 entry:
   %task.current = bitcast i8* %parentHandle to %"internal/task.Task"*
-  %ret.ditch = call i8* @runtime.alloc(i32 4, i8* undef, i8* undef)
+  %ret.ditch = call i8* @runtime.alloc(i32 4, i8* inttoptr (i32 3 to i8*), i8* undef, i8* undef)
   call void @"(*internal/task.Task).setReturnPtr"(%"internal/task.Task"* %task.current, i8* %ret.ditch, i8* undef, i8* undef)
   %3 = call i32 @delayedValue(i32 %0, i64 %1, i8* undef, i8* %parentHandle)
   ret void
@@ -85,7 +85,7 @@ entry:
   %ret.ptr = call i8* @"(*internal/task.Task).getReturnPtr"(%"internal/task.Task"* %task.current, i8* undef, i8* undef)
   %ret.ptr.bitcast = bitcast i8* %ret.ptr to i32*
   store i32 %0, i32* %ret.ptr.bitcast
-  %ret.alternate = call i8* @runtime.alloc(i32 4, i8* undef, i8* undef)
+  %ret.alternate = call i8* @runtime.alloc(i32 4, i8* inttoptr (i32 3 to i8*), i8* undef, i8* undef)
   call void @"(*internal/task.Task).setReturnPtr"(%"internal/task.Task"* %task.current, i8* %ret.alternate, i8* undef, i8* undef)
   %4 = call i32 @delayedValue(i32 %1, i64 %2, i8* undef, i8* %parentHandle)
   ret i32 undef
@@ -96,7 +96,7 @@ entry:
   %call.return = alloca i32
   %coro.id = call token @llvm.coro.id(i32 0, i8* null, i8* null, i8* null)
   %coro.size = call i32 @llvm.coro.size.i32()
-  %coro.alloc = call i8* @runtime.alloc(i32 %coro.size, i8* undef, i8* undef)
+  %coro.alloc = call i8* @runtime.alloc(i32 %coro.size, i8* null, i8* undef, i8* undef)
   %coro.state = call i8* @llvm.coro.begin(token %coro.id, i8* %coro.alloc)
   %task.current2 = bitcast i8* %parentHandle to %"internal/task.Task"*
   %task.state.parent = call i8* @"(*internal/task.Task).setState"(%"internal/task.Task"* %task.current2, i8* %coro.state, i8* undef, i8* undef)
@@ -138,17 +138,17 @@ entry:
   ret void
 }
 
-define void @sleepGoroutine(i8* %0, i8* %parentHandle) {
+define void @sleepGoroutine(i8* %0, i8* %parentHandle) #0 {
   %task.current = bitcast i8* %parentHandle to %"internal/task.Task"*
   call void @sleep(i64 1000000, i8* undef, i8* %parentHandle)
   ret void
 }
 
-define void @progMain(i8* %0, i8* %parentHandle) {
+define void @progMain(i8* %0, i8* %parentHandle) #1 {
 entry:
   %task.current = bitcast i8* %parentHandle to %"internal/task.Task"*
   call void @doNothing(i8* undef, i8* undef)
-  %start.task = call %"internal/task.Task"* @"internal/task.createTask"(i8* undef, i8* undef)
+  %start.task = call %"internal/task.Task"* @"internal/task.createTask"(i32 ptrtoint (void (i8*, i8*)* @sleepGoroutine to i32), i8* undef, i8* undef)
   %start.task.bitcast = bitcast %"internal/task.Task"* %start.task to i8*
   call void @sleepGoroutine(i8* undef, i8* %start.task.bitcast)
   call void @sleep(i64 2000000, i8* undef, i8* %parentHandle)
@@ -157,7 +157,7 @@ entry:
 
 define void @main() {
 entry:
-  %start.task = call %"internal/task.Task"* @"internal/task.createTask"(i8* undef, i8* undef)
+  %start.task = call %"internal/task.Task"* @"internal/task.createTask"(i32 ptrtoint (void (i8*, i8*)* @progMain to i32), i8* undef, i8* undef)
   %start.task.bitcast = bitcast %"internal/task.Task"* %start.task to i8*
   call void @progMain(i8* undef, i8* %start.task.bitcast)
   call void @runtime.scheduler(i8* undef, i8* null)
@@ -165,33 +165,35 @@ entry:
 }
 
 ; Function Attrs: argmemonly nounwind readonly
-declare token @llvm.coro.id(i32, i8* readnone, i8* nocapture readonly, i8*) #0
+declare token @llvm.coro.id(i32, i8* readnone, i8* nocapture readonly, i8*) #2
 
 ; Function Attrs: nounwind readnone
-declare i32 @llvm.coro.size.i32() #1
+declare i32 @llvm.coro.size.i32() #3
 
 ; Function Attrs: nounwind
-declare i8* @llvm.coro.begin(token, i8* writeonly) #2
+declare i8* @llvm.coro.begin(token, i8* writeonly) #4
 
 ; Function Attrs: nounwind
-declare i8 @llvm.coro.suspend(token, i1) #2
+declare i8 @llvm.coro.suspend(token, i1) #4
 
 ; Function Attrs: nounwind
-declare i1 @llvm.coro.end(i8*, i1) #2
+declare i1 @llvm.coro.end(i8*, i1) #4
 
 ; Function Attrs: argmemonly nounwind readonly
-declare i8* @llvm.coro.free(token, i8* nocapture readonly) #0
+declare i8* @llvm.coro.free(token, i8* nocapture readonly) #2
 
 ; Function Attrs: nounwind
-declare token @llvm.coro.save(i8*) #2
+declare token @llvm.coro.save(i8*) #4
 
 ; Function Attrs: argmemonly nounwind willreturn
-declare void @llvm.lifetime.start.p0i8(i64 immarg, i8* nocapture) #3
+declare void @llvm.lifetime.start.p0i8(i64 immarg, i8* nocapture) #5
 
 ; Function Attrs: argmemonly nounwind willreturn
-declare void @llvm.lifetime.end.p0i8(i64 immarg, i8* nocapture) #3
-
-attributes #0 = { argmemonly nounwind readonly }
-attributes #1 = { nounwind readnone }
-attributes #2 = { nounwind }
-attributes #3 = { argmemonly nounwind willreturn }
+declare void @llvm.lifetime.end.p0i8(i64 immarg, i8* nocapture) #5
+
+attributes #0 = { "tinygo-goroutine"="sleepGoroutine" }
+attributes #1 = { "tinygo-goroutine"="progMain" }
+attributes #2 = { argmemonly nounwind readonly }
+attributes #3 = { nounwind readnone }
+attributes #4 = { nounwind }
+attributes #5 = { argmemonly nounwind willreturn }
@@ -0,0 +1,30 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+%runtime._string = type { i8*, i32 }
+
+declare %runtime._string @"internal/task.getGoroutineName"(i32, i8*, i8*)
+
+define internal void @"main.worker$gowrapper"(i8* %0) #0 {
+entry:
+  ret void
+}
+
+define internal void @main.gowrapper(i8* %0) #1 {
+entry:
+  ret void
+}
+
+define void @main.sleeper(i8* %0, i8* %parentHandle) #2 {
+entry:
+  ret void
+}
+
+define void @main.notStarted(i8* %0, i8* %parentHandle) {
+entry:
+  ret void
+}
+
+attributes #0 = { "tinygo-gowrapper"="main.worker" }
+attributes #1 = { "tinygo-gowrapper"="" }
+attributes #2 = { "tinygo-goroutine"="main.sleeper" }
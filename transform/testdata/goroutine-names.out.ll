@@ -0,0 +1,50 @@
+target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "armv7m-none-eabi"
+
+%runtime._string = type { i8*, i32 }
+
+@"main.worker$gowrapper$name" = internal unnamed_addr constant [11 x i8] c"main.worker"
+@"main.sleeper$name" = internal unnamed_addr constant [12 x i8] c"main.sleeper"
+
+define internal %runtime._string @"internal/task.getGoroutineName"(i32 %0, i8* %1, i8* %2) {
+entry:
+  %3 = icmp eq i32 %0, ptrtoint (void (i8*)* @"main.worker$gowrapper" to i32)
+  br i1 %3, label %match, label %next
+
+match:                                            ; preds = %entry
+  ret %runtime._string { i8* getelementptr inbounds ([11 x i8], [11 x i8]* @"main.worker$gowrapper$name", i32 0, i32 0), i32 11 }
+
+next:                                             ; preds = %entry
+  %4 = icmp eq i32 %0, ptrtoint (void (i8*, i8*)* @main.sleeper to i32)
+  br i1 %4, label %match1, label %next2
+
+match1:                                           ; preds = %next
+  ret %runtime._string { i8* getelementptr inbounds ([12 x i8], [12 x i8]* @"main.sleeper$name", i32 0, i32 0), i32 12 }
+
+next2:                                            ; preds = %next
+  ret %runtime._string zeroinitializer
+}
+
+define internal void @"main.worker$gowrapper"(i8* %0) #0 {
+entry:
+  ret void
+}
+
+define internal void @main.gowrapper(i8* %0) #1 {
+entry:
+  ret void
+}
+
+define void @main.sleeper(i8* %0, i8* %parentHandle) #2 {
+entry:
+  ret void
+}
+
+define void @main.notStarted(i8* %0, i8* %parentHandle) {
+entry:
+  ret void
+}
+
+attributes #0 = { "tinygo-gowrapper"="main.worker" }
+attributes #1 = { "tinygo-gowrapper"="" }
+attributes #2 = { "tinygo-goroutine"="main.sleeper" }
@@ -3,7 +3,7 @@ target triple = "armv7m-none-eabi"
 
 %runtime.typecodeID = type { %runtime.typecodeID*, i32 }
 %runtime.typeInInterface = type { %runtime.typecodeID*, %runtime.interfaceMethodInfo* }
-%runtime.interfaceMethodInfo = type { i8*, i32 }
+%runtime.interfaceMethodInfo = type { i8*, i32, %runtime.typecodeID*, i32 }
 
 @"reflect/types.type:basic:uint8" = external constant %runtime.typecodeID
 @"reflect/types.type:basic:int" = external constant %runtime.typecodeID
@@ -13,7 +13,7 @@ target triple = "armv7m-none-eabi"
 @"Unmatched$interface" = private constant [1 x i8*] [i8* @"func NeverImplementedMethod()"]
 @"func Double() int" = external constant i8
 @"Doubler$interface" = private constant [1 x i8*] [i8* @"func Double() int"]
-@"Number$methodset" = private constant [1 x %runtime.interfaceMethodInfo] [%runtime.interfaceMethodInfo { i8* @"func Double() int", i32 ptrtoint (i32 (i8*, i8*)* @"(Number).Double$invoke" to i32) }]
+@"Number$methodset" = private constant [1 x %runtime.interfaceMethodInfo] [%runtime.interfaceMethodInfo { i8* @"func Double() int", i32 ptrtoint (i32 (i8*, i8*)* @"(Number).Double$invoke" to i32), %runtime.typecodeID* null, i32 0 }]
 @"reflect/types.type:named:Number" = private constant %runtime.typecodeID { %runtime.typecodeID* @"reflect/types.type:basic:int", i32 0 }
 @"typeInInterface:reflect/types.type:named:Number" = private constant %runtime.typeInInterface { %runtime.typecodeID* @"reflect/types.type:named:Number", %runtime.interfaceMethodInfo* getelementptr inbounds ([1 x %runtime.interfaceMethodInfo], [1 x %runtime.interfaceMethodInfo]* @"Number$methodset", i32 0, i32 0) }
 
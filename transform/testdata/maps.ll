@@ -1,12 +1,12 @@
 target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
 target triple = "armv7m-none-eabi"
 
-%runtime.hashmap = type { %runtime.hashmap*, i8*, i32, i8, i8, i8 }
+%runtime.hashmap = type { %runtime.hashmap*, i8*, i32, i8, i8, i8, i8* }
 
 @answer = constant [6 x i8] c"answer"
 
-; func(keySize, valueSize uint8, sizeHint uintptr) *runtime.hashmap
-declare nonnull %runtime.hashmap* @runtime.hashmapMake(i8, i8, i32)
+; func(keySize, valueSize uint8, sizeHint uintptr, bucketLayout unsafe.Pointer) *runtime.hashmap
+declare nonnull %runtime.hashmap* @runtime.hashmapMake(i8, i8, i32, i8*)
 
 ; func(map[string]int, string, unsafe.Pointer)
 declare void @runtime.hashmapStringSet(%runtime.hashmap* nocapture, i8*, i32, i8* nocapture readonly)
@@ -16,7 +16,7 @@ declare i1 @runtime.hashmapStringGet(%runtime.hashmap* nocapture, i8*, i32, i8*
 
 define void @testUnused() {
     ; create the map
-    %map = call %runtime.hashmap* @runtime.hashmapMake(i8 4, i8 4, i32 0)
+    %map = call %runtime.hashmap* @runtime.hashmapMake(i8 4, i8 4, i32 0, i8* inttoptr (i32 3 to i8*))
     ; create the value to be stored
     %hashmap.value = alloca i32
     store i32 42, i32* %hashmap.value
@@ -30,7 +30,7 @@ define void @testUnused() {
 ; return 42), but isn't at the moment.
 define i32 @testReadonly() {
     ; create the map
-    %map = call %runtime.hashmap* @runtime.hashmapMake(i8 4, i8 4, i32 0)
+    %map = call %runtime.hashmap* @runtime.hashmapMake(i8 4, i8 4, i32 0, i8* inttoptr (i32 3 to i8*))
 
     ; create the value to be stored
     %hashmap.value = alloca i32
@@ -50,6 +50,6 @@ define i32 @testReadonly() {
 }
 
 define %runtime.hashmap* @testUsed() {
-    %1 = call %runtime.hashmap* @runtime.hashmapMake(i8 4, i8 4, i32 0)
+    %1 = call %runtime.hashmap* @runtime.hashmapMake(i8 4, i8 4, i32 0, i8* inttoptr (i32 3 to i8*))
     ret %runtime.hashmap* %1
 }
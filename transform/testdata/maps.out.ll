@@ -1,11 +1,11 @@
 target datalayout = "e-m:e-p:32:32-i64:64-v128:64:128-a:0:32-n32-S64"
 target triple = "armv7m-none-eabi"
 
-%runtime.hashmap = type { %runtime.hashmap*, i8*, i32, i8, i8, i8 }
+%runtime.hashmap = type { %runtime.hashmap*, i8*, i32, i8, i8, i8, i8* }
 
 @answer = constant [6 x i8] c"answer"
 
-declare nonnull %runtime.hashmap* @runtime.hashmapMake(i8, i8, i32)
+declare nonnull %runtime.hashmap* @runtime.hashmapMake(i8, i8, i32, i8*)
 
 declare void @runtime.hashmapStringSet(%runtime.hashmap* nocapture, i8*, i32, i8* nocapture readonly)
 
@@ -16,7 +16,7 @@ define void @testUnused() {
 }
 
 define i32 @testReadonly() {
-  %map = call %runtime.hashmap* @runtime.hashmapMake(i8 4, i8 4, i32 0)
+  %map = call %runtime.hashmap* @runtime.hashmapMake(i8 4, i8 4, i32 0, i8* inttoptr (i32 3 to i8*))
   %hashmap.value = alloca i32
   store i32 42, i32* %hashmap.value
   %hashmap.value.bitcast = bitcast i32* %hashmap.value to i8*
@@ -29,6 +29,6 @@ define i32 @testReadonly() {
 }
 
 define %runtime.hashmap* @testUsed() {
-  %1 = call %runtime.hashmap* @runtime.hashmapMake(i8 4, i8 4, i32 0)
+  %1 = call %runtime.hashmap* @runtime.hashmapMake(i8 4, i8 4, i32 0, i8* inttoptr (i32 3 to i8*))
   ret %runtime.hashmap* %1
 }